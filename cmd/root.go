@@ -10,6 +10,7 @@ import (
 
 	"github.com/Cray-HPE/loftsman/internal"
 	"github.com/Cray-HPE/loftsman/internal/logger"
+	"github.com/Cray-HPE/loftsman/internal/settings"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -30,7 +31,7 @@ var rootCmd = &cobra.Command{
 Define, organize, and ship your Kubernetes workloads with Helm charts easily
 
 Requirements:
-  • helm v3 binary installed on the machine running this tool
+  • helm v3 binary installed on the machine running this tool, unless using --helm-backend sdk
   • A kubeconfig and context available locally with admin access to your cluster
   • A container images registry hosting your container images. If it's private,
     your Kubernetes cluster CRI should be configured to have access to pull from
@@ -79,6 +80,38 @@ Will take a manifest file in, identify the schema version, and validate it again
 	Run:     runManifestValidate,
 }
 
+var manifestLockCmd = &cobra.Command{
+	Use:   internal.LockCmd,
+	Short: "Lock the chart versions of a manifest to the exact versions they resolve to",
+	Long: fmt.Sprintf(`%s
+Resolves the version of each chart in a manifest, an exact version or a semver constraint like ~1.4, >=2.0.0 <3, or
+latest, against its charts source. Writes the exact versions and chart digests to the manifest lock, which ship then
+releases instead of resolving the versions again`, logger.GetHelpLogo()),
+	PreRunE: commonPreRun,
+	Run:     runManifestLock,
+}
+
+var manifestRenderCmd = &cobra.Command{
+	Use:   internal.RenderCmd,
+	Short: "Print a manifest composed with its imports and overlays",
+	Long: fmt.Sprintf(`%s
+Composes a manifest with the charts and chart sources of the manifests in its spec.imports, and merges the overlays in
+its spec.overlays over it, printing the effective manifest that's validated and shipped. ${NAME} variables are left for
+validation and ship to substitute`, logger.GetHelpLogo()),
+	PreRunE: commonPreRun,
+	Run:     runManifestRender,
+}
+
+var manifestMigrateCmd = &cobra.Command{
+	Use:   internal.MigrateCmd,
+	Short: "Migrate a manifest to the latest manifest schema version",
+	Long: fmt.Sprintf(`%s
+Converts a manifest to the latest schema version, manifests/v1, keeping everything in it as it was written. The converted
+manifest is validated and printed, or written over the manifest file with --in-place`, logger.GetHelpLogo()),
+	PreRunE: commonPreRun,
+	Run:     runManifestMigrate,
+}
+
 var shipCmd = &cobra.Command{
 	Use:   internal.ShipCmd,
 	Short: "Ship out your Helm chart workloads to run in your Kubernetes cluster",
@@ -88,12 +121,43 @@ Shipping will prep your cluster and then ship out your Helm charts to install or
 	Run:     runShip,
 }
 
+var diffCmd = &cobra.Command{
+	Use:   internal.DiffCmd,
+	Short: "Show the differences between the live Helm releases in your cluster and your manifest",
+	Long: fmt.Sprintf(`%s
+Renders each chart in the manifest with its values and compares it with the manifest of the live Helm release in the
+cluster, printing a unified diff for each resource that differs. Exits non-zero if any differences are found`, logger.GetHelpLogo()),
+	PreRunE: commonPreRun,
+	Run:     runDiff,
+}
+
+var historyCmd = &cobra.Command{
+	Use:   internal.HistoryCmd,
+	Short: "List the history of past ships recorded in your Kubernetes cluster",
+	Long: fmt.Sprintf(`%s
+Lists every recorded ship of a manifest, or of all manifests, with when it ran, its status, the user and host that ran it,
+and the digest of the manifest shipped`, logger.GetHelpLogo()),
+	PreRunE: commonPreRun,
+	Run:     runHistory,
+}
+
+var logsCmd = &cobra.Command{
+	Use:   internal.LogsCmd,
+	Short: "Print or follow the stored log of the latest ship of a manifest",
+	Long: fmt.Sprintf(`%s
+Prints the log of the latest ship of a manifest as stored in your Kubernetes cluster, so a ship run from another shell or
+machine can be watched as it goes with --follow`, logger.GetHelpLogo()),
+	PreRunE: commonPreRun,
+	Run:     runLogs,
+}
+
 var avastCmd = &cobra.Command{
 	Use:   internal.AvastCmd,
 	Short: "Halt or clear an existing ship command that's stuck",
 	Long: fmt.Sprintf(`%s
-Shipping a given manifest will prevent others from running over it, use this command if you've had a failure that leaves
-a loftsman ship in the "running" state, halting that operation`, logger.GetHelpLogo()),
+Shipping a given manifest will prevent others from running over it, use this command to halt a ship in progress. A
+running ship stops before releasing its next chart, and a ship that was left in the "running" state by a failure is
+cleared`, logger.GetHelpLogo()),
 	PreRunE: commonPreRun,
 	Run:     runAvast,
 }
@@ -121,12 +185,41 @@ func init() {
 		"The name of the Kubernetes config context to use (default is the current-context in kubeconfig used)")
 	rootCmd.PersistentFlags().StringVarP(&loftsman.Settings.HelmExecConfig.Binary, "helm-binary", "", loftsman.Settings.HelmExecConfig.Binary,
 		"The Helm binary to use, helpful in being able to have Helm 3 installed alternatively")
+	rootCmd.PersistentFlags().StringVarP(&loftsman.Settings.GitExecConfig.Binary, "git-binary", "", loftsman.Settings.GitExecConfig.Binary,
+		"The git binary to use to check out charts from git chart sources")
+	rootCmd.PersistentFlags().StringVarP(&loftsman.Settings.SopsExecConfig.Binary, "sops-binary", "", loftsman.Settings.SopsExecConfig.Binary,
+		"The sops binary to use to decrypt chart values encrypted with sops")
+	rootCmd.PersistentFlags().StringVarP(&loftsman.Settings.SopsExecConfig.AgeKeyFile, "age-key-file", "", "",
+		"Path to the age key file to decrypt chart values encrypted with sops with (default is the age key sops finds\n"+
+			"itself, from the SOPS_AGE_KEY or SOPS_AGE_KEY_FILE env vars or its default key file)")
+	rootCmd.PersistentFlags().StringVarP(&loftsman.Settings.HelmBackend, "helm-backend", "", loftsman.Settings.HelmBackend,
+		fmt.Sprintf("How Helm operations are run, one of: %s. exec runs the helm binary, sdk uses the Helm Go SDK built\n"+
+			"into loftsman so that no helm binary is needed", strings.Join(settings.HelmBackends, ", ")))
+	rootCmd.PersistentFlags().StringArrayVarP(&loftsman.Settings.Manifest.Variables, "set-var", "", []string{},
+		"Set a ${NAME} variable of the manifest, as NAME=value, can be repeated. Takes precedence over --vars-file,\n"+
+			"LOFTSMAN_VAR_NAME env vars, and the defaults in the manifest's spec.variables")
+	rootCmd.PersistentFlags().StringVarP(&loftsman.Settings.Manifest.VariablesFile, "vars-file", "", "",
+		"Path to a YAML file of NAME: value variables of the manifest, taking precedence over LOFTSMAN_VAR_NAME env\n"+
+			"vars and the defaults in the manifest's spec.variables")
 	rootCmd.PersistentFlags().StringVarP(&loftsman.Settings.Namespace, "loftsman-namespace", "", loftsman.Settings.Namespace,
 		"The namespace where loftsman records are stored: manifests, logs, etc.")
 
 	manifestCreateCmd.PersistentFlags().StringVarP(&loftsman.Settings.Manifest.ChartNames, "chart-names", "", "",
 		"A comma-delimited list of charts to initialize in the manifest")
 
+	manifestLockCmd.PersistentFlags().StringVarP(&loftsman.Settings.Manifest.Path, manifestPathArgName, "", "",
+		"Local path to the Loftsman YAML manifest file to lock the chart versions of (required)")
+	manifestLockCmd.PersistentFlags().StringVarP(&loftsman.Settings.Manifest.LockPath, "lock-path", "", "",
+		"Local path to write the manifest lock to (default is manifest.lock next to a manifest.yaml manifest)")
+
+	manifestRenderCmd.PersistentFlags().StringVarP(&loftsman.Settings.Manifest.Path, manifestPathArgName, "", "",
+		"Local path to the Loftsman YAML manifest file to render (required)")
+
+	manifestMigrateCmd.PersistentFlags().StringVarP(&loftsman.Settings.Manifest.Path, manifestPathArgName, "", "",
+		"Local path to the Loftsman YAML manifest file to migrate (required)")
+	manifestMigrateCmd.PersistentFlags().BoolVarP(&loftsman.Settings.Manifest.InPlace, "in-place", "", false,
+		"Write the migrated manifest over the manifest file rather than printing it")
+
 	shipCmd.PersistentFlags().StringVarP(&loftsman.Settings.ChartsSource.Repo, "charts-repo", "", "",
 		"DEPRECATED in favor of manifest spec.sources.charts. The root URL for an external helm chart repo to use for\n"+
 			"installing/upgrading charts")
@@ -141,16 +234,70 @@ func init() {
 	shipCmd.PersistentFlags().StringVarP(&loftsman.Settings.Manifest.Path, manifestPathArgName, "", "",
 		"Local path to the Loftsman YAML manifest file, instruction on what charts to install and how to install them.\n"+
 			"See loftsman manifest --help for more info (required)")
+	shipCmd.PersistentFlags().BoolVarP(&loftsman.Settings.Ship.DryRun, "dry-run", "", false,
+		"Plan the ship without making any changes to the cluster, showing whether each chart would be installed,\n"+
+			"upgraded, reinstalled over a failed first release, or left unchanged")
+	shipCmd.PersistentFlags().IntVarP(&loftsman.Settings.Ship.MaxConcurrency, "max-concurrency", "", loftsman.Settings.Ship.MaxConcurrency,
+		"The max number of charts to release at the same time. Charts are released once all of the charts they depend on\n"+
+			"via dependsOn have released, the default of 1 releases charts one at a time in the manifest order")
+	shipCmd.PersistentFlags().BoolVarP(&loftsman.Settings.Ship.Resume, "resume", "", false,
+		"Resume the last ship of the manifest, skipping the charts that already released successfully in it with the same\n"+
+			"version and values")
+	shipCmd.PersistentFlags().BoolVarP(&loftsman.Settings.Ship.Prune, "prune", "", false,
+		"Uninstall the releases that were shipped with the previous ship of the manifest but are no longer in it, same as\n"+
+			"spec.prune in the manifest")
+	shipCmd.PersistentFlags().BoolVarP(&loftsman.Settings.Ship.ConfirmPrune, "confirm-prune", "", false,
+		"Don't ask for confirmation before pruning releases")
+	shipCmd.PersistentFlags().StringVarP(&loftsman.Settings.Manifest.LockPath, "lock-path", "", "",
+		"Local path to the manifest lock to ship the exact chart versions of, if it exists (default is manifest.lock\n"+
+			"next to a manifest.yaml manifest)")
+	shipCmd.PersistentFlags().BoolVarP(&loftsman.Settings.Ship.Update, "update", "", false,
+		"Resolve the chart versions of the manifest against the charts sources, ignoring the manifest lock")
+	shipCmd.PersistentFlags().StringSliceVarP(&loftsman.Settings.Ship.Only, "only", "", []string{},
+		"Only ship the charts with these names, as name[,name]. A ship of only some of the manifest's charts is recorded\n"+
+			"as partial in the ship history, and never prunes releases")
+	shipCmd.PersistentFlags().StringSliceVarP(&loftsman.Settings.Ship.Skip, "skip", "", []string{},
+		"Don't ship the charts with these names, as name[,name]")
+	shipCmd.PersistentFlags().StringVarP(&loftsman.Settings.Ship.Selector, "selector", "", "",
+		"Only ship the charts with all of these labels in spec.charts[].labels, as key=value[,key=value]")
+	shipCmd.PersistentFlags().IntVarP(&loftsman.Settings.Ship.HistoryLimit, "history-limit", "", loftsman.Settings.Ship.HistoryLimit,
+		"The number of the latest ships to keep in the ship history of the manifest, older ships are removed from it as\n"+
+			"this ship is recorded")
+
+	diffCmd.PersistentFlags().StringVarP(&loftsman.Settings.Manifest.Path, manifestPathArgName, "", "",
+		"Local path to the Loftsman YAML manifest file to compare with the live releases in the cluster (required)")
+
+	historyCmd.PersistentFlags().StringVarP(&loftsman.Settings.Manifest.Name, "manifest-name", "", "",
+		"The name of the manifest to list the ship history of (default is the history of all manifests)")
+	historyCmd.PersistentFlags().StringVarP(&loftsman.Settings.History.Output, "output", "o", loftsman.Settings.History.Output,
+		fmt.Sprintf("The output format of the history, one of: %s", strings.Join(settings.HistoryOutputs, ", ")))
+
+	logsCmd.PersistentFlags().StringVarP(&loftsman.Settings.Manifest.Name, "manifest-name", "", "",
+		"The name of the manifest to print the ship log of (required)")
+	logsCmd.PersistentFlags().BoolVarP(&loftsman.Settings.Logs.Follow, "follow", "f", false,
+		"Keep printing the log as it's stored until the ship finishes")
+	logsCmd.PersistentFlags().StringVarP(&loftsman.Settings.Logs.ShipID, "ship-id", "", "",
+		"Print the log of this ship rather than of the latest, as listed by loftsman history")
+	logsCmd.PersistentFlags().StringVarP(&loftsman.Settings.Logs.Chart, "chart", "", "",
+		"Only print the log lines of this chart")
+	logsCmd.PersistentFlags().StringVarP(&loftsman.Settings.Logs.Level, "level", "", "",
+		"Only print the log lines of this level or above, e.g. info, warn, error")
 
 	avastCmd.PersistentFlags().StringVarP(&loftsman.Settings.Manifest.Path, manifestPathArgName, "", "",
 		"Local path to the Loftsman YAML mainfest file, by name it will determine the existing loftsman ship to halt\n"+
 			"(required if not using manifest-name)")
 	avastCmd.PersistentFlags().StringVarP(&loftsman.Settings.Manifest.Name, "manifest-name", "", "",
 		fmt.Sprintf("The name of the manifest ship operation you want to halt (required if not using %s)", manifestPathArgName))
-
-	manifestCmd.AddCommand(manifestCreateCmd, manifestValidateCmd)
+	avastCmd.PersistentFlags().BoolVarP(&loftsman.Settings.Avast.Yes, "yes", "y", false,
+		"Don't ask for confirmation before halting the ship")
+	avastCmd.PersistentFlags().BoolVarP(&loftsman.Settings.Avast.Yes, "force", "", false,
+		"Same as --yes")
+	avastCmd.PersistentFlags().StringVarP(&loftsman.Settings.Avast.Reason, "reason", "", "",
+		"Why the ship is being halted, stored on the ship record")
+
+	manifestCmd.AddCommand(manifestCreateCmd, manifestValidateCmd, manifestLockCmd, manifestRenderCmd, manifestMigrateCmd)
 	helmCmd.Flags().SetInterspersed(false)
-	rootCmd.AddCommand(manifestCmd, shipCmd, avastCmd, helmCmd)
+	rootCmd.AddCommand(manifestCmd, shipCmd, diffCmd, historyCmd, logsCmd, avastCmd, helmCmd)
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -158,7 +305,7 @@ func init() {
 func Execute() {
 	defer cleanup()
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		exitWithError()
 	}
 }
 
@@ -170,10 +317,13 @@ func initConfig() {
 }
 
 func cleanup() {
-	loftsman.Settings.JSONLog.File.Close()
-	if err := os.RemoveAll(loftsman.Settings.TempDirectory); err != nil {
-		fmt.Println(fmt.Sprintf("Couldn't remove temp directory: %s", loftsman.Settings.TempDirectory))
-	}
+	loftsman.Cleanup()
+}
+
+// exitWithError will clean up and exit with an error status, since os.Exit skips the deferred cleanup in Execute
+func exitWithError() {
+	cleanup()
+	os.Exit(1)
 }
 
 func commonPreRun(cmd *cobra.Command, args []string) error {
@@ -206,25 +356,61 @@ func commonPreRun(cmd *cobra.Command, args []string) error {
 
 func runManifestCreate(cmd *cobra.Command, args []string) {
 	if err := loftsman.ManifestCreate(); err != nil {
-		os.Exit(1)
+		exitWithError()
 	}
 }
 
 func runManifestValidate(cmd *cobra.Command, args []string) {
 	if err := loftsman.ManifestValidate(args...); err != nil {
-		os.Exit(1)
+		exitWithError()
+	}
+}
+
+func runManifestLock(cmd *cobra.Command, args []string) {
+	if err := loftsman.ManifestLock(); err != nil {
+		exitWithError()
+	}
+}
+
+func runManifestRender(cmd *cobra.Command, args []string) {
+	if err := loftsman.ManifestRender(); err != nil {
+		exitWithError()
+	}
+}
+
+func runManifestMigrate(cmd *cobra.Command, args []string) {
+	if err := loftsman.ManifestMigrate(); err != nil {
+		exitWithError()
 	}
 }
 
 func runShip(cmd *cobra.Command, args []string) {
 	if err := loftsman.Ship(); err != nil {
-		os.Exit(1)
+		exitWithError()
+	}
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+	if err := loftsman.Diff(); err != nil {
+		exitWithError()
+	}
+}
+
+func runHistory(cmd *cobra.Command, args []string) {
+	if err := loftsman.History(); err != nil {
+		exitWithError()
+	}
+}
+
+func runLogs(cmd *cobra.Command, args []string) {
+	if err := loftsman.Logs(); err != nil {
+		exitWithError()
 	}
 }
 
 func runAvast(cmd *cobra.Command, args []string) {
 	if err := loftsman.Avast(); err != nil {
-		os.Exit(1)
+		exitWithError()
 	}
 }
 
@@ -0,0 +1,40 @@
+// Package git is for our git command object and operations, used to get charts from git repos
+package git
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Cray-HPE/go-lib/shell"
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+)
+
+// Git is our object for running git commands, implements internal/interfaces/git.go
+type Git struct {
+	ExecConfig *interfaces.GitExecConfig
+}
+
+// Initialize will set our instance up with necessary config/settings. The git binary isn't checked for here since it's
+// only needed by manifests with git chart sources
+func (g *Git) Initialize(execConfig *interfaces.GitExecConfig) error {
+	g.ExecConfig = execConfig
+	return nil
+}
+
+// Exec will run a git cli command/sub-command
+func (g *Git) Exec(subCommand string) (string, error) {
+	return g.ExecConfig.Shell.Exec(fmt.Sprintf("%s %s", g.ExecConfig.Binary, strings.TrimSpace(subCommand)),
+		shell.ExecOptions{Silent: true, TrimOutput: true})
+}
+
+// Checkout will clone a git repo, from a URL like file:///path/to/repo.git or a local path, to a destination directory
+// and check out a ref there, a branch, tag or commit
+func (g *Git) Checkout(location string, ref string, destination string) error {
+	if _, err := g.Exec(fmt.Sprintf("clone --quiet --no-checkout %s %s", location, destination)); err != nil {
+		return fmt.Errorf("Error cloning git repo %s: %s", location, err)
+	}
+	if _, err := g.Exec(fmt.Sprintf("-C %s checkout --quiet %s", destination, ref)); err != nil {
+		return fmt.Errorf("Error checking out ref %s of git repo %s: %s", ref, location, err)
+	}
+	return nil
+}
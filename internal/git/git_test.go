@@ -0,0 +1,76 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/go-lib/shell"
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+)
+
+// getTestRepo will create a bare git repo with a v1 tag and a main branch one commit past it, returning its file:// URL
+func getTestRepo(t *testing.T) string {
+	directory := t.TempDir()
+	work := filepath.Join(directory, "work")
+	bare := filepath.Join(directory, "repo.git")
+	run := func(args ...string) {
+		command := fmt.Sprintf("git -c user.name=test -c user.email=test@example.com %s", strings.Join(args, " "))
+		if _, err := (&shell.Shell{}).Exec(command, shell.ExecOptions{Silent: true}); err != nil {
+			t.Fatalf("Error setting up test git repo with %s: %s", command, err)
+		}
+	}
+	run("init", "--quiet", "--initial-branch=main", work)
+	if err := ioutil.WriteFile(filepath.Join(work, "version"), []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("-C", work, "add", "version")
+	run("-C", work, "commit", "--quiet", "-m", "v1")
+	run("-C", work, "tag", "v1")
+	if err := ioutil.WriteFile(filepath.Join(work, "version"), []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("-C", work, "commit", "--quiet", "-am", "v2")
+	run("clone", "--quiet", "--bare", work, bare)
+	return fmt.Sprintf("file://%s", bare)
+}
+
+func getTestGit() *Git {
+	g := &Git{}
+	g.Initialize(&interfaces.GitExecConfig{Shell: &shell.Shell{}, Binary: "git"})
+	return g
+}
+
+func TestCheckout(t *testing.T) {
+	repo := getTestRepo(t)
+	for ref, expected := range map[string]string{"main": "v2", "v1": "v1"} {
+		destination := filepath.Join(t.TempDir(), "checkout")
+		if err := getTestGit().Checkout(repo, ref, destination); err != nil {
+			t.Errorf("Got unexpected error from git.TestCheckout(): %s", err)
+			continue
+		}
+		version, err := ioutil.ReadFile(filepath.Join(destination, "version"))
+		if err != nil {
+			t.Errorf("Got unexpected error from git.TestCheckout(): %s", err)
+		} else if string(version) != expected {
+			t.Errorf("Didn't get expected checkout of ref %s from git.TestCheckout(), got version %s", ref, version)
+		}
+	}
+}
+
+func TestCheckoutUnknownRef(t *testing.T) {
+	err := getTestGit().Checkout(getTestRepo(t), "not-a-ref", filepath.Join(t.TempDir(), "checkout"))
+	if err == nil || !strings.Contains(err.Error(), "Error checking out ref not-a-ref") {
+		t.Errorf("Didn't get expected error from git.TestCheckoutUnknownRef(), instead got: %s", err)
+	}
+}
+
+func TestCheckoutUnknownRepo(t *testing.T) {
+	err := getTestGit().Checkout(filepath.Join(os.TempDir(), "loftsman-tests-not-a-repo.git"), "main", filepath.Join(t.TempDir(), "checkout"))
+	if err == nil || !strings.Contains(err.Error(), "Error cloning git repo") {
+		t.Errorf("Didn't get expected error from git.TestCheckoutUnknownRepo(), instead got: %s", err)
+	}
+}
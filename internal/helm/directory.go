@@ -0,0 +1,139 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// chartsDirectoryIndex is the packaged charts in a directory, indexed by the name and version in their Chart.yaml
+type chartsDirectoryIndex struct {
+	charts   map[string][]*interfaces.HelmAvailableChartVersion
+	warnings []string
+}
+
+// chartsDirectoryPackage is a packaged chart found in a directory
+type chartsDirectoryPackage struct {
+	fileName string
+	digest   string
+}
+
+// chartMetadata is the part of a chart's Chart.yaml we index by
+type chartMetadata struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+}
+
+var (
+	// indexes are cached for the run, packaged charts aren't expected to change under us mid-ship
+	chartsDirectoryIndexes      = make(map[string]*chartsDirectoryIndex)
+	chartsDirectoryIndexesMutex sync.Mutex
+)
+
+// getChartsDirectoryIndex will return the index of the packaged charts in a directory, indexing it the first time
+func getChartsDirectoryIndex(directory string) (*chartsDirectoryIndex, error) {
+	chartsDirectoryIndexesMutex.Lock()
+	defer chartsDirectoryIndexesMutex.Unlock()
+	directory = filepath.Clean(directory)
+	if index, ok := chartsDirectoryIndexes[directory]; ok {
+		return index, nil
+	}
+	index, err := newChartsDirectoryIndex(directory)
+	if err != nil {
+		return nil, err
+	}
+	chartsDirectoryIndexes[directory] = index
+	return index, nil
+}
+
+// newChartsDirectoryIndex will read the Chart.yaml of each packaged chart in a directory to index it. Packages that
+// aren't readable charts are skipped, and packages of the same chart version are noted in the index's warnings, the
+// first package of the version by file name being the one used
+func newChartsDirectoryIndex(directory string) (*chartsDirectoryIndex, error) {
+	index := &chartsDirectoryIndex{charts: make(map[string][]*interfaces.HelmAvailableChartVersion)}
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	indexed := make(map[string]*chartsDirectoryPackage)
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".tgz") {
+			continue
+		}
+		chartPath := filepath.Join(directory, file.Name())
+		metadata, digest, err := readChartPackage(chartPath)
+		if err != nil {
+			index.warnings = append(index.warnings, fmt.Sprintf("Skipping %s, it isn't a readable packaged chart: %s", chartPath, err))
+			continue
+		}
+		key := fmt.Sprintf("%s-%s", metadata.Name, metadata.Version)
+		if existing, ok := indexed[key]; ok {
+			if existing.digest == digest {
+				index.warnings = append(index.warnings, fmt.Sprintf("Duplicate packages of chart %s v%s in %s: %s and %s",
+					metadata.Name, metadata.Version, directory, existing.fileName, file.Name()))
+			} else {
+				index.warnings = append(index.warnings, fmt.Sprintf("Conflicting packages of chart %s v%s in %s with different contents: %s and %s, using %s",
+					metadata.Name, metadata.Version, directory, existing.fileName, file.Name(), existing.fileName))
+			}
+			continue
+		}
+		indexed[key] = &chartsDirectoryPackage{fileName: file.Name(), digest: digest}
+		index.charts[metadata.Name] = append(index.charts[metadata.Name], &interfaces.HelmAvailableChartVersion{
+			Path:    chartPath,
+			Version: metadata.Version,
+			Digest:  digest,
+		})
+	}
+	return index, nil
+}
+
+// readChartPackage will read the Chart.yaml of a packaged chart, returning it along with the package's digest
+func readChartPackage(chartPath string) (*chartMetadata, string, error) {
+	chartBytes, err := ioutil.ReadFile(chartPath)
+	if err != nil {
+		return nil, "", err
+	}
+	gzipReader, err := gzip.NewReader(bytes.NewReader(chartBytes))
+	if err != nil {
+		return nil, "", err
+	}
+	defer gzipReader.Close()
+	var metadata *chartMetadata
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		// the Chart.yaml of the chart itself is at <chart name>/Chart.yaml, any deeper are those of its dependencies
+		parts := strings.Split(header.Name, "/")
+		if len(parts) != 2 || parts[1] != "Chart.yaml" {
+			continue
+		}
+		chartYAMLBytes, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return nil, "", err
+		}
+		if err = yaml.Unmarshal(chartYAMLBytes, &metadata); err != nil {
+			return nil, "", fmt.Errorf("Error parsing %s: %s", header.Name, err)
+		}
+		break
+	}
+	if metadata == nil || metadata.Name == "" || metadata.Version == "" {
+		return nil, "", fmt.Errorf("No Chart.yaml with a name and version found")
+	}
+	return metadata, fmt.Sprintf("sha256:%x", sha256.Sum256(chartBytes)), nil
+}
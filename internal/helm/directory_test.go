@@ -0,0 +1,131 @@
+package helm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+)
+
+// writeTestChartPackage will write a packaged chart with a Chart.yaml, the Chart.yaml of a dependency, and a values.yaml
+// with the given content
+func writeTestChartPackage(t *testing.T, directory string, fileName string, name string, version string, values string) {
+	file, err := os.Create(filepath.Join(directory, fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	// a dependency's Chart.yaml comes first, it shouldn't be mistaken for the chart's own
+	for _, entry := range [][]string{
+		{fmt.Sprintf("%s/charts/common/Chart.yaml", name), "apiVersion: v2\nname: common\nversion: 9.9.9\n"},
+		{fmt.Sprintf("%s/Chart.yaml", name), fmt.Sprintf("apiVersion: v2\nname: %s\nversion: %s\n", name, version)},
+		{fmt.Sprintf("%s/values.yaml", name), values},
+	} {
+		if err = tarWriter.WriteHeader(&tar.Header{Name: entry[0], Mode: 0644, Size: int64(len(entry[1]))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = tarWriter.Write([]byte(entry[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetChartsDirectoryIndex(t *testing.T) {
+	directory := t.TempDir()
+	writeTestChartPackage(t, directory, "cray-1.0.0.tgz", "cray", "1.0.0", "")
+	writeTestChartPackage(t, directory, "cray-service-1.0.0.tgz", "cray-service", "1.0.0", "")
+	// the file name doesn't matter, only the Chart.yaml
+	writeTestChartPackage(t, directory, "renamed.tgz", "cray", "1.1.0", "")
+	index, err := getChartsDirectoryIndex(directory)
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestGetChartsDirectoryIndex(): %s", err)
+		return
+	}
+	cray := index.charts["cray"]
+	if len(cray) != 2 || cray[0].Version != "1.0.0" || cray[0].Path != filepath.Join(directory, "cray-1.0.0.tgz") ||
+		cray[1].Version != "1.1.0" || cray[1].Path != filepath.Join(directory, "renamed.tgz") {
+		t.Errorf("Didn't get expected cray chart versions from helm.TestGetChartsDirectoryIndex(), got: %v", cray)
+	}
+	if crayService := index.charts["cray-service"]; len(crayService) != 1 || crayService[0].Version != "1.0.0" {
+		t.Errorf("Didn't get expected cray-service chart versions from helm.TestGetChartsDirectoryIndex(), got: %v", crayService)
+	}
+	if len(index.warnings) != 0 {
+		t.Errorf("Got unexpected warnings from helm.TestGetChartsDirectoryIndex(): %v", index.warnings)
+	}
+}
+
+func TestGetChartsDirectoryIndexCached(t *testing.T) {
+	directory := t.TempDir()
+	writeTestChartPackage(t, directory, "cray-1.0.0.tgz", "cray", "1.0.0", "")
+	if _, err := getChartsDirectoryIndex(directory); err != nil {
+		t.Errorf("Got unexpected error from helm.TestGetChartsDirectoryIndexCached(): %s", err)
+		return
+	}
+	writeTestChartPackage(t, directory, "cray-1.1.0.tgz", "cray", "1.1.0", "")
+	index, err := getChartsDirectoryIndex(directory)
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestGetChartsDirectoryIndexCached(): %s", err)
+		return
+	}
+	if len(index.charts["cray"]) != 1 {
+		t.Errorf("Didn't get expected cached index from helm.TestGetChartsDirectoryIndexCached(), got: %v", index.charts["cray"])
+	}
+}
+
+func TestValidateChartsDirectory(t *testing.T) {
+	directory := t.TempDir()
+	writeTestChartPackage(t, directory, "cray-1.0.0.tgz", "cray", "1.0.0", "")
+	writeTestChartPackage(t, directory, "cray-1.0.0-copy.tgz", "cray", "1.0.0", "")
+	writeTestChartPackage(t, directory, "cray-2.0.0.tgz", "cray", "2.0.0", "replicas: 1")
+	writeTestChartPackage(t, directory, "cray-2.0.0-rebuilt.tgz", "cray", "2.0.0", "replicas: 3")
+	if err := ioutil.WriteFile(filepath.Join(directory, "not-a-chart.tgz"), []byte("not a chart"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []interface {
+		ValidateChartsDirectory(directory string) ([]string, error)
+	}{&Helm{}, &SDK{}} {
+		warnings, err := h.ValidateChartsDirectory(directory)
+		if err != nil {
+			t.Errorf("Got unexpected error from helm.TestValidateChartsDirectory(): %s", err)
+			continue
+		}
+		all := strings.Join(warnings, "\n")
+		if len(warnings) != 3 ||
+			!strings.Contains(all, "Duplicate packages of chart cray v1.0.0") ||
+			!strings.Contains(all, "Conflicting packages of chart cray v2.0.0") ||
+			!strings.Contains(all, "not-a-chart.tgz, it isn't a readable packaged chart") {
+			t.Errorf("Didn't get expected warnings from helm.TestValidateChartsDirectory(), got: %v", warnings)
+		}
+	}
+	available, err := (&Helm{ChartsSource: &interfaces.HelmChartsSource{Path: directory}}).GetAvailableChartVersions("cray")
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.GetAvailableChartVersions() in helm.TestValidateChartsDirectory(): %s", err)
+		return
+	}
+	// the first package of a version by file name is the one used
+	if len(available) != 2 || available[0].Path != filepath.Join(directory, "cray-1.0.0-copy.tgz") ||
+		available[1].Path != filepath.Join(directory, "cray-2.0.0-rebuilt.tgz") {
+		t.Errorf("Didn't get expected available list from helm.TestValidateChartsDirectory(), got: %v", available)
+	}
+}
+
+func TestValidateChartsDirectoryNotFound(t *testing.T) {
+	_, err := (&Helm{}).ValidateChartsDirectory(filepath.Join(t.TempDir(), "not-found"))
+	if err == nil {
+		t.Errorf("Didn't get expected error from helm.TestValidateChartsDirectoryNotFound()")
+	}
+}
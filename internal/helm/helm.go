@@ -2,13 +2,14 @@
 package helm
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
-	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/Cray-HPE/go-lib/shell"
@@ -22,6 +23,13 @@ type Helm struct {
 	ChartsSource *interfaces.HelmChartsSource
 }
 
+var (
+	upgradeOutputRevision = regexp.MustCompile(`(?m)^REVISION: (\d+)$`)
+	upgradeOutputStatus   = regexp.MustCompile(`(?m)^STATUS: (.+)$`)
+	packageOutputPath     = regexp.MustCompile(`(?m)saved it to: (.+)$`)
+	shellSafeArg          = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+)
+
 // ChartRepoIndexYAML is the root index of a chart repo
 type ChartRepoIndexYAML struct {
 	Entries map[string][]*ChartRepoEntryVersion `yaml:"entries"`
@@ -31,6 +39,7 @@ type ChartRepoIndexYAML struct {
 type ChartRepoEntryVersion struct {
 	URLs    []string `yaml:"urls"`
 	Version string   `yaml:"version"`
+	Digest  string   `yaml:"digest"`
 }
 
 // Initialize will set our instance up with necessary config/settings and run some initial validation as well
@@ -41,10 +50,8 @@ func (h *Helm) Initialize(execConfig *interfaces.HelmExecConfig, chartsSource *i
 	if err != nil {
 		return err
 	}
-	if h.ChartsSource.Repo != "" {
-		if _, err = url.Parse(h.ChartsSource.Repo); err != nil {
-			return fmt.Errorf("Charts repo url is invalid: %s", err)
-		}
+	if err = validateChartsSource(h.ChartsSource); err != nil {
+		return err
 	}
 	if !strings.Contains(versionOutput, `v3`) {
 		return fmt.Errorf("Helm v3 client binary is required to run the Loftsman tool, found: %s", versionOutput)
@@ -52,6 +59,30 @@ func (h *Helm) Initialize(execConfig *interfaces.HelmExecConfig, chartsSource *i
 	return nil
 }
 
+// ForChartsSource will return a new helm object for the charts of a charts source, with the same exec config. This one
+// is left as it is, so that charts from different sources can be resolved and released in parallel
+func (h *Helm) ForChartsSource(chartsSource *interfaces.HelmChartsSource) (interfaces.Helm, error) {
+	if err := validateChartsSource(chartsSource); err != nil {
+		return nil, err
+	}
+	return &Helm{ExecConfig: h.ExecConfig, ChartsSource: chartsSource}, nil
+}
+
+// validateChartsSource will check that the locations of a charts source are valid
+func validateChartsSource(chartsSource *interfaces.HelmChartsSource) error {
+	if chartsSource.Repo != "" {
+		if _, err := url.Parse(chartsSource.Repo); err != nil {
+			return fmt.Errorf("Charts repo url is invalid: %s", err)
+		}
+	}
+	if chartsSource.OCI != "" {
+		if _, err := parseOCIReference(chartsSource.OCI); err != nil {
+			return fmt.Errorf("Charts OCI registry location is invalid: %s", err)
+		}
+	}
+	return nil
+}
+
 // Exec will run a helm cli command/sub-command
 func (h *Helm) Exec(subCommand string) (string, error) {
 	command := h.ExecConfig.Binary
@@ -65,35 +96,82 @@ func (h *Helm) Exec(subCommand string) (string, error) {
 		shell.ExecOptions{Silent: true, TrimOutput: true})
 }
 
+// execArgs will run a helm cli sub-command from its args, which can hold values from the manifest and settings. With a
+// shell that implements interfaces.HelmArgsShell the args are passed to helm as they are, otherwise each one is quoted
+// for the shell so that it's still a single arg however many spaces or quotes it has
+func (h *Helm) execArgs(subCommandArgs ...string) (string, error) {
+	args := h.getArgs(subCommandArgs...)
+	options := shell.ExecOptions{Silent: true, TrimOutput: true}
+	if argsShell, ok := h.ExecConfig.Shell.(interfaces.HelmArgsShell); ok {
+		return argsShell.ExecArgs(args, options)
+	}
+	quotedArgs := make([]string, len(args))
+	for i, arg := range args {
+		quotedArgs[i] = quoteArg(arg)
+	}
+	return h.ExecConfig.Shell.Exec(strings.Join(quotedArgs, " "), options)
+}
+
+// execArgsStdin will run a helm cli sub-command from its args the same as execArgs, with input for it on stdin. This
+// needs a shell that implements interfaces.HelmStdinShell, there's no way to pass the input otherwise
+func (h *Helm) execArgsStdin(stdin string, subCommandArgs ...string) (string, error) {
+	stdinShell, ok := h.ExecConfig.Shell.(interfaces.HelmStdinShell)
+	if !ok {
+		return "", errors.New("The helm shell can't pass input to helm on stdin")
+	}
+	return stdinShell.ExecArgsStdin(h.getArgs(subCommandArgs...), stdin, shell.ExecOptions{Silent: true, TrimOutput: true})
+}
+
+// getArgs returns the args to run a helm cli sub-command with, including the binary and global flags
+func (h *Helm) getArgs(subCommandArgs ...string) []string {
+	args := []string{h.ExecConfig.Binary}
+	if h.ExecConfig.KubeconfigPath != "" {
+		args = append(args, "--kubeconfig", h.ExecConfig.KubeconfigPath)
+	}
+	if h.ExecConfig.KubeContext != "" {
+		args = append(args, "--kube-context", h.ExecConfig.KubeContext)
+	}
+	return append(args, subCommandArgs...)
+}
+
 // GetAvailableChartVersions will return a list of available versions for a given chart according to our charts source
 func (h *Helm) GetAvailableChartVersions(chartName string) ([]*interfaces.HelmAvailableChartVersion, error) {
+	return getAvailableChartVersions(h.ChartsSource, chartName)
+}
+
+// ValidateChartsDirectory will index the packaged charts in a directory, returning warnings about packages that were
+// skipped or that duplicate or conflict with another package of the same chart version
+func (h *Helm) ValidateChartsDirectory(directory string) ([]string, error) {
+	index, err := getChartsDirectoryIndex(directory)
+	if err != nil {
+		return nil, err
+	}
+	return index.warnings, nil
+}
+
+// getAvailableChartVersions will return a list of available versions for a given chart in a charts source, from the
+// packaged charts in its directory, the index.yaml of its repo, or the tags of its OCI registry
+func getAvailableChartVersions(chartsSource *interfaces.HelmChartsSource, chartName string) ([]*interfaces.HelmAvailableChartVersion, error) {
 	var available []*interfaces.HelmAvailableChartVersion
-	if h.ChartsSource.Path != "" {
-		localChartFiles, err := ioutil.ReadDir(h.ChartsSource.Path)
+	if chartsSource.OCI != "" {
+		return getOCIChartVersions(chartsSource, chartName)
+	}
+	if chartsSource.Path != "" {
+		index, err := getChartsDirectoryIndex(chartsSource.Path)
 		if err != nil {
 			return available, err
 		}
-		for _, localChartFile := range localChartFiles {
-			matched, _ := regexp.MatchString(fmt.Sprintf("^%s-", chartName), localChartFile.Name())
-			if matched {
-				version := strings.ReplaceAll(localChartFile.Name(), fmt.Sprintf("%s-", chartName), "")
-				version = strings.ReplaceAll(version, ".tgz", "")
-				available = append(available, &interfaces.HelmAvailableChartVersion{
-					Path:    filepath.Join(h.ChartsSource.Path, localChartFile.Name()),
-					Version: version,
-				})
-			}
-		}
-	} else if h.ChartsSource.Repo != "" {
-		indexURL, _ := url.Parse(h.ChartsSource.Repo)
+		available = append(available, index.charts[chartName]...)
+	} else if chartsSource.Repo != "" {
+		indexURL, _ := url.Parse(chartsSource.Repo)
 		indexURL.Path = path.Join(indexURL.Path, "index.yaml")
 		httpClient := &http.Client{}
 		req, err := http.NewRequest("GET", indexURL.String(), nil)
 		if err != nil {
 			return available, err
 		}
-		if h.ChartsSource.RepoUsername != "" && h.ChartsSource.RepoPassword != "" {
-			req.SetBasicAuth(h.ChartsSource.RepoUsername, h.ChartsSource.RepoPassword)
+		if chartsSource.RepoUsername != "" && chartsSource.RepoPassword != "" {
+			req.SetBasicAuth(chartsSource.RepoUsername, chartsSource.RepoPassword)
 		}
 		resp, err := httpClient.Do(req)
 		if err != nil {
@@ -116,14 +194,18 @@ func (h *Helm) GetAvailableChartVersions(chartName string) ([]*interfaces.HelmAv
 						return available, err
 					}
 					if !isFullURL {
-						fullURL, _ := url.Parse(h.ChartsSource.Repo)
+						fullURL, _ := url.Parse(chartsSource.Repo)
 						fullURL.Path = path.Join(fullURL.Path, version.URLs[0])
 						urlPath = fullURL.String()
 					}
-					available = append(available, &interfaces.HelmAvailableChartVersion{
+					availableVersion := &interfaces.HelmAvailableChartVersion{
 						Path:    urlPath,
 						Version: version.Version,
-					})
+					}
+					if version.Digest != "" {
+						availableVersion.Digest = fmt.Sprintf("sha256:%s", version.Digest)
+					}
+					available = append(available, availableVersion)
 				}
 				break
 			}
@@ -132,10 +214,14 @@ func (h *Helm) GetAvailableChartVersions(chartName string) ([]*interfaces.HelmAv
 	return available, nil
 }
 
-// GetReleaseStatus attempts to retrieve the status of a chart release
+// GetReleaseStatus attempts to retrieve the status of a chart release, the error is interfaces.ErrReleaseNotFound if the
+// release isn't installed
 func (h *Helm) GetReleaseStatus(chartName string, chartNamespace string) (*interfaces.HelmReleaseStatus, error) {
-	output, err := h.Exec(fmt.Sprintf("status %s --namespace %s --output yaml", chartName, chartNamespace))
+	output, err := h.execArgs("status", chartName, "--namespace", chartNamespace, "--output", "yaml")
 	rs := &interfaces.HelmReleaseStatus{}
+	if err != nil && isReleaseNotFound(err) {
+		return rs, fmt.Errorf("%w: %s in namespace %s", interfaces.ErrReleaseNotFound, chartName, chartNamespace)
+	}
 	if err != nil {
 		return rs, err
 	}
@@ -145,7 +231,192 @@ func (h *Helm) GetReleaseStatus(chartName string, chartNamespace string) (*inter
 	return rs, nil
 }
 
+// isReleaseNotFound will determine if an error from helm is of a release that isn't installed, as opposed to any other
+// error that mentions something not being found, like the namespace or the cluster
+func isReleaseNotFound(err error) bool {
+	return strings.Contains(err.Error(), "release: not found")
+}
+
 // GetExecConfig returns the existing ExecConfig
 func (h *Helm) GetExecConfig() *interfaces.HelmExecConfig {
 	return h.ExecConfig
 }
+
+// AddRepo will add a credentialed chart repo to Helm, so that its charts can be released as <repo name>/<chart name>
+func (h *Helm) AddRepo(chartsSource *interfaces.HelmChartsSource) error {
+	args := []string{"repo", "add", chartsSource.RepoName, chartsSource.Repo, "--username", chartsSource.RepoUsername}
+	if chartsSource.RepoPassword == "" {
+		_, err := h.execArgs(args...)
+		return err
+	}
+	// the password is passed on stdin rather than as an arg, where anyone on the host could see it
+	_, err := h.execArgsStdin(chartsSource.RepoPassword, append(args, "--password-stdin")...)
+	return err
+}
+
+// RemoveRepo will remove a chart repo added with AddRepo
+func (h *Helm) RemoveRepo(repoName string) error {
+	_, err := h.execArgs("repo", "rm", repoName)
+	return err
+}
+
+// Upgrade will install a release, or upgrade it if it's already installed
+func (h *Helm) Upgrade(options *interfaces.HelmReleaseOptions) (*interfaces.HelmReleaseResult, error) {
+	args := append([]string{"upgrade", "--install"}, getReleaseArgs(options)...)
+	output, err := h.execArgs(append(args, getUpgradeArgs(options)...)...)
+	if err != nil {
+		return nil, err
+	}
+	result := &interfaces.HelmReleaseResult{
+		ReleaseName: options.ReleaseName,
+		Namespace:   options.Namespace,
+		Output:      output,
+	}
+	if matches := upgradeOutputRevision.FindStringSubmatch(output); matches != nil {
+		result.Revision, _ = strconv.Atoi(matches[1])
+	}
+	if matches := upgradeOutputStatus.FindStringSubmatch(output); matches != nil {
+		result.Status = strings.TrimSpace(matches[1])
+	}
+	return result, nil
+}
+
+// Template will render the resources of a release the way Upgrade would install them, without hooks
+func (h *Helm) Template(options *interfaces.HelmReleaseOptions) (string, error) {
+	args := append([]string{"template"}, getReleaseArgs(options)...)
+	return h.execArgs(append(args, "--no-hooks")...)
+}
+
+// GetManifest will get the rendered resources of an installed release, or an empty manifest if it's not installed
+func (h *Helm) GetManifest(releaseName string, namespace string) (string, error) {
+	output, err := h.execArgs("get", "manifest", releaseName, "--namespace", namespace)
+	if err != nil {
+		if isReleaseNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return output, nil
+}
+
+// Uninstall will uninstall a release, optionally without running its hooks, the error is interfaces.ErrReleaseNotFound
+// if the release isn't installed
+func (h *Helm) Uninstall(releaseName string, namespace string, noHooks bool) error {
+	args := []string{"uninstall", releaseName, "--namespace", namespace}
+	if noHooks {
+		args = append(args, "--no-hooks")
+	}
+	_, err := h.execArgs(args...)
+	if err != nil && isReleaseNotFound(err) {
+		return fmt.Errorf("%w: %s in namespace %s", interfaces.ErrReleaseNotFound, releaseName, namespace)
+	}
+	return err
+}
+
+// Rollback will roll a release back to a previous revision
+func (h *Helm) Rollback(releaseName string, namespace string, revision int) error {
+	_, err := h.execArgs("rollback", releaseName, strconv.Itoa(revision), "--namespace", namespace)
+	return err
+}
+
+// Test will run the tests of a release, its test hooks, for as long as the timeout, a go duration
+func (h *Helm) Test(releaseName string, namespace string, timeout string) (string, error) {
+	return h.execArgs("test", releaseName, "--namespace", namespace, "--timeout", timeout)
+}
+
+// getReleaseArgs will return the helm command arguments shared by install/upgrade and template for release options
+func getReleaseArgs(options *interfaces.HelmReleaseOptions) []string {
+	args := []string{options.ReleaseName, options.ChartPath, "--namespace", options.Namespace}
+	for _, setValue := range options.SetValues {
+		args = append(args, "--set", setValue)
+	}
+	if options.Timeout != "" {
+		args = append(args, "--timeout", options.Timeout)
+	}
+	if options.Version != "" {
+		args = append(args, "--version", options.Version)
+	}
+	for _, valuesFile := range options.ValuesFiles {
+		args = append(args, "-f", valuesFile)
+	}
+	if options.PostRenderer != "" {
+		args = append(args, "--post-renderer", options.PostRenderer)
+	}
+	return args
+}
+
+// getUpgradeArgs returns the args of the Helm options only used to install/upgrade a release
+func getUpgradeArgs(options *interfaces.HelmReleaseOptions) []string {
+	args := []string{}
+	flags := []struct {
+		flag    string
+		enabled bool
+	}{
+		{"--create-namespace", options.CreateNamespace},
+		{"--wait", options.Wait},
+		{"--wait-for-jobs", options.WaitForJobs},
+		{"--atomic", options.Atomic},
+		{"--force", options.Force},
+		{"--no-hooks", options.DisableHooks},
+		{"--skip-crds", options.SkipCRDs},
+		{"--cleanup-on-fail", options.CleanupOnFail},
+	}
+	for _, flag := range flags {
+		if flag.enabled {
+			args = append(args, flag.flag)
+		}
+	}
+	if options.MaxHistory > 0 {
+		args = append(args, "--history-max", strconv.Itoa(options.MaxHistory))
+	}
+	if options.Description != "" {
+		args = append(args, "--description", options.Description)
+	}
+	return append(args, options.ExtraArgs...)
+}
+
+// quoteArg single quotes an arg for the shell unless it's only made up of characters the shell leaves alone, ending the
+// quoting around each single quote in it to escape it, so that the shell keeps it as a single arg exactly as it is
+func quoteArg(arg string) string {
+	if shellSafeArg.MatchString(arg) {
+		return arg
+	}
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(arg, "'", `'\''`))
+}
+
+// PullChart will download a chart from an OCI registry, an oci://<registry>/<repository>:<tag> path from
+// GetAvailableChartVersions, to a local directory, returning its local path and the digest of its OCI manifest. When a
+// digest is given, the chart's manifest must have that digest
+func (h *Helm) PullChart(chartPath string, digest string, destination string) (string, string, error) {
+	return pullOCIChart(h.ChartsSource, chartPath, digest, destination)
+}
+
+// PackageChart will build the dependencies of an unpackaged chart directory and package it to a destination directory,
+// returning the path of the packaged chart
+func (h *Helm) PackageChart(chartDirectory string, destination string) (string, error) {
+	if _, err := h.execArgs("dependency", "build", chartDirectory); err != nil {
+		return "", err
+	}
+	output, err := h.execArgs("package", chartDirectory, "--destination", destination)
+	if err != nil {
+		return "", err
+	}
+	matches := packageOutputPath.FindStringSubmatch(output)
+	if matches == nil {
+		return "", fmt.Errorf("Couldn't find the path of the packaged chart in the helm package output: %s", output)
+	}
+	return strings.TrimSpace(matches[1]), nil
+}
+
+// DownloadChart will download a chart from a chart repo, a url path from GetAvailableChartVersions, to a local directory,
+// returning its local path. With provenance, the chart's .prov file is downloaded next to it for VerifyChart
+func (h *Helm) DownloadChart(chartPath string, provenance bool, destination string) (string, error) {
+	return downloadChart(h.ChartsSource, chartPath, provenance, destination)
+}
+
+// VerifyChart will verify the signature in the .prov file next to a packaged chart against a keyring, and that the
+// chart matches the checksum it signs
+func (h *Helm) VerifyChart(chartPath string, keyring string) error {
+	_, err := h.execArgs("verify", chartPath, "--keyring", keyring)
+	return err
+}
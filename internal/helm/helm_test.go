@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -70,6 +71,17 @@ info:
 status: deployed
 	`
 		}
+		if strings.Contains(command, "upgrade --install test-release-upgrade") {
+			return `Release "test-release-upgrade" has been upgraded. Happy Helming!
+NAME: test-release-upgrade
+LAST DEPLOYED: Tue Jan 28 20:31:31 2020
+NAMESPACE: default
+STATUS: deployed
+REVISION: 3`
+		}
+		if strings.HasPrefix(command, "helm package") {
+			return "Successfully packaged chart and saved it to: /tmp/packaged/chart1-0.1.0.tgz"
+		}
 		if strings.Contains(command, "status test-release-status") {
 			return `---
 info:
@@ -101,6 +113,25 @@ func TestInitializeOldHelmVersion(t *testing.T) {
 	}
 }
 
+func TestForChartsSource(t *testing.T) {
+	h := &Helm{}
+	if err := h.Initialize(getMockExecConfig(false), &interfaces.HelmChartsSource{Path: "/charts"}); err != nil {
+		t.Errorf("Got unexpected error from helm.TestForChartsSource(): %s", err)
+		return
+	}
+	forSource, err := h.ForChartsSource(&interfaces.HelmChartsSource{Repo: "https://charts.io"})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestForChartsSource(): %s", err)
+		return
+	}
+	if forSource.GetExecConfig() != h.ExecConfig || forSource.(*Helm).ChartsSource.Repo != "https://charts.io" || h.ChartsSource.Path != "/charts" {
+		t.Errorf("Didn't get expected helm object from helm.TestForChartsSource(), got: %v", forSource)
+	}
+	if _, err = h.ForChartsSource(&interfaces.HelmChartsSource{OCI: "not a reference"}); err == nil {
+		t.Errorf("Didn't get expected error from helm.TestForChartsSource() with an invalid OCI reference")
+	}
+}
+
 func TestNewExecError(t *testing.T) {
 	setExecError("new-exec-error")
 	defer resetExecError()
@@ -298,3 +329,367 @@ func TestGetReleaseStatusInvalidStatus(t *testing.T) {
 		t.Errorf("Didn't get expected error from helm.TestGetReleaseStatusInvalidStatus(): %s", err)
 	}
 }
+
+func TestUpgrade(t *testing.T) {
+	h := &Helm{}
+	err := h.Initialize(getMockExecConfig(false), &interfaces.HelmChartsSource{})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestUpgrade(): %s", err)
+		return
+	}
+	result, err := h.Upgrade(&interfaces.HelmReleaseOptions{
+		ReleaseName: "test-release-upgrade",
+		Namespace:   "default",
+		ChartPath:   "charts/chart1",
+	})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestUpgrade(): %s", err)
+		return
+	}
+	if result.Revision != 3 || result.Status != "deployed" || result.Namespace != "default" {
+		t.Errorf("Didn't get expected result from helm.TestUpgrade(), instead got: %+v", result)
+	}
+}
+
+func TestUpgradeArgs(t *testing.T) {
+	h := &Helm{}
+	err := h.Initialize(getMockExecConfig(false), &interfaces.HelmChartsSource{})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestUpgradeArgs(): %s", err)
+		return
+	}
+	result, err := h.Upgrade(&interfaces.HelmReleaseOptions{
+		ReleaseName:     "release1",
+		Namespace:       "services",
+		ChartPath:       "repo/chart1",
+		Version:         "0.1.0",
+		SetValues:       []string{"global.chart.name=chart1", "global.chart.version=0.1.0"},
+		ValuesFiles:     []string{"/tmp/chart1-values.yaml"},
+		Timeout:         "10m",
+		CreateNamespace: true,
+	})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestUpgradeArgs(): %s", err)
+		return
+	}
+	expected := "helm upgrade --install release1 repo/chart1 --namespace services --set global.chart.name=chart1 " +
+		"--set global.chart.version=0.1.0 --timeout 10m --version 0.1.0 -f /tmp/chart1-values.yaml --create-namespace"
+	if result.Output != expected {
+		t.Errorf("Didn't get expected command from helm.TestUpgradeArgs(), instead got: %s", result.Output)
+	}
+}
+
+func TestUpgradeHelmOptions(t *testing.T) {
+	h := &Helm{}
+	err := h.Initialize(getMockExecConfig(false), &interfaces.HelmChartsSource{})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestUpgradeHelmOptions(): %s", err)
+		return
+	}
+	result, err := h.Upgrade(&interfaces.HelmReleaseOptions{
+		ReleaseName:     "release1",
+		Namespace:       "services",
+		ChartPath:       "/tmp/chart1-0.1.0.tgz",
+		Wait:            true,
+		Atomic:          true,
+		SkipCRDs:        true,
+		MaxHistory:      5,
+		CreateNamespace: true,
+	})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestUpgradeHelmOptions(): %s", err)
+		return
+	}
+	expected := "helm upgrade --install release1 /tmp/chart1-0.1.0.tgz --namespace services --create-namespace --wait --atomic " +
+		"--skip-crds --history-max 5"
+	if result.Output != expected {
+		t.Errorf("Didn't get expected command from helm.TestUpgradeHelmOptions(), instead got: %s", result.Output)
+	}
+}
+
+func TestUpgradeReleaseOptions(t *testing.T) {
+	h := &Helm{}
+	err := h.Initialize(getMockExecConfig(false), &interfaces.HelmChartsSource{})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestUpgradeReleaseOptions(): %s", err)
+		return
+	}
+	result, err := h.Upgrade(&interfaces.HelmReleaseOptions{
+		ReleaseName:  "release1",
+		Namespace:    "services",
+		ChartPath:    "/tmp/chart1-0.1.0.tgz",
+		PostRenderer: "/manifests/kustomize.sh",
+		Description:  "shipped by the platform team",
+		ExtraArgs:    []string{"--disable-openapi-validation", "--reset-values"},
+	})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestUpgradeReleaseOptions(): %s", err)
+		return
+	}
+	expected := "helm upgrade --install release1 /tmp/chart1-0.1.0.tgz --namespace services --post-renderer /manifests/kustomize.sh " +
+		`--description 'shipped by the platform team' --disable-openapi-validation --reset-values`
+	if result.Output != expected {
+		t.Errorf("Didn't get expected command from helm.TestUpgradeReleaseOptions(), instead got: %s", result.Output)
+	}
+}
+
+const testHostileDescription = `it's "done"; rm -rf / $(whoami) ` + "`id`"
+
+func TestUpgradeHostileValues(t *testing.T) {
+	h := &Helm{}
+	err := h.Initialize(getMockExecConfig(false), &interfaces.HelmChartsSource{})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestUpgradeHostileValues(): %s", err)
+		return
+	}
+	result, err := h.Upgrade(&interfaces.HelmReleaseOptions{
+		ReleaseName:  "release1",
+		Namespace:    "services",
+		ChartPath:    "/tmp/chart1-0.1.0.tgz",
+		ValuesFiles:  []string{"/tmp/my values.yaml"},
+		PostRenderer: "/manifests/post render's.sh",
+		Description:  testHostileDescription,
+	})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestUpgradeHostileValues(): %s", err)
+		return
+	}
+	expected := "helm upgrade --install release1 /tmp/chart1-0.1.0.tgz --namespace services -f '/tmp/my values.yaml' " +
+		`--post-renderer '/manifests/post render'\''s.sh' --description 'it'\''s "done"; rm -rf / $(whoami) ` + "`id`'"
+	if result.Output != expected {
+		t.Errorf("Didn't get expected command from helm.TestUpgradeHostileValues(), instead got: %s", result.Output)
+	}
+}
+
+// argsShell is a helm shell that records the args, and any stdin, it's given to run
+type argsShell struct {
+	shellmocks.Interface
+	args  [][]string
+	stdin []string
+}
+
+func (sh *argsShell) ExecArgs(args []string, options shell.ExecOptions) (string, error) {
+	sh.args = append(sh.args, args)
+	return "", nil
+}
+
+func (sh *argsShell) ExecArgsStdin(args []string, stdin string, options shell.ExecOptions) (string, error) {
+	sh.stdin = append(sh.stdin, stdin)
+	return sh.ExecArgs(args, options)
+}
+
+func TestAddRepoPasswordStdin(t *testing.T) {
+	recorder := &argsShell{}
+	h := &Helm{ExecConfig: &interfaces.HelmExecConfig{Shell: recorder, Binary: "helm"}}
+	if err := h.AddRepo(&interfaces.HelmChartsSource{RepoName: "repo1", Repo: "https://charts.local", RepoUsername: "user",
+		RepoPassword: "hunter2"}); err != nil {
+		t.Errorf("Got unexpected error from helm.TestAddRepoPasswordStdin(): %s", err)
+		return
+	}
+	expected := []string{"helm", "repo", "add", "repo1", "https://charts.local", "--username", "user", "--password-stdin"}
+	if len(recorder.args) != 1 || !reflect.DeepEqual(recorder.args[0], expected) {
+		t.Errorf("Didn't get expected args from helm.TestAddRepoPasswordStdin(), instead got: %v", recorder.args)
+	}
+	if !reflect.DeepEqual(recorder.stdin, []string{"hunter2"}) {
+		t.Errorf("Didn't get expected stdin from helm.TestAddRepoPasswordStdin(), instead got: %v", recorder.stdin)
+	}
+
+	// a shell that can't pass stdin is never given the password as an arg instead
+	h.ExecConfig = getMockExecConfig(false)
+	err := h.AddRepo(&interfaces.HelmChartsSource{RepoName: "repo1", Repo: "https://charts.local", RepoUsername: "user",
+		RepoPassword: "hunter2"})
+	if err == nil {
+		t.Errorf("Didn't get expected error from helm.TestAddRepoPasswordStdin() without a stdin shell")
+	}
+	h.ExecConfig.Shell.(*shellmocks.Interface).AssertNotCalled(t, "Exec", mock.Anything, mock.Anything)
+}
+
+func TestUpgradeArgsShell(t *testing.T) {
+	recorder := &argsShell{}
+	h := &Helm{ExecConfig: &interfaces.HelmExecConfig{Shell: recorder, Binary: "helm", KubeconfigPath: "/home/my user/.kube/config"}}
+	if _, err := h.Upgrade(&interfaces.HelmReleaseOptions{
+		ReleaseName: "release1",
+		Namespace:   "services",
+		ChartPath:   "/tmp/chart1-0.1.0.tgz",
+		Description: testHostileDescription,
+	}); err != nil {
+		t.Errorf("Got unexpected error from helm.TestUpgradeArgsShell(): %s", err)
+		return
+	}
+	expected := []string{"helm", "--kubeconfig", "/home/my user/.kube/config", "upgrade", "--install", "release1",
+		"/tmp/chart1-0.1.0.tgz", "--namespace", "services", "--description", testHostileDescription}
+	if len(recorder.args) != 1 || !reflect.DeepEqual(recorder.args[0], expected) {
+		t.Errorf("Didn't get expected args from helm.TestUpgradeArgsShell(), instead got: %q", recorder.args)
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	for arg, expected := range map[string]string{
+		"release1":                 "release1",
+		"--set":                    "--set",
+		"global.chart.name=chart1": "global.chart.name=chart1",
+		"":                         "''",
+		"two words":                "'two words'",
+		"it's":                     `'it'\''s'`,
+		"$(reboot)":                "'$(reboot)'",
+	} {
+		if quoted := quoteArg(arg); quoted != expected {
+			t.Errorf("Didn't get expected quoted arg from helm.TestQuoteArg() for %q, expected %s, got: %s", arg, expected, quoted)
+		}
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	h := &Helm{}
+	err := h.Initialize(getMockExecConfig(false), &interfaces.HelmChartsSource{})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestTemplate(): %s", err)
+		return
+	}
+	out, err := h.Template(&interfaces.HelmReleaseOptions{
+		ReleaseName: "release1",
+		Namespace:   "default",
+		ChartPath:   "charts/chart1-0.1.0.tgz",
+	})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestTemplate(): %s", err)
+		return
+	}
+	if out != "helm template release1 charts/chart1-0.1.0.tgz --namespace default --no-hooks" {
+		t.Errorf("Didn't get expected command from helm.TestTemplate(), instead got: %s", out)
+	}
+}
+
+func TestGetReleaseStatusNotInstalled(t *testing.T) {
+	h := &Helm{}
+	err := h.Initialize(getMockExecConfig(false), &interfaces.HelmChartsSource{})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestGetReleaseStatusNotInstalled(): %s", err)
+		return
+	}
+	setExecError("Error: release: not found")
+	defer resetExecError()
+	h.ExecConfig = getMockExecConfig(false)
+	_, err = h.GetReleaseStatus("release1", "default")
+	if !errors.Is(err, interfaces.ErrReleaseNotFound) {
+		t.Errorf("Didn't get expected not found error from helm.TestGetReleaseStatusNotInstalled(), got: %v", err)
+	}
+
+	// other errors that mention something not being found aren't of the release not being installed
+	setExecError("Error: Kubernetes cluster unreachable: dial tcp: lookup kubernetes: no such host, not found")
+	h.ExecConfig = getMockExecConfig(false)
+	_, err = h.GetReleaseStatus("release1", "default")
+	if err == nil || errors.Is(err, interfaces.ErrReleaseNotFound) {
+		t.Errorf("Didn't get expected error from helm.TestGetReleaseStatusNotInstalled() when the cluster is unreachable, got: %v", err)
+	}
+}
+
+func TestGetManifestNotInstalled(t *testing.T) {
+	h := &Helm{}
+	err := h.Initialize(getMockExecConfig(false), &interfaces.HelmChartsSource{})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestGetManifestNotInstalled(): %s", err)
+		return
+	}
+	setExecError("Error: release: not found")
+	defer resetExecError()
+	h.ExecConfig = getMockExecConfig(false)
+	out, err := h.GetManifest("release1", "default")
+	if err != nil || out != "" {
+		t.Errorf("Didn't get expected empty manifest from helm.TestGetManifestNotInstalled(), got: %s, %s", out, err)
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	h := &Helm{}
+	execConfig := getMockExecConfig(false)
+	err := h.Initialize(execConfig, &interfaces.HelmChartsSource{})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestUninstall(): %s", err)
+		return
+	}
+	if err = h.Uninstall("release1", "default", true); err != nil {
+		t.Errorf("Got unexpected error from helm.TestUninstall(): %s", err)
+		return
+	}
+	execConfig.Shell.(*shellmocks.Interface).AssertCalled(t, "Exec", "helm uninstall release1 --namespace default --no-hooks",
+		mock.AnythingOfType("shell.ExecOptions"))
+}
+
+func TestUninstallNotInstalled(t *testing.T) {
+	h := &Helm{}
+	err := h.Initialize(getMockExecConfig(false), &interfaces.HelmChartsSource{})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestUninstallNotInstalled(): %s", err)
+		return
+	}
+	setExecError("Error: uninstall: Release not loaded: release1: release: not found")
+	defer resetExecError()
+	h.ExecConfig = getMockExecConfig(false)
+	err = h.Uninstall("release1", "default", false)
+	if !errors.Is(err, interfaces.ErrReleaseNotFound) {
+		t.Errorf("Didn't get expected not found error from helm.TestUninstallNotInstalled(), got: %v", err)
+	}
+
+	// the namespace not being found isn't the release not being installed
+	setExecError(`Error: uninstall: Release not loaded: release1: namespaces "removed" not found`)
+	h.ExecConfig = getMockExecConfig(false)
+	err = h.Uninstall("release1", "removed", false)
+	if err == nil || errors.Is(err, interfaces.ErrReleaseNotFound) {
+		t.Errorf("Didn't get expected error from helm.TestUninstallNotInstalled() when the namespace isn't found, got: %v", err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	h := &Helm{}
+	execConfig := getMockExecConfig(false)
+	err := h.Initialize(execConfig, &interfaces.HelmChartsSource{})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestRollback(): %s", err)
+		return
+	}
+	if err = h.Rollback("release1", "default", 2); err != nil {
+		t.Errorf("Got unexpected error from helm.TestRollback(): %s", err)
+		return
+	}
+	execConfig.Shell.(*shellmocks.Interface).AssertCalled(t, "Exec", "helm rollback release1 2 --namespace default",
+		mock.AnythingOfType("shell.ExecOptions"))
+}
+
+func TestTest(t *testing.T) {
+	h := &Helm{}
+	execConfig := getMockExecConfig(false)
+	err := h.Initialize(execConfig, &interfaces.HelmChartsSource{})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestTest(): %s", err)
+		return
+	}
+	if _, err = h.Test("release1", "default", "5m0s"); err != nil {
+		t.Errorf("Got unexpected error from helm.TestTest(): %s", err)
+		return
+	}
+	execConfig.Shell.(*shellmocks.Interface).AssertCalled(t, "Exec", "helm test release1 --namespace default --timeout 5m0s",
+		mock.AnythingOfType("shell.ExecOptions"))
+}
+
+func TestPackageChart(t *testing.T) {
+	h := &Helm{}
+	execConfig := getMockExecConfig(false)
+	err := h.Initialize(execConfig, &interfaces.HelmChartsSource{})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestPackageChart(): %s", err)
+		return
+	}
+	chartPath, err := h.PackageChart("/tmp/checkout/chart1", "/tmp/packaged")
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestPackageChart(): %s", err)
+		return
+	}
+	if chartPath != "/tmp/packaged/chart1-0.1.0.tgz" {
+		t.Errorf("Didn't get expected packaged chart path from helm.TestPackageChart(), got: %s", chartPath)
+	}
+	execConfig.Shell.(*shellmocks.Interface).AssertCalled(t, "Exec", "helm dependency build /tmp/checkout/chart1",
+		mock.AnythingOfType("shell.ExecOptions"))
+	execConfig.Shell.(*shellmocks.Interface).AssertCalled(t, "Exec", "helm package /tmp/checkout/chart1 --destination /tmp/packaged",
+		mock.AnythingOfType("shell.ExecOptions"))
+}
@@ -0,0 +1,302 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+)
+
+const (
+	ociScheme               = "oci://"
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	ociChartLayerMediaType  = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	ociLegacyChartMediaType = "application/tar+gzip" // pushed by the experimental OCI support of Helm before v3.7
+)
+
+var (
+	ociAuthChallengeParams = regexp.MustCompile(`(\w+)="([^"]*)"`)
+	ociNextLink            = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+)
+
+// ociReference is a parsed oci://<registry>/<repository>[:<tag>] reference to a chart in an OCI registry
+type ociReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+}
+
+// ociManifest is a minimal representation of the OCI image manifest of a chart
+type ociManifest struct {
+	Layers []*ociDescriptor `json:"layers"`
+}
+
+// ociDescriptor is a minimal representation of an OCI content descriptor
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+// ociClient is a minimal client for the OCI distribution API, enough to list the versions of a chart and pull it
+type ociClient struct {
+	username      string
+	password      string
+	authorization string // the last authorization the registry accepted, reused until it isn't
+	httpClient    *http.Client
+}
+
+// parseOCIReference will parse an oci://<registry>/<repository>[:<tag>] reference
+func parseOCIReference(reference string) (*ociReference, error) {
+	if !strings.HasPrefix(reference, ociScheme) {
+		return nil, fmt.Errorf("OCI reference %s must start with %s", reference, ociScheme)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(reference, ociScheme), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("OCI reference %s must be of the form %s<registry>/<repository>", reference, ociScheme)
+	}
+	parsed := &ociReference{Registry: parts[0], Repository: strings.Trim(parts[1], "/")}
+	if i := strings.LastIndex(parsed.Repository, ":"); i != -1 {
+		parsed.Tag = parsed.Repository[i+1:]
+		parsed.Repository = parsed.Repository[:i]
+	}
+	return parsed, nil
+}
+
+// String returns the oci:// form of the reference
+func (r *ociReference) String() string {
+	if r.Tag == "" {
+		return fmt.Sprintf("%s%s/%s", ociScheme, r.Registry, r.Repository)
+	}
+	return fmt.Sprintf("%s%s/%s:%s", ociScheme, r.Registry, r.Repository, r.Tag)
+}
+
+// getChartName returns the last element of the reference's repository, the chart name
+func (r *ociReference) getChartName() string {
+	return r.Repository[strings.LastIndex(r.Repository, "/")+1:]
+}
+
+func newOCIClient(chartsSource *interfaces.HelmChartsSource) *ociClient {
+	return &ociClient{
+		username:   chartsSource.RepoUsername,
+		password:   chartsSource.RepoPassword,
+		httpClient: &http.Client{},
+	}
+}
+
+// getOCIChartVersions will return the versions of a chart in an OCI registry, from the tags of its repository. Helm
+// pushes chart versions with build metadata with a _ in place of the +, which isn't allowed in tags
+func getOCIChartVersions(chartsSource *interfaces.HelmChartsSource, chartName string) ([]*interfaces.HelmAvailableChartVersion, error) {
+	var available []*interfaces.HelmAvailableChartVersion
+	source, err := parseOCIReference(chartsSource.OCI)
+	if err != nil {
+		return available, err
+	}
+	chart := &ociReference{Registry: source.Registry, Repository: fmt.Sprintf("%s/%s", source.Repository, chartName)}
+	tags, err := newOCIClient(chartsSource).listTags(chart)
+	if err != nil {
+		return available, err
+	}
+	for _, tag := range tags {
+		available = append(available, &interfaces.HelmAvailableChartVersion{
+			Path:    (&ociReference{Registry: chart.Registry, Repository: chart.Repository, Tag: tag}).String(),
+			Version: strings.ReplaceAll(tag, "_", "+"),
+		})
+	}
+	return available, nil
+}
+
+// pullOCIChart will download the packaged chart of an oci://<registry>/<repository>:<tag> reference to a destination
+// directory, returning its local path and the digest of its manifest. When a digest is given, the chart's manifest must
+// have that digest
+func pullOCIChart(chartsSource *interfaces.HelmChartsSource, reference string, digest string, destination string) (string, string, error) {
+	chart, err := parseOCIReference(reference)
+	if err != nil {
+		return "", "", err
+	}
+	if chart.Tag == "" {
+		return "", "", fmt.Errorf("OCI reference %s has no tag to pull", reference)
+	}
+	client := newOCIClient(chartsSource)
+	manifestBytes, err := client.get(chart, fmt.Sprintf("manifests/%s", chart.Tag), ociManifestMediaType)
+	if err != nil {
+		return "", "", err
+	}
+	manifestDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifestBytes))
+	if digest != "" && digest != manifestDigest {
+		return "", "", fmt.Errorf("Chart %s has digest %s, not the pinned digest %s", reference, manifestDigest, digest)
+	}
+	var manifest *ociManifest
+	if err = json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return "", "", fmt.Errorf("Error parsing the OCI manifest of chart %s: %s", reference, err)
+	}
+	var chartLayer *ociDescriptor
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == ociChartLayerMediaType || layer.MediaType == ociLegacyChartMediaType {
+			chartLayer = layer
+			break
+		}
+	}
+	if chartLayer == nil {
+		return "", "", fmt.Errorf("OCI manifest of %s has no chart content layer, is it a Helm chart?", reference)
+	}
+	chartBytes, err := client.get(chart, fmt.Sprintf("blobs/%s", chartLayer.Digest), "")
+	if err != nil {
+		return "", "", err
+	}
+	if layerDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(chartBytes)); layerDigest != chartLayer.Digest {
+		return "", "", fmt.Errorf("Chart content of %s has digest %s, expected %s", reference, layerDigest, chartLayer.Digest)
+	}
+	if err = os.MkdirAll(destination, 0755); err != nil {
+		return "", "", err
+	}
+	chartPath := filepath.Join(destination, fmt.Sprintf("%s-%s.tgz", chart.getChartName(), chart.Tag))
+	if err = ioutil.WriteFile(chartPath, chartBytes, 0644); err != nil {
+		return "", "", err
+	}
+	return chartPath, manifestDigest, nil
+}
+
+// listTags will list all of the tags of a repository, following the registry's pagination
+func (c *ociClient) listTags(repository *ociReference) ([]string, error) {
+	var tags []string
+	endpoint := fmt.Sprintf("https://%s/v2/%s/tags/list", repository.Registry, repository.Repository)
+	for {
+		resp, body, err := c.do(endpoint, "")
+		if err != nil {
+			return tags, err
+		}
+		var tagList struct {
+			Tags []string `json:"tags"`
+		}
+		if err = json.Unmarshal(body, &tagList); err != nil {
+			return tags, fmt.Errorf("Error parsing the tags of %s: %s", repository, err)
+		}
+		tags = append(tags, tagList.Tags...)
+		matches := ociNextLink.FindStringSubmatch(resp.Header.Get("Link"))
+		if matches == nil {
+			break
+		}
+		current, err := url.Parse(endpoint)
+		if err != nil {
+			return tags, err
+		}
+		next, err := current.Parse(matches[1])
+		if err != nil {
+			return tags, err
+		}
+		endpoint = next.String()
+	}
+	return tags, nil
+}
+
+// get will get the content at a path under the /v2/<repository>/ API of a repository
+func (c *ociClient) get(repository *ociReference, apiPath string, accept string) ([]byte, error) {
+	_, body, err := c.do(fmt.Sprintf("https://%s/v2/%s/%s", repository.Registry, repository.Repository, apiPath), accept)
+	return body, err
+}
+
+// do will make a GET request to the registry, authorizing with it when it asks us to
+func (c *ociClient) do(endpoint string, accept string) (*http.Response, []byte, error) {
+	resp, body, err := c.doWithAuthorization(endpoint, accept, c.authorization)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		authorization, err := c.authorize(resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, nil, err
+		}
+		if resp, body, err = c.doWithAuthorization(endpoint, accept, authorization); err != nil {
+			return nil, nil, err
+		}
+		c.authorization = authorization
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("Got status %s from OCI registry request %s: %s", resp.Status, endpoint, strings.TrimSpace(string(body)))
+	}
+	return resp, body, nil
+}
+
+func (c *ociClient) doWithAuthorization(endpoint string, accept string, authorization string) (*http.Response, []byte, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
+
+// authorize will get the authorization asked for by a registry's WWW-Authenticate challenge, either basic auth with our
+// credentials, or a bearer token from the registry's token service
+func (c *ociClient) authorize(challenge string) (string, error) {
+	basicAuth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", c.username, c.password)))
+	if strings.HasPrefix(strings.ToLower(challenge), "basic") {
+		if c.username == "" {
+			return "", fmt.Errorf("OCI registry requires credentials, set a credentialsSecret on the chart source")
+		}
+		return fmt.Sprintf("Basic %s", basicAuth), nil
+	}
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer") {
+		return "", fmt.Errorf("OCI registry asked for an unsupported authorization: %s", challenge)
+	}
+	params := make(map[string]string)
+	for _, matches := range ociAuthChallengeParams.FindAllStringSubmatch(challenge, -1) {
+		params[matches[1]] = matches[2]
+	}
+	tokenURL, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("OCI registry asked for a bearer token without a valid realm: %s", challenge)
+	}
+	query := tokenURL.Query()
+	for _, param := range []string{"service", "scope"} {
+		if params[param] != "" {
+			query.Set(param, params[param])
+		}
+	}
+	tokenURL.RawQuery = query.Encode()
+	authorization := ""
+	if c.username != "" {
+		authorization = fmt.Sprintf("Basic %s", basicAuth)
+	}
+	resp, body, err := c.doWithAuthorization(tokenURL.String(), "", authorization)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Got status %s getting a token from OCI registry token service %s", resp.Status, params["realm"])
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("Error parsing the token from OCI registry token service %s: %s", params["realm"], err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return fmt.Sprintf("Bearer %s", token.Token), nil
+}
@@ -0,0 +1,160 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	"github.com/jarcoal/httpmock"
+)
+
+const testOCIManifestTemplate = `{
+  "schemaVersion": 2,
+  "config": {"mediaType": "application/vnd.cncf.helm.config.v1+json", "digest": "sha256:0000000000000000000000000000000000000000000000000000000000000000", "size": 2},
+  "layers": [{"mediaType": "application/vnd.cncf.helm.chart.content.v1.tar+gzip", "digest": "%s", "size": %d}]
+}`
+
+// registerOCIRegistry will mock an OCI registry at registry.io serving chart1 as charts/chart1, behind a bearer token
+// service like Harbor's, returning the digest of the chart1:0.1.0 manifest
+func registerOCIRegistry(t *testing.T) string {
+	chartBytes, err := ioutil.ReadFile(".test-fixtures/charts/chart1-0.1.0.tgz")
+	if err != nil {
+		t.Fatalf("Couldn't read the chart fixture: %s", err)
+	}
+	manifest := fmt.Sprintf(testOCIManifestTemplate, fmt.Sprintf("sha256:%x", sha256.Sum256(chartBytes)), len(chartBytes))
+	authorized := func(responder httpmock.Responder) httpmock.Responder {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") != "Bearer test-token" {
+				resp := httpmock.NewStringResponse(401, `{"errors":[{"code":"UNAUTHORIZED"}]}`)
+				resp.Header.Set("WWW-Authenticate", `Bearer realm="https://registry.io/service/token",service="harbor-registry",scope="repository:charts/chart1:pull"`)
+				return resp, nil
+			}
+			return responder(req)
+		}
+	}
+	httpmock.RegisterResponder("GET", "https://registry.io/service/token", func(req *http.Request) (*http.Response, error) {
+		username, password, _ := req.BasicAuth()
+		if username != "user" || password != "pass" || req.URL.Query().Get("scope") != "repository:charts/chart1:pull" {
+			return httpmock.NewStringResponse(401, "unauthorized"), nil
+		}
+		return httpmock.NewStringResponse(200, `{"token":"test-token"}`), nil
+	})
+	httpmock.RegisterResponder("GET", "https://registry.io/v2/charts/chart1/tags/list", authorized(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Query().Get("last") == "0.1.0" {
+			return httpmock.NewStringResponse(200, `{"name":"charts/chart1","tags":["0.1.1_build.1"]}`), nil
+		}
+		resp := httpmock.NewStringResponse(200, `{"name":"charts/chart1","tags":["0.1.0"]}`)
+		resp.Header.Set("Link", `</v2/charts/chart1/tags/list?last=0.1.0&n=1>; rel="next"`)
+		return resp, nil
+	}))
+	httpmock.RegisterResponder("GET", "https://registry.io/v2/charts/chart1/manifests/0.1.0", authorized(httpmock.NewStringResponder(200, manifest)))
+	httpmock.RegisterResponder("GET", fmt.Sprintf("https://registry.io/v2/charts/chart1/blobs/sha256:%x", sha256.Sum256(chartBytes)),
+		authorized(httpmock.NewBytesResponder(200, chartBytes)))
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(manifest)))
+}
+
+func getTestOCIChartsSource() *interfaces.HelmChartsSource {
+	return &interfaces.HelmChartsSource{
+		OCI:          "oci://registry.io/charts",
+		RepoUsername: "user",
+		RepoPassword: "pass",
+	}
+}
+
+func TestParseOCIReference(t *testing.T) {
+	reference, err := parseOCIReference("oci://registry.io:5000/platform/charts/chart1:0.1.0")
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestParseOCIReference(): %s", err)
+		return
+	}
+	if reference.Registry != "registry.io:5000" || reference.Repository != "platform/charts/chart1" || reference.Tag != "0.1.0" {
+		t.Errorf("Didn't get expected reference from helm.TestParseOCIReference(), instead got: %+v", reference)
+	}
+	if _, err = parseOCIReference("https://registry.io/charts"); err == nil {
+		t.Errorf("Didn't get expected error from helm.TestParseOCIReference() for a reference without oci://")
+	}
+}
+
+func TestGetAvailableChartVersionsWithOCI(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	registerOCIRegistry(t)
+	h := &Helm{}
+	if err := h.Initialize(getMockExecConfig(false), getTestOCIChartsSource()); err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestGetAvailableChartVersionsWithOCI(): %s", err)
+		return
+	}
+	available, err := h.GetAvailableChartVersions("chart1")
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestGetAvailableChartVersionsWithOCI(): %s", err)
+		return
+	}
+	if len(available) != 2 {
+		t.Errorf("Didn't get expected size for available list from helm.TestGetAvailableChartVersionsWithOCI(), expected 2, but got %d", len(available))
+		return
+	}
+	if available[0].Version != "0.1.0" || available[0].Path != "oci://registry.io/charts/chart1:0.1.0" {
+		t.Errorf("Found unexpected version in available list from helm.TestGetAvailableChartVersionsWithOCI(): %+v", available[0])
+	}
+	if available[1].Version != "0.1.1+build.1" || available[1].Path != "oci://registry.io/charts/chart1:0.1.1_build.1" {
+		t.Errorf("Found unexpected version in available list from helm.TestGetAvailableChartVersionsWithOCI(): %+v", available[1])
+	}
+}
+
+func TestGetAvailableChartVersionsWithOCIWithoutCreds(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	registerOCIRegistry(t)
+	_, err := getAvailableChartVersions(&interfaces.HelmChartsSource{OCI: "oci://registry.io/charts"}, "chart1")
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Didn't get expected error from helm.TestGetAvailableChartVersionsWithOCIWithoutCreds(), instead got: %s", err)
+	}
+}
+
+func TestPullChart(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	digest := registerOCIRegistry(t)
+	destination, _ := ioutil.TempDir("", "loftsman-tests-helm-oci")
+	defer os.RemoveAll(destination)
+	s := &SDK{}
+	if err := s.Initialize(&interfaces.HelmExecConfig{}, getTestOCIChartsSource()); err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestPullChart(): %s", err)
+		return
+	}
+	chartPath, pulledDigest, err := s.PullChart("oci://registry.io/charts/chart1:0.1.0", digest, destination)
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestPullChart(): %s", err)
+		return
+	}
+	if chartPath != filepath.Join(destination, "chart1-0.1.0.tgz") {
+		t.Errorf("Didn't get expected chart path from helm.TestPullChart(), instead got: %s", chartPath)
+	}
+	if pulledDigest != digest {
+		t.Errorf("Didn't get expected digest from helm.TestPullChart(), instead got: %s", pulledDigest)
+	}
+	pulled, _ := ioutil.ReadFile(chartPath)
+	expected, _ := ioutil.ReadFile(".test-fixtures/charts/chart1-0.1.0.tgz")
+	if string(pulled) != string(expected) {
+		t.Errorf("The chart pulled in helm.TestPullChart() isn't the chart in the registry")
+	}
+}
+
+func TestPullChartDigestMismatch(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	registerOCIRegistry(t)
+	destination, _ := ioutil.TempDir("", "loftsman-tests-helm-oci")
+	defer os.RemoveAll(destination)
+	_, _, err := pullOCIChart(getTestOCIChartsSource(), "oci://registry.io/charts/chart1:0.1.0",
+		"sha256:fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9", destination)
+	if err == nil || !strings.Contains(err.Error(), "not the pinned digest") {
+		t.Errorf("Didn't get expected error from helm.TestPullChartDigestMismatch(), instead got: %s", err)
+	}
+}
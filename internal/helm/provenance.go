@@ -0,0 +1,54 @@
+package helm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+)
+
+// downloadChart will download the packaged chart at a chart repo url, a path from GetAvailableChartVersions, to a
+// destination directory, returning its local path. With provenance, the chart's .prov file is downloaded next to it
+func downloadChart(chartsSource *interfaces.HelmChartsSource, chartURL string, provenance bool, destination string) (string, error) {
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		return "", err
+	}
+	chartPath := filepath.Join(destination, path.Base(chartURL))
+	if err := downloadFile(chartsSource, chartURL, chartPath); err != nil {
+		return "", err
+	}
+	if provenance {
+		if err := downloadFile(chartsSource, fmt.Sprintf("%s.prov", chartURL), fmt.Sprintf("%s.prov", chartPath)); err != nil {
+			return "", fmt.Errorf("Error downloading the provenance file of chart %s: %s", chartURL, err)
+		}
+	}
+	return chartPath, nil
+}
+
+// downloadFile will download a file from a chart repo, with the repo's credentials if it has them
+func downloadFile(chartsSource *interfaces.HelmChartsSource, fileURL string, filePath string) error {
+	req, err := http.NewRequest("GET", fileURL, nil)
+	if err != nil {
+		return err
+	}
+	if chartsSource.RepoUsername != "" && chartsSource.RepoPassword != "" {
+		req.SetBasicAuth(chartsSource.RepoUsername, chartsSource.RepoPassword)
+	}
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", fileURL, resp.Status)
+	}
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filePath, content, 0644)
+}
@@ -0,0 +1,113 @@
+package helm
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	shellmocks "github.com/Cray-HPE/go-lib/mocks/shell"
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/openpgp"
+	"helm.sh/helm/v3/pkg/provenance"
+)
+
+func TestDownloadChart(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", "http://charts.io/charts/chart1-0.1.0.tgz", func(req *http.Request) (*http.Response, error) {
+		if username, password, ok := req.BasicAuth(); !ok || username != "user" || password != "pass" {
+			return httpmock.NewStringResponse(401, "unauthorized"), nil
+		}
+		return httpmock.NewStringResponse(200, "chart"), nil
+	})
+	httpmock.RegisterResponder("GET", "http://charts.io/charts/chart1-0.1.0.tgz.prov", httpmock.NewStringResponder(200, "provenance"))
+	destination := t.TempDir()
+	h := &Helm{ChartsSource: &interfaces.HelmChartsSource{Repo: "http://charts.io", RepoUsername: "user", RepoPassword: "pass"}}
+	chartPath, err := h.DownloadChart("http://charts.io/charts/chart1-0.1.0.tgz", true, destination)
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestDownloadChart(): %s", err)
+		return
+	}
+	if chartPath != filepath.Join(destination, "chart1-0.1.0.tgz") {
+		t.Errorf("Didn't get expected chart path from helm.TestDownloadChart(), got: %s", chartPath)
+	}
+	for path, expected := range map[string]string{chartPath: "chart", chartPath + ".prov": "provenance"} {
+		if content, err := ioutil.ReadFile(path); err != nil || string(content) != expected {
+			t.Errorf("Didn't get expected content of %s from helm.TestDownloadChart(), got: %s, %v", path, content, err)
+		}
+	}
+}
+
+func TestDownloadChartNotFound(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", "http://charts.io/charts/chart1-0.1.0.tgz", httpmock.NewStringResponder(200, "chart"))
+	httpmock.RegisterResponder("GET", "http://charts.io/charts/chart1-0.1.0.tgz.prov", httpmock.NewStringResponder(404, "not found"))
+	_, err := (&SDK{ChartsSource: &interfaces.HelmChartsSource{}}).DownloadChart("http://charts.io/charts/chart1-0.1.0.tgz", true, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "Error downloading the provenance file of chart") {
+		t.Errorf("Didn't get expected error from helm.TestDownloadChartNotFound(), got: %v", err)
+	}
+}
+
+func TestVerifyChart(t *testing.T) {
+	h := &Helm{}
+	execConfig := getMockExecConfig(false)
+	if err := h.Initialize(execConfig, &interfaces.HelmChartsSource{}); err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestVerifyChart(): %s", err)
+		return
+	}
+	if err := h.VerifyChart("/tmp/charts/chart1-0.1.0.tgz", "/tmp/pubring.gpg"); err != nil {
+		t.Errorf("Got unexpected error from helm.TestVerifyChart(): %s", err)
+	}
+	execConfig.Shell.(*shellmocks.Interface).AssertCalled(t, "Exec", "helm verify /tmp/charts/chart1-0.1.0.tgz --keyring /tmp/pubring.gpg",
+		mock.AnythingOfType("shell.ExecOptions"))
+}
+
+// writeTestKeyring will write the public keyring of a new signing key, returning the key
+func writeTestKeyring(t *testing.T, keyringPath string) *openpgp.Entity {
+	entity, err := openpgp.NewEntity("loftsman-tests", "", "loftsman-tests@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := os.Create(keyringPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keyring.Close()
+	if err = entity.Serialize(keyring); err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
+
+func TestSDKVerifyChart(t *testing.T) {
+	directory := t.TempDir()
+	writeTestChartPackage(t, directory, "chart1-0.1.0.tgz", "chart1", "0.1.0", "")
+	chartPath := filepath.Join(directory, "chart1-0.1.0.tgz")
+	signer := writeTestKeyring(t, filepath.Join(directory, "signer.gpg"))
+	writeTestKeyring(t, filepath.Join(directory, "other.gpg"))
+	signature, err := (&provenance.Signatory{Entity: signer}).ClearSign(chartPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(chartPath+".prov", []byte(signature), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := &SDK{}
+	if err = s.VerifyChart(chartPath, filepath.Join(directory, "signer.gpg")); err != nil {
+		t.Errorf("Got unexpected error from helm.TestSDKVerifyChart(): %s", err)
+	}
+	if err = s.VerifyChart(chartPath, filepath.Join(directory, "other.gpg")); err == nil {
+		t.Errorf("Didn't get expected error for a keyring without the signing key from helm.TestSDKVerifyChart()")
+	}
+	// a chart changed after it was signed no longer matches the checksum in its .prov file
+	writeTestChartPackage(t, directory, "chart1-0.1.0.tgz", "chart1", "0.1.0", "replicas: 3")
+	if err = s.VerifyChart(chartPath, filepath.Join(directory, "signer.gpg")); err == nil {
+		t.Errorf("Didn't get expected error for a changed chart from helm.TestSDKVerifyChart()")
+	}
+}
@@ -0,0 +1,443 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/postrender"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// defaultSDKTimeout is how long to wait for Kubernetes operations when a release has no timeout, the same as the helm
+// binary's default
+const defaultSDKTimeout = 5 * time.Minute
+
+// SDK is our object for running helm operations with the Helm Go SDK rather than the helm binary, so that no helm
+// binary is needed, implements internal/interfaces/helm.go
+type SDK struct {
+	ExecConfig   *interfaces.HelmExecConfig
+	ChartsSource *interfaces.HelmChartsSource
+	repos        *sdkRepos // shared with the objects from ForChartsSource
+}
+
+// sdkRepos are the repos added with AddRepo, kept in memory rather than in Helm's repo config
+type sdkRepos struct {
+	mutex   sync.Mutex
+	sources map[string]*interfaces.HelmChartsSource
+}
+
+// Initialize will set our instance up with necessary config/settings and run some initial validation as well
+func (s *SDK) Initialize(execConfig *interfaces.HelmExecConfig, chartsSource *interfaces.HelmChartsSource) error {
+	s.ExecConfig = execConfig
+	s.ChartsSource = chartsSource
+	if s.repos == nil {
+		s.repos = &sdkRepos{sources: make(map[string]*interfaces.HelmChartsSource)}
+	}
+	return validateChartsSource(s.ChartsSource)
+}
+
+// ForChartsSource will return a new SDK object for the charts of a charts source, with the same exec config and added
+// repos. This one is left as it is, so that charts from different sources can be resolved and released in parallel
+func (s *SDK) ForChartsSource(chartsSource *interfaces.HelmChartsSource) (interfaces.Helm, error) {
+	if err := validateChartsSource(chartsSource); err != nil {
+		return nil, err
+	}
+	return &SDK{ExecConfig: s.ExecConfig, ChartsSource: chartsSource, repos: s.repos}, nil
+}
+
+// GetAvailableChartVersions will return a list of available versions for a given chart according to our charts source
+func (s *SDK) GetAvailableChartVersions(chartName string) ([]*interfaces.HelmAvailableChartVersion, error) {
+	return getAvailableChartVersions(s.ChartsSource, chartName)
+}
+
+// ValidateChartsDirectory will index the packaged charts in a directory, returning warnings about packages that were
+// skipped or that duplicate or conflict with another package of the same chart version
+func (s *SDK) ValidateChartsDirectory(directory string) ([]string, error) {
+	index, err := getChartsDirectoryIndex(directory)
+	if err != nil {
+		return nil, err
+	}
+	return index.warnings, nil
+}
+
+// GetReleaseStatus attempts to retrieve the status of a chart release, the error is interfaces.ErrReleaseNotFound if the
+// release isn't installed
+func (s *SDK) GetReleaseStatus(chartName string, chartNamespace string) (*interfaces.HelmReleaseStatus, error) {
+	actionConfig, err := s.getActionConfig(chartNamespace)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := action.NewStatus(actionConfig).Run(chartName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, fmt.Errorf("%w: %s in namespace %s", interfaces.ErrReleaseNotFound, chartName, chartNamespace)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return getReleaseStatus(rel), nil
+}
+
+// GetExecConfig returns the existing ExecConfig
+func (s *SDK) GetExecConfig() *interfaces.HelmExecConfig {
+	return s.ExecConfig
+}
+
+// AddRepo will add a credentialed chart repo, so that its charts can be released as <repo name>/<chart name>
+func (s *SDK) AddRepo(chartsSource *interfaces.HelmChartsSource) error {
+	if s.repos == nil {
+		s.repos = &sdkRepos{sources: make(map[string]*interfaces.HelmChartsSource)}
+	}
+	s.repos.mutex.Lock()
+	defer s.repos.mutex.Unlock()
+	s.repos.sources[chartsSource.RepoName] = chartsSource
+	return nil
+}
+
+// RemoveRepo will remove a chart repo added with AddRepo
+func (s *SDK) RemoveRepo(repoName string) error {
+	if s.repos == nil {
+		return nil
+	}
+	s.repos.mutex.Lock()
+	defer s.repos.mutex.Unlock()
+	delete(s.repos.sources, repoName)
+	return nil
+}
+
+// Upgrade will install a release, or upgrade it if it's already installed
+func (s *SDK) Upgrade(options *interfaces.HelmReleaseOptions) (*interfaces.HelmReleaseResult, error) {
+	actionConfig, err := s.getActionConfig(options.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	chrt, vals, timeout, err := s.loadRelease(options)
+	if err != nil {
+		return nil, err
+	}
+	postRenderer, err := getPostRenderer(options)
+	if err != nil {
+		return nil, err
+	}
+	history := action.NewHistory(actionConfig)
+	history.Max = 1
+	var rel *release.Release
+	if _, err = history.Run(options.ReleaseName); errors.Is(err, driver.ErrReleaseNotFound) {
+		install := action.NewInstall(actionConfig)
+		install.ReleaseName = options.ReleaseName
+		install.Namespace = options.Namespace
+		install.CreateNamespace = options.CreateNamespace
+		install.Timeout = timeout
+		install.Wait = options.Wait
+		install.WaitForJobs = options.WaitForJobs
+		install.Atomic = options.Atomic
+		install.DisableHooks = options.DisableHooks
+		install.SkipCRDs = options.SkipCRDs
+		install.Description = options.Description
+		install.PostRenderer = postRenderer
+		install.DisableOpenAPIValidation = hasExtraArg(options, "--disable-openapi-validation")
+		install.SubNotes = hasExtraArg(options, "--render-subchart-notes")
+		rel, err = install.Run(chrt, vals)
+	} else if err == nil {
+		upgrade := action.NewUpgrade(actionConfig)
+		upgrade.Namespace = options.Namespace
+		upgrade.Timeout = timeout
+		upgrade.Wait = options.Wait
+		upgrade.WaitForJobs = options.WaitForJobs
+		upgrade.Atomic = options.Atomic
+		upgrade.Force = options.Force
+		upgrade.DisableHooks = options.DisableHooks
+		upgrade.SkipCRDs = options.SkipCRDs
+		upgrade.CleanupOnFail = options.CleanupOnFail
+		upgrade.MaxHistory = options.MaxHistory
+		upgrade.Description = options.Description
+		upgrade.PostRenderer = postRenderer
+		upgrade.DisableOpenAPIValidation = hasExtraArg(options, "--disable-openapi-validation")
+		upgrade.SubNotes = hasExtraArg(options, "--render-subchart-notes")
+		upgrade.ResetValues = hasExtraArg(options, "--reset-values")
+		rel, err = upgrade.Run(options.ReleaseName, chrt, vals)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := &interfaces.HelmReleaseResult{
+		ReleaseName: rel.Name,
+		Namespace:   rel.Namespace,
+		Revision:    rel.Version,
+	}
+	output := fmt.Sprintf("NAME: %s\nNAMESPACE: %s\nREVISION: %d", rel.Name, rel.Namespace, rel.Version)
+	if rel.Info != nil {
+		result.Status = rel.Info.Status.String()
+		output = fmt.Sprintf("%s\nSTATUS: %s\nLAST DEPLOYED: %s", output, result.Status, rel.Info.LastDeployed.Format(time.ANSIC))
+		if rel.Info.Notes != "" {
+			output = fmt.Sprintf("%s\nNOTES:\n%s", output, strings.TrimSpace(rel.Info.Notes))
+		}
+	}
+	result.Output = output
+	return result, nil
+}
+
+// Template will render the resources of a release the way Upgrade would install them, without hooks
+func (s *SDK) Template(options *interfaces.HelmReleaseOptions) (string, error) {
+	actionConfig, err := s.getActionConfig(options.Namespace)
+	if err != nil {
+		return "", err
+	}
+	chrt, vals, _, err := s.loadRelease(options)
+	if err != nil {
+		return "", err
+	}
+	postRenderer, err := getPostRenderer(options)
+	if err != nil {
+		return "", err
+	}
+	install := action.NewInstall(actionConfig)
+	install.ReleaseName = options.ReleaseName
+	install.Namespace = options.Namespace
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	install.DisableHooks = true
+	install.PostRenderer = postRenderer
+	rel, err := install.Run(chrt, vals)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(rel.Manifest), nil
+}
+
+// getPostRenderer returns the post-renderer of a release, if it has one
+func getPostRenderer(options *interfaces.HelmReleaseOptions) (postrender.PostRenderer, error) {
+	if options.PostRenderer == "" {
+		return nil, nil
+	}
+	postRenderer, err := postrender.NewExec(options.PostRenderer)
+	if err != nil {
+		return nil, fmt.Errorf("Error finding post-renderer %s: %s", options.PostRenderer, err)
+	}
+	return postRenderer, nil
+}
+
+// hasExtraArg returns whether a release has one of the HelmExtraArgs
+func hasExtraArg(options *interfaces.HelmReleaseOptions, extraArg string) bool {
+	for _, arg := range options.ExtraArgs {
+		if arg == extraArg {
+			return true
+		}
+	}
+	return false
+}
+
+// GetManifest will get the rendered resources of an installed release, or an empty manifest if it's not installed
+func (s *SDK) GetManifest(releaseName string, namespace string) (string, error) {
+	actionConfig, err := s.getActionConfig(namespace)
+	if err != nil {
+		return "", err
+	}
+	rel, err := action.NewGet(actionConfig).Run(releaseName)
+	if err != nil {
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(rel.Manifest), nil
+}
+
+// Uninstall will uninstall a release, optionally without running its hooks, the error is interfaces.ErrReleaseNotFound
+// if the release isn't installed
+func (s *SDK) Uninstall(releaseName string, namespace string, noHooks bool) error {
+	actionConfig, err := s.getActionConfig(namespace)
+	if err != nil {
+		return err
+	}
+	uninstall := action.NewUninstall(actionConfig)
+	uninstall.DisableHooks = noHooks
+	uninstall.Timeout = defaultSDKTimeout
+	_, err = uninstall.Run(releaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return fmt.Errorf("%w: %s in namespace %s", interfaces.ErrReleaseNotFound, releaseName, namespace)
+	}
+	return err
+}
+
+// Rollback will roll a release back to a previous revision
+func (s *SDK) Rollback(releaseName string, namespace string, revision int) error {
+	actionConfig, err := s.getActionConfig(namespace)
+	if err != nil {
+		return err
+	}
+	rollback := action.NewRollback(actionConfig)
+	rollback.Version = revision
+	rollback.Timeout = defaultSDKTimeout
+	return rollback.Run(releaseName)
+}
+
+// Test will run the tests of a release, its test hooks, for as long as the timeout, a go duration
+func (s *SDK) Test(releaseName string, namespace string, timeout string) (string, error) {
+	testTimeout, err := time.ParseDuration(timeout)
+	if err != nil {
+		return "", fmt.Errorf("Invalid timeout %s: %s", timeout, err)
+	}
+	actionConfig, err := s.getActionConfig(namespace)
+	if err != nil {
+		return "", err
+	}
+	releaseTesting := action.NewReleaseTesting(actionConfig)
+	releaseTesting.Namespace = namespace
+	releaseTesting.Timeout = testTimeout
+	rel, err := releaseTesting.Run(releaseName)
+	if rel == nil {
+		return "", err
+	}
+	// the same summary of the test hooks that `helm test` prints
+	output := []string{fmt.Sprintf("NAME: %s", rel.Name), fmt.Sprintf("NAMESPACE: %s", rel.Namespace)}
+	for _, hook := range rel.Hooks {
+		for _, event := range hook.Events {
+			if event == release.HookTest && hook.LastRun.Phase != release.HookPhaseUnknown {
+				output = append(output, fmt.Sprintf("TEST SUITE: %s", hook.Name), fmt.Sprintf("Phase: %s", hook.LastRun.Phase))
+			}
+		}
+	}
+	return strings.Join(output, "\n"), err
+}
+
+// getEnvSettings will return Helm SDK settings for our kubeconfig and context, the same as we'd pass to the helm binary
+func (s *SDK) getEnvSettings(namespace string) *cli.EnvSettings {
+	settings := cli.New()
+	if s.ExecConfig != nil && s.ExecConfig.KubeconfigPath != "" {
+		settings.KubeConfig = s.ExecConfig.KubeconfigPath
+	}
+	if s.ExecConfig != nil && s.ExecConfig.KubeContext != "" {
+		settings.KubeContext = s.ExecConfig.KubeContext
+	}
+	if configFlags, ok := settings.RESTClientGetter().(*genericclioptions.ConfigFlags); ok && namespace != "" {
+		configFlags.Namespace = &namespace
+	}
+	return settings
+}
+
+// getActionConfig will return the Helm SDK action config for operations on releases in a namespace
+func (s *SDK) getActionConfig(namespace string) (*action.Configuration, error) {
+	actionConfig := &action.Configuration{}
+	err := actionConfig.Init(s.getEnvSettings(namespace).RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"),
+		func(format string, v ...interface{}) {})
+	if err != nil {
+		return nil, fmt.Errorf("Error initializing the Helm SDK for namespace %s: %s", namespace, err)
+	}
+	return actionConfig, nil
+}
+
+// loadRelease will locate and load the chart, merge the values, and parse the timeout of release options
+func (s *SDK) loadRelease(options *interfaces.HelmReleaseOptions) (*chart.Chart, map[string]interface{}, time.Duration, error) {
+	settings := s.getEnvSettings(options.Namespace)
+	chartPathOptions := action.ChartPathOptions{Version: options.Version}
+	chartName := options.ChartPath
+	if parts := strings.SplitN(options.ChartPath, "/", 2); len(parts) == 2 && s.repos != nil {
+		s.repos.mutex.Lock()
+		repo, ok := s.repos.sources[parts[0]]
+		s.repos.mutex.Unlock()
+		if ok {
+			chartPathOptions.RepoURL = repo.Repo
+			chartPathOptions.Username = repo.RepoUsername
+			chartPathOptions.Password = repo.RepoPassword
+			chartName = parts[1]
+		}
+	}
+	chartPath, err := chartPathOptions.LocateChart(chartName, settings)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	chrt, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	valuesOptions := &values.Options{
+		ValueFiles: options.ValuesFiles,
+		Values:     options.SetValues,
+	}
+	vals, err := valuesOptions.MergeValues(getter.All(settings))
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	timeout := defaultSDKTimeout
+	if options.Timeout != "" {
+		if timeout, err = time.ParseDuration(options.Timeout); err != nil {
+			return nil, nil, 0, fmt.Errorf("Invalid timeout %s: %s", options.Timeout, err)
+		}
+	}
+	return chrt, vals, timeout, nil
+}
+
+// getReleaseStatus will convert a Helm SDK release to our minimal release status
+func getReleaseStatus(rel *release.Release) *interfaces.HelmReleaseStatus {
+	rs := &interfaces.HelmReleaseStatus{
+		Config:   rel.Config,
+		Revision: rel.Version,
+	}
+	if rel.Info != nil {
+		rs.Info = &interfaces.HelmReleaseStatusInfo{Status: rel.Info.Status.String()}
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		rs.Chart = &interfaces.HelmReleaseStatusChart{
+			Metadata: &interfaces.HelmReleaseStatusChartMetadata{
+				Name:    rel.Chart.Metadata.Name,
+				Version: rel.Chart.Metadata.Version,
+			},
+		}
+	}
+	return rs
+}
+
+// PullChart will download a chart from an OCI registry, an oci://<registry>/<repository>:<tag> path from
+// GetAvailableChartVersions, to a local directory, returning its local path and the digest of its OCI manifest. When a
+// digest is given, the chart's manifest must have that digest
+func (s *SDK) PullChart(chartPath string, digest string, destination string) (string, string, error) {
+	return pullOCIChart(s.ChartsSource, chartPath, digest, destination)
+}
+
+// PackageChart will build the dependencies of an unpackaged chart directory and package it to a destination directory,
+// returning the path of the packaged chart
+func (s *SDK) PackageChart(chartDirectory string, destination string) (string, error) {
+	settings := s.getEnvSettings("")
+	manager := &downloader.Manager{
+		Out:              ioutil.Discard,
+		ChartPath:        chartDirectory,
+		Getters:          getter.All(settings),
+		RepositoryConfig: settings.RepositoryConfig,
+		RepositoryCache:  settings.RepositoryCache,
+	}
+	if err := manager.Build(); err != nil {
+		return "", err
+	}
+	pkg := action.NewPackage()
+	pkg.Destination = destination
+	return pkg.Run(chartDirectory, nil)
+}
+
+// DownloadChart will download a chart from a chart repo, a url path from GetAvailableChartVersions, to a local directory,
+// returning its local path. With provenance, the chart's .prov file is downloaded next to it for VerifyChart
+func (s *SDK) DownloadChart(chartPath string, provenance bool, destination string) (string, error) {
+	return downloadChart(s.ChartsSource, chartPath, provenance, destination)
+}
+
+// VerifyChart will verify the signature in the .prov file next to a packaged chart against a keyring, and that the
+// chart matches the checksum it signs
+func (s *SDK) VerifyChart(chartPath string, keyring string) error {
+	_, err := downloader.VerifyChart(chartPath, keyring)
+	return err
+}
@@ -0,0 +1,155 @@
+package helm
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestSDKInitialize(t *testing.T) {
+	s := &SDK{}
+	// no helm binary should be needed
+	err := s.Initialize(&interfaces.HelmExecConfig{Binary: "not-a-helm-binary"}, &interfaces.HelmChartsSource{})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestSDKInitialize(): %s", err)
+	}
+}
+
+func TestSDKGetAvailableChartVersionsWithLocalPath(t *testing.T) {
+	s := &SDK{}
+	chartsPath := ".test-fixtures/charts"
+	if err := s.Initialize(&interfaces.HelmExecConfig{}, &interfaces.HelmChartsSource{Path: chartsPath}); err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestSDKGetAvailableChartVersionsWithLocalPath(): %s", err)
+		return
+	}
+	available, err := s.GetAvailableChartVersions("chart2")
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestSDKGetAvailableChartVersionsWithLocalPath(): %s", err)
+		return
+	}
+	if len(available) != 1 || available[0].Version != "0.2.0" || available[0].Path != filepath.Join(chartsPath, "chart2-0.2.0.tgz") {
+		t.Errorf("Didn't get expected available list from helm.TestSDKGetAvailableChartVersionsWithLocalPath(), got: %v", available)
+	}
+}
+
+func TestSDKAddRemoveRepo(t *testing.T) {
+	s := &SDK{}
+	if err := s.AddRepo(&interfaces.HelmChartsSource{RepoName: "secure", Repo: "https://charts.io", RepoUsername: "user",
+		RepoPassword: "pass"}); err != nil {
+		t.Errorf("Got unexpected error from helm.AddRepo() in helm.TestSDKAddRemoveRepo(): %s", err)
+		return
+	}
+	if _, ok := s.repos.sources["secure"]; !ok {
+		t.Errorf("Didn't find the added repo in helm.TestSDKAddRemoveRepo()")
+	}
+	forSource, err := s.ForChartsSource(&interfaces.HelmChartsSource{Repo: "https://other-charts.io"})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.ForChartsSource() in helm.TestSDKAddRemoveRepo(): %s", err)
+		return
+	}
+	if _, ok := forSource.(*SDK).repos.sources["secure"]; !ok || s.ChartsSource != nil {
+		t.Errorf("Didn't get expected shared repos and unchanged charts source from helm.ForChartsSource() in helm.TestSDKAddRemoveRepo()")
+	}
+	if err := s.RemoveRepo("secure"); err != nil {
+		t.Errorf("Got unexpected error from helm.RemoveRepo() in helm.TestSDKAddRemoveRepo(): %s", err)
+		return
+	}
+	if _, ok := s.repos.sources["secure"]; ok {
+		t.Errorf("Found the removed repo in helm.TestSDKAddRemoveRepo()")
+	}
+}
+
+func TestSDKTemplate(t *testing.T) {
+	s := &SDK{}
+	if err := s.Initialize(&interfaces.HelmExecConfig{}, &interfaces.HelmChartsSource{}); err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestSDKTemplate(): %s", err)
+		return
+	}
+	_, err := s.Template(&interfaces.HelmReleaseOptions{
+		ReleaseName: "release1",
+		Namespace:   "default",
+		ChartPath:   ".test-fixtures/charts/chart1-0.1.0.tgz",
+		// the fixture chart only has notes, which need these values to render
+		SetValues: []string{"global.chart.name=chart1", "global.chart.version=0.1.0", "ingress.enabled=false", "service.type=ClusterIP"},
+	})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestSDKTemplate(): %s", err)
+	}
+}
+
+func TestSDKTemplateInvalidTimeout(t *testing.T) {
+	s := &SDK{}
+	if err := s.Initialize(&interfaces.HelmExecConfig{}, &interfaces.HelmChartsSource{}); err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestSDKTemplateInvalidTimeout(): %s", err)
+		return
+	}
+	_, err := s.Template(&interfaces.HelmReleaseOptions{
+		ReleaseName: "release1",
+		Namespace:   "default",
+		ChartPath:   ".test-fixtures/charts/chart1-0.1.0.tgz",
+		Timeout:     "soon",
+	})
+	if err == nil || !strings.Contains(err.Error(), "Invalid timeout soon") {
+		t.Errorf("Didn't get expected error from helm.TestSDKTemplateInvalidTimeout(), instead got: %s", err)
+	}
+}
+
+func TestSDKTestInvalidTimeout(t *testing.T) {
+	s := &SDK{}
+	if err := s.Initialize(&interfaces.HelmExecConfig{}, &interfaces.HelmChartsSource{}); err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestSDKTestInvalidTimeout(): %s", err)
+		return
+	}
+	_, err := s.Test("release1", "default", "soon")
+	if err == nil || !strings.Contains(err.Error(), "Invalid timeout soon") {
+		t.Errorf("Didn't get expected error from helm.TestSDKTestInvalidTimeout(), instead got: %s", err)
+	}
+}
+
+func TestSDKTemplateChartNotFound(t *testing.T) {
+	s := &SDK{}
+	if err := s.Initialize(&interfaces.HelmExecConfig{}, &interfaces.HelmChartsSource{}); err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestSDKTemplateChartNotFound(): %s", err)
+		return
+	}
+	_, err := s.Template(&interfaces.HelmReleaseOptions{
+		ReleaseName: "release1",
+		Namespace:   "default",
+		ChartPath:   "./.test-fixtures/charts/chart3-0.3.0.tgz",
+	})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Didn't get expected error from helm.TestSDKTemplateChartNotFound(), instead got: %s", err)
+	}
+}
+
+func TestSDKPackageChart(t *testing.T) {
+	s := &SDK{}
+	if err := s.Initialize(&interfaces.HelmExecConfig{}, &interfaces.HelmChartsSource{}); err != nil {
+		t.Errorf("Got unexpected error from helm.Initialize() in helm.TestSDKPackageChart(): %s", err)
+		return
+	}
+	// unpack a fixture chart to a directory, like a chart checked out of a git repo
+	chart, err := loader.Load(".test-fixtures/charts/chart2-0.2.0.tgz")
+	if err != nil {
+		t.Errorf("Got unexpected error loading the fixture chart in helm.TestSDKPackageChart(): %s", err)
+		return
+	}
+	checkoutDirectory := t.TempDir()
+	if err = chartutil.SaveDir(chart, checkoutDirectory); err != nil {
+		t.Errorf("Got unexpected error unpacking the fixture chart in helm.TestSDKPackageChart(): %s", err)
+		return
+	}
+	destination := t.TempDir()
+	chartPath, err := s.PackageChart(filepath.Join(checkoutDirectory, "chart2"), destination)
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestSDKPackageChart(): %s", err)
+		return
+	}
+	if chartPath != filepath.Join(destination, "chart2-0.2.0.tgz") {
+		t.Errorf("Didn't get expected packaged chart path from helm.TestSDKPackageChart(), got: %s", chartPath)
+	}
+}
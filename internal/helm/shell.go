@@ -0,0 +1,53 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/Cray-HPE/go-lib/shell"
+)
+
+// Shell is the shell helm is run with, the go-lib shell that also implements interfaces.HelmArgsShell so that the
+// values from the manifest and settings are passed to helm as they are rather than split on spaces, and
+// interfaces.HelmStdinShell so that secrets can be passed to helm on stdin
+type Shell struct {
+	shell.Shell
+}
+
+// ExecArgs will run a command from its args, with its output and errors the same as the go-lib shell's Exec
+func (sh *Shell) ExecArgs(args []string, options shell.ExecOptions) (string, error) {
+	return sh.execArgs(args, os.Stdin, options)
+}
+
+// ExecArgsStdin will run a command from its args the same as ExecArgs, with the input given on its stdin
+func (sh *Shell) ExecArgsStdin(args []string, stdin string, options shell.ExecOptions) (string, error) {
+	return sh.execArgs(args, strings.NewReader(stdin), options)
+}
+
+func (sh *Shell) execArgs(args []string, stdin io.Reader, options shell.ExecOptions) (string, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = os.Environ()
+	cmd.Stdin = stdin
+	var output bytes.Buffer
+	if options.Silent {
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+	} else {
+		cmd.Stdout = io.MultiWriter(os.Stdout, &output)
+		cmd.Stderr = io.MultiWriter(os.Stderr, &output)
+	}
+	if err := cmd.Start(); err != nil {
+		return output.String(), err
+	}
+	if err := cmd.Wait(); err != nil {
+		return output.String(), fmt.Errorf("Shell error: %v", output.String())
+	}
+	if options.TrimOutput {
+		return strings.TrimSpace(output.String()), nil
+	}
+	return output.String(), nil
+}
@@ -0,0 +1,40 @@
+package helm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/go-lib/shell"
+)
+
+func TestShellExecArgs(t *testing.T) {
+	sh := &Shell{}
+	output, err := sh.ExecArgs([]string{"printf", "%s", testHostileDescription}, shell.ExecOptions{Silent: true})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestShellExecArgs(): %s", err)
+		return
+	}
+	if output != testHostileDescription {
+		t.Errorf("Didn't get expected output from helm.TestShellExecArgs(), instead got: %s", output)
+	}
+}
+
+func TestShellExecArgsStdin(t *testing.T) {
+	sh := &Shell{}
+	output, err := sh.ExecArgsStdin([]string{"cat"}, testHostileDescription, shell.ExecOptions{Silent: true})
+	if err != nil {
+		t.Errorf("Got unexpected error from helm.TestShellExecArgsStdin(): %s", err)
+		return
+	}
+	if output != testHostileDescription {
+		t.Errorf("Didn't get expected output from helm.TestShellExecArgsStdin(), instead got: %s", output)
+	}
+}
+
+func TestShellExecArgsError(t *testing.T) {
+	sh := &Shell{}
+	_, err := sh.ExecArgs([]string{"ls", "/loftsman-tests-does-not-exist"}, shell.ExecOptions{Silent: true, TrimOutput: true})
+	if err == nil || !strings.Contains(err.Error(), "Shell error:") {
+		t.Errorf("Didn't get expected error from helm.TestShellExecArgsError(), instead got: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Cray-HPE/loftsman/internal/kubernetes"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ShipHistoryEntry is the record of a single ship of a manifest, kept in the manifest's ship history configmap
+type ShipHistoryEntry struct {
+	ID             string     `json:"id"`
+	Manifest       string     `json:"manifest"`
+	StartTime      time.Time  `json:"startTime"`
+	EndTime        *time.Time `json:"endTime,omitempty"`
+	Status         string     `json:"status"`
+	User           string     `json:"user"`
+	Host           string     `json:"host"`
+	ManifestDigest string     `json:"manifestDigest"`
+	Partial        bool       `json:"partial,omitempty"` // only some of the manifest's charts were shipped, those in Charts
+	Charts         []string   `json:"charts,omitempty"`
+}
+
+// newShipHistoryEntry will return the history entry for a ship of a manifest starting now, from this user and host
+func newShipHistoryEntry(manifestName string, manifestContent []byte) *ShipHistoryEntry {
+	startTime := time.Now().UTC()
+	idSuffix := make([]byte, 4)
+	_, _ = rand.Read(idSuffix)
+	host, _ := os.Hostname()
+	return &ShipHistoryEntry{
+		ID:             fmt.Sprintf("%s-%x", startTime.Format("20060102T150405Z"), idSuffix),
+		Manifest:       manifestName,
+		StartTime:      startTime,
+		Status:         statusActive,
+		User:           getShipUser(),
+		Host:           host,
+		ManifestDigest: fmt.Sprintf("sha256:%x", sha256.Sum256(manifestContent)),
+	}
+}
+
+// getShipUser will return the name of the user running loftsman, as best as we can determine it
+func getShipUser() string {
+	if currentUser, err := user.Current(); err == nil && currentUser.Username != "" {
+		return currentUser.Username
+	}
+	return os.Getenv("USER")
+}
+
+// recordShipHistory will add or update an entry in the ship history configmap, each ship is its own key so that
+// entries from other ships are never touched
+func (loftsman *Loftsman) recordShipHistory(configMapName string, historyEntry *ShipHistoryEntry) {
+	historyEntryEncoded, err := json.Marshal(historyEntry)
+	if err == nil {
+		_, err = loftsman.kubernetes.PatchConfigMap(configMapName, loftsman.Settings.Namespace, map[string]string{
+			historyEntry.ID: string(historyEntryEncoded),
+		})
+	}
+	if err != nil {
+		loftsman.logger.Error().Err(fmt.Errorf("Error patching configmap %s with ship history to the %s namespace: %s",
+			configMapName, loftsman.Settings.Namespace, err)).Msg("")
+		fmt.Println("")
+	}
+}
+
+// trimShipHistory will remove the entries of the oldest ships from the ship history configmap, so that only those of
+// the latest ships, up to the history limit, are kept
+func (loftsman *Loftsman) trimShipHistory(configMapName string) {
+	configMap, err := loftsman.kubernetes.GetConfigMap(configMapName, loftsman.Settings.Namespace)
+	if err == nil && configMap != nil && len(configMap.Data) > loftsman.Settings.Ship.HistoryLimit {
+		shipIDs := []string{}
+		for shipID := range configMap.Data {
+			shipIDs = append(shipIDs, shipID)
+		}
+		// ship IDs start with the time of the ship, so they sort from the oldest to the latest
+		sort.Strings(shipIDs)
+		removedShipIDs := shipIDs[:len(shipIDs)-loftsman.Settings.Ship.HistoryLimit]
+		loftsman.logger.Info().Msgf("Removing %d of the oldest ships from the ship history in configmap %s, keeping the latest %d",
+			len(removedShipIDs), configMapName, loftsman.Settings.Ship.HistoryLimit)
+		_, err = loftsman.kubernetes.RemoveConfigMapKeys(configMapName, loftsman.Settings.Namespace, removedShipIDs)
+	}
+	if err != nil {
+		loftsman.logger.Error().Err(fmt.Errorf("Error removing the oldest ships from the ship history in configmap %s in the %s namespace: %s",
+			configMapName, loftsman.Settings.Namespace, err)).Msg("")
+		fmt.Println("")
+	}
+}
+
+// History will list the history of past ships stored in the cluster, for a single manifest or all of them
+func (loftsman *Loftsman) History() error {
+	var configMaps []v1.ConfigMap
+	if err := loftsman.Settings.ValidateHistoryOutput(); err != nil {
+		return loftsman.fail(err)
+	}
+
+	if loftsman.Settings.Manifest.Name != "" {
+		configMapName := fmt.Sprintf(historyConfigMapNameTemplate, loftsman.Settings.Manifest.Name)
+		configMap, err := loftsman.kubernetes.GetConfigMap(configMapName, loftsman.Settings.Namespace)
+		if err != nil {
+			return loftsman.fail(fmt.Errorf("Error getting the ship history of manifest %s: %s", loftsman.Settings.Manifest.Name, err))
+		}
+		if configMap != nil {
+			configMaps = append(configMaps, *configMap)
+		}
+	} else {
+		var err error
+		configMaps, err = loftsman.kubernetes.ListConfigMaps(loftsman.Settings.Namespace,
+			fmt.Sprintf("%s=%s", kubernetes.HistoryLabel, kubernetes.HistoryLabelValue))
+		if err != nil {
+			return loftsman.fail(fmt.Errorf("Error listing the ship history configmaps in namespace %s: %s", loftsman.Settings.Namespace, err))
+		}
+	}
+
+	historyEntries := []*ShipHistoryEntry{}
+	for _, configMap := range configMaps {
+		for key, value := range configMap.Data {
+			historyEntry := &ShipHistoryEntry{}
+			if err := json.Unmarshal([]byte(value), historyEntry); err != nil {
+				return loftsman.fail(fmt.Errorf("Error parsing ship history entry %s in configmap %s: %s", key, configMap.Name, err))
+			}
+			historyEntries = append(historyEntries, historyEntry)
+		}
+	}
+	sort.SliceStable(historyEntries, func(i, j int) bool {
+		if historyEntries[i].StartTime.Equal(historyEntries[j].StartTime) {
+			return historyEntries[i].ID < historyEntries[j].ID
+		}
+		return historyEntries[i].StartTime.Before(historyEntries[j].StartTime)
+	})
+
+	if loftsman.Settings.History.Output == "json" {
+		historyEncoded, err := json.MarshalIndent(historyEntries, "", "  ")
+		if err != nil {
+			return loftsman.fail(err)
+		}
+		fmt.Println(string(historyEncoded))
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(writer, "ID\tMANIFEST\tSTATUS\tSTARTED\tENDED\tUSER\tHOST\tMANIFEST DIGEST")
+	for _, historyEntry := range historyEntries {
+		endTime := "-"
+		if historyEntry.EndTime != nil {
+			endTime = historyEntry.EndTime.Local().Format(time.RFC3339)
+		}
+		status := historyEntry.Status
+		if historyEntry.Partial {
+			status = fmt.Sprintf("%s (partial: %s)", status, strings.Join(historyEntry.Charts, ","))
+		}
+		manifestDigest := historyEntry.ManifestDigest
+		if len(manifestDigest) > 19 {
+			manifestDigest = manifestDigest[:19]
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", historyEntry.ID, historyEntry.Manifest, status,
+			historyEntry.StartTime.Local().Format(time.RFC3339), endTime, historyEntry.User, historyEntry.Host, manifestDigest)
+	}
+	writer.Flush()
+	return nil
+}
@@ -0,0 +1,17 @@
+package interfaces
+
+import (
+	"github.com/Cray-HPE/go-lib/shell"
+)
+
+// GitExecConfig are settings/config related to running shell git commands
+type GitExecConfig struct {
+	Shell  shell.Interface
+	Binary string
+}
+
+// Git is an interface for a git command object instance
+type Git interface {
+	Initialize(execConfig *GitExecConfig) error
+	Checkout(location string, ref string, destination string) error
+}
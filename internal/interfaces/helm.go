@@ -1,9 +1,20 @@
 package interfaces
 
 import (
+	"errors"
+
 	"github.com/Cray-HPE/go-lib/shell"
 )
 
+// ErrReleaseNotFound is the error from getting the status of a release that isn't installed
+var ErrReleaseNotFound = errors.New("release not found")
+
+// Helm backends, how Helm operations are run
+const (
+	HelmBackendExec = "exec" // run the helm binary
+	HelmBackendSDK  = "sdk"  // use the Helm Go SDK, no helm binary needed
+)
+
 // HelmExecConfig are settings/config related to running shell helm commands
 type HelmExecConfig struct {
 	Shell          shell.Interface
@@ -12,6 +23,18 @@ type HelmExecConfig struct {
 	KubeContext    string
 }
 
+// HelmArgsShell is a shell that can run a command from its args as they are, rather than from a command string that's
+// split on spaces, so that args with spaces or quotes in them reach the command intact
+type HelmArgsShell interface {
+	ExecArgs(args []string, options shell.ExecOptions) (string, error)
+}
+
+// HelmStdinShell is a shell that can run a command from its args with input for it on stdin, so that secrets like a
+// charts repo password reach the command without being in its args, where anyone on the host can see them
+type HelmStdinShell interface {
+	ExecArgsStdin(args []string, stdin string, options shell.ExecOptions) (string, error)
+}
+
 // HelmChartsSource is an object storing config for where our Helm charts exist
 type HelmChartsSource struct {
 	RepoName     string
@@ -19,18 +42,22 @@ type HelmChartsSource struct {
 	RepoUsername string
 	RepoPassword string
 	Path         string
+	OCI          string // oci://<registry>/<path> where the charts are pushed to an OCI registry, each as <path>/<chart name>
 }
 
 // HelmAvailableChartVersion is a single version available for a chart
 type HelmAvailableChartVersion struct {
 	Path    string
 	Version string
+	Digest  string // the sha256:<hex> digest of the packaged chart, if the charts source knows it without pulling the chart
 }
 
 // HelmReleaseStatus represents a minimal representation of helm release status YAML output
 type HelmReleaseStatus struct {
-	Info     *HelmReleaseStatusInfo `yaml:"info"`
-	Revision int                    `yaml:"version"`
+	Info     *HelmReleaseStatusInfo  `yaml:"info"`
+	Chart    *HelmReleaseStatusChart `yaml:"chart"`
+	Config   map[string]interface{}  `yaml:"config"`
+	Revision int                     `yaml:"version"`
 }
 
 // HelmReleaseStatusInfo represents a minimal representation of helm release status YAML info status
@@ -38,11 +65,71 @@ type HelmReleaseStatusInfo struct {
 	Status string `yaml:"status"`
 }
 
+// HelmReleaseStatusChart represents a minimal representation of helm release status YAML chart
+type HelmReleaseStatusChart struct {
+	Metadata *HelmReleaseStatusChartMetadata `yaml:"metadata"`
+}
+
+// HelmReleaseStatusChartMetadata represents a minimal representation of helm release status YAML chart metadata
+type HelmReleaseStatusChartMetadata struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+}
+
+// HelmReleaseOptions are the options to install/upgrade, or render, a release of a chart with
+type HelmReleaseOptions struct {
+	ReleaseName  string
+	Namespace    string
+	ChartPath    string   // path or URL of a packaged chart, or <repo name>/<chart name> for a repo added with AddRepo
+	Version      string   // version of the chart, needed when ChartPath is from a repo added with AddRepo
+	SetValues    []string // individual values to set, each as key=value
+	ValuesFiles  []string // paths to values files
+	Timeout      string   // how long to wait for a release, a go duration
+	PostRenderer string   // path of an executable to pass the rendered resources through before they're released
+	// the Helm options of a release, only used to install/upgrade it
+	Wait            bool     // wait for the release's resources to be ready, for as long as the timeout
+	WaitForJobs     bool     // with Wait, also wait for the release's jobs to complete
+	Atomic          bool     // roll a failed upgrade back, or uninstall a failed install
+	Force           bool     // force resource updates by replacing them
+	DisableHooks    bool     // don't run the chart's hooks
+	SkipCRDs        bool     // don't install the chart's CRDs
+	CleanupOnFail   bool     // delete the new resources of a failed upgrade
+	MaxHistory      int      // the max number of revisions kept of the release, the backend's default if 0
+	CreateNamespace bool     // create the release's namespace if it doesn't exist
+	Description     string   // a description of the release revision
+	ExtraArgs       []string // extra Helm flags, each one of HelmExtraArgs
+}
+
+// HelmExtraArgs are the extra Helm flags a release can be installed/upgraded with that have no option of their own
+var HelmExtraArgs = []string{"--disable-openapi-validation", "--render-subchart-notes", "--reset-values"}
+
+// HelmReleaseResult is the outcome of installing/upgrading a release
+type HelmReleaseResult struct {
+	ReleaseName string
+	Namespace   string
+	Revision    int
+	Status      string
+	Output      string // human-readable summary of the release, including any chart notes
+}
+
 // Helm is an interface for a helm command object instance
 type Helm interface {
 	Initialize(execConfig *HelmExecConfig, chartsSource *HelmChartsSource) error
-	Exec(subCommand string) (string, error)
+	ForChartsSource(chartsSource *HelmChartsSource) (Helm, error)
 	GetAvailableChartVersions(chartName string) ([]*HelmAvailableChartVersion, error)
+	ValidateChartsDirectory(directory string) ([]string, error)
 	GetReleaseStatus(chartName string, chartNamespace string) (*HelmReleaseStatus, error)
 	GetExecConfig() *HelmExecConfig
+	AddRepo(chartsSource *HelmChartsSource) error
+	RemoveRepo(repoName string) error
+	Upgrade(options *HelmReleaseOptions) (*HelmReleaseResult, error)
+	Template(options *HelmReleaseOptions) (string, error)
+	GetManifest(releaseName string, namespace string) (string, error)
+	Uninstall(releaseName string, namespace string, noHooks bool) error
+	Rollback(releaseName string, namespace string, revision int) error
+	Test(releaseName string, namespace string, timeout string) (string, error)
+	PullChart(chartPath string, digest string, destination string) (string, string, error)
+	PackageChart(chartDirectory string, destination string) (string, error)
+	DownloadChart(chartPath string, provenance bool, destination string) (string, error)
+	VerifyChart(chartPath string, keyring string) error
 }
@@ -1,17 +1,34 @@
 package interfaces
 
 import (
+	"errors"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
 )
 
+// ErrLeaseNotHeld is the error from renewing a lease that no longer exists or is held by someone else
+var ErrLeaseNotHeld = errors.New("lease not held")
+
 // Kubernetes is the interface for a k8s api object instance
 type Kubernetes interface {
 	Initialize(kubeconfigPath string, kubeContext string) error
 	IsRetryError(err error) bool
 	EnsureNamespace(name string) error
 	FindConfigMap(name string, namespace string, withKey string, withValue string) (*v1.ConfigMap, error)
+	GetConfigMap(name string, namespace string) (*v1.ConfigMap, error)
 	InitializeShipConfigMap(name string, namespace string, data map[string]string) (*v1.ConfigMap, error)
 	InitializeLogConfigMap(name string, namespace string, data map[string]string) (*v1.ConfigMap, error)
+	InitializeHistoryConfigMap(name string, namespace string) (*v1.ConfigMap, error)
+	ListConfigMaps(namespace string, labelSelector string) ([]v1.ConfigMap, error)
 	PatchConfigMap(name string, namespace string, data map[string]string) (*v1.ConfigMap, error)
+	RemoveConfigMapKeys(name string, namespace string, keys []string) (*v1.ConfigMap, error)
+	AcquireLease(name string, namespace string, holderIdentity string, leaseDuration time.Duration) (*coordinationv1.Lease, bool, error)
+	RenewLease(name string, namespace string, holderIdentity string) (*coordinationv1.Lease, error)
+	ReleaseLease(name string, namespace string, holderIdentity string) error
 	GetSecretKeyValue(secretName string, namespace string, dataKey string) (string, error)
+	WaitForRollout(kind string, name string, namespace string, timeout time.Duration) error
+	WaitForJob(name string, namespace string, timeout time.Duration) error
+	WaitForCondition(apiVersion string, kind string, name string, namespace string, conditionType string, status string, timeout time.Duration) error
 }
@@ -12,12 +12,116 @@ type ManifestReleaseError struct {
 	Error     error
 }
 
+// Manifest chart result statuses, the outcome of releasing a single chart
+const (
+	ManifestChartStatusSuccess    = "success"
+	ManifestChartStatusFailed     = "failed"
+	ManifestChartStatusSkipped    = "skipped"
+	ManifestChartStatusRolledBack = "rolled-back"
+)
+
+// ManifestChartResult is the recorded outcome of releasing a single chart of a manifest
+type ManifestChartResult struct {
+	Chart       string `json:"chart"`
+	Version     string `json:"version"`
+	Namespace   string `json:"namespace"`
+	ReleaseName string `json:"releaseName"`
+	ValuesHash  string `json:"valuesHash"`
+	OptionsHash string `json:"optionsHash,omitempty"` // the hash of the Helm options and timeout the chart was released with, if it was
+	Status      string `json:"status"`
+	// the release revision the chart was rolled back to after failing to release, if it was rolled back
+	RolledBackToRevision int `json:"rolledBackToRevision,omitempty"`
+}
+
+// ManifestRelease is a Helm release owned by a manifest, identified by its release name and namespace
+type ManifestRelease struct {
+	ReleaseName string `json:"releaseName"`
+	Namespace   string `json:"namespace"`
+	OptionsHash string `json:"optionsHash,omitempty"` // the hash of the Helm options and timeout it was last released with, where recorded
+}
+
+// ManifestReleaseOptions are options for how a manifest release is run
+type ManifestReleaseOptions struct {
+	MaxConcurrency  int                                    // the max number of charts to release at the same time
+	ResumeFrom      []*ManifestChartResult                 // chart results of a previous release, charts that succeeded there with the same version and values are skipped
+	ReleasedOptions []*ManifestRelease                     // the options hash each release was last released with, for Plan to tell when the options of a release have changed
+	OnChartResult   func(chartResult *ManifestChartResult) // called as each chart finishes, possibly from several charts releasing at the same time
+	Cancelled       func() bool                            // checked before each chart is released, once it returns true no further charts are released
+}
+
+// ManifestChartSelection selects some of the enabled charts of a manifest to ship, rather than all of them
+type ManifestChartSelection struct {
+	Only     []string          // only the charts with these names
+	Skip     []string          // not the charts with these names
+	Selector map[string]string // only the charts with all of these labels
+}
+
+// Manifest plan actions, what a release of a chart would do to the cluster
+const (
+	ManifestPlanActionInstall   = "install"
+	ManifestPlanActionUpgrade   = "upgrade"
+	ManifestPlanActionReinstall = "reinstall"
+	ManifestPlanActionUnchanged = "unchanged"
+	ManifestPlanActionPrune     = "prune"
+)
+
+// ManifestPlanEntry is what a manifest release would do for a single chart, without doing it
+type ManifestPlanEntry struct {
+	Chart           string
+	Version         string
+	Namespace       string
+	ReleaseName     string
+	ChartPath       string
+	Action          string
+	CurrentVersion  string
+	CurrentRevision int
+}
+
+// ManifestDiffEntry is a single resource that differs between a live release and the chart rendered from the manifest
+type ManifestDiffEntry struct {
+	Chart       string
+	Version     string
+	Namespace   string
+	ReleaseName string
+	Resource    string
+	Diff        string
+}
+
+// ManifestLock is the exact version and digest each chart's version in a manifest resolved to, written to a lock file
+// so that later ships release exactly the same charts
+type ManifestLock struct {
+	Manifest string                 `yaml:"manifest" json:"manifest"`
+	Charts   []*ManifestLockedChart `yaml:"charts" json:"charts"`
+}
+
+// ManifestLockedChart is the exact version and digest a single chart's version in a manifest resolved to
+type ManifestLockedChart struct {
+	Chart       string `yaml:"chart" json:"chart"`
+	ReleaseName string `yaml:"releaseName" json:"releaseName"`
+	Namespace   string `yaml:"namespace" json:"namespace"`
+	Constraint  string `yaml:"constraint" json:"constraint"` // the version as written in the manifest, an exact version or a constraint
+	Version     string `yaml:"version" json:"version"`
+	Digest      string `yaml:"digest,omitempty" json:"digest,omitempty"` // the sha256:<hex> digest of the chart, if its source has one
+}
+
 // Manifest is the interface for all manifest schema versions
 type Manifest interface {
 	GetName() string
+	GetReleases() []*ManifestRelease
+	GetPrune() bool
 	Create(initializeCharts []string) (string, error)
 	Load(manifestContent string) error
 	SetLogger(log *logger.Logger)
 	SetTempDirectory(tempDirectory string)
+	SetDirectory(directory string)
+	SetGit(git Git)
+	SetSops(sops Sops)
+	SetReleaseOptions(releaseOptions *ManifestReleaseOptions)
+	SelectCharts(selection *ManifestChartSelection) ([]string, error)
+	ApplyLock(lock *ManifestLock) error
+	ValidateSpec() error
 	Release(kubernetes Kubernetes, helm Helm) []*ManifestReleaseError
+	Plan(kubernetes Kubernetes, helm Helm) ([]*ManifestPlanEntry, []*ManifestReleaseError)
+	Diff(kubernetes Kubernetes, helm Helm) ([]*ManifestDiffEntry, []*ManifestReleaseError)
+	Lock(kubernetes Kubernetes, helm Helm) (*ManifestLock, []*ManifestReleaseError)
 }
@@ -0,0 +1,13 @@
+package interfaces
+
+// SopsExecConfig are settings/config related to running sops to decrypt encrypted chart values
+type SopsExecConfig struct {
+	Binary     string
+	AgeKeyFile string // the age key file to decrypt with, otherwise sops finds the age key itself, e.g. in SOPS_AGE_KEY
+}
+
+// Sops is an interface for a sops command object instance
+type Sops interface {
+	Initialize(execConfig *SopsExecConfig) error
+	Decrypt(content []byte) ([]byte, error)
+}
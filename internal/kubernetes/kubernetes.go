@@ -6,12 +6,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	k8s "k8s.io/client-go/kubernetes"
 
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/retry"
@@ -35,11 +44,22 @@ import (
 	// by default in the client-go default usage itself
 )
 
+const (
+	// HistoryLabel is the label key set on ship history configmaps
+	HistoryLabel = "loftsman.io/record"
+	// HistoryLabelValue is the HistoryLabel value set on ship history configmaps
+	HistoryLabelValue = "ship-history"
+)
+
 // Kubernetes is our k8s client object, implements internal/interfaces/kubernetes.go
 type Kubernetes struct {
-	client *k8s.Clientset
+	client        *k8s.Clientset
+	dynamicClient dynamic.Interface // for resources of any kind, like custom resources
 }
 
+// waitPollInterval is how often the Wait* methods check on the resource they're waiting for
+var waitPollInterval = 2 * time.Second
+
 // Initialize will set up our object for connection to the desired cluster, and test that connection
 func (k *Kubernetes) Initialize(kubeconfigPath string, kubeContext string) error {
 	var err error
@@ -60,6 +80,10 @@ func (k *Kubernetes) Initialize(kubeconfigPath string, kubeContext string) error
 	if err != nil {
 		return fmt.Errorf("could not set up Kubernetes client: %s", err)
 	}
+	k.dynamicClient, err = dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("could not set up Kubernetes client: %s", err)
+	}
 	if _, err := k.client.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{}); err != nil {
 		return fmt.Errorf("error attempting to list namespaces in the cluster, are you sure you have your kubeconfig connected to an active cluster? %s", err)
 	}
@@ -133,6 +157,21 @@ func (k *Kubernetes) FindConfigMap(name string, namespace string, withKey string
 	return result, err
 }
 
+// GetConfigMap will get a configmap by name in a namespace, returning nil if it doesn't exist
+func (k *Kubernetes) GetConfigMap(name string, namespace string) (*v1.ConfigMap, error) {
+	var err error
+	var result *v1.ConfigMap
+	err = retry.OnError(retry.DefaultBackoff, k.IsRetryError, func() error {
+		result, err = k.client.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			result = nil
+			return nil
+		}
+		return err
+	})
+	return result, err
+}
+
 // InitializeShipConfigMap will ensure a configmap exists by name, in a namespace, with data. If an existing configmap
 // is found and it is presisting previous data, then remove any previous data in the new version of the configmap
 func (k *Kubernetes) InitializeShipConfigMap(name string, namespace string, data map[string]string) (*v1.ConfigMap, error) {
@@ -163,7 +202,7 @@ func (k *Kubernetes) InitializeShipConfigMap(name string, namespace string, data
 		patchData := map[string]interface{}{
 			"metadata": map[string]interface{}{
 				"annotations": map[string]interface{}{
-					"loftsman.io/previous-data": nil,
+					"loftsman.io/previous-data":      nil,
 					"loftsman.io/ship-log-configmap": logConfigMapName,
 				},
 			},
@@ -213,6 +252,47 @@ func (k *Kubernetes) InitializeLogConfigMap(name string, namespace string, data
 	return result, err
 }
 
+// InitializeHistoryConfigMap will ensure a configmap exists by name, in a namespace, labeled as a ship history record
+// so it can be found with ListConfigMaps. If an existing configmap is found then it will not be modified
+func (k *Kubernetes) InitializeHistoryConfigMap(name string, namespace string) (*v1.ConfigMap, error) {
+	var err error
+	var result *v1.ConfigMap
+	err = retry.OnError(retry.DefaultBackoff, k.IsRetryError, func() error {
+		result, err = k.client.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			labels := k.getCommonLabels()
+			labels[HistoryLabel] = HistoryLabelValue
+			result, err = k.client.CoreV1().ConfigMaps(namespace).Create(context.Background(), &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+					Labels:    labels,
+				},
+				Data: make(map[string]string),
+			}, metav1.CreateOptions{})
+		}
+		return err
+	})
+	return result, err
+}
+
+// ListConfigMaps will list the configmaps in a namespace matching a label selector
+func (k *Kubernetes) ListConfigMaps(namespace string, labelSelector string) ([]v1.ConfigMap, error) {
+	var err error
+	var result []v1.ConfigMap
+	err = retry.OnError(retry.DefaultBackoff, k.IsRetryError, func() error {
+		list, err := k.client.CoreV1().ConfigMaps(namespace).List(context.Background(), metav1.ListOptions{
+			LabelSelector: labelSelector,
+		})
+		if err != nil {
+			return err
+		}
+		result = list.Items
+		return nil
+	})
+	return result, err
+}
+
 // PatchConfigMap will patch an existing configmap with the StrategicMergePatchType
 func (k *Kubernetes) PatchConfigMap(name string, namespace string, data map[string]string) (*v1.ConfigMap, error) {
 	var err error
@@ -231,6 +311,155 @@ func (k *Kubernetes) PatchConfigMap(name string, namespace string, data map[stri
 	return result, err
 }
 
+// RemoveConfigMapKeys will remove keys from the data of a configmap by name, in a namespace, leaving its other keys
+// alone
+func (k *Kubernetes) RemoveConfigMapKeys(name string, namespace string, keys []string) (*v1.ConfigMap, error) {
+	var err error
+	var result *v1.ConfigMap
+	removedData := make(map[string]interface{})
+	for _, key := range keys {
+		removedData[key] = nil
+	}
+	patchData, err := json.Marshal(map[string]interface{}{
+		"data": removedData,
+	})
+	if err != nil {
+		return result, err
+	}
+	err = retry.OnError(retry.DefaultBackoff, k.IsRetryError, func() error {
+		result, err = k.client.CoreV1().ConfigMaps(namespace).Patch(context.Background(), name,
+			types.StrategicMergePatchType, []byte(patchData), metav1.PatchOptions{})
+		return err
+	})
+	return result, err
+}
+
+// AcquireLease will take a lease by name, in a namespace, for a holder identity. A lease that doesn't exist, has been
+// released, has expired, or is already held by the holder is taken, otherwise the lease is left alone and returned
+// along with false so the caller can see who holds it
+func (k *Kubernetes) AcquireLease(name string, namespace string, holderIdentity string, leaseDuration time.Duration) (*coordinationv1.Lease, bool, error) {
+	var err error
+	var result *coordinationv1.Lease
+	var acquired bool
+	err = retry.OnError(retry.DefaultBackoff, k.IsRetryError, func() error {
+		now := metav1.NewMicroTime(time.Now())
+		leaseDurationSeconds := int32(leaseDuration.Seconds())
+		result, err = k.client.CoordinationV1().Leases(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			result, err = k.client.CoordinationV1().Leases(namespace).Create(context.Background(), &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+					Labels:    k.getCommonLabels(),
+				},
+				Spec: coordinationv1.LeaseSpec{
+					HolderIdentity:       &holderIdentity,
+					LeaseDurationSeconds: &leaseDurationSeconds,
+					AcquireTime:          &now,
+					RenewTime:            &now,
+				},
+			}, metav1.CreateOptions{})
+			if kerrors.IsAlreadyExists(err) {
+				// another holder created it first, get it again to see who that is
+				return kerrors.NewConflict(coordinationv1.Resource("leases"), name, err)
+			}
+			acquired = err == nil
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		currentHolderIdentity := ""
+		if result.Spec.HolderIdentity != nil {
+			currentHolderIdentity = *result.Spec.HolderIdentity
+		}
+		if currentHolderIdentity != "" && currentHolderIdentity != holderIdentity && !IsLeaseExpired(result, now.Time) {
+			acquired = false
+			return nil
+		}
+		if currentHolderIdentity != holderIdentity {
+			var leaseTransitions int32
+			if result.Spec.LeaseTransitions != nil {
+				leaseTransitions = *result.Spec.LeaseTransitions
+			}
+			leaseTransitions++
+			result.Spec.LeaseTransitions = &leaseTransitions
+			result.Spec.AcquireTime = &now
+		}
+		result.Spec.HolderIdentity = &holderIdentity
+		result.Spec.LeaseDurationSeconds = &leaseDurationSeconds
+		result.Spec.RenewTime = &now
+		// the update carries the resource version we read, so if anyone else took the lease in the meantime we'll get a
+		// conflict and read it again
+		result, err = k.client.CoordinationV1().Leases(namespace).Update(context.Background(), result, metav1.UpdateOptions{})
+		acquired = err == nil
+		return err
+	})
+	return result, acquired, err
+}
+
+// RenewLease will renew a lease held by a holder identity, failing if the lease is no longer held by it
+func (k *Kubernetes) RenewLease(name string, namespace string, holderIdentity string) (*coordinationv1.Lease, error) {
+	var err error
+	var result *coordinationv1.Lease
+	err = retry.OnError(retry.DefaultBackoff, k.IsRetryError, func() error {
+		result, err = k.client.CoordinationV1().Leases(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			return fmt.Errorf("%w, lease %s no longer exists", interfaces.ErrLeaseNotHeld, name)
+		}
+		if err != nil {
+			return err
+		}
+		if result.Spec.HolderIdentity == nil || *result.Spec.HolderIdentity != holderIdentity {
+			return fmt.Errorf("%w, lease %s is no longer held by %s", interfaces.ErrLeaseNotHeld, name, holderIdentity)
+		}
+		now := metav1.NewMicroTime(time.Now())
+		result.Spec.RenewTime = &now
+		result, err = k.client.CoordinationV1().Leases(namespace).Update(context.Background(), result, metav1.UpdateOptions{})
+		return err
+	})
+	return result, err
+}
+
+// ReleaseLease will delete a lease if it's held by a holder identity, or no matter who holds it if the holder identity
+// is empty. A lease that doesn't exist or is held by someone else is left alone
+func (k *Kubernetes) ReleaseLease(name string, namespace string, holderIdentity string) error {
+	var err error
+	err = retry.OnError(retry.DefaultBackoff, k.IsRetryError, func() error {
+		lease, err := k.client.CoordinationV1().Leases(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if holderIdentity != "" && (lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != holderIdentity) {
+			return nil
+		}
+		err = k.client.CoordinationV1().Leases(namespace).Delete(context.Background(), name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+		})
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
+	return err
+}
+
+// IsLeaseExpired will determine whether a lease has gone longer than its duration without being renewed
+func IsLeaseExpired(lease *coordinationv1.Lease, now time.Time) bool {
+	renewTime := lease.Spec.RenewTime
+	if renewTime == nil {
+		renewTime = lease.Spec.AcquireTime
+	}
+	if renewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return renewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second).Before(now)
+}
+
 // GetSecretKeyValue will retrieve a particular data key from a secret
 func (k *Kubernetes) GetSecretKeyValue(secretName string, namespace string, dataKey string) (string, error) {
 	var err error
@@ -246,3 +475,212 @@ func (k *Kubernetes) GetSecretKeyValue(secretName string, namespace string, data
 	})
 	return result, err
 }
+
+// waitFor will poll check until it's done, it errors, or the timeout passes. Errors that suggest retrying, like the
+// resource not being found yet, are polled through. The timeout error includes why check was last still waiting
+func (k *Kubernetes) waitFor(description string, timeout time.Duration, check func() (bool, string, error)) error {
+	waitingReason := "not checked yet"
+	err := wait.PollImmediate(waitPollInterval, timeout, func() (bool, error) {
+		done, reason, err := check()
+		if err != nil && k.IsRetryError(err) {
+			waitingReason = err.Error()
+			return false, nil
+		}
+		waitingReason = reason
+		return done, err
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("timed out after %s waiting for %s: %s", timeout, description, waitingReason)
+	}
+	if err != nil {
+		return fmt.Errorf("error waiting for %s: %s", description, err)
+	}
+	return nil
+}
+
+// WaitForRollout will wait until a Deployment, StatefulSet or DaemonSet has rolled out, the same way
+// `kubectl rollout status` would
+func (k *Kubernetes) WaitForRollout(kind string, name string, namespace string, timeout time.Duration) error {
+	var check func() (bool, string, error)
+	switch kind {
+	case "Deployment":
+		check = func() (bool, string, error) {
+			deployment, err := k.client.AppsV1().Deployments(namespace).Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				return false, "", err
+			}
+			return getDeploymentRolloutStatus(deployment)
+		}
+	case "StatefulSet":
+		check = func() (bool, string, error) {
+			statefulSet, err := k.client.AppsV1().StatefulSets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				return false, "", err
+			}
+			done, reason := getStatefulSetRolloutStatus(statefulSet)
+			return done, reason, nil
+		}
+	case "DaemonSet":
+		check = func() (bool, string, error) {
+			daemonSet, err := k.client.AppsV1().DaemonSets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				return false, "", err
+			}
+			done, reason := getDaemonSetRolloutStatus(daemonSet)
+			return done, reason, nil
+		}
+	default:
+		return fmt.Errorf("can't wait for a %s to roll out, only a Deployment, StatefulSet or DaemonSet", kind)
+	}
+	return k.waitFor(fmt.Sprintf("%s %s/%s to roll out", kind, namespace, name), timeout, check)
+}
+
+func getDeploymentRolloutStatus(deployment *appsv1.Deployment) (bool, string, error) {
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return false, "the latest spec hasn't been observed yet", nil
+	}
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded" {
+			return false, "", fmt.Errorf("deployment %s exceeded its progress deadline", deployment.Name)
+		}
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	switch {
+	case deployment.Status.UpdatedReplicas < replicas:
+		return false, fmt.Sprintf("%d of %d replicas have been updated", deployment.Status.UpdatedReplicas, replicas), nil
+	case deployment.Status.Replicas > deployment.Status.UpdatedReplicas:
+		return false, fmt.Sprintf("%d old replicas are pending termination", deployment.Status.Replicas-deployment.Status.UpdatedReplicas), nil
+	case deployment.Status.AvailableReplicas < deployment.Status.UpdatedReplicas:
+		return false, fmt.Sprintf("%d of %d updated replicas are available", deployment.Status.AvailableReplicas, deployment.Status.UpdatedReplicas), nil
+	}
+	return true, "", nil
+}
+
+func getStatefulSetRolloutStatus(statefulSet *appsv1.StatefulSet) (bool, string) {
+	if statefulSet.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		// pods are only updated as they're deleted, so there's no rollout to wait for
+		return true, ""
+	}
+	if statefulSet.Generation > statefulSet.Status.ObservedGeneration {
+		return false, "the latest spec hasn't been observed yet"
+	}
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	if statefulSet.Status.ReadyReplicas < replicas {
+		return false, fmt.Sprintf("%d of %d replicas are ready", statefulSet.Status.ReadyReplicas, replicas)
+	}
+	rollingUpdate := statefulSet.Spec.UpdateStrategy.RollingUpdate
+	if rollingUpdate != nil && rollingUpdate.Partition != nil && *rollingUpdate.Partition > 0 {
+		if updated := replicas - *rollingUpdate.Partition; statefulSet.Status.UpdatedReplicas < updated {
+			return false, fmt.Sprintf("%d of %d replicas of the partition have been updated", statefulSet.Status.UpdatedReplicas, updated)
+		}
+		return true, ""
+	}
+	if statefulSet.Status.UpdateRevision != statefulSet.Status.CurrentRevision {
+		return false, fmt.Sprintf("%d of %d replicas have been updated", statefulSet.Status.UpdatedReplicas, replicas)
+	}
+	return true, ""
+}
+
+func getDaemonSetRolloutStatus(daemonSet *appsv1.DaemonSet) (bool, string) {
+	if daemonSet.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType {
+		// pods are only updated as they're deleted, so there's no rollout to wait for
+		return true, ""
+	}
+	if daemonSet.Generation > daemonSet.Status.ObservedGeneration {
+		return false, "the latest spec hasn't been observed yet"
+	}
+	if daemonSet.Status.UpdatedNumberScheduled < daemonSet.Status.DesiredNumberScheduled {
+		return false, fmt.Sprintf("%d of %d pods have been updated", daemonSet.Status.UpdatedNumberScheduled, daemonSet.Status.DesiredNumberScheduled)
+	}
+	if daemonSet.Status.NumberAvailable < daemonSet.Status.DesiredNumberScheduled {
+		return false, fmt.Sprintf("%d of %d updated pods are available", daemonSet.Status.NumberAvailable, daemonSet.Status.DesiredNumberScheduled)
+	}
+	return true, ""
+}
+
+// WaitForJob will wait until a Job has completed, erroring as soon as it's failed
+func (k *Kubernetes) WaitForJob(name string, namespace string, timeout time.Duration) error {
+	return k.waitFor(fmt.Sprintf("Job %s/%s to complete", namespace, name), timeout, func() (bool, string, error) {
+		job, err := k.client.BatchV1().Jobs(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return false, "", err
+		}
+		for _, condition := range job.Status.Conditions {
+			if condition.Status != v1.ConditionTrue {
+				continue
+			}
+			if condition.Type == batchv1.JobComplete {
+				return true, "", nil
+			}
+			if condition.Type == batchv1.JobFailed {
+				return false, "", fmt.Errorf("job %s failed: %s", name, condition.Message)
+			}
+		}
+		return false, fmt.Sprintf("%d pods are active, %d have succeeded and %d have failed", job.Status.Active,
+			job.Status.Succeeded, job.Status.Failed), nil
+	})
+}
+
+// WaitForCondition will wait until a resource of any kind, like a custom resource, has a status condition of a type
+// with the given status. The namespace is ignored for a cluster-scoped kind
+func (k *Kubernetes) WaitForCondition(apiVersion string, kind string, name string, namespace string, conditionType string,
+	status string, timeout time.Duration) error {
+	groupVersion, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return fmt.Errorf("invalid apiVersion %s: %s", apiVersion, err)
+	}
+	var resource dynamic.ResourceInterface
+	err = retry.OnError(retry.DefaultBackoff, k.IsRetryError, func() error {
+		resourceList, err := k.client.Discovery().ServerResourcesForGroupVersion(apiVersion)
+		if err != nil {
+			return err
+		}
+		for _, apiResource := range resourceList.APIResources {
+			// subresources like deployments/status have the kind of their resource
+			if apiResource.Kind != kind || strings.Contains(apiResource.Name, "/") {
+				continue
+			}
+			namespaceableResource := k.dynamicClient.Resource(groupVersion.WithResource(apiResource.Name))
+			resource = namespaceableResource
+			if apiResource.Namespaced {
+				resource = namespaceableResource.Namespace(namespace)
+			}
+			return nil
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error finding the resource of kind %s in %s: %s", kind, apiVersion, err)
+	}
+	if resource == nil {
+		return fmt.Errorf("there's no resource of kind %s in %s", kind, apiVersion)
+	}
+	description := fmt.Sprintf("%s %s/%s to have condition %s=%s", kind, namespace, name, conditionType, status)
+	return k.waitFor(description, timeout, func() (bool, string, error) {
+		object, err := resource.Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return false, "", err
+		}
+		conditions, _, err := unstructured.NestedSlice(object.Object, "status", "conditions")
+		if err != nil {
+			return false, "", fmt.Errorf("invalid status.conditions: %s", err)
+		}
+		for _, condition := range conditions {
+			fields, ok := condition.(map[string]interface{})
+			if !ok || fields["type"] != conditionType {
+				continue
+			}
+			if fields["status"] == status {
+				return true, "", nil
+			}
+			return false, fmt.Sprintf("condition %s is %v: %v", conditionType, fields["status"], fields["message"]), nil
+		}
+		return false, fmt.Sprintf("there's no condition %s yet", conditionType), nil
+	})
+}
@@ -2,11 +2,17 @@ package kubernetes
 
 import (
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
 	"github.com/jarcoal/httpmock"
+	appsv1 "k8s.io/api/apps/v1"
 )
 
 var configMapList = `{
@@ -102,6 +108,38 @@ func TestFindConfigMapNotFound(t *testing.T) {
 	}
 }
 
+func TestGetConfigMap(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, `{"metadata": {"name": "found"}, "data": {"status": "failed"}}`))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	configMap, err := k.GetConfigMap("found", "default")
+	if err != nil {
+		t.Errorf("Got unexpected error from kubernetes.TestGetConfigMap(): %s", err)
+		return
+	}
+	if configMap == nil || configMap.Data["status"] != "failed" {
+		t.Errorf("Didn't get expected configmap from kubernetes.TestGetConfigMap(), instead got: %v", configMap)
+	}
+}
+
+func TestGetConfigMapNotFound(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(404, `{}`))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	configMap, err := k.GetConfigMap("not-found", "default")
+	if err != nil {
+		t.Errorf("Got unexpected error from kubernetes.TestGetConfigMapNotFound(): %s", err)
+		return
+	}
+	if configMap != nil {
+		t.Errorf("Expected to get a nil result in kubernetes.TestGetConfigMapNotFound(), but got: %v", configMap)
+	}
+}
+
 func TestInitializeShipConfigMapNew(t *testing.T) {
 	httpmock.Activate()
 	defer httpmock.DeactivateAndReset()
@@ -167,6 +205,47 @@ func TestInitializeLogConfigMapExists(t *testing.T) {
 		t.Errorf("Got unexpected error from kubernetes.TestInitializeLogConfigMapExists(): %s", err)
 	}
 }
+func TestInitializeHistoryConfigMapNew(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(404, `{}`))
+	httpmock.RegisterResponder("POST", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, `{}`))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	_, err := k.InitializeHistoryConfigMap("loftsman-tests-ship-history", "default")
+	if err != nil {
+		t.Errorf("Got unexpected error from kubernetes.TestInitializeHistoryConfigMapNew(): %s", err)
+	}
+}
+
+func TestInitializeHistoryConfigMapExists(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, `{"metadata": {"name": "loftsman-tests-ship-history"}}`))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	_, err := k.InitializeHistoryConfigMap("loftsman-tests-ship-history", "default")
+	if err != nil {
+		t.Errorf("Got unexpected error from kubernetes.TestInitializeHistoryConfigMapExists(): %s", err)
+	}
+}
+
+func TestListConfigMaps(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, configMapList))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	configMaps, err := k.ListConfigMaps("default", "loftsman.io/record=ship-history")
+	if err != nil {
+		t.Errorf("Got unexpected error from kubernetes.TestListConfigMaps(): %s", err)
+		return
+	}
+	if len(configMaps) != 1 || configMaps[0].Name != "found" {
+		t.Errorf("Didn't get expected configmaps from kubernetes.TestListConfigMaps(), instead got: %v", configMaps)
+	}
+}
+
 func TestPatchConfigMap(t *testing.T) {
 	httpmock.Activate()
 	defer httpmock.DeactivateAndReset()
@@ -183,6 +262,134 @@ func TestPatchConfigMap(t *testing.T) {
 	}
 }
 
+func TestRemoveConfigMapKeys(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	var patchData string
+	httpmock.RegisterResponder("PATCH", `=~http://loftsman-tests`, func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		patchData = string(body)
+		return httpmock.NewStringResponse(200, `{}`), nil
+	})
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	_, err := k.RemoveConfigMapKeys("loftsman-tests", "default", []string{"one", "two"})
+	if err != nil {
+		t.Errorf("Got unexpected error from kubernetes.TestRemoveConfigMapKeys(): %s", err)
+	}
+	if patchData != `{"data":{"one":null,"two":null}}` {
+		t.Errorf("Didn't get expected patch from kubernetes.TestRemoveConfigMapKeys(), got: %s", patchData)
+	}
+}
+
+func getLeaseResponse(holderIdentity string, renewTime time.Time) string {
+	return fmt.Sprintf(`{"metadata": {"name": "loftsman-tests-ship-lock", "resourceVersion": "1"}, "spec": {"holderIdentity": %q, "leaseDurationSeconds": 60, "acquireTime": %q, "renewTime": %q}}`,
+		holderIdentity, renewTime.Format("2006-01-02T15:04:05.000000Z07:00"), renewTime.Format("2006-01-02T15:04:05.000000Z07:00"))
+}
+
+func TestAcquireLeaseNew(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(404, `{}`))
+	httpmock.RegisterResponder("POST", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, getLeaseResponse("me", time.Now())))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	_, acquired, err := k.AcquireLease("loftsman-tests-ship-lock", "default", "me", time.Minute)
+	if err != nil || !acquired {
+		t.Errorf("Didn't acquire the lease in kubernetes.TestAcquireLeaseNew(), got acquired %t, error: %s", acquired, err)
+	}
+}
+
+func TestAcquireLeaseHeld(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, getLeaseResponse("someone-else", time.Now())))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	lease, acquired, err := k.AcquireLease("loftsman-tests-ship-lock", "default", "me", time.Minute)
+	if err != nil || acquired {
+		t.Errorf("Didn't expect to acquire the lease in kubernetes.TestAcquireLeaseHeld(), got acquired %t, error: %s", acquired, err)
+		return
+	}
+	if lease == nil || *lease.Spec.HolderIdentity != "someone-else" {
+		t.Errorf("Didn't get the lease of the other holder from kubernetes.TestAcquireLeaseHeld(), instead got: %v", lease)
+	}
+}
+
+func TestAcquireLeaseExpired(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, getLeaseResponse("someone-else", time.Now().Add(-2*time.Minute))))
+	httpmock.RegisterResponder("PUT", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, getLeaseResponse("me", time.Now())))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	lease, acquired, err := k.AcquireLease("loftsman-tests-ship-lock", "default", "me", time.Minute)
+	if err != nil || !acquired {
+		t.Errorf("Didn't take over the expired lease in kubernetes.TestAcquireLeaseExpired(), got acquired %t, error: %s", acquired, err)
+		return
+	}
+	if *lease.Spec.HolderIdentity != "me" {
+		t.Errorf("Didn't get the taken over lease from kubernetes.TestAcquireLeaseExpired(), instead got: %v", lease)
+	}
+}
+
+func TestRenewLease(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, getLeaseResponse("me", time.Now())))
+	httpmock.RegisterResponder("PUT", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, getLeaseResponse("me", time.Now())))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	_, err := k.RenewLease("loftsman-tests-ship-lock", "default", "me")
+	if err != nil {
+		t.Errorf("Got unexpected error from kubernetes.TestRenewLease(): %s", err)
+	}
+}
+
+func TestRenewLeaseTakenOver(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, getLeaseResponse("someone-else", time.Now())))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	_, err := k.RenewLease("loftsman-tests-ship-lock", "default", "me")
+	if !errors.Is(err, interfaces.ErrLeaseNotHeld) || !strings.Contains(err.Error(), "no longer held by me") {
+		t.Errorf("Didn't get expected error from kubernetes.TestRenewLeaseTakenOver(), instead got: %s", err)
+	}
+}
+
+func TestReleaseLease(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, getLeaseResponse("me", time.Now())))
+	httpmock.RegisterResponder("DELETE", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, `{}`))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	err := k.ReleaseLease("loftsman-tests-ship-lock", "default", "me")
+	if err != nil {
+		t.Errorf("Got unexpected error from kubernetes.TestReleaseLease(): %s", err)
+	}
+	if httpmock.GetCallCountInfo()["DELETE =~http://loftsman-tests"] != 1 {
+		t.Errorf("Expected the lease to be deleted in kubernetes.TestReleaseLease(), got calls: %v", httpmock.GetCallCountInfo())
+	}
+}
+
+func TestReleaseLeaseHeldByOther(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, getLeaseResponse("someone-else", time.Now())))
+	httpmock.RegisterResponder("DELETE", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, `{}`))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	err := k.ReleaseLease("loftsman-tests-ship-lock", "default", "me")
+	if err != nil {
+		t.Errorf("Got unexpected error from kubernetes.TestReleaseLeaseHeldByOther(): %s", err)
+	}
+	if httpmock.GetCallCountInfo()["DELETE =~http://loftsman-tests"] != 0 {
+		t.Errorf("Didn't expect the lease of another holder to be deleted in kubernetes.TestReleaseLeaseHeldByOther()")
+	}
+}
+
 func TestGetSecretKeyValue(t *testing.T) {
 	httpmock.Activate()
 	defer httpmock.DeactivateAndReset()
@@ -212,3 +419,114 @@ func TestGetSecretKeyValueKeyDoesntExist(t *testing.T) {
 		t.Errorf("Got unexpected value from kubernetes.TestGetSecretKeyValueKeyDoesntExist(): %s", value)
 	}
 }
+
+func TestWaitForRolloutDeployment(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, `{"metadata": {"name": "app", "generation": 2},
+		"spec": {"replicas": 2}, "status": {"observedGeneration": 2, "replicas": 2, "updatedReplicas": 2, "availableReplicas": 2}}`))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	if err := k.WaitForRollout("Deployment", "app", "default", time.Second); err != nil {
+		t.Errorf("Got unexpected error from kubernetes.TestWaitForRolloutDeployment(): %s", err)
+	}
+}
+
+func TestWaitForRolloutTimeout(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, `{"metadata": {"name": "db"},
+		"spec": {"replicas": 3}, "status": {"replicas": 3, "readyReplicas": 1}}`))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	err := k.WaitForRollout("StatefulSet", "db", "default", 10*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 10ms waiting for StatefulSet default/db to roll out: 1 of 3 replicas are ready") {
+		t.Errorf("Didn't get expected error from kubernetes.TestWaitForRolloutTimeout(), instead got: %v", err)
+	}
+}
+
+func TestWaitForRolloutUnsupportedKind(t *testing.T) {
+	k := &Kubernetes{}
+	err := k.WaitForRollout("ReplicaSet", "app", "default", time.Second)
+	if err == nil || !strings.Contains(err.Error(), "can't wait for a ReplicaSet to roll out") {
+		t.Errorf("Didn't get expected error from kubernetes.TestWaitForRolloutUnsupportedKind(), instead got: %v", err)
+	}
+}
+
+func TestRolloutStatus(t *testing.T) {
+	onDelete := &appsv1.DaemonSet{Spec: appsv1.DaemonSetSpec{UpdateStrategy: appsv1.DaemonSetUpdateStrategy{Type: appsv1.OnDeleteDaemonSetStrategyType}}}
+	if done, reason := getDaemonSetRolloutStatus(onDelete); !done {
+		t.Errorf("Didn't get expected done from kubernetes.TestRolloutStatus() for an OnDelete DaemonSet, got: %s", reason)
+	}
+	daemonSet := &appsv1.DaemonSet{Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, UpdatedNumberScheduled: 3, NumberAvailable: 2}}
+	if done, reason := getDaemonSetRolloutStatus(daemonSet); done || reason != "2 of 3 updated pods are available" {
+		t.Errorf("Didn't get expected reason from kubernetes.TestRolloutStatus() for a DaemonSet, got: %t, %s", done, reason)
+	}
+	replicas, partition := int32(3), int32(2)
+	statefulSet := &appsv1.StatefulSet{
+		Spec: appsv1.StatefulSetSpec{Replicas: &replicas, UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+			RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition},
+		}},
+		Status: appsv1.StatefulSetStatus{ReadyReplicas: 3, UpdatedReplicas: 0, UpdateRevision: "2", CurrentRevision: "1"},
+	}
+	if done, reason := getStatefulSetRolloutStatus(statefulSet); done || reason != "0 of 1 replicas of the partition have been updated" {
+		t.Errorf("Didn't get expected reason from kubernetes.TestRolloutStatus() for a partitioned StatefulSet, got: %t, %s", done, reason)
+	}
+	deployment := &appsv1.Deployment{Status: appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{
+		{Type: appsv1.DeploymentProgressing, Reason: "ProgressDeadlineExceeded"},
+	}}}
+	deployment.Name = "app"
+	if _, _, err := getDeploymentRolloutStatus(deployment); err == nil || !strings.Contains(err.Error(), "deployment app exceeded its progress deadline") {
+		t.Errorf("Didn't get expected error from kubernetes.TestRolloutStatus() for a Deployment, instead got: %v", err)
+	}
+}
+
+func TestWaitForJob(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, `{"metadata": {"name": "migrate"},
+		"status": {"succeeded": 1, "conditions": [{"type": "Complete", "status": "True"}]}}`))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	if err := k.WaitForJob("migrate", "default", time.Second); err != nil {
+		t.Errorf("Got unexpected error from kubernetes.TestWaitForJob(): %s", err)
+	}
+}
+
+func TestWaitForJobFailed(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests`, httpmock.NewStringResponder(200, `{"metadata": {"name": "migrate"},
+		"status": {"failed": 6, "conditions": [{"type": "Failed", "status": "True", "message": "Job has reached the specified backoff limit"}]}}`))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	err := k.WaitForJob("migrate", "default", time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "job migrate failed: Job has reached the specified backoff limit") {
+		t.Errorf("Didn't get expected error from kubernetes.TestWaitForJobFailed(), instead got: %v", err)
+	}
+}
+
+func TestWaitForCondition(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests/apis/cert-manager.io/v1$`, httpmock.NewStringResponder(200, `{"kind": "APIResourceList",
+		"groupVersion": "cert-manager.io/v1", "resources": [{"name": "certificates/status", "kind": "Certificate", "namespaced": true},
+		{"name": "certificates", "kind": "Certificate", "namespaced": true}]}`))
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests/apis/cert-manager.io/v1/namespaces/certs/certificates/tls`,
+		httpmock.NewStringResponder(200, `{"apiVersion": "cert-manager.io/v1", "kind": "Certificate", "metadata": {"name": "tls", "namespace": "certs"},
+		"status": {"conditions": [{"type": "Issuing", "status": "False"}, {"type": "Ready", "status": "True"}]}}`))
+	httpmock.RegisterResponder("GET", `=~http://loftsman-tests/api/v1/namespaces`, httpmock.NewStringResponder(200, `{}`))
+	k := &Kubernetes{}
+	_ = k.Initialize("./.test-fixtures/kubeconfig.yaml", "default")
+	if err := k.WaitForCondition("cert-manager.io/v1", "Certificate", "tls", "certs", "Ready", "True", time.Second); err != nil {
+		t.Errorf("Got unexpected error from kubernetes.TestWaitForCondition(): %s", err)
+	}
+	err := k.WaitForCondition("cert-manager.io/v1", "Certificate", "tls", "certs", "Issuing", "True", 10*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "waiting for Certificate certs/tls to have condition Issuing=True: condition Issuing is False") {
+		t.Errorf("Didn't get expected error from kubernetes.TestWaitForCondition(), instead got: %v", err)
+	}
+	err = k.WaitForCondition("cert-manager.io/v1", "Issuer", "ca", "certs", "Ready", "True", time.Second)
+	if err == nil || !strings.Contains(err.Error(), "there's no resource of kind Issuer in cert-manager.io/v1") {
+		t.Errorf("Didn't get expected error from kubernetes.TestWaitForCondition() for an unknown kind, instead got: %v", err)
+	}
+}
@@ -2,21 +2,32 @@
 package internal
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"github.com/Cray-HPE/loftsman/internal/git"
 	"github.com/Cray-HPE/loftsman/internal/helm"
 	"github.com/Cray-HPE/loftsman/internal/interfaces"
 	"github.com/Cray-HPE/loftsman/internal/kubernetes"
 	"github.com/Cray-HPE/loftsman/internal/logger"
 	"github.com/Cray-HPE/loftsman/internal/manifest"
 	"github.com/Cray-HPE/loftsman/internal/settings"
+	"github.com/Cray-HPE/loftsman/internal/sops"
+	yaml "gopkg.in/yaml.v2"
+	coordinationv1 "k8s.io/api/coordination/v1"
 )
 
 const (
@@ -28,42 +39,107 @@ const (
 	CreateCmd = "create"
 	// ValidateCmd is the cli validate command identifier
 	ValidateCmd = "validate"
+	// LockCmd is the cli lock command identifier
+	LockCmd = "lock"
+	// RenderCmd is the cli render command identifier
+	RenderCmd = "render"
+	// MigrateCmd is the cli migrate command identifier
+	MigrateCmd = "migrate"
 	// AvastCmd is the cli avast command identifier
 	AvastCmd = "avast"
+	// DiffCmd is the cli diff command identifier
+	DiffCmd = "diff"
+	// HistoryCmd is the cli history command identifier
+	HistoryCmd = "history"
+	// LogsCmd is the cli logs command identifier
+	LogsCmd = "logs"
 
-	statusKey             = "status"
-	statusActive          = "active"
-	statusFailed          = "failed"
-	statusSuccess         = "success"
-	statusCancelled       = "cancelled"
-	statusCrashed         = "crashed"
-	statusAvasted         = "avasted"
-	shipConfigMapNameTemplate = "loftsman-%s"
-	logConfigMapNameTemplate = "loftsman-%s-ship-log"
+	statusKey                    = "status"
+	chartResultsKey              = "charts.json"
+	ownedReleasesKey             = "releases.json"
+	releasedOptionsKey           = "options.json"
+	shipLogKey                   = "loftsman.log"
+	shipLogDroppedKey            = "loftsman.log.dropped"
+	shipLogSuffix                = ".log"
+	shipLogDroppedSuffix         = ".log.dropped"
+	shipIDKey                    = "ship-id"
+	avastReasonKey               = "avast-reason"
+	statusActive                 = "active"
+	statusFailed                 = "failed"
+	statusSuccess                = "success"
+	statusCancelled              = "cancelled"
+	statusCrashed                = "crashed"
+	statusAvasted                = "avasted"
+	shipConfigMapNameTemplate    = "loftsman-%s"
+	logConfigMapNameTemplate     = "loftsman-%s-ship-log"
+	historyConfigMapNameTemplate = "loftsman-%s-ship-history"
+	shipLeaseNameTemplate        = "loftsman-%s-ship-lock"
+
+	shipLeaseDuration      = 60 * time.Second
+	shipAvastWatchInterval = 5 * time.Second
+
+	shipLogStreamInterval = 5 * time.Second
+	logsFollowInterval    = 2 * time.Second
+
+	// the log configmap keeps the logs of up to this many of the latest ships, each cut down to the last
+	// maxShipLogSize bytes so that they all fit within the size limit of a configmap
+	shipLogRetention = 4
+	maxShipLogSize   = 200 * 1024
 )
 
+// shipLeaseRenewInterval is how often the ship lock lease is renewed while a ship runs
+var shipLeaseRenewInterval = 20 * time.Second
+
 // To reduce the need for always initializing cluster connectivity and internal objects
 // like Helm and Kubernetes, we can maintain this simple list of only the commands that
 // actually require it
 var commandsRequiringClusterConnectivity = []string{
 	ShipCmd,
+	ManifestCmd + " " + LockCmd,
 	AvastCmd,
+	DiffCmd,
+	HistoryCmd,
+	LogsCmd,
+}
+
+// Commands whose output is written to stdout for reading or parsing, so their own logs are written to stderr instead
+var commandsLoggingToStderr = []string{
+	ManifestCmd + " " + RenderCmd,
+	ManifestCmd + " " + MigrateCmd,
+	HistoryCmd,
+	LogsCmd,
 }
 
 // Loftsman is the central object for loftsman operations, settings, data, etc.
 type Loftsman struct {
-	Settings   *settings.Settings
-	reader     io.Reader
-	manifest   interfaces.Manifest
-	logger     *logger.Logger
-	kubernetes interfaces.Kubernetes
-	helm       interfaces.Helm
+	Settings         *settings.Settings
+	reader           io.Reader
+	manifest         interfaces.Manifest
+	logger           *logger.Logger
+	kubernetes       interfaces.Kubernetes
+	helm             interfaces.Helm
+	git              interfaces.Git
+	sops             interfaces.Sops
+	shipHistoryEntry *ShipHistoryEntry // the history entry of the ship in progress, if any
+	shipLogMutex     sync.Mutex
+	shipAvastMutex   sync.Mutex
+	shipAvasted      bool   // whether the ship in progress has been avasted, guarded by shipAvastMutex
+	shipAvastReason  string // the reason the ship in progress was avasted with, guarded by shipAvastMutex
+	shipLeaseMutex   sync.Mutex
+	shipLeaseLost    error // why the ship in progress lost its ship lock lease, if it did, guarded by shipLeaseMutex
 }
 
 // Initialize will go through the process of initializing or setting up common needs/objects across all commands
 func (loftsman *Loftsman) Initialize(commandString string) error {
 	var err error
-	loftsman.logger = logger.New(loftsman.Settings.JSONLog.File, commandString)
+	var consoleOutput io.Writer = os.Stdout
+	for _, commandLoggingToStderr := range commandsLoggingToStderr {
+		if commandLoggingToStderr == commandString {
+			consoleOutput = os.Stderr
+			break
+		}
+	}
+	loftsman.logger = logger.NewWithConsoleOutput(loftsman.Settings.JSONLog.File, commandString, consoleOutput)
 
 	for _, commandRequiringClusterConnectivity := range commandsRequiringClusterConnectivity {
 		if commandRequiringClusterConnectivity == commandString {
@@ -80,12 +156,27 @@ func (loftsman *Loftsman) Initialize(commandString string) error {
 			if err = loftsman.kubernetes.Initialize(loftsman.Settings.Kubernetes.KubeconfigPath, loftsman.Settings.Kubernetes.KubeContext); err != nil {
 				return err
 			}
-			loftsman.logger.Info().Msg("Initializing helm client object")
+			if err = loftsman.Settings.ValidateHelmBackend(); err != nil {
+				return err
+			}
+			if loftsman.helm == nil {
+				loftsman.helm = &helm.Helm{}
+				if loftsman.Settings.HelmBackend == interfaces.HelmBackendSDK {
+					loftsman.helm = &helm.SDK{}
+				}
+			}
+			loftsman.logger.Info().Msgf("Initializing helm client object, using the %s backend", loftsman.Settings.HelmBackend)
 			loftsman.Settings.HelmExecConfig.KubeconfigPath = loftsman.Settings.Kubernetes.KubeconfigPath
 			loftsman.Settings.HelmExecConfig.KubeContext = loftsman.Settings.Kubernetes.KubeContext
 			if err = loftsman.helm.Initialize(loftsman.Settings.HelmExecConfig, loftsman.Settings.ChartsSource); err != nil {
 				return err
 			}
+			if err = loftsman.git.Initialize(loftsman.Settings.GitExecConfig); err != nil {
+				return err
+			}
+			if err = loftsman.sops.Initialize(loftsman.Settings.SopsExecConfig); err != nil {
+				return err
+			}
 			break
 		}
 	}
@@ -94,11 +185,22 @@ func (loftsman *Loftsman) Initialize(commandString string) error {
 		if err = loftsman.Settings.ValidateManifestPath(); err != nil {
 			return err
 		}
-		loftsman.Settings.Manifest.Content, err = ioutil.ReadFile(loftsman.Settings.Manifest.Path)
+		manifestContent, err := ioutil.ReadFile(loftsman.Settings.Manifest.Path)
+		if err != nil {
+			return err
+		}
+		// the manifest is composed with its imports and overlays first, so that what's validated, shipped, and recorded
+		// is the whole of it
+		renderedContent, err := manifest.Render(string(manifestContent), filepath.Dir(loftsman.Settings.Manifest.Path))
 		if err != nil {
 			return err
 		}
-		loftsman.manifest, err = manifest.Validate(string(loftsman.Settings.Manifest.Content))
+		loftsman.Settings.Manifest.Content = []byte(renderedContent)
+		variables, err := loftsman.Settings.GetManifestVariables()
+		if err != nil {
+			return err
+		}
+		loftsman.manifest, err = manifest.Validate(string(loftsman.Settings.Manifest.Content), filepath.Dir(loftsman.Settings.Manifest.Path), variables)
 		if err != nil {
 			return err
 		}
@@ -110,6 +212,15 @@ func (loftsman *Loftsman) Initialize(commandString string) error {
 	return nil
 }
 
+// Cleanup will close the JSON log file and remove the temp directory, which can hold decrypted values and pulled
+// charts. It must run on every exit, including the ones from os.Exit that skip deferred calls
+func (loftsman *Loftsman) Cleanup() {
+	loftsman.Settings.JSONLog.File.Close()
+	if err := os.RemoveAll(loftsman.Settings.TempDirectory); err != nil {
+		fmt.Println(fmt.Sprintf("Couldn't remove temp directory: %s", loftsman.Settings.TempDirectory))
+	}
+}
+
 // Ship is the main operation to prep and ship out workloads to the cluster via Helm, etc.
 func (loftsman *Loftsman) Ship() error {
 	var err error
@@ -117,6 +228,23 @@ func (loftsman *Loftsman) Ship() error {
 	if err = loftsman.Settings.ValidateChartsSource(); err != nil {
 		return loftsman.fail(err)
 	}
+	if err = loftsman.Settings.ValidateShipHistoryLimit(); err != nil {
+		return loftsman.fail(err)
+	}
+	if err = loftsman.validateChartsDirectory(); err != nil {
+		return loftsman.fail(err)
+	}
+	if err = loftsman.applyManifestLock(); err != nil {
+		return loftsman.fail(err)
+	}
+	includedCharts, err := loftsman.selectCharts()
+	if err != nil {
+		return loftsman.fail(err)
+	}
+
+	if loftsman.Settings.Ship.DryRun {
+		return loftsman.shipDryRun(includedCharts)
+	}
 
 	loftsman.logger.Header("Shipping your Helm workloads with Loftsman")
 
@@ -124,20 +252,59 @@ func (loftsman *Loftsman) Ship() error {
 	shipConfigMapData := make(map[string]string)
 	logConfigMapName := fmt.Sprintf(logConfigMapNameTemplate, loftsman.Settings.Manifest.Name)
 	logConfigMapData := make(map[string]string)
+	historyConfigMapName := fmt.Sprintf(historyConfigMapNameTemplate, loftsman.Settings.Manifest.Name)
 
 	loftsman.logger.Info().Msgf("Ensuring that the %s namespace exists", loftsman.Settings.Namespace)
 	if err = loftsman.kubernetes.EnsureNamespace(loftsman.Settings.Namespace); err != nil {
 		return loftsman.fail(fmt.Errorf("Error ensuring that the %s namespace exists: %s", loftsman.Settings.Namespace, err))
 	}
 
-	activeConfigMap, err := loftsman.kubernetes.FindConfigMap(shipConfigMapName, loftsman.Settings.Namespace, statusKey, statusActive)
+	shipLeaseName := fmt.Sprintf(shipLeaseNameTemplate, loftsman.Settings.Manifest.Name)
+	shipLeaseHolderIdentity := getShipLeaseHolderIdentity()
+	shipLease, acquired, err := loftsman.kubernetes.AcquireLease(shipLeaseName, loftsman.Settings.Namespace, shipLeaseHolderIdentity, shipLeaseDuration)
 	if err != nil {
-		return loftsman.fail(fmt.Errorf("Error determining if another loftsman ship is in progress for manifest %s: %s", loftsman.Settings.Manifest.Name, err))
+		return loftsman.fail(fmt.Errorf("Error acquiring the ship lock lease %s for manifest %s: %s", shipLeaseName, loftsman.Settings.Manifest.Name, err))
 	}
-	if activeConfigMap != nil {
+	if !acquired {
 		return loftsman.fail(fmt.Errorf(
-			"There's another loftsman ship in progress for manifest %s in this cluster, please wait and try again in a bit, or use `loftsman avast` to cancel it",
-			loftsman.Settings.Manifest.Name))
+			"There's another loftsman ship in progress for manifest %s in this cluster, %s. Please wait and try again in a bit, the lock is taken over automatically if it goes %s without being renewed",
+			loftsman.Settings.Manifest.Name, describeShipLease(shipLease, time.Now()), shipLeaseDuration))
+	}
+	loftsman.logger.Info().Msgf("Acquired the ship lock lease %s in namespace %s as %s", shipLeaseName, loftsman.Settings.Namespace, shipLeaseHolderIdentity)
+	defer loftsman.releaseShipLease(shipLeaseName, shipLeaseHolderIdentity)
+	stopShipLeaseHeartbeat := loftsman.heartbeatShipLease(shipLeaseName, shipLeaseHolderIdentity)
+	defer stopShipLeaseHeartbeat()
+
+	var previousChartResults []*interfaces.ManifestChartResult
+	if loftsman.Settings.Ship.Resume {
+		if previousChartResults, err = loftsman.getPreviousChartResults(shipConfigMapName); err != nil {
+			return loftsman.fail(fmt.Errorf("Error getting the chart results of the last ship of manifest %s to resume: %s", loftsman.Settings.Manifest.Name, err))
+		}
+		loftsman.logger.Info().Msgf("Resuming the last ship of manifest %s, found %d recorded chart results", loftsman.Settings.Manifest.Name, len(previousChartResults))
+	}
+
+	ownedReleases, err := loftsman.getOwnedReleases(shipConfigMapName)
+	if err != nil {
+		return loftsman.fail(fmt.Errorf("Error getting the releases owned by manifest %s: %s", loftsman.Settings.Manifest.Name, err))
+	}
+	releasedOptions, err := loftsman.getReleasedOptions(shipConfigMapName)
+	if err != nil {
+		return loftsman.fail(fmt.Errorf("Error getting the release options hashes of manifest %s: %s", loftsman.Settings.Manifest.Name, err))
+	}
+	pruneCandidates := loftsman.getPruneCandidates(ownedReleases)
+	prune := false
+	if len(pruneCandidates) > 0 {
+		if !loftsman.isPruneEnabled() {
+			loftsman.logger.Info().Msgf("Found releases from a previous ship that are no longer in manifest %s, use --prune or spec.prune to uninstall them: %s",
+				loftsman.Settings.Manifest.Name, describeReleases(pruneCandidates))
+		} else if includedCharts != nil {
+			loftsman.logger.Info().Msgf("Not pruning releases that are no longer in manifest %s, since only some of its charts are being shipped: %s",
+				loftsman.Settings.Manifest.Name, describeReleases(pruneCandidates))
+		} else if prune, err = loftsman.confirmPrune(pruneCandidates); err != nil {
+			return loftsman.fail(err)
+		} else if !prune {
+			loftsman.logger.Info().Msg("User did not enter 'yes', not pruning releases that are no longer in the manifest")
+		}
 	}
 
 	if loftsman.Settings.ChartsSource.Path != "" {
@@ -152,20 +319,54 @@ func (loftsman *Loftsman) Ship() error {
 	loftsman.logger.Info().Msgf("Running a release for the provided manifest at %s", loftsman.Settings.Manifest.Path)
 
 	shipConfigMapData[statusKey] = statusActive
-	sigChannel := make(chan os.Signal)
+	sigChannel := make(chan os.Signal, 1)
 	signal.Notify(sigChannel, os.Interrupt, os.Kill, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT)
 	go func() {
 		<-sigChannel
 		loftsman.recordShipResult(shipConfigMapName, shipConfigMapData, statusCancelled)
 		loftsman.recordShipLog(logConfigMapName, logConfigMapData)
+		loftsman.releaseShipLease(shipLeaseName, shipLeaseHolderIdentity)
+		loftsman.Cleanup()
 		os.Exit(0)
 	}()
 	if _, err := loftsman.kubernetes.InitializeShipConfigMap(shipConfigMapName, loftsman.Settings.Namespace, shipConfigMapData); err != nil {
 		return loftsman.fail(fmt.Errorf("Error creating ship configmap %s in namespace %s: %s", shipConfigMapName, loftsman.Settings.Namespace, err))
 	}
+	// an existing ship configmap keeps the status of the last ship, including any avast of it, until we reset it here
+	if _, err := loftsman.kubernetes.PatchConfigMap(shipConfigMapName, loftsman.Settings.Namespace, map[string]string{
+		statusKey:      statusActive,
+		avastReasonKey: "",
+	}); err != nil {
+		return loftsman.fail(fmt.Errorf("Error setting the status of ship configmap %s in namespace %s: %s", shipConfigMapName, loftsman.Settings.Namespace, err))
+	}
 	if _, err := loftsman.kubernetes.InitializeLogConfigMap(logConfigMapName, loftsman.Settings.Namespace, logConfigMapData); err != nil {
 		return loftsman.fail(fmt.Errorf("Error creating log configmap %s in namespace %s: %s", logConfigMapName, loftsman.Settings.Namespace, err))
 	}
+	if _, err := loftsman.kubernetes.InitializeHistoryConfigMap(historyConfigMapName, loftsman.Settings.Namespace); err != nil {
+		return loftsman.fail(fmt.Errorf("Error creating ship history configmap %s in namespace %s: %s", historyConfigMapName, loftsman.Settings.Namespace, err))
+	}
+	loftsman.shipHistoryEntry = newShipHistoryEntry(loftsman.Settings.Manifest.Name, loftsman.Settings.Manifest.Content)
+	loftsman.shipHistoryEntry.Partial = includedCharts != nil
+	loftsman.shipHistoryEntry.Charts = includedCharts
+	loftsman.logger.Info().Msgf("Recording ship %s to the ship history in configmap %s", loftsman.shipHistoryEntry.ID, historyConfigMapName)
+	loftsman.recordShipHistory(historyConfigMapName, loftsman.shipHistoryEntry)
+	loftsman.trimShipHistory(historyConfigMapName)
+	if err := loftsman.archiveShipLog(logConfigMapName); err != nil {
+		loftsman.logger.Warn().Msgf("Error keeping the log of the last ship in configmap %s in namespace %s: %s",
+			logConfigMapName, loftsman.Settings.Namespace, err)
+	}
+	if err := loftsman.storeShipLog(logConfigMapName); err != nil {
+		return loftsman.fail(fmt.Errorf("Error resetting log configmap %s in namespace %s for this ship: %s", logConfigMapName, loftsman.Settings.Namespace, err))
+	}
+	stopShipLogStream := loftsman.streamShipLog(logConfigMapName)
+	defer stopShipLogStream()
+	stopShipAvastWatch := loftsman.watchShipAvast(shipConfigMapName)
+	defer stopShipAvastWatch()
+	chartResults := []*interfaces.ManifestChartResult{}
+	var chartResultsMutex sync.Mutex
+	loftsman.recordChartResults(shipConfigMapName, chartResults)
+	// until they're pruned, releases no longer in the manifest are still owned by it
+	loftsman.recordOwnedReleases(shipConfigMapName, mergeReleases(loftsman.manifest.GetReleases(), ownedReleases))
 	crashHandler := func() {
 		if r := recover(); r != nil {
 			loftsman.recordShipResult(shipConfigMapName, shipConfigMapData, statusCrashed)
@@ -176,55 +377,677 @@ func (loftsman *Loftsman) Ship() error {
 	defer crashHandler()
 	loftsman.manifest.SetLogger(loftsman.logger)
 	loftsman.manifest.SetTempDirectory(loftsman.Settings.TempDirectory)
+	loftsman.manifest.SetGit(loftsman.git)
+	loftsman.manifest.SetSops(loftsman.sops)
+	loftsman.manifest.SetReleaseOptions(&interfaces.ManifestReleaseOptions{
+		MaxConcurrency:  loftsman.Settings.Ship.MaxConcurrency,
+		ResumeFrom:      previousChartResults,
+		ReleasedOptions: releasedOptions,
+		OnChartResult: func(chartResult *interfaces.ManifestChartResult) {
+			chartResultsMutex.Lock()
+			defer chartResultsMutex.Unlock()
+			chartResults = append(chartResults, chartResult)
+			loftsman.recordChartResults(shipConfigMapName, chartResults)
+			if chartResult.Status != interfaces.ManifestChartStatusSkipped {
+				releasedOptions = setReleasedOptions(releasedOptions, chartResult)
+				loftsman.recordReleasedOptions(shipConfigMapName, releasedOptions)
+			}
+		},
+		Cancelled: func() bool {
+			shipAvasted, _ := loftsman.getShipAvast()
+			return shipAvasted || loftsman.getShipLeaseLost() != nil
+		},
+	})
 	releaseErrors := loftsman.manifest.Release(loftsman.kubernetes, loftsman.helm)
+	shipAvasted, shipAvastReason := loftsman.getShipAvast()
+	shipLeaseLost := loftsman.getShipLeaseLost()
+	var pruneErrors []error
+	if prune {
+		notPruned := pruneCandidates
+		if len(releaseErrors) > 0 || shipAvasted || shipLeaseLost != nil {
+			loftsman.logger.Warn().Msg("Not pruning releases that are no longer in the manifest, since not all charts were released successfully")
+		} else {
+			notPruned, pruneErrors = loftsman.pruneReleases(pruneCandidates)
+		}
+		loftsman.recordOwnedReleases(shipConfigMapName, mergeReleases(loftsman.manifest.GetReleases(), notPruned))
+	}
 	releaseStatus := statusSuccess
-	if len(releaseErrors) > 0 {
+	if len(releaseErrors) > 0 || len(pruneErrors) > 0 || shipLeaseLost != nil {
 		releaseStatus = statusFailed
 	}
+	if shipAvasted {
+		releaseStatus = statusAvasted
+		shipConfigMapData[avastReasonKey] = shipAvastReason
+	}
 	loftsman.recordShipResult(shipConfigMapName, shipConfigMapData, releaseStatus)
 	loftsman.recordShipLog(logConfigMapName, logConfigMapData)
 
-	if len(releaseErrors) > 0 {
-		loftsman.logger.ClosingHeader("Encountered errors during the manifest release:")
-		for _, releaseError := range releaseErrors {
-			loftsman.logger.Error().
-				Str("chart", releaseError.Chart).
-				Str("version", releaseError.Version).
-				Str("namespace", releaseError.Namespace).
-				Msg(strings.TrimSpace(releaseError.Error.Error()))
+	if shipAvasted {
+		if len(releaseErrors) > 0 {
+			loftsman.logReleaseErrors("Charts not released after the ship was avasted:", releaseErrors)
+		}
+		return loftsman.fail(fmt.Errorf("The ship was avasted, reason: %s", describeAvastReason(shipAvastReason)))
+	}
+	if shipLeaseLost != nil {
+		if len(releaseErrors) > 0 {
+			loftsman.logReleaseErrors("Charts not released after the ship lock lease was lost:", releaseErrors)
+		}
+		return loftsman.fail(shipLeaseLost)
+	}
+
+	if len(pruneErrors) > 0 {
+		loftsman.logger.ClosingHeader("Encountered errors pruning releases that are no longer in the manifest:")
+		for _, pruneError := range pruneErrors {
+			loftsman.logger.Error().Err(pruneError).Msg("")
 			fmt.Println("")
 		}
+	}
+	if len(releaseErrors) > 0 {
+		loftsman.logReleaseErrors("Encountered errors during the manifest release:", releaseErrors)
 		return loftsman.fail(errors.New("Some charts did not release successfully, see above and/or the output log file for more info"))
 	}
+	if len(pruneErrors) > 0 {
+		return loftsman.fail(errors.New("Some releases could not be pruned, see above and/or the output log file for more info"))
+	}
 	return nil
 }
 
+// selectCharts will select the charts of the manifest to ship from the --only, --skip and --selector settings,
+// returning the names of the charts selected, or nil when all of the charts are to be shipped
+func (loftsman *Loftsman) selectCharts() ([]string, error) {
+	selection, err := loftsman.Settings.GetChartSelection()
+	if err != nil || selection == nil {
+		return nil, err
+	}
+	includedCharts, err := loftsman.manifest.SelectCharts(selection)
+	if err != nil {
+		return nil, fmt.Errorf("Error selecting the charts of manifest %s to ship: %s", loftsman.Settings.Manifest.Name, err)
+	}
+	loftsman.logger.Info().Msgf("Shipping only some of the charts of manifest %s: %s", loftsman.Settings.Manifest.Name,
+		strings.Join(includedCharts, ", "))
+	return includedCharts, nil
+}
+
+// shipDryRun will plan a ship of the manifest, reporting what a release would do for each chart without making any
+// changes to the cluster or the loftsman records stored in it. includedCharts are the charts selected to ship, if
+// only some of them are
+func (loftsman *Loftsman) shipDryRun(includedCharts []string) error {
+	loftsman.logger.Header("Planning a ship of your Helm workloads with Loftsman (dry run)")
+	loftsman.logger.Info().Msgf("Planning a release for the provided manifest at %s, no changes will be made to the cluster", loftsman.Settings.Manifest.Path)
+
+	loftsman.manifest.SetLogger(loftsman.logger)
+	loftsman.manifest.SetTempDirectory(loftsman.Settings.TempDirectory)
+	loftsman.manifest.SetGit(loftsman.git)
+	loftsman.manifest.SetSops(loftsman.sops)
+	shipConfigMapName := fmt.Sprintf(shipConfigMapNameTemplate, loftsman.Settings.Manifest.Name)
+	releasedOptions, err := loftsman.getReleasedOptions(shipConfigMapName)
+	if err != nil {
+		loftsman.logger.Error().Err(fmt.Errorf("Error getting the release options hashes of manifest %s, releases will be planned as upgrades: %s",
+			loftsman.Settings.Manifest.Name, err)).Msg("")
+	}
+	loftsman.manifest.SetReleaseOptions(&interfaces.ManifestReleaseOptions{
+		MaxConcurrency:  loftsman.Settings.Ship.MaxConcurrency,
+		ReleasedOptions: releasedOptions,
+	})
+	planEntries, releaseErrors := loftsman.manifest.Plan(loftsman.kubernetes, loftsman.helm)
+
+	loftsman.logger.ClosingHeader("Ship plan:")
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(writer, "CHART\tNAMESPACE\tRELEASE\tVERSION\tCURRENT VERSION\tCURRENT REVISION\tACTION")
+	for _, planEntry := range planEntries {
+		loftsman.logger.Info().
+			Str("chart", planEntry.Chart).
+			Str("version", planEntry.Version).
+			Str("namespace", planEntry.Namespace).
+			Str("release", planEntry.ReleaseName).
+			Str("chart-path", planEntry.ChartPath).
+			Str("current-version", planEntry.CurrentVersion).
+			Int("current-revision", planEntry.CurrentRevision).
+			Str("action", planEntry.Action).
+			Msgf("Ship would %s release %s", planEntry.Action, planEntry.ReleaseName)
+		currentVersion := planEntry.CurrentVersion
+		if currentVersion == "" {
+			currentVersion = "-"
+		}
+		currentRevision := "-"
+		if planEntry.CurrentRevision > 0 {
+			currentRevision = fmt.Sprintf("%d", planEntry.CurrentRevision)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", planEntry.Chart, planEntry.Namespace, planEntry.ReleaseName,
+			planEntry.Version, currentVersion, currentRevision, planEntry.Action)
+	}
+	ownedReleases, err := loftsman.getOwnedReleases(shipConfigMapName)
+	if err != nil {
+		loftsman.logger.Error().Err(fmt.Errorf("Error getting the releases owned by manifest %s, can't plan pruning: %s",
+			loftsman.Settings.Manifest.Name, err)).Msg("")
+	}
+	pruneCandidates := loftsman.getPruneCandidates(ownedReleases)
+	if len(pruneCandidates) > 0 && !loftsman.isPruneEnabled() {
+		loftsman.logger.Info().Msgf("Found releases from a previous ship that are no longer in manifest %s, use --prune or spec.prune to uninstall them: %s",
+			loftsman.Settings.Manifest.Name, describeReleases(pruneCandidates))
+	} else if len(pruneCandidates) > 0 && includedCharts != nil {
+		loftsman.logger.Info().Msgf("Not pruning releases that are no longer in manifest %s, since only some of its charts are being shipped: %s",
+			loftsman.Settings.Manifest.Name, describeReleases(pruneCandidates))
+	} else {
+		for _, pruneCandidate := range pruneCandidates {
+			loftsman.logger.Info().
+				Str("namespace", pruneCandidate.Namespace).
+				Str("release", pruneCandidate.ReleaseName).
+				Str("action", interfaces.ManifestPlanActionPrune).
+				Msgf("Ship would %s release %s", interfaces.ManifestPlanActionPrune, pruneCandidate.ReleaseName)
+			fmt.Fprintf(writer, "-\t%s\t%s\t-\t-\t-\t%s\n", pruneCandidate.Namespace, pruneCandidate.ReleaseName, interfaces.ManifestPlanActionPrune)
+		}
+	}
+	writer.Flush()
+	fmt.Println("")
+
+	if len(releaseErrors) > 0 {
+		loftsman.logReleaseErrors("Encountered errors while planning the manifest release:", releaseErrors)
+		return loftsman.fail(errors.New("Some charts could not be planned, see above and/or the output log file for more info"))
+	}
+	return nil
+}
+
+// describeReleases will describe a list of releases for logging
+func describeReleases(releases []*interfaces.ManifestRelease) string {
+	descriptions := []string{}
+	for _, release := range releases {
+		descriptions = append(descriptions, fmt.Sprintf("%s (namespace %s)", release.ReleaseName, release.Namespace))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// validateChartsDirectory will index the packaged charts at the charts-path, logging warnings about packages that
+// duplicate or conflict with another package of the same chart version. These don't fail the command, the first
+// package of a version by file name is used
+func (loftsman *Loftsman) validateChartsDirectory() error {
+	if loftsman.Settings.ChartsSource.Path == "" {
+		return nil
+	}
+	warnings, err := loftsman.helm.ValidateChartsDirectory(loftsman.Settings.ChartsSource.Path)
+	if err != nil {
+		return fmt.Errorf("Error reading the packaged charts at %s: %s", loftsman.Settings.ChartsSource.Path, err)
+	}
+	for _, warning := range warnings {
+		loftsman.logger.Warn().Msg(warning)
+	}
+	return nil
+}
+
+// applyManifestLock will set the charts of the manifest to the exact versions and digests in the manifest lock, unless
+// there's no lock or we're updating the chart versions
+func (loftsman *Loftsman) applyManifestLock() error {
+	lockPath := loftsman.Settings.GetManifestLockPath()
+	if loftsman.Settings.Ship.Update {
+		loftsman.logger.Info().Msgf("Resolving chart versions against the charts sources, ignoring any manifest lock at %s", lockPath)
+		return nil
+	}
+	lockBytes, err := ioutil.ReadFile(lockPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error reading the manifest lock at %s: %s", lockPath, err)
+	}
+	var lock *interfaces.ManifestLock
+	if err = yaml.Unmarshal(lockBytes, &lock); err != nil || lock == nil {
+		return fmt.Errorf("Error parsing the manifest lock at %s: %v", lockPath, err)
+	}
+	if err = loftsman.manifest.ApplyLock(lock); err != nil {
+		return fmt.Errorf("The manifest lock at %s is out of date, %s. Run loftsman manifest lock to update it, or ship with --update to ignore it",
+			lockPath, err)
+	}
+	loftsman.logger.Info().Msgf("Shipping the exact chart versions in the manifest lock at %s", lockPath)
+	return nil
+}
+
+func (loftsman *Loftsman) logReleaseErrors(header string, releaseErrors []*interfaces.ManifestReleaseError) {
+	loftsman.logger.ClosingHeader(header)
+	for _, releaseError := range releaseErrors {
+		loftsman.logger.Error().
+			Str("chart", releaseError.Chart).
+			Str("version", releaseError.Version).
+			Str("namespace", releaseError.Namespace).
+			Msg(strings.TrimSpace(releaseError.Error.Error()))
+		fmt.Println("")
+	}
+}
+
 func (loftsman *Loftsman) recordShipResult(configMapName string, configMapData map[string]string, status string) {
+	if !loftsman.holdsShipRecords(configMapName, fmt.Sprintf("Ship status: %s", status)) {
+		// the ship's own entry in the ship history is still its own to finish
+		loftsman.recordShipHistoryResult(status)
+		return
+	}
 	loftsman.logger.Info().Msgf("Ship status: %s. Recording status, manifest to configmap %s in namespace %s", status,
-	configMapName, loftsman.Settings.Namespace)
-	configMapData["manifest.yaml"] = string(loftsman.Settings.Manifest.Content)
+		configMapName, loftsman.Settings.Namespace)
+	// the manifest is recorded with the same secrets redacted as the ship log
+	configMapData["manifest.yaml"] = loftsman.logger.Redact(string(loftsman.Settings.Manifest.Content))
 	configMapData["status"] = status
 	if _, err := loftsman.kubernetes.PatchConfigMap(configMapName, loftsman.Settings.Namespace, configMapData); err != nil {
 		loftsman.logger.Error().Err(fmt.Errorf("Error patching configmap %s with result, manifest, and log data to the %s namespace: %s",
 			configMapName, loftsman.Settings.Namespace, err)).Msg("")
 		fmt.Println("")
 	}
+	loftsman.recordShipHistoryResult(status)
+}
+
+// recordShipHistoryResult will record when and how the ship ended to its entry in the ship history
+func (loftsman *Loftsman) recordShipHistoryResult(status string) {
+	if loftsman.shipHistoryEntry != nil {
+		endTime := time.Now().UTC()
+		loftsman.shipHistoryEntry.EndTime = &endTime
+		loftsman.shipHistoryEntry.Status = status
+		loftsman.recordShipHistory(fmt.Sprintf(historyConfigMapNameTemplate, loftsman.Settings.Manifest.Name), loftsman.shipHistoryEntry)
+	}
+}
+
+// holdsShipRecords will determine if the ship in progress still owns the ship and log configmaps of the manifest. Once
+// its ship lock lease is lost they belong to the ship that can take over, so what would have been recorded to them is
+// only logged here
+func (loftsman *Loftsman) holdsShipRecords(configMapName string, outcome string) bool {
+	if loftsman.getShipLeaseLost() == nil {
+		return true
+	}
+	loftsman.logger.Warn().Msgf("%s. Not recording it to configmap %s in namespace %s, since the ship lock lease was lost",
+		outcome, configMapName, loftsman.Settings.Namespace)
+	return false
+}
+
+// getPreviousChartResults will get the chart results recorded in the ship configmap by the last ship of the manifest
+func (loftsman *Loftsman) getPreviousChartResults(configMapName string) ([]*interfaces.ManifestChartResult, error) {
+	var chartResults []*interfaces.ManifestChartResult
+	configMap, err := loftsman.kubernetes.GetConfigMap(configMapName, loftsman.Settings.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if configMap == nil || configMap.Data[chartResultsKey] == "" {
+		return chartResults, nil
+	}
+	if err = json.Unmarshal([]byte(configMap.Data[chartResultsKey]), &chartResults); err != nil {
+		return nil, fmt.Errorf("Error parsing %s in configmap %s: %s", chartResultsKey, configMapName, err)
+	}
+	return chartResults, nil
+}
+
+// recordChartResults will record the outcome of each chart released so far to the ship configmap, so that a failed
+// ship can be resumed from where it left off
+func (loftsman *Loftsman) recordChartResults(configMapName string, chartResults []*interfaces.ManifestChartResult) {
+	chartResultsEncoded, err := json.Marshal(chartResults)
+	if err == nil && !loftsman.holdsShipRecords(configMapName, fmt.Sprintf("Chart results: %s", chartResultsEncoded)) {
+		return
+	}
+	if err == nil {
+		_, err = loftsman.kubernetes.PatchConfigMap(configMapName, loftsman.Settings.Namespace, map[string]string{
+			chartResultsKey: string(chartResultsEncoded),
+		})
+	}
+	if err != nil {
+		loftsman.logger.Error().Err(fmt.Errorf("Error patching configmap %s with chart results to the %s namespace: %s",
+			configMapName, loftsman.Settings.Namespace, err)).Msg("")
+		fmt.Println("")
+	}
+}
+
+// getReleasedOptions will get the options hash each release of the manifest was last released with, as recorded in the
+// ship configmap by its previous ships
+func (loftsman *Loftsman) getReleasedOptions(configMapName string) ([]*interfaces.ManifestRelease, error) {
+	var releasedOptions []*interfaces.ManifestRelease
+	configMap, err := loftsman.kubernetes.GetConfigMap(configMapName, loftsman.Settings.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if configMap == nil || configMap.Data[releasedOptionsKey] == "" {
+		return releasedOptions, nil
+	}
+	if err = json.Unmarshal([]byte(configMap.Data[releasedOptionsKey]), &releasedOptions); err != nil {
+		return nil, fmt.Errorf("Error parsing %s in configmap %s: %s", releasedOptionsKey, configMapName, err)
+	}
+	return releasedOptions, nil
+}
+
+// setReleasedOptions will set the options hash a chart's release was released with from its result. A release that
+// failed or was rolled back no longer has a known options hash, so it's left out until it's released again
+func setReleasedOptions(releasedOptions []*interfaces.ManifestRelease, chartResult *interfaces.ManifestChartResult) []*interfaces.ManifestRelease {
+	updated := []*interfaces.ManifestRelease{}
+	for _, released := range releasedOptions {
+		if released.ReleaseName != chartResult.ReleaseName || released.Namespace != chartResult.Namespace {
+			updated = append(updated, released)
+		}
+	}
+	if chartResult.Status == interfaces.ManifestChartStatusSuccess && chartResult.OptionsHash != "" {
+		updated = append(updated, &interfaces.ManifestRelease{
+			ReleaseName: chartResult.ReleaseName,
+			Namespace:   chartResult.Namespace,
+			OptionsHash: chartResult.OptionsHash,
+		})
+	}
+	return updated
+}
+
+// recordReleasedOptions will record the options hash each release was last released with to the ship configmap, so
+// that a later ship can plan which releases are unchanged
+func (loftsman *Loftsman) recordReleasedOptions(configMapName string, releasedOptions []*interfaces.ManifestRelease) {
+	releasedOptionsEncoded, err := json.Marshal(releasedOptions)
+	if err == nil && !loftsman.holdsShipRecords(configMapName, fmt.Sprintf("Release options hashes: %s", releasedOptionsEncoded)) {
+		return
+	}
+	if err == nil {
+		_, err = loftsman.kubernetes.PatchConfigMap(configMapName, loftsman.Settings.Namespace, map[string]string{
+			releasedOptionsKey: string(releasedOptionsEncoded),
+		})
+	}
+	if err != nil {
+		loftsman.logger.Error().Err(fmt.Errorf("Error patching configmap %s with release options hashes to the %s namespace: %s",
+			configMapName, loftsman.Settings.Namespace, err)).Msg("")
+		fmt.Println("")
+	}
 }
 
 func (loftsman *Loftsman) recordShipLog(configMapName string, configMapData map[string]string) {
+	if !loftsman.holdsShipRecords(configMapName, fmt.Sprintf("The log of the ship is kept in %s", loftsman.Settings.JSONLog.Path)) {
+		return
+	}
 	loftsman.logger.Info().Msgf("Recording log data to configmap %s in namespace %s",
 		configMapName, loftsman.Settings.Namespace)
 
+	if err := loftsman.storeShipLog(configMapName); err != nil {
+		loftsman.logger.Error().Err(fmt.Errorf("Error patching configmap %s with log data to the %s namespace: %s",
+			configMapName, loftsman.Settings.Namespace, err)).Msg("")
+		fmt.Println("")
+	}
+}
+
+// storeShipLog will store the log of the ship so far to the log configmap, along with the ID of the ship it's from. The
+// log record only ever grows, so storing it one call at a time means the latest call always stores the most of it
+func (loftsman *Loftsman) storeShipLog(configMapName string) error {
+	loftsman.shipLogMutex.Lock()
+	defer loftsman.shipLogMutex.Unlock()
+
 	logConfigMapData := make(map[string]string)
-	logConfigMapData["loftsman.log"] = loftsman.logger.GetRecord()
+	shipLog, dropped := truncateShipLog(loftsman.logger.GetRecord())
+	logConfigMapData[shipLogKey] = shipLog
+	logConfigMapData[shipLogDroppedKey] = strconv.Itoa(dropped)
+	if loftsman.shipHistoryEntry != nil {
+		logConfigMapData[shipIDKey] = loftsman.shipHistoryEntry.ID
+	}
+	_, err := loftsman.kubernetes.PatchConfigMap(configMapName, loftsman.Settings.Namespace, logConfigMapData)
+	return err
+}
 
-	if _, err := loftsman.kubernetes.PatchConfigMap(configMapName, loftsman.Settings.Namespace, logConfigMapData); err != nil {
-		loftsman.logger.Error().Err(fmt.Errorf("Error patching configmap %s with log data to the %s namespace: %s",
-		configMapName, loftsman.Settings.Namespace, err)).Msg("")
+// archiveShipLog will keep the log of the last ship in the log configmap under its ship ID, before it's replaced by the
+// log of this ship, and remove the logs of the oldest ships so that only those of the latest shipLogRetention are kept
+func (loftsman *Loftsman) archiveShipLog(configMapName string) error {
+	logConfigMap, err := loftsman.kubernetes.GetConfigMap(configMapName, loftsman.Settings.Namespace)
+	if err != nil || logConfigMap == nil {
+		return err
+	}
+	archivedShipIDs := []string{}
+	for key := range logConfigMap.Data {
+		if key != shipLogKey && strings.HasSuffix(key, shipLogSuffix) {
+			archivedShipIDs = append(archivedShipIDs, strings.TrimSuffix(key, shipLogSuffix))
+		}
+	}
+	lastShipID := logConfigMap.Data[shipIDKey]
+	archiveLastShip := lastShipID != "" && logConfigMap.Data[shipLogKey] != "" && logConfigMap.Data[lastShipID+shipLogSuffix] == ""
+	if archiveLastShip {
+		archivedShipIDs = append(archivedShipIDs, lastShipID)
+	}
+	// ship IDs start with the time of the ship, so they sort from the oldest to the latest
+	sort.Strings(archivedShipIDs)
+	// the oldest logs are removed first, so the configmap never holds more than it will once this ship is stored
+	if removedCount := len(archivedShipIDs) - (shipLogRetention - 1); removedCount > 0 {
+		removedKeys := []string{}
+		for _, shipID := range archivedShipIDs[:removedCount] {
+			removedKeys = append(removedKeys, shipID+shipLogSuffix, shipID+shipLogDroppedSuffix)
+		}
+		if _, err := loftsman.kubernetes.RemoveConfigMapKeys(configMapName, loftsman.Settings.Namespace, removedKeys); err != nil {
+			return err
+		}
+		archiveLastShip = archiveLastShip && archivedShipIDs[len(archivedShipIDs)-1] == lastShipID
+	}
+	if !archiveLastShip {
+		return nil
+	}
+	// logs stored before they were cut down to size are cut down as they're kept
+	shipLog, dropped := truncateShipLog(logConfigMap.Data[shipLogKey])
+	previouslyDropped, _ := strconv.Atoi(logConfigMap.Data[shipLogDroppedKey])
+	_, err = loftsman.kubernetes.PatchConfigMap(configMapName, loftsman.Settings.Namespace, map[string]string{
+		lastShipID + shipLogSuffix:        shipLog,
+		lastShipID + shipLogDroppedSuffix: strconv.Itoa(previouslyDropped + dropped),
+	})
+	return err
+}
+
+// truncateShipLog will cut a ship log down to its last maxShipLogSize bytes, dropping whole lines from its start, and
+// return what's left along with how many bytes were dropped
+func truncateShipLog(shipLog string) (string, int) {
+	if len(shipLog) <= maxShipLogSize {
+		return shipLog, 0
+	}
+	start := len(shipLog) - maxShipLogSize
+	if shipLog[start-1] != '\n' {
+		if nextNewline := strings.Index(shipLog[start:], "\n"); nextNewline >= 0 {
+			start += nextNewline + 1
+		} else {
+			start = len(shipLog)
+		}
+	}
+	return shipLog[start:], start
+}
+
+// streamShipLog will periodically store the log of the ship in progress to the log configmap, so that the ship can be
+// followed with `loftsman logs` while it runs. The returned function stops the streaming
+func (loftsman *Loftsman) streamShipLog(configMapName string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(shipLogStreamInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// the log configmap belongs to the ship that can take over once the ship lock lease is lost
+				if loftsman.getShipLeaseLost() != nil {
+					return
+				}
+				if err := loftsman.storeShipLog(configMapName); err != nil {
+					loftsman.logger.Warn().Msgf("Error streaming log data to configmap %s in namespace %s, will try again: %s",
+						configMapName, loftsman.Settings.Namespace, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// getShipLeaseHolderIdentity will return the identity this process holds the ship lock lease under
+func getShipLeaseHolderIdentity() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s@%s/%d", getShipUser(), host, os.Getpid())
+}
+
+// describeShipLease will describe who holds the ship lock lease and for how long, for telling a user why they can't ship
+func describeShipLease(lease *coordinationv1.Lease, now time.Time) string {
+	holderIdentity := "(unknown)"
+	if lease.Spec.HolderIdentity != nil {
+		holderIdentity = *lease.Spec.HolderIdentity
+	}
+	description := fmt.Sprintf("held by %s", holderIdentity)
+	if lease.Spec.AcquireTime != nil {
+		description = fmt.Sprintf("%s for %s", description, now.Sub(lease.Spec.AcquireTime.Time).Round(time.Second))
+	}
+	if lease.Spec.RenewTime != nil {
+		description = fmt.Sprintf("%s, last renewed %s ago", description, now.Sub(lease.Spec.RenewTime.Time).Round(time.Second))
+	}
+	return description
+}
+
+// heartbeatShipLease will periodically renew the ship lock lease for as long as the ship runs, so that it isn't taken
+// over by another ship. Once the lease is lost, either taken over or not renewed for as long as it lasts, the release
+// is cancelled before the next chart the same as an avast. The returned function stops the renewing
+func (loftsman *Loftsman) heartbeatShipLease(leaseName string, holderIdentity string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(shipLeaseRenewInterval)
+		defer ticker.Stop()
+		lastRenewed := time.Now()
+		for {
+			select {
+			case <-ticker.C:
+				_, err := loftsman.kubernetes.RenewLease(leaseName, loftsman.Settings.Namespace, holderIdentity)
+				if err == nil {
+					lastRenewed = time.Now()
+					continue
+				}
+				if errors.Is(err, interfaces.ErrLeaseNotHeld) || time.Since(lastRenewed) >= shipLeaseDuration {
+					loftsman.shipLeaseMutex.Lock()
+					loftsman.shipLeaseLost = fmt.Errorf("Lost the ship lock lease %s in namespace %s, another ship of the manifest can take over: %s",
+						leaseName, loftsman.Settings.Namespace, err)
+					loftsman.shipLeaseMutex.Unlock()
+					loftsman.logger.Error().Msgf("%s. No further charts will be released", loftsman.getShipLeaseLost())
+					return
+				}
+				loftsman.logger.Warn().Msgf("Error renewing the ship lock lease %s in namespace %s, will try again: %s",
+					leaseName, loftsman.Settings.Namespace, err)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// getShipLeaseLost will return why the ship in progress lost its ship lock lease, or nil if it still holds it
+func (loftsman *Loftsman) getShipLeaseLost() error {
+	loftsman.shipLeaseMutex.Lock()
+	defer loftsman.shipLeaseMutex.Unlock()
+	return loftsman.shipLeaseLost
+}
+
+// releaseShipLease will release the ship lock lease so another ship of the manifest can start right away
+func (loftsman *Loftsman) releaseShipLease(leaseName string, holderIdentity string) {
+	if err := loftsman.kubernetes.ReleaseLease(leaseName, loftsman.Settings.Namespace, holderIdentity); err != nil {
+		loftsman.logger.Error().Err(fmt.Errorf("Error releasing the ship lock lease %s in the %s namespace, it will be taken over once it expires: %s",
+			leaseName, loftsman.Settings.Namespace, err)).Msg("")
 		fmt.Println("")
 	}
 }
 
+// getShipAvast will return whether the ship in progress has been avasted, and the reason it was avasted with
+func (loftsman *Loftsman) getShipAvast() (bool, string) {
+	loftsman.shipAvastMutex.Lock()
+	defer loftsman.shipAvastMutex.Unlock()
+	return loftsman.shipAvasted, loftsman.shipAvastReason
+}
+
+// checkShipAvast will check the ship configmap for an avast of the ship in progress from `loftsman avast`
+func (loftsman *Loftsman) checkShipAvast(configMapName string) error {
+	configMap, err := loftsman.kubernetes.GetConfigMap(configMapName, loftsman.Settings.Namespace)
+	if err != nil {
+		return err
+	}
+	if configMap == nil || configMap.Data[statusKey] != statusAvasted {
+		return nil
+	}
+	loftsman.shipAvastMutex.Lock()
+	defer loftsman.shipAvastMutex.Unlock()
+	loftsman.shipAvasted = true
+	loftsman.shipAvastReason = configMap.Data[avastReasonKey]
+	return nil
+}
+
+// watchShipAvast will periodically check the ship configmap for an avast of the ship in progress, once it's found the
+// release is cancelled before the next chart. The returned function stops the watching
+func (loftsman *Loftsman) watchShipAvast(configMapName string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(shipAvastWatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := loftsman.checkShipAvast(configMapName); err != nil {
+					loftsman.logger.Warn().Msgf("Error checking configmap %s in namespace %s for an avast of the ship, will try again: %s",
+						configMapName, loftsman.Settings.Namespace, err)
+					continue
+				}
+				if shipAvasted, shipAvastReason := loftsman.getShipAvast(); shipAvasted {
+					loftsman.logger.Warn().Msgf("The ship was avasted, reason: %s. No further charts will be released",
+						describeAvastReason(shipAvastReason))
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func describeAvastReason(reason string) string {
+	if reason == "" {
+		return "(none given)"
+	}
+	return reason
+}
+
+// Diff will compare the charts of a manifest, rendered with their manifest values, with the live Helm releases in the
+// cluster and print a unified diff for each resource that differs
+func (loftsman *Loftsman) Diff() error {
+	if loftsman.manifest == nil {
+		return loftsman.fail(errors.New("A manifest path is required in order to diff a manifest against the cluster"))
+	}
+
+	loftsman.logger.Header("Comparing your manifest with the live Helm releases in the cluster")
+	if err := loftsman.validateChartsDirectory(); err != nil {
+		return loftsman.fail(err)
+	}
+	loftsman.logger.Info().Msgf("Rendering and comparing the charts of the provided manifest at %s", loftsman.Settings.Manifest.Path)
+
+	loftsman.manifest.SetLogger(loftsman.logger)
+	loftsman.manifest.SetTempDirectory(loftsman.Settings.TempDirectory)
+	loftsman.manifest.SetGit(loftsman.git)
+	loftsman.manifest.SetSops(loftsman.sops)
+	diffEntries, releaseErrors := loftsman.manifest.Diff(loftsman.kubernetes, loftsman.helm)
+	for _, diffEntry := range diffEntries {
+		loftsman.logger.Info().
+			Str("chart", diffEntry.Chart).
+			Str("version", diffEntry.Version).
+			Str("namespace", diffEntry.Namespace).
+			Str("release", diffEntry.ReleaseName).
+			Str("resource", diffEntry.Resource).
+			Msgf("Found differences for %s", diffEntry.Resource)
+		fmt.Println(diffEntry.Diff)
+	}
+
+	if len(releaseErrors) > 0 {
+		loftsman.logReleaseErrors("Encountered errors while comparing the manifest with the cluster:", releaseErrors)
+		return loftsman.fail(errors.New("Some charts could not be compared, see above and/or the output log file for more info"))
+	}
+	if len(diffEntries) > 0 {
+		return loftsman.fail(fmt.Errorf("Found %d resource(s) that differ between the live releases and the manifest", len(diffEntries)))
+	}
+	loftsman.logger.Info().Msg("No differences found between the live releases and the manifest")
+	return nil
+}
+
 // ManifestCreate will create a new manifest and output it to stdout
 func (loftsman *Loftsman) ManifestCreate() error {
 	var err error
@@ -236,6 +1059,103 @@ func (loftsman *Loftsman) ManifestCreate() error {
 	return nil
 }
 
+// ManifestLock will resolve the version of each chart in a manifest against its charts source, and write the exact
+// versions and digests they resolved to to the manifest lock, which later ships release
+func (loftsman *Loftsman) ManifestLock() error {
+	var err error
+	if loftsman.manifest == nil {
+		return loftsman.fail(errors.New("A manifest path is required in order to lock a manifest"))
+	}
+	if err = loftsman.Settings.ValidateChartsSource(); err != nil {
+		return loftsman.fail(err)
+	}
+
+	loftsman.logger.Header("Locking the chart versions of your manifest")
+	if err = loftsman.validateChartsDirectory(); err != nil {
+		return loftsman.fail(err)
+	}
+
+	loftsman.manifest.SetLogger(loftsman.logger)
+	loftsman.manifest.SetTempDirectory(loftsman.Settings.TempDirectory)
+	loftsman.manifest.SetGit(loftsman.git)
+	loftsman.manifest.SetSops(loftsman.sops)
+	lock, releaseErrors := loftsman.manifest.Lock(loftsman.kubernetes, loftsman.helm)
+	if len(releaseErrors) > 0 {
+		loftsman.logReleaseErrors("Encountered errors while locking the manifest:", releaseErrors)
+		return loftsman.fail(errors.New("Some charts could not be locked, see above and/or the output log file for more info"))
+	}
+	for _, lockedChart := range lock.Charts {
+		loftsman.logger.Info().
+			Str("chart", lockedChart.Chart).
+			Str("version", lockedChart.Version).
+			Str("namespace", lockedChart.Namespace).
+			Str("release", lockedChart.ReleaseName).
+			Msgf("Locked release %s of chart %s %s to v%s", lockedChart.ReleaseName, lockedChart.Chart, lockedChart.Constraint, lockedChart.Version)
+	}
+
+	lockBytes, err := yaml.Marshal(lock)
+	if err != nil {
+		return loftsman.fail(fmt.Errorf("Error generating the manifest lock: %s", err))
+	}
+	lockPath := loftsman.Settings.GetManifestLockPath()
+	if err = ioutil.WriteFile(lockPath, lockBytes, 0644); err != nil {
+		return loftsman.fail(fmt.Errorf("Error writing the manifest lock to %s: %s", lockPath, err))
+	}
+	loftsman.logger.Info().Msgf("Wrote the manifest lock to %s", lockPath)
+	return nil
+}
+
+// ManifestRender will output a manifest composed with its imports and overlays to stdout, the manifest that's validated
+// and shipped
+func (loftsman *Loftsman) ManifestRender() error {
+	if loftsman.manifest == nil {
+		return loftsman.fail(errors.New("A manifest path is required in order to render a manifest"))
+	}
+	fmt.Print(string(loftsman.Settings.Manifest.Content))
+	return nil
+}
+
+// ManifestMigrate will convert a manifest to the latest schema version and output it to stdout, or write it over the
+// manifest file. The converted manifest is validated the same way the manifest was before it's output
+func (loftsman *Loftsman) ManifestMigrate() error {
+	if loftsman.manifest == nil {
+		return loftsman.fail(errors.New("A manifest path is required in order to migrate a manifest"))
+	}
+	manifestContent, err := ioutil.ReadFile(loftsman.Settings.Manifest.Path)
+	if err != nil {
+		return loftsman.fail(err)
+	}
+	migratedContent, err := manifest.Migrate(string(manifestContent))
+	if err != nil {
+		return loftsman.fail(fmt.Errorf("Error migrating %s: %s", loftsman.Settings.Manifest.Path, err))
+	}
+	manifestDirectory := filepath.Dir(loftsman.Settings.Manifest.Path)
+	renderedContent, err := manifest.Render(migratedContent, manifestDirectory)
+	if err != nil {
+		return loftsman.fail(err)
+	}
+	variables, err := loftsman.Settings.GetManifestVariables()
+	if err != nil {
+		return loftsman.fail(err)
+	}
+	if _, err = manifest.Validate(renderedContent, manifestDirectory, variables); err != nil {
+		return loftsman.fail(fmt.Errorf("The migrated manifest isn't valid: %s", err))
+	}
+	if !loftsman.Settings.Manifest.InPlace {
+		fmt.Print(migratedContent)
+		return nil
+	}
+	fileInfo, err := os.Stat(loftsman.Settings.Manifest.Path)
+	if err != nil {
+		return loftsman.fail(err)
+	}
+	if err = ioutil.WriteFile(loftsman.Settings.Manifest.Path, []byte(migratedContent), fileInfo.Mode()); err != nil {
+		return loftsman.fail(fmt.Errorf("Error writing the migrated manifest to %s: %s", loftsman.Settings.Manifest.Path, err))
+	}
+	loftsman.logger.Info().Msgf("Migrated %s to the latest manifest schema version", loftsman.Settings.Manifest.Path)
+	return nil
+}
+
 // ManifestValidate will validate a manifest
 func (loftsman *Loftsman) ManifestValidate(args ...string) error {
 	var err error
@@ -249,11 +1169,10 @@ func (loftsman *Loftsman) ManifestValidate(args ...string) error {
 	return nil
 }
 
-// Avast will look for an existing, locked manifest being shipped and tell it to halt
-// NOTE: this command/method won't actually be able to kill any existing loftsman cli operation
-// in progress, this is more about updating the state stored in the cluster about a given ship
-// run in the case of that getting stuck by catastrophic failures of the loftsman cli during
-// a ship
+// Avast will look for an existing, locked manifest being shipped and tell it to halt. A running ship
+// watches its configmap for this, and stops before releasing its next chart. A ship that got stuck
+// by catastrophic failures of the loftsman cli during a ship will just have the state stored in
+// the cluster cleared
 func (loftsman *Loftsman) Avast() error {
 	var err error
 	var response string
@@ -264,18 +1183,19 @@ func (loftsman *Loftsman) Avast() error {
 
 	loftsman.logger.Header(fmt.Sprintf("Clearing/halting any ship in progress for manifest: %s", loftsman.Settings.Manifest.Name))
 
-	fmt.Println(fmt.Sprintf(`WARNING: loftsman avast is currently used for unlocking stuck ship runs only, so those that might have
-         encountered fatal errors and left the loftsman cluster state stuck in the running position.")
-         If you have other loftsman ship runs going for the manifest, %s, please cancel them by simply")
-         killing the process. Use avast only to recover from bad loftsman states.`, loftsman.Settings.Manifest.Name))
-	fmt.Print("Do you want to continue? (only a response of 'yes' will continue with the avast operation): ")
-	_, err = fmt.Fscanln(loftsman.reader, &response)
-	if err != nil {
-		return loftsman.fail(err)
-	}
-	if response != "yes" {
-		loftsman.logger.Info().Msgf("User did not enter 'yes', not running avast")
-		return nil
+	if !loftsman.Settings.Avast.Yes {
+		fmt.Println(fmt.Sprintf(`WARNING: loftsman avast will halt the ship in progress for the manifest, %s. A running ship will
+         finish releasing the charts it's already releasing, then stop before releasing any more of them.
+         A ship that left the loftsman cluster state stuck in the running position will have it cleared.`, loftsman.Settings.Manifest.Name))
+		fmt.Print("Do you want to continue? (only a response of 'yes' will continue with the avast operation): ")
+		_, err = fmt.Fscanln(loftsman.reader, &response)
+		if err != nil {
+			return loftsman.fail(err)
+		}
+		if response != "yes" {
+			loftsman.logger.Info().Msgf("User did not enter 'yes', not running avast")
+			return nil
+		}
 	}
 
 	configMapName := fmt.Sprintf(shipConfigMapNameTemplate, loftsman.Settings.Manifest.Name)
@@ -288,11 +1208,13 @@ func (loftsman *Loftsman) Avast() error {
 		return loftsman.fail(fmt.Errorf("Couldn't find an active ship in progress for manifest: %s", loftsman.Settings.Manifest.Name))
 	}
 	activeConfigMap.Data[statusKey] = statusAvasted
+	activeConfigMap.Data[avastReasonKey] = loftsman.Settings.Avast.Reason
 	if _, err := loftsman.kubernetes.PatchConfigMap(configMapName, loftsman.Settings.Namespace, activeConfigMap.Data); err != nil {
-		loftsman.logger.Error().Err(fmt.Errorf("Error patching configmap %s with avasted status to the %s namespace: %s",
-			configMapName, loftsman.Settings.Namespace, err)).Msg("")
-		fmt.Println("")
+		return loftsman.fail(fmt.Errorf("Error patching configmap %s with avasted status to the %s namespace: %s",
+			configMapName, loftsman.Settings.Namespace, err))
 	}
+	loftsman.logger.Info().Msgf("Avasted the ship in progress for manifest %s, reason: %s", loftsman.Settings.Manifest.Name,
+		describeAvastReason(loftsman.Settings.Avast.Reason))
 
 	return nil
 }
@@ -309,6 +1231,8 @@ func NewLoftsman() *Loftsman {
 		reader:     os.Stdin,
 		manifest:   nil,
 		kubernetes: &kubernetes.Kubernetes{},
-		helm:       &helm.Helm{},
+		helm:       nil, // set on Initialize, once we know the helm backend to use
+		git:        &git.Git{},
+		sops:       &sops.Sops{},
 	}
 }
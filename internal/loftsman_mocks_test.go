@@ -13,6 +13,51 @@ import (
 
 var releaseErrors []*interfaces.ManifestReleaseError
 
+var diffEntries []*interfaces.ManifestDiffEntry
+
+func setDiffEntries() {
+	diffEntries = []*interfaces.ManifestDiffEntry{
+		&interfaces.ManifestDiffEntry{
+			Chart:       "tests",
+			Version:     "0.0.1",
+			Namespace:   "default",
+			ReleaseName: "tests",
+			Resource:    "ConfigMap/default/tests",
+			Diff:        "--- live/ConfigMap/default/tests\n+++ manifest/ConfigMap/default/tests\n",
+		},
+	}
+}
+func resetDiffEntries() {
+	diffEntries = []*interfaces.ManifestDiffEntry{}
+}
+
+var planEntries = []*interfaces.ManifestPlanEntry{
+	&interfaces.ManifestPlanEntry{
+		Chart:           "tests",
+		Version:         "0.0.1",
+		Namespace:       "default",
+		ReleaseName:     "tests",
+		ChartPath:       "/tmp/tests-0.0.1.tgz",
+		Action:          interfaces.ManifestPlanActionUpgrade,
+		CurrentVersion:  "0.0.0",
+		CurrentRevision: 1,
+	},
+}
+
+var manifestLock = &interfaces.ManifestLock{
+	Manifest: "test-manifest",
+	Charts: []*interfaces.ManifestLockedChart{
+		&interfaces.ManifestLockedChart{
+			Chart:       "tests",
+			ReleaseName: "tests",
+			Namespace:   "default",
+			Constraint:  "~0.0",
+			Version:     "0.0.1",
+			Digest:      "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		},
+	},
+}
+
 func setReleaseErrors(msg string) {
 	releaseErrors = []*interfaces.ManifestReleaseError{
 		&interfaces.ManifestReleaseError{
@@ -45,8 +90,21 @@ func getTestLoftsman(initializeForCommand string) *Loftsman {
 func getManifestMock() *mocks.Manifest {
 	m := &mocks.Manifest{}
 	m.On("GetName").Return("test-manifest")
+	m.On("GetReleases").Return([]*interfaces.ManifestRelease{
+		&interfaces.ManifestRelease{ReleaseName: "tests", Namespace: "default"},
+	})
+	m.On("GetPrune").Return(false)
 	m.On("SetLogger", mock.AnythingOfType("*logger.Logger"))
 	m.On("SetTempDirectory", mock.AnythingOfType("string"))
+	m.On("SetGit", mock.Anything)
+	m.On("SetSops", mock.Anything)
+	m.On("SetReleaseOptions", mock.AnythingOfType("*interfaces.ManifestReleaseOptions"))
+	m.On("SelectCharts", mock.AnythingOfType("*interfaces.ManifestChartSelection")).Return([]string{"tests"}, nil)
+	m.On("ValidateSpec").Return(nil)
 	m.On("Release", mock.AnythingOfType("*mocks.Kubernetes"), mock.AnythingOfType("*mocks.Helm")).Return(releaseErrors)
+	m.On("Diff", mock.AnythingOfType("*mocks.Kubernetes"), mock.AnythingOfType("*mocks.Helm")).Return(diffEntries, releaseErrors)
+	m.On("Plan", mock.AnythingOfType("*mocks.Kubernetes"), mock.AnythingOfType("*mocks.Helm")).Return(planEntries, releaseErrors)
+	m.On("ApplyLock", mock.AnythingOfType("*interfaces.ManifestLock")).Return(nil)
+	m.On("Lock", mock.AnythingOfType("*mocks.Kubernetes"), mock.AnythingOfType("*mocks.Helm")).Return(manifestLock, releaseErrors)
 	return m
 }
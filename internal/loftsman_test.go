@@ -2,10 +2,23 @@ package internal
 
 import (
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	"github.com/Cray-HPE/loftsman/internal/logger"
 	custommocks "github.com/Cray-HPE/loftsman/mocks/custom-mocks"
+	mocks "github.com/Cray-HPE/loftsman/mocks/interfaces"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/mock"
+	yaml "gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
 )
 
 func TestInitialize(t *testing.T) {
@@ -58,6 +71,20 @@ func TestShipChartsPath(t *testing.T) {
 	if err != nil {
 		t.Errorf("Got unexpected error from loftsman.TestShipChartsPath(): %s", err)
 	}
+	loftsman.helm.(*mocks.Helm).AssertCalled(t, "ValidateChartsDirectory", "./helm/.test-fixtures/charts")
+}
+
+func TestShipChartsPathUnreadable(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	helm := &mocks.Helm{}
+	helm.On("ValidateChartsDirectory", mock.AnythingOfType("string")).Return(nil, errors.New("permission denied"))
+	loftsman.helm = helm
+	err := loftsman.Ship()
+	if err == nil || !strings.Contains(err.Error(), "Error reading the packaged charts at ./helm/.test-fixtures/charts") {
+		t.Errorf("Didn't get expected error from loftsman.TestShipChartsPathUnreadable(), instead got: %s", err)
+	}
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertNotCalled(t, "AcquireLease", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestShipChartsRepo(t *testing.T) {
@@ -85,9 +112,126 @@ func TestShipWhenAnotherRunning(t *testing.T) {
 	loftsman.kubernetes = custommocks.GetKubernetesMock(true)
 	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
 	err := loftsman.Ship()
-	if err == nil || !strings.Contains(err.Error(), "another loftsman ship in progress") {
+	if err == nil || !strings.Contains(err.Error(), "another loftsman ship in progress") ||
+		!strings.Contains(err.Error(), "held by "+custommocks.TestLeaseHolderIdentity+" for 5m0s, last renewed 10s ago") {
 		t.Errorf("Didn't get expected error from loftsman.TestShipWhenAnotherRunning(), instead got: %s", err)
 	}
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertNotCalled(t, "InitializeShipConfigMap", mock.Anything, mock.Anything, mock.Anything)
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertNotCalled(t, "ReleaseLease", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestShipReleasesLease(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	err := loftsman.Ship()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestShipReleasesLease(): %s", err)
+	}
+	holderIdentity := getShipLeaseHolderIdentity()
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertCalled(t, "AcquireLease", "loftsman-test-manifest-ship-lock", "loftsman", holderIdentity, shipLeaseDuration)
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertCalled(t, "ReleaseLease", "loftsman-test-manifest-ship-lock", "loftsman", holderIdentity)
+}
+
+func TestShipAvasted(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.shipAvasted = true
+	loftsman.shipAvastReason = custommocks.TestAvastReason
+	err := loftsman.Ship()
+	if err == nil || !strings.Contains(err.Error(), "The ship was avasted, reason: "+custommocks.TestAvastReason) {
+		t.Errorf("Didn't get expected error from loftsman.TestShipAvasted(), instead got: %s", err)
+	}
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertCalled(t, "PatchConfigMap", "loftsman-test-manifest", "loftsman", mock.MatchedBy(func(data map[string]string) bool {
+		return data[statusKey] == statusAvasted && data[avastReasonKey] == custommocks.TestAvastReason
+	}))
+}
+
+func TestShipLeaseLost(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	kubernetes := loftsman.kubernetes.(*mocks.Kubernetes)
+	manifest := loftsman.manifest.(*mocks.Manifest)
+	var releaseOptions *interfaces.ManifestReleaseOptions
+	for _, expectedCall := range manifest.ExpectedCalls {
+		switch expectedCall.Method {
+		case "SetReleaseOptions":
+			expectedCall.Run(func(args mock.Arguments) {
+				releaseOptions = args.Get(0).(*interfaces.ManifestReleaseOptions)
+			})
+		case "Release":
+			// the lease is lost part-way through the ship, with a chart still in flight
+			expectedCall.Run(func(args mock.Arguments) {
+				kubernetes.Calls = nil
+				loftsman.shipLeaseMutex.Lock()
+				loftsman.shipLeaseLost = errors.New("Lost the ship lock lease loftsman-test-manifest-ship-lock in namespace loftsman")
+				loftsman.shipLeaseMutex.Unlock()
+				releaseOptions.OnChartResult(&interfaces.ManifestChartResult{Chart: "tests", Status: "success"})
+			})
+		}
+	}
+	err := loftsman.Ship()
+	if err == nil || !strings.Contains(err.Error(), "Lost the ship lock lease") {
+		t.Errorf("Didn't get expected error from loftsman.TestShipLeaseLost(), instead got: %s", err)
+	}
+	// the ship and log configmaps belong to the ship that can take over once the lease is lost
+	kubernetes.AssertNotCalled(t, "PatchConfigMap", "loftsman-test-manifest", mock.Anything, mock.Anything)
+	kubernetes.AssertNotCalled(t, "PatchConfigMap", "loftsman-test-manifest-ship-log", mock.Anything, mock.Anything)
+}
+
+func Test_heartbeatShipLease(t *testing.T) {
+	defer func(interval time.Duration) { shipLeaseRenewInterval = interval }(shipLeaseRenewInterval)
+	shipLeaseRenewInterval = 10 * time.Millisecond
+
+	loftsman := getTestLoftsman("ship")
+	kubernetes := &mocks.Kubernetes{}
+	kubernetes.On("RenewLease", "renew-error", mock.Anything, mock.Anything).Return(nil, errors.New("connection refused"))
+	kubernetes.On("RenewLease", "taken-over", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("%w, lease taken-over is no longer held by me", interfaces.ErrLeaseNotHeld))
+	loftsman.kubernetes = kubernetes
+
+	// errors renewing are tried again until the lease would have expired
+	stopShipLeaseHeartbeat := loftsman.heartbeatShipLease("renew-error", "me")
+	time.Sleep(5 * shipLeaseRenewInterval)
+	stopShipLeaseHeartbeat()
+	if err := loftsman.getShipLeaseLost(); err != nil {
+		t.Errorf("Didn't expect the lease to be lost from loftsman.Test_heartbeatShipLease(), got: %s", err)
+	}
+
+	stopShipLeaseHeartbeat = loftsman.heartbeatShipLease("taken-over", "me")
+	defer stopShipLeaseHeartbeat()
+	for i := 0; i < 100 && loftsman.getShipLeaseLost() == nil; i++ {
+		time.Sleep(shipLeaseRenewInterval)
+	}
+	if err := loftsman.getShipLeaseLost(); err == nil || !strings.Contains(err.Error(), "no longer held by me") {
+		t.Errorf("Didn't get expected lost lease from loftsman.Test_heartbeatShipLease(), got: %v", err)
+	}
+}
+
+func TestShipRecordsRedactedManifest(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.Settings.Manifest.Content = append(loftsman.Settings.Manifest.Content, []byte("        adminPassword: hunter2\n")...)
+	if err := loftsman.Ship(); err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestShipRecordsRedactedManifest(): %s", err)
+	}
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertCalled(t, "PatchConfigMap", "loftsman-test-manifest", "loftsman", mock.MatchedBy(func(data map[string]string) bool {
+		return strings.Contains(data["manifest.yaml"], "adminPassword: [REDACTED]") && !strings.Contains(data["manifest.yaml"], "hunter2")
+	}))
+}
+
+func Test_checkShipAvast(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	if err := loftsman.checkShipAvast("loftsman-test-manifest"); err != nil {
+		t.Errorf("Got unexpected error from loftsman.Test_checkShipAvast(): %s", err)
+	}
+	if shipAvasted, _ := loftsman.getShipAvast(); shipAvasted {
+		t.Error("Didn't expect the ship to be avasted in loftsman.Test_checkShipAvast()")
+	}
+	if err := loftsman.checkShipAvast("loftsman-avasted"); err != nil {
+		t.Errorf("Got unexpected error from loftsman.Test_checkShipAvast(): %s", err)
+	}
+	if shipAvasted, shipAvastReason := loftsman.getShipAvast(); !shipAvasted || shipAvastReason != custommocks.TestAvastReason {
+		t.Errorf("Expected the ship to be avasted in loftsman.Test_checkShipAvast(), got %t with reason %s", shipAvasted, shipAvastReason)
+	}
 }
 
 func TestShipFailure(t *testing.T) {
@@ -101,6 +245,492 @@ func TestShipFailure(t *testing.T) {
 	}
 }
 
+func TestShipResume(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.Settings.Ship.Resume = true
+	err := loftsman.Ship()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestShipResume(): %s", err)
+	}
+	loftsman.manifest.(*mocks.Manifest).AssertCalled(t, "SetReleaseOptions", mock.MatchedBy(func(releaseOptions *interfaces.ManifestReleaseOptions) bool {
+		return len(releaseOptions.ResumeFrom) == 1 && releaseOptions.ResumeFrom[0].Chart == "tests" &&
+			releaseOptions.ResumeFrom[0].Status == interfaces.ManifestChartStatusSuccess
+	}))
+}
+
+func writeTestManifestLock(t *testing.T, loftsman *Loftsman) {
+	loftsman.Settings.Manifest.LockPath = filepath.Join(t.TempDir(), "manifest.lock")
+	lockBytes, _ := yaml.Marshal(manifestLock)
+	if err := ioutil.WriteFile(loftsman.Settings.Manifest.LockPath, lockBytes, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShipManifestLock(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	writeTestManifestLock(t, loftsman)
+	err := loftsman.Ship()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestShipManifestLock(): %s", err)
+	}
+	loftsman.manifest.(*mocks.Manifest).AssertCalled(t, "ApplyLock", manifestLock)
+}
+
+func TestShipManifestLockOutOfDate(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	writeTestManifestLock(t, loftsman)
+	manifest := &mocks.Manifest{}
+	manifest.On("ApplyLock", mock.AnythingOfType("*interfaces.ManifestLock")).Return(errors.New("release tests isn't in the manifest lock"))
+	loftsman.manifest = manifest
+	err := loftsman.Ship()
+	if err == nil || !strings.Contains(err.Error(), "is out of date, release tests isn't in the manifest lock") {
+		t.Errorf("Didn't get expected error from loftsman.TestShipManifestLockOutOfDate(), instead got: %s", err)
+	}
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertNotCalled(t, "AcquireLease", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestShipManifestLockUpdate(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.Settings.Ship.Update = true
+	writeTestManifestLock(t, loftsman)
+	err := loftsman.Ship()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestShipManifestLockUpdate(): %s", err)
+	}
+	loftsman.manifest.(*mocks.Manifest).AssertNotCalled(t, "ApplyLock", mock.Anything)
+}
+
+func TestShipDryRun(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.Settings.Ship.DryRun = true
+	err := loftsman.Ship()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestShipDryRun(): %s", err)
+	}
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertNotCalled(t, "InitializeShipConfigMap", mock.Anything, mock.Anything, mock.Anything)
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertNotCalled(t, "EnsureNamespace", mock.Anything)
+}
+
+func TestShipDryRunReleasedOptions(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.Settings.Ship.DryRun = true
+	if err := loftsman.Ship(); err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestShipDryRunReleasedOptions(): %s", err)
+	}
+	loftsman.manifest.(*mocks.Manifest).AssertCalled(t, "SetReleaseOptions", mock.MatchedBy(func(options *interfaces.ManifestReleaseOptions) bool {
+		return len(options.ReleasedOptions) == 1 && options.ReleasedOptions[0].OptionsHash == "sha256:abc123"
+	}))
+}
+
+func TestShipRecordsReleasedOptions(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	manifest := loftsman.manifest.(*mocks.Manifest)
+	var releaseOptions *interfaces.ManifestReleaseOptions
+	for _, expectedCall := range manifest.ExpectedCalls {
+		switch expectedCall.Method {
+		case "SetReleaseOptions":
+			expectedCall.Run(func(args mock.Arguments) {
+				releaseOptions = args.Get(0).(*interfaces.ManifestReleaseOptions)
+			})
+		case "Release":
+			expectedCall.Run(func(args mock.Arguments) {
+				releaseOptions.OnChartResult(&interfaces.ManifestChartResult{Chart: "tests", Namespace: "default", ReleaseName: "tests",
+					OptionsHash: "sha256:def456", Status: interfaces.ManifestChartStatusSuccess})
+			})
+		}
+	}
+	if err := loftsman.Ship(); err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestShipRecordsReleasedOptions(): %s", err)
+	}
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertCalled(t, "PatchConfigMap", "loftsman-test-manifest", "loftsman", map[string]string{
+		releasedOptionsKey: `[{"releaseName":"tests","namespace":"default","optionsHash":"sha256:def456"}]`,
+	})
+}
+
+func Test_setReleasedOptions(t *testing.T) {
+	releasedOptions := []*interfaces.ManifestRelease{
+		&interfaces.ManifestRelease{ReleaseName: "released", Namespace: "default", OptionsHash: "sha256:abc123"},
+		&interfaces.ManifestRelease{ReleaseName: "other", Namespace: "default", OptionsHash: "sha256:abc123"},
+	}
+	released := setReleasedOptions(releasedOptions, &interfaces.ManifestChartResult{ReleaseName: "released", Namespace: "default",
+		OptionsHash: "sha256:def456", Status: interfaces.ManifestChartStatusSuccess})
+	if len(released) != 2 || released[0].ReleaseName != "other" || released[1].OptionsHash != "sha256:def456" {
+		t.Errorf("Didn't get expected updated options hash from loftsman.Test_setReleasedOptions(), got: %v", released)
+	}
+	// a release that was rolled back, or failed, was last released with options that aren't known
+	released = setReleasedOptions(releasedOptions, &interfaces.ManifestChartResult{ReleaseName: "released", Namespace: "default",
+		Status: interfaces.ManifestChartStatusRolledBack})
+	if len(released) != 1 || released[0].ReleaseName != "other" {
+		t.Errorf("Didn't get expected options hash removed from loftsman.Test_setReleasedOptions(), got: %v", released)
+	}
+}
+
+func TestShipDryRunPrune(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.Settings.Ship.DryRun = true
+	loftsman.Settings.Ship.Prune = true
+	err := loftsman.Ship()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestShipDryRunPrune(): %s", err)
+	}
+	if !strings.Contains(loftsman.logger.GetRecord(), "Ship would prune release removed") {
+		t.Errorf("Didn't find the prune candidate in the log of loftsman.TestShipDryRunPrune()")
+	}
+	loftsman.helm.(*mocks.Helm).AssertNotCalled(t, "Uninstall", "removed", "default", false)
+}
+
+func TestShipPrune(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.Settings.Ship.Prune = true
+	loftsman.reader = strings.NewReader("yes")
+	err := loftsman.Ship()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestShipPrune(): %s", err)
+	}
+	loftsman.helm.(*mocks.Helm).AssertCalled(t, "Uninstall", "removed", "default", false)
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertCalled(t, "PatchConfigMap", "loftsman-test-manifest", "loftsman", map[string]string{
+		ownedReleasesKey: `[{"releaseName":"tests","namespace":"default"}]`,
+	})
+}
+
+func TestShipPruneNotConfirmed(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.Settings.Ship.Prune = true
+	loftsman.reader = strings.NewReader("no")
+	err := loftsman.Ship()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestShipPruneNotConfirmed(): %s", err)
+	}
+	loftsman.helm.(*mocks.Helm).AssertNotCalled(t, "Uninstall", "removed", "default", false)
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertCalled(t, "PatchConfigMap", "loftsman-test-manifest", "loftsman", map[string]string{
+		ownedReleasesKey: custommocks.TestOwnedReleases,
+	})
+}
+
+func TestShipPruneAfterFailure(t *testing.T) {
+	setReleaseErrors("ERROR")
+	defer resetReleaseErrors()
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.Settings.Ship.Prune = true
+	loftsman.Settings.Ship.ConfirmPrune = true
+	err := loftsman.Ship()
+	if err == nil || !strings.Contains(err.Error(), "Some charts did not release successfully") {
+		t.Errorf("Didn't get expected error from loftsman.TestShipPruneAfterFailure(), instead got: %s", err)
+	}
+	loftsman.helm.(*mocks.Helm).AssertNotCalled(t, "Uninstall", "removed", "default", false)
+}
+
+func Test_pruneReleases(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	notPruned, pruneErrors := loftsman.pruneReleases([]*interfaces.ManifestRelease{
+		&interfaces.ManifestRelease{ReleaseName: "removed", Namespace: "default"},
+		&interfaces.ManifestRelease{ReleaseName: "failed-prune", Namespace: "default"},
+		&interfaces.ManifestRelease{ReleaseName: "already-removed", Namespace: "default"},
+	})
+	if len(notPruned) != 1 || notPruned[0].ReleaseName != "failed-prune" || len(pruneErrors) != 1 ||
+		!strings.Contains(pruneErrors[0].Error(), "Error pruning release failed-prune") {
+		t.Errorf("Didn't get expected results from loftsman.Test_pruneReleases(), got: %v, %v", notPruned, pruneErrors)
+	}
+
+	// only a release that isn't installed is pruned by not being found, not one whose namespace isn't
+	notPruned, pruneErrors = loftsman.pruneReleases([]*interfaces.ManifestRelease{
+		&interfaces.ManifestRelease{ReleaseName: "namespace-removed", Namespace: "removed"},
+	})
+	if len(notPruned) != 1 || notPruned[0].ReleaseName != "namespace-removed" || len(pruneErrors) != 1 {
+		t.Errorf("Didn't get expected results from loftsman.Test_pruneReleases() for a namespace not found, got: %v, %v", notPruned, pruneErrors)
+	}
+}
+
+func TestShipDryRunFailure(t *testing.T) {
+	setReleaseErrors("ERROR")
+	defer resetReleaseErrors()
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.Settings.Ship.DryRun = true
+	err := loftsman.Ship()
+	if err == nil || !strings.Contains(err.Error(), "Some charts could not be planned") {
+		t.Errorf("Didn't get expected error from loftsman.TestShipDryRunFailure(), instead got: %s", err)
+	}
+}
+
+func TestDiffNoDrift(t *testing.T) {
+	loftsman := getTestLoftsman("diff")
+	err := loftsman.Diff()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestDiffNoDrift(): %s", err)
+	}
+}
+
+func TestDiffDrift(t *testing.T) {
+	setDiffEntries()
+	defer resetDiffEntries()
+	loftsman := getTestLoftsman("diff")
+	err := loftsman.Diff()
+	if err == nil || !strings.Contains(err.Error(), "differ between the live releases and the manifest") {
+		t.Errorf("Didn't get expected error from loftsman.TestDiffDrift(), instead got: %s", err)
+	}
+}
+
+func TestDiffFailure(t *testing.T) {
+	setReleaseErrors("ERROR")
+	defer resetReleaseErrors()
+	loftsman := getTestLoftsman("diff")
+	err := loftsman.Diff()
+	if err == nil || !strings.Contains(err.Error(), "Some charts could not be compared") {
+		t.Errorf("Didn't get expected error from loftsman.TestDiffFailure(), instead got: %s", err)
+	}
+}
+
+func TestShipRecordsHistory(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	err := loftsman.Ship()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestShipRecordsHistory(): %s", err)
+	}
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertCalled(t, "InitializeHistoryConfigMap", "loftsman-test-manifest-ship-history", "loftsman")
+	if loftsman.shipHistoryEntry == nil || loftsman.shipHistoryEntry.Status != statusSuccess || loftsman.shipHistoryEntry.EndTime == nil ||
+		!strings.HasPrefix(loftsman.shipHistoryEntry.ManifestDigest, "sha256:") {
+		t.Errorf("Didn't get expected ship history entry from loftsman.TestShipRecordsHistory(), got: %v", loftsman.shipHistoryEntry)
+	}
+}
+
+func TestShipPartial(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.Settings.Ship.Prune = true
+	loftsman.Settings.Ship.Only = []string{"tests"}
+	loftsman.Settings.Ship.Selector = "tier=core"
+	err := loftsman.Ship()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestShipPartial(): %s", err)
+	}
+	loftsman.manifest.(*mocks.Manifest).AssertCalled(t, "SelectCharts", &interfaces.ManifestChartSelection{
+		Only:     []string{"tests"},
+		Selector: map[string]string{"tier": "core"},
+	})
+	if loftsman.shipHistoryEntry == nil || !loftsman.shipHistoryEntry.Partial || !reflect.DeepEqual(loftsman.shipHistoryEntry.Charts, []string{"tests"}) {
+		t.Errorf("Didn't get expected partial ship history entry from loftsman.TestShipPartial(), got: %v", loftsman.shipHistoryEntry)
+	}
+	// a partial ship never prunes, even with --prune
+	loftsman.helm.(*mocks.Helm).AssertNotCalled(t, "Uninstall", "removed", "default", false)
+}
+
+func TestShipInvalidSelector(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.Settings.Ship.Selector = "tier"
+	err := loftsman.Ship()
+	if err == nil || !strings.Contains(err.Error(), "selector tier must be of the form key=value") {
+		t.Errorf("Didn't get expected error from loftsman.TestShipInvalidSelector(), instead got: %s", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	loftsman := getTestLoftsman("history")
+	loftsman.Settings.Manifest.Name = ""
+	err := loftsman.History()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestHistory(): %s", err)
+	}
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertCalled(t, "ListConfigMaps", "loftsman", "loftsman.io/record=ship-history")
+}
+
+func TestHistoryManifestNameJSON(t *testing.T) {
+	loftsman := getTestLoftsman("history")
+	loftsman.Settings.Manifest.Name = "test-manifest"
+	loftsman.Settings.History.Output = "json"
+	err := loftsman.History()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestHistoryManifestNameJSON(): %s", err)
+	}
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertCalled(t, "GetConfigMap", "loftsman-test-manifest-ship-history", "loftsman")
+}
+
+func TestHistoryInvalidOutput(t *testing.T) {
+	loftsman := getTestLoftsman("history")
+	loftsman.Settings.History.Output = "yaml"
+	err := loftsman.History()
+	if err == nil || !strings.Contains(err.Error(), "history output yaml is not supported") {
+		t.Errorf("Didn't get expected error from loftsman.TestHistoryInvalidOutput(), instead got: %s", err)
+	}
+}
+
+func Test_trimShipHistory(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.Ship.HistoryLimit = 2
+	kubernetes := &mocks.Kubernetes{}
+	kubernetes.On("GetConfigMap", "loftsman-test-manifest-ship-history", "loftsman").Return(&v1.ConfigMap{
+		Data: map[string]string{
+			"20211209T200714Z-0a1b2c3d": custommocks.TestShipHistoryEntry,
+			"20211207T100000Z-00000000": custommocks.TestShipHistoryEntry,
+			"20211208T100000Z-00000000": custommocks.TestShipHistoryEntry,
+			"20211206T100000Z-00000000": custommocks.TestShipHistoryEntry,
+		},
+	}, nil)
+	kubernetes.On("RemoveConfigMapKeys", mock.Anything, mock.Anything, mock.Anything).Return(&v1.ConfigMap{}, nil)
+	loftsman.kubernetes = kubernetes
+
+	loftsman.trimShipHistory("loftsman-test-manifest-ship-history")
+	kubernetes.AssertCalled(t, "RemoveConfigMapKeys", "loftsman-test-manifest-ship-history", "loftsman", []string{
+		"20211206T100000Z-00000000", "20211207T100000Z-00000000",
+	})
+
+	// a history within the limit is left alone
+	loftsman.Settings.Ship.HistoryLimit = 4
+	kubernetes.Calls = nil
+	loftsman.trimShipHistory("loftsman-test-manifest-ship-history")
+	kubernetes.AssertNotCalled(t, "RemoveConfigMapKeys", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestShipInvalidHistoryLimit(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	loftsman.Settings.Ship.HistoryLimit = 0
+	err := loftsman.Ship()
+	if err == nil || !strings.Contains(err.Error(), "history limit 0 is not supported") {
+		t.Errorf("Didn't get expected error from loftsman.TestShipInvalidHistoryLimit(), instead got: %s", err)
+	}
+}
+
+func TestLogs(t *testing.T) {
+	loftsman := getTestLoftsman("logs")
+	err := loftsman.Logs()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestLogs(): %s", err)
+	}
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertCalled(t, "GetConfigMap", "loftsman-test-manifest-ship-log", "loftsman")
+}
+
+func TestLogsFollow(t *testing.T) {
+	loftsman := getTestLoftsman("logs")
+	loftsman.Settings.Logs.Follow = true
+	loftsman.Settings.Logs.ShipID = custommocks.TestShipID
+	err := loftsman.Logs()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestLogsFollow(): %s", err)
+	}
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertCalled(t, "GetConfigMap", "loftsman-test-manifest", "loftsman")
+}
+
+func TestLogsOtherShipID(t *testing.T) {
+	loftsman := getTestLoftsman("logs")
+	loftsman.Settings.Logs.Follow = true
+	loftsman.Settings.Logs.ShipID = custommocks.TestPastShipID
+	err := loftsman.Logs()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestLogsOtherShipID(): %s", err)
+	}
+	// an earlier ship is never in progress, so there's nothing to follow
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertNotCalled(t, "GetConfigMap", "loftsman-test-manifest", "loftsman")
+}
+
+func TestLogsShipIDNotKept(t *testing.T) {
+	loftsman := getTestLoftsman("logs")
+	loftsman.Settings.Logs.ShipID = "20211201T100000Z-00000000"
+	err := loftsman.Logs()
+	if err == nil || !strings.Contains(err.Error(), "only the logs of the latest 4 ships of a manifest are kept") {
+		t.Errorf("Didn't get expected error from loftsman.TestLogsShipIDNotKept(), instead got: %s", err)
+	}
+}
+
+func TestLogsDropped(t *testing.T) {
+	loftsman := getTestLoftsman("logs")
+	kubernetes := &mocks.Kubernetes{}
+	kubernetes.On("GetConfigMap", "loftsman-test-manifest-ship-log", "loftsman").Return(&v1.ConfigMap{
+		Data: map[string]string{
+			shipIDKey:         custommocks.TestShipID,
+			shipLogKey:        custommocks.TestShipLog,
+			shipLogDroppedKey: "300000",
+		},
+	}, nil)
+	kubernetes.On("GetConfigMap", "loftsman-test-manifest", "loftsman").Return(&v1.ConfigMap{
+		Data: map[string]string{statusKey: statusSuccess},
+	}, nil)
+	loftsman.kubernetes = kubernetes
+	loftsman.Settings.Logs.Follow = true
+	err := loftsman.Logs()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestLogsDropped(): %s", err)
+	}
+}
+
+func TestLogsInvalidLevel(t *testing.T) {
+	loftsman := getTestLoftsman("logs")
+	loftsman.Settings.Logs.Level = "loud"
+	err := loftsman.Logs()
+	if err == nil || !strings.Contains(err.Error(), "Invalid log level") {
+		t.Errorf("Didn't get expected error from loftsman.TestLogsInvalidLevel(), instead got: %s", err)
+	}
+}
+
+func Test_printShipLog(t *testing.T) {
+	var out strings.Builder
+	loftsman := getTestLoftsman("logs")
+	loftsman.Settings.Logs.Chart = "tests"
+	loftsman.printShipLog(logger.NewConsoleWriter(&out), custommocks.TestShipLog, zerolog.ErrorLevel)
+	if strings.Contains(out.String(), "Running") || !strings.Contains(out.String(), "Error releasing chart tests") {
+		t.Errorf("Didn't get expected filtered log from loftsman.Test_printShipLog(), got: %s", out.String())
+	}
+}
+
+func Test_truncateShipLog(t *testing.T) {
+	line := strings.Repeat("x", 1023) + "\n"
+	shipLog, dropped := truncateShipLog(strings.Repeat(line, 10))
+	if shipLog != strings.Repeat(line, 10) || dropped != 0 {
+		t.Errorf("Didn't expect a short log to be truncated by loftsman.Test_truncateShipLog(), dropped %d bytes", dropped)
+	}
+	// whole lines are dropped, including the one that doesn't fit in full
+	shipLog, dropped = truncateShipLog(strings.Repeat(line, 300) + "partial")
+	if shipLog != strings.Repeat(line, maxShipLogSize/len(line)-1)+"partial" || dropped+len(shipLog) != 300*len(line)+len("partial") {
+		t.Errorf("Didn't get expected truncated log from loftsman.Test_truncateShipLog(), got %d bytes with %d dropped", len(shipLog), dropped)
+	}
+	shipLog, dropped = truncateShipLog(strings.Repeat(line, 300))
+	if shipLog != strings.Repeat(line, maxShipLogSize/len(line)) || dropped != 100*len(line) {
+		t.Errorf("Didn't get expected truncated log from loftsman.Test_truncateShipLog(), got %d bytes with %d dropped", len(shipLog), dropped)
+	}
+}
+
+func Test_archiveShipLog(t *testing.T) {
+	loftsman := getTestLoftsman("ship")
+	kubernetes := &mocks.Kubernetes{}
+	kubernetes.On("GetConfigMap", "loftsman-test-manifest-ship-log", "loftsman").Return(&v1.ConfigMap{
+		Data: map[string]string{
+			shipIDKey:                               "20211209T200714Z-0a1b2c3d",
+			shipLogKey:                              custommocks.TestShipLog,
+			shipLogDroppedKey:                       "10",
+			"20211206T100000Z-00000000.log":         custommocks.TestShipLog,
+			"20211207T100000Z-00000000.log":         custommocks.TestShipLog,
+			"20211207T100000Z-00000000.log.dropped": "0",
+			"20211208T100000Z-00000000.log":         custommocks.TestShipLog,
+		},
+	}, nil)
+	kubernetes.On("RemoveConfigMapKeys", mock.Anything, mock.Anything, mock.Anything).Return(&v1.ConfigMap{}, nil)
+	kubernetes.On("PatchConfigMap", mock.Anything, mock.Anything, mock.Anything).Return(&v1.ConfigMap{}, nil)
+	loftsman.kubernetes = kubernetes
+
+	if err := loftsman.archiveShipLog("loftsman-test-manifest-ship-log"); err != nil {
+		t.Errorf("Got unexpected error from loftsman.Test_archiveShipLog(): %s", err)
+	}
+	kubernetes.AssertCalled(t, "RemoveConfigMapKeys", "loftsman-test-manifest-ship-log", "loftsman", []string{
+		"20211206T100000Z-00000000.log", "20211206T100000Z-00000000.log.dropped",
+	})
+	kubernetes.AssertCalled(t, "PatchConfigMap", "loftsman-test-manifest-ship-log", "loftsman", map[string]string{
+		"20211209T200714Z-0a1b2c3d.log":         custommocks.TestShipLog,
+		"20211209T200714Z-0a1b2c3d.log.dropped": "10",
+	})
+}
+
 func TestManifestCreate(t *testing.T) {
 	loftsman := getTestLoftsman("manifest create")
 	err := loftsman.ManifestCreate()
@@ -109,6 +739,100 @@ func TestManifestCreate(t *testing.T) {
 	}
 }
 
+func TestManifestLock(t *testing.T) {
+	loftsman := getTestLoftsman("manifest lock")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.Settings.Manifest.LockPath = filepath.Join(t.TempDir(), "manifest.lock")
+	err := loftsman.ManifestLock()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestManifestLock(): %s", err)
+		return
+	}
+	lockBytes, err := ioutil.ReadFile(loftsman.Settings.Manifest.LockPath)
+	if err != nil {
+		t.Errorf("Got unexpected error reading the lock in loftsman.TestManifestLock(): %s", err)
+		return
+	}
+	var lock *interfaces.ManifestLock
+	if err = yaml.Unmarshal(lockBytes, &lock); err != nil || !reflect.DeepEqual(lock, manifestLock) {
+		t.Errorf("Didn't get expected lock from loftsman.TestManifestLock(), got: %s", lockBytes)
+	}
+}
+
+func TestManifestLockFailure(t *testing.T) {
+	setReleaseErrors("ERROR")
+	defer resetReleaseErrors()
+	loftsman := getTestLoftsman("manifest lock")
+	loftsman.Settings.ChartsSource.Path = "./helm/.test-fixtures/charts"
+	loftsman.Settings.Manifest.LockPath = filepath.Join(t.TempDir(), "manifest.lock")
+	err := loftsman.ManifestLock()
+	if err == nil || !strings.Contains(err.Error(), "Some charts could not be locked") {
+		t.Errorf("Didn't get expected error from loftsman.TestManifestLockFailure(), instead got: %s", err)
+	}
+	if _, err = os.Stat(loftsman.Settings.Manifest.LockPath); !os.IsNotExist(err) {
+		t.Errorf("Didn't expect loftsman.TestManifestLockFailure() to write a lock")
+	}
+}
+
+func TestManifestRender(t *testing.T) {
+	loftsman := getTestLoftsman("")
+	loftsman.manifest = nil
+	loftsman.Settings.Manifest.Path = "./.test-fixtures/manifest-v1beta1-imports.yaml"
+	if err := loftsman.Initialize("manifest render"); err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestManifestRender(): %s", err)
+		return
+	}
+	if err := loftsman.ManifestRender(); err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestManifestRender(): %s", err)
+	}
+	rendered := string(loftsman.Settings.Manifest.Content)
+	for _, expected := range []string{"name: test-manifest-imports", "version: 1.0.1", "a: A", "b: BB"} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Didn't get expected %s in the manifest from loftsman.TestManifestRender(), got:\n%s", expected, rendered)
+		}
+	}
+	if loftsman.Settings.Manifest.Name != "test-manifest-imports" {
+		t.Errorf("Didn't get expected manifest name from loftsman.TestManifestRender(), got: %s", loftsman.Settings.Manifest.Name)
+	}
+}
+
+func TestManifestRenderMissingManifest(t *testing.T) {
+	loftsman := getTestLoftsman("manifest render")
+	loftsman.manifest = nil
+	err := loftsman.ManifestRender()
+	if err == nil || !strings.Contains(err.Error(), "A manifest path is required") {
+		t.Errorf("Didn't get expected error from loftsman.TestManifestRenderMissingManifest(), instead got: %s", err)
+	}
+}
+
+func TestManifestMigrate(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), "manifest.yaml")
+	manifestContent, _ := ioutil.ReadFile("./.test-fixtures/manifest-v1beta1.yaml")
+	if err := ioutil.WriteFile(manifestPath, manifestContent, 0640); err != nil {
+		t.Fatal(err)
+	}
+	loftsman := getTestLoftsman("")
+	loftsman.manifest = nil
+	loftsman.Settings.Manifest.Path = manifestPath
+	loftsman.Settings.Manifest.InPlace = true
+	if err := loftsman.Initialize("manifest migrate"); err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestManifestMigrate(): %s", err)
+		return
+	}
+	if err := loftsman.ManifestMigrate(); err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestManifestMigrate(): %s", err)
+		return
+	}
+	migratedContent, _ := ioutil.ReadFile(manifestPath)
+	expected := strings.Replace(string(manifestContent), "apiVersion: manifests/v1beta1", "apiVersion: manifests/v1", 1)
+	if string(migratedContent) != expected {
+		t.Errorf("Didn't get expected migrated manifest from loftsman.TestManifestMigrate(), got:\n%s", migratedContent)
+	}
+	if err := loftsman.ManifestMigrate(); err == nil || !strings.Contains(err.Error(), "already manifests/v1") {
+		t.Errorf("Didn't get expected error migrating again from loftsman.TestManifestMigrate(), instead got: %s", err)
+	}
+}
+
 func TestManifestValidateV1Beta1(t *testing.T) {
 	loftsman := getTestLoftsman("manifest validate")
 	err := loftsman.ManifestValidate("./.test-fixtures/manifest-v1beta1.yaml")
@@ -146,6 +870,25 @@ func TestAvastActiveConfigmapFound(t *testing.T) {
 	if err != nil {
 		t.Errorf("Got unexpected error from loftsman.TestAvastActiveConfigmapFound(): %s", err)
 	}
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertCalled(t, "PatchConfigMap", "loftsman-test-manifest", "loftsman", mock.MatchedBy(func(data map[string]string) bool {
+		return data[statusKey] == statusAvasted
+	}))
+}
+
+func TestAvastYesWithReason(t *testing.T) {
+	loftsman := getTestLoftsman("avast")
+	loftsman.kubernetes = custommocks.GetKubernetesMock(true)
+	loftsman.Settings.Manifest.Name = "test-manifest"
+	loftsman.Settings.Avast.Yes = true
+	loftsman.Settings.Avast.Reason = "wrong manifest"
+	loftsman.reader = strings.NewReader("")
+	err := loftsman.Avast()
+	if err != nil {
+		t.Errorf("Got unexpected error from loftsman.TestAvastYesWithReason(): %s", err)
+	}
+	loftsman.kubernetes.(*mocks.Kubernetes).AssertCalled(t, "PatchConfigMap", "loftsman-test-manifest", "loftsman", mock.MatchedBy(func(data map[string]string) bool {
+		return data[statusKey] == statusAvasted && data[avastReasonKey] == "wrong manifest"
+	}))
 }
 
 func TestAvastManifestPathActiveConfigmapFound(t *testing.T) {
@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -22,12 +23,31 @@ const (
 )
 
 var (
-	record *strings.Builder
+	record *logRecord
 )
 
+// logRecord is the saved log record of a cli run, safe to read while it's still being written to
+type logRecord struct {
+	mutex   sync.Mutex
+	builder strings.Builder
+}
+
+func (r *logRecord) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.builder.Write(p)
+}
+
+func (r *logRecord) String() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.builder.String()
+}
+
 // Logger is our main logger object
 type Logger struct {
 	zerolog.Logger
+	redactor *redactor
 }
 
 type consoleWriter struct {
@@ -51,6 +71,12 @@ func (cw consoleWriter) Write(p []byte) (int, error) {
 	return cw.zerologConsoleWriter.Write(p)
 }
 
+// NewConsoleWriter will return a writer that formats JSON log lines, like those of a log record, the same way they're
+// written to the console as they're logged
+func NewConsoleWriter(out io.Writer) io.Writer {
+	return consoleWriter{zerologConsoleWriter: zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339}}
+}
+
 // GetHelpLogo will return the logo to display in the CLI help
 func GetHelpLogo() string {
 	return fmt.Sprintf(`%s%s   _        __ _                                %s%s|\
@@ -102,11 +128,31 @@ func (log *Logger) GetRecord() string {
 	return record.String()
 }
 
+// AddSensitiveValues will add values, like the secrets in chart values, to redact from all log output wherever they
+// appear, other than ones shorter than MinSensitiveValueLength. Values of keys named secret, or ending in password,
+// token, or a kind of key like apiKey or privateKey, are always redacted
+func (log *Logger) AddSensitiveValues(values ...string) {
+	log.redactor.addValues(values...)
+}
+
+// Redact will return text with the same secrets redacted that are redacted from log output, for other records of a run
+// stored in the cluster
+func (log *Logger) Redact(text string) string {
+	return log.redactor.redact(text)
+}
+
 // New will return a new instance of a logger
 func New(jsonLogFile *os.File, commandName string) *Logger {
+	return NewWithConsoleOutput(jsonLogFile, commandName, os.Stdout)
+}
+
+// NewWithConsoleOutput will return a new instance of a logger writing its console logs to a particular output, for
+// commands where stdout is reserved for the command's own output
+func NewWithConsoleOutput(jsonLogFile *os.File, commandName string, consoleOutput io.Writer) *Logger {
 	var multiWriter io.Writer
-	record = &strings.Builder{}
-	zerologConsoleWriter := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+	record = &logRecord{}
+	redactor := &redactor{}
+	zerologConsoleWriter := zerolog.ConsoleWriter{Out: consoleOutput, TimeFormat: time.RFC3339}
 	_, err := jsonLogFile.Stat()
 	if err == nil {
 		multiWriter = io.MultiWriter(consoleWriter{zerologConsoleWriter: zerologConsoleWriter}, jsonLogFile, record)
@@ -114,6 +160,7 @@ func New(jsonLogFile *os.File, commandName string) *Logger {
 		multiWriter = io.MultiWriter(consoleWriter{zerologConsoleWriter: zerologConsoleWriter}, record)
 	}
 	return &Logger{
-		zerolog.New(multiWriter).With().Str("command", commandName).Timestamp().Logger(),
+		zerolog.New(zerolog.SyncWriter(redactingWriter{redactor: redactor, out: multiWriter})).With().Str("command", commandName).Timestamp().Logger(),
+		redactor,
 	}
 }
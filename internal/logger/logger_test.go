@@ -3,6 +3,7 @@ package logger
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -15,6 +16,11 @@ func TestNew(t *testing.T) {
 	New(getLogFile(), "loftsman-tests-logger")
 }
 
+func TestNewWithConsoleOutput(t *testing.T) {
+	l := NewWithConsoleOutput(getLogFile(), "loftsman-tests-logger", os.Stderr)
+	l.Info().Msg("test")
+}
+
 func TestGetHelpLogo(t *testing.T) {
 	GetHelpLogo()
 }
@@ -33,3 +39,13 @@ func TestClosingHeader(t *testing.T) {
 	l := New(getLogFile(), "loftsman-tests-logger")
 	l.ClosingHeader("test")
 }
+
+func TestNewConsoleWriter(t *testing.T) {
+	var out strings.Builder
+	w := NewConsoleWriter(&out)
+	_, _ = w.Write([]byte(`{"level":"info","command":"ship","time":"2021-12-09T14:08:31-06:00","message":"test message"}`))
+	_, _ = w.Write([]byte(`{"command":"ship","header":"test header","time":"2021-12-09T14:08:31-06:00"}`))
+	if !strings.Contains(out.String(), "test message") || strings.Contains(out.String(), "test header") {
+		t.Errorf("Didn't get expected console output from logger.TestNewConsoleWriter(), got: %s", out.String())
+	}
+}
@@ -0,0 +1,172 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// Redacted is what redacted values are replaced with in log output
+	Redacted = "[REDACTED]"
+	// MinSensitiveValueLength is the length of the shortest value added as sensitive that's redacted, shorter values
+	// would match too much of the log output
+	MinSensitiveValueLength = 4
+
+	// sensitiveKeyName matches the whole last segment of a key path whose value is redacted, a key named secret, or
+	// one ending in password, token, or the name of a kind of key or client secret. Keys that only name where a
+	// secret is, like passwordKey or keyringSecret, are left alone
+	sensitiveKeyName = `(?:[\w-]*(?:password|passwd|token|api_?key|private_?key|secret_?key|access_?key|client_?secret)|secret)`
+)
+
+var (
+	// sensitiveKey matches the names of keys whose values are redacted, or dot-separated paths of them
+	sensitiveKey = regexp.MustCompile(`(?i)^(?:[\w.-]*\.)?` + sensitiveKeyName + `$`)
+	// yamlKeyLine matches a yaml line of a key and its value, or a list item of one
+	yamlKeyLine = regexp.MustCompile(`^(\s*(?:-\s+)?["']?([\w.-]+)["']?:)(\s+(.*))?$`)
+	// sensitivePair matches a key=value pair with a sensitive key, like a command line flag or env var
+	sensitivePair = regexp.MustCompile(`(?i)(^|[^\w.-])((?:[\w.-]*\.)?` + sensitiveKeyName + `=)("[^"]*"|\S+)`)
+	// sensitiveJSONPair matches a "key": "value" pair of json with a sensitive key
+	sensitiveJSONPair = regexp.MustCompile(`(?i)("(?:[\w.-]*\.)?` + sensitiveKeyName + `"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// encryptedValue matches a value encrypted with sops, which is left as it is since it's only ciphertext
+	encryptedValue = regexp.MustCompile(`^["']?ENC\[`)
+
+	// unredactedFields are the fields of JSON log lines that are never redacted
+	unredactedFields = map[string]bool{
+		zerolog.TimestampFieldName: true,
+		zerolog.LevelFieldName:     true,
+	}
+)
+
+// redactor redacts secrets from log output, the values of keys with sensitive names, and any values explicitly added as
+// sensitive
+type redactor struct {
+	mutex    sync.Mutex
+	values   []string
+	replacer *strings.Replacer
+}
+
+// addValues will add values to redact wherever they appear, other than ones shorter than MinSensitiveValueLength
+func (r *redactor) addValues(values ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, value := range values {
+		if len(value) >= MinSensitiveValueLength {
+			r.values = append(r.values, value)
+		}
+	}
+	// longer values first, so that a value containing another is redacted whole
+	sort.SliceStable(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+	oldnew := []string{}
+	for _, value := range r.values {
+		oldnew = append(oldnew, value, Redacted)
+	}
+	r.replacer = strings.NewReplacer(oldnew...)
+}
+
+// redact will return text with its secrets redacted
+func (r *redactor) redact(text string) string {
+	r.mutex.Lock()
+	replacer := r.replacer
+	r.mutex.Unlock()
+	if replacer != nil {
+		text = replacer.Replace(text)
+	}
+	lines := strings.Split(text, "\n")
+	redactedLines := []string{}
+	blockIndent := -1
+	for _, line := range lines {
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if blockIndent >= 0 {
+			// the rest of a redacted yaml block scalar, which is left out
+			if strings.TrimSpace(line) == "" || indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+		if match := yamlKeyLine.FindStringSubmatch(line); match != nil && match[4] != "" && sensitiveKey.MatchString(match[2]) &&
+			!encryptedValue.MatchString(match[4]) {
+			if strings.HasPrefix(match[4], "|") || strings.HasPrefix(match[4], ">") {
+				blockIndent = indent
+			}
+			line = match[1] + " " + Redacted
+		}
+		line = sensitivePair.ReplaceAllString(line, "${1}${2}"+Redacted)
+		line = sensitiveJSONPair.ReplaceAllString(line, `${1}"`+Redacted+`"`)
+		redactedLines = append(redactedLines, line)
+	}
+	return strings.Join(redactedLines, "\n")
+}
+
+// redactLogLine will redact the fields of a JSON log line, the message and any other strings, as well as fields with
+// sensitive names. Only the redacted strings are replaced in the line, so that its fields stay in the order they were
+// logged in
+func (r *redactor) redactLogLine(p []byte) []byte {
+	var line map[string]interface{}
+	if err := json.Unmarshal(p, &line); err != nil {
+		return []byte(r.redact(string(p)))
+	}
+	redactedLine := p
+	changed := false
+	for key, value := range line {
+		stringValue, ok := value.(string)
+		if !ok || unredactedFields[key] {
+			continue
+		}
+		redactedValue := r.redact(stringValue)
+		if sensitiveKey.MatchString(key) {
+			redactedValue = Redacted
+		}
+		if redactedValue == stringValue {
+			continue
+		}
+		line[key] = redactedValue
+		changed = true
+		original, redacted := encodeJSONString(stringValue), encodeJSONString(redactedValue)
+		if redactedLine != nil && bytes.Contains(redactedLine, original) {
+			redactedLine = bytes.Replace(redactedLine, original, redacted, 1)
+		} else {
+			// the string was escaped differently than encoding/json would, so the whole line is encoded again instead
+			redactedLine = nil
+		}
+	}
+	if !changed {
+		return p
+	}
+	if redactedLine == nil {
+		encoded, err := json.Marshal(line)
+		if err != nil {
+			return []byte(r.redact(string(p)))
+		}
+		redactedLine = append(encoded, '\n')
+	}
+	return redactedLine
+}
+
+// encodeJSONString returns a string encoded as JSON, escaped the same way zerolog escapes it
+func encodeJSONString(value string) []byte {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n"))
+}
+
+// redactingWriter redacts each JSON log line before it's written to the console, the JSON log, and the log record
+type redactingWriter struct {
+	redactor *redactor
+	out      io.Writer
+}
+
+func (w redactingWriter) Write(p []byte) (int, error) {
+	if _, err := w.out.Write(w.redactor.redactLogLine(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cray-HPE/loftsman/internal/logger"
+	"github.com/rs/zerolog"
+)
+
+// Logs will print the stored log of the latest ship of a manifest, or of an earlier one by its ship ID, optionally
+// following it until the ship finishes
+func (loftsman *Loftsman) Logs() error {
+	var err error
+	minLevel := zerolog.TraceLevel
+
+	if loftsman.Settings.Manifest.Name == "" {
+		return loftsman.fail(errors.New("Unable to determine the manifest to print the ship log of, a manifest name must be provided"))
+	}
+	if loftsman.Settings.Logs.Level != "" {
+		if minLevel, err = zerolog.ParseLevel(loftsman.Settings.Logs.Level); err != nil {
+			return loftsman.fail(fmt.Errorf("Invalid log level %s: %s", loftsman.Settings.Logs.Level, err))
+		}
+	}
+
+	logConfigMapName := fmt.Sprintf(logConfigMapNameTemplate, loftsman.Settings.Manifest.Name)
+	shipConfigMapName := fmt.Sprintf(shipConfigMapNameTemplate, loftsman.Settings.Manifest.Name)
+	consoleWriter := logger.NewConsoleWriter(os.Stdout)
+	shipID := ""
+	printed := 0
+	finished := false
+	for {
+		logConfigMap, err := loftsman.kubernetes.GetConfigMap(logConfigMapName, loftsman.Settings.Namespace)
+		if err != nil {
+			return loftsman.fail(fmt.Errorf("Error getting the ship log configmap %s: %s", logConfigMapName, err))
+		}
+		if logConfigMap == nil {
+			return loftsman.fail(fmt.Errorf("Couldn't find a ship log for manifest: %s", loftsman.Settings.Manifest.Name))
+		}
+		logShipID := logConfigMap.Data[shipIDKey]
+		logKey, droppedKey := shipLogKey, shipLogDroppedKey
+		if loftsman.Settings.Logs.ShipID != "" && loftsman.Settings.Logs.ShipID != logShipID {
+			logShipID = loftsman.Settings.Logs.ShipID
+			logKey, droppedKey = logShipID+shipLogSuffix, logShipID+shipLogDroppedSuffix
+			if _, ok := logConfigMap.Data[logKey]; !ok {
+				return loftsman.fail(fmt.Errorf("Couldn't find the log of ship %s of manifest %s, only the logs of the latest %d ships of a manifest are kept",
+					logShipID, loftsman.Settings.Manifest.Name, shipLogRetention))
+			}
+			// only the latest ship can still be in progress
+			finished = true
+		}
+		shipLog := logConfigMap.Data[logKey]
+		// printed counts from the start of the whole log, including what was dropped to fit it in the configmap
+		dropped, _ := strconv.Atoi(logConfigMap.Data[droppedKey])
+		if logShipID != shipID || printed > dropped+len(shipLog) {
+			// a new ship has started since we last looked, so start again from the beginning of its log
+			shipID = logShipID
+			printed = 0
+		}
+		if printed < dropped {
+			loftsman.logger.Warn().Msgf("Skipping %d bytes of the log of ship %s, they were dropped to fit the log in configmap %s",
+				dropped-printed, shipID, logConfigMapName)
+			printed = dropped
+		}
+		// only print complete lines, the rest will be printed once it's been stored in full
+		if lastNewline := strings.LastIndex(shipLog[printed-dropped:], "\n"); lastNewline >= 0 {
+			loftsman.printShipLog(consoleWriter, shipLog[printed-dropped:printed-dropped+lastNewline+1], minLevel)
+			printed += lastNewline + 1
+		}
+		if !loftsman.Settings.Logs.Follow || finished {
+			return nil
+		}
+
+		shipConfigMap, err := loftsman.kubernetes.GetConfigMap(shipConfigMapName, loftsman.Settings.Namespace)
+		if err != nil {
+			return loftsman.fail(fmt.Errorf("Error getting the ship configmap %s: %s", shipConfigMapName, err))
+		}
+		if shipConfigMap == nil || shipConfigMap.Data[statusKey] != statusActive {
+			// the ship records its result before its final log, so take one last look for the rest of the log
+			finished = true
+			continue
+		}
+		time.Sleep(logsFollowInterval)
+	}
+}
+
+// printShipLog will print JSON log lines of a stored ship log with the console formatter, skipping those that don't
+// match the chart or level filters
+func (loftsman *Loftsman) printShipLog(writer io.Writer, shipLog string, minLevel zerolog.Level) {
+	for _, line := range strings.Split(shipLog, "\n") {
+		var logLine map[string]interface{}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(line), &logLine); err != nil {
+			continue
+		}
+		if loftsman.Settings.Logs.Chart != "" && logLine["chart"] != loftsman.Settings.Logs.Chart {
+			continue
+		}
+		if levelString, ok := logLine["level"].(string); ok {
+			level, err := zerolog.ParseLevel(levelString)
+			if err == nil && level < minLevel {
+				continue
+			}
+		}
+		_, _ = writer.Write([]byte(line))
+	}
+}
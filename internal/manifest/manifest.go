@@ -8,6 +8,7 @@ import (
 	"github.com/xeipuuv/gojsonschema"
 	yaml "gopkg.in/yaml.v2"
 	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	"github.com/Cray-HPE/loftsman/schemas/manifests/v1"
 	"github.com/Cray-HPE/loftsman/schemas/manifests/v1beta1"
 )
 
@@ -27,17 +28,25 @@ func getVersion(manifestContent string) (string, error) {
 	return manifest.APIVersion, nil
 }
 
-// Validate will accept a string that is the manifest file content and validate it
-func Validate(manifestContent string) (interfaces.Manifest, error) {
+// Validate will accept a string that is the manifest file content and validate it, the paths in the manifest being
+// relative to the manifest directory. The manifest's ${NAME} variables are substituted first, with the variables given
+// taking precedence over the defaults in the manifest's spec.variables
+func Validate(manifestContent string, manifestDirectory string, variables map[string]string) (interfaces.Manifest, error) {
 	var err error
 	var manifest interfaces.Manifest
 	var schema gojsonschema.JSONLoader
 	var document gojsonschema.JSONLoader
+	if manifestContent, err = substituteVariables(manifestContent, variables); err != nil {
+		return nil, err
+	}
 	apiVersion, err := getVersion(manifestContent)
 	if err != nil {
 		return nil, err
 	}
 	switch apiVersion {
+	case v1.APIVersion:
+		manifest = &v1.Manifest{}
+		schema = gojsonschema.NewStringLoader(v1.Schema)
 	case v1beta1.APIVersion:
 		manifest = &v1beta1.Manifest{}
 		schema = gojsonschema.NewStringLoader(v1beta1.Schema)
@@ -45,8 +54,9 @@ func Validate(manifestContent string) (interfaces.Manifest, error) {
 		return nil, fmt.Errorf("the manifest apiVersion is not supported: %s", apiVersion)
 	}
 	if err = manifest.Load(manifestContent); err != nil {
-		return nil, fmt.Errorf("could not parse the manifest as %s yaml: %s", v1beta1.APIVersion, err)
+		return nil, fmt.Errorf("could not parse the manifest as %s yaml: %s", apiVersion, err)
 	}
+	manifest.SetDirectory(manifestDirectory)
 	document = gojsonschema.NewGoLoader(manifest)
 	result, err := gojsonschema.Validate(schema, document)
 	if err != nil {
@@ -59,11 +69,14 @@ func Validate(manifestContent string) (interfaces.Manifest, error) {
 		}
 		return nil, errors.New(errorList)
 	}
+	if err = manifest.ValidateSpec(); err != nil {
+		return nil, fmt.Errorf("manifest validation errors: %s", err)
+	}
 	return manifest, nil
 }
 
 // Create is the entrypoint for creating a baseline manifest for the most-recent schema version
 func Create(initializeCharts []string) (string, error) {
-	manifestV1Beta1 := v1beta1.Manifest{}
-	return manifestV1Beta1.Create(initializeCharts)
+	manifestV1 := v1.Manifest{}
+	return manifestV1.Create(initializeCharts)
 }
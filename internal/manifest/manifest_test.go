@@ -10,7 +10,7 @@ func TestValidateInvalidAPIVersion(t *testing.T) {
 	manifest := `---
 apiVersion: v0
 `
-	_, err := Validate(manifest)
+	_, err := Validate(manifest, ".", nil)
 	if err == nil || err.Error() != "the manifest apiVersion is not supported: v0" {
 		t.Errorf("Didn't get expected error from manifest.TestValidateInvalidAPIVersion(), instead got: %s", err)
 	}
@@ -26,4 +26,7 @@ func TestCreate(t *testing.T) {
 	if err != nil {
 		t.Errorf("could not parse the manifest from TestManifestCreate() as yaml: %s", err)
 	}
+	if manifest.APIVersion != "manifests/v1" {
+		t.Errorf("Didn't get expected apiVersion from TestManifestCreate(), got: %s", manifest.APIVersion)
+	}
 }
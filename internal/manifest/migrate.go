@@ -0,0 +1,51 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/Cray-HPE/loftsman/schemas/manifests/v1"
+	"github.com/Cray-HPE/loftsman/schemas/manifests/v1beta1"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// apiVersionLine matches the top-level apiVersion of a manifest, and anything quoting or commenting it
+var apiVersionLine = regexp.MustCompile(`(?m)^(apiVersion:\s*["']?)([^"'\s#]+)(["']?\s*(?:#.*)?)$`)
+
+// Migrate will convert a manifest to the latest schema version. A manifests/v1beta1 manifest is a valid manifests/v1
+// manifest once its apiVersion is changed, so only the apiVersion line is changed, keeping everything else in the
+// manifest as it was written, comments included
+func Migrate(manifestContent string) (string, error) {
+	apiVersion, err := getVersion(manifestContent)
+	if err != nil {
+		return "", err
+	}
+	switch apiVersion {
+	case v1.APIVersion:
+		return "", fmt.Errorf("the manifest is already %s", v1.APIVersion)
+	case v1beta1.APIVersion:
+	default:
+		return "", fmt.Errorf("the manifest apiVersion is not supported: %s", apiVersion)
+	}
+	migrated := ""
+	if matches := apiVersionLine.FindAllStringSubmatchIndex(manifestContent, -1); len(matches) == 1 {
+		versionStart, versionEnd := matches[0][4], matches[0][5]
+		migrated = manifestContent[:versionStart] + v1.APIVersion + manifestContent[versionEnd:]
+	} else {
+		// the apiVersion isn't on a line of its own, e.g. in a flow style manifest, so it's set on the parsed manifest
+		// instead, which keeps everything but comments
+		var manifest yaml.MapSlice
+		if err = yaml.Unmarshal([]byte(manifestContent), &manifest); err != nil {
+			return "", err
+		}
+		migratedBytes, err := yaml.Marshal(setKey(manifest, "apiVersion", v1.APIVersion))
+		if err != nil {
+			return "", err
+		}
+		migrated = string(migratedBytes)
+	}
+	if migratedVersion, err := getVersion(migrated); err != nil || migratedVersion != v1.APIVersion {
+		return "", fmt.Errorf("couldn't change the apiVersion of the manifest to %s", v1.APIVersion)
+	}
+	return migrated, nil
+}
@@ -0,0 +1,57 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	manifest := `---
+# the lab manifest
+apiVersion: "manifests/v1beta1" # migrated with loftsman manifest migrate
+metadata:
+  name: test-manifest
+  labels:
+    site: lab
+spec:
+  charts:
+    - name: chart1
+      namespace: default
+      version: 0.0.1   # pinned
+      values:
+        manifest:
+          apiVersion: manifests/v1beta1
+`
+	migrated, err := Migrate(manifest)
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.TestMigrate(): %s", err)
+		return
+	}
+	expected := strings.Replace(manifest, `"manifests/v1beta1"`, `"manifests/v1"`, 1)
+	if migrated != expected {
+		t.Errorf("Didn't get expected migrated manifest from manifest.TestMigrate(), got:\n%s", migrated)
+	}
+	if _, err = Validate(migrated, ".", nil); err != nil {
+		t.Errorf("Got unexpected error validating the migrated manifest from manifest.TestMigrate(): %s", err)
+	}
+}
+
+func TestMigrateFlowStyle(t *testing.T) {
+	manifest := `{apiVersion: manifests/v1beta1, metadata: {name: test-manifest}, spec: {charts: []}}`
+	migrated, err := Migrate(manifest)
+	if err != nil || !strings.Contains(migrated, "apiVersion: manifests/v1\n") || !strings.Contains(migrated, "name: test-manifest") {
+		t.Errorf("Didn't get expected migrated manifest from manifest.TestMigrateFlowStyle(), got: %v\n%s", err, migrated)
+	}
+}
+
+func TestMigrateUnsupported(t *testing.T) {
+	for apiVersion, expectedError := range map[string]string{
+		"manifests/v1": "the manifest is already manifests/v1",
+		"v0":           "the manifest apiVersion is not supported: v0",
+	} {
+		_, err := Migrate("apiVersion: " + apiVersion + "\n")
+		if err == nil || err.Error() != expectedError {
+			t.Errorf("Didn't get expected error for %s from manifest.TestMigrateUnsupported(), instead got: %v", apiVersion, err)
+		}
+	}
+}
@@ -0,0 +1,447 @@
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// listReplaceMarker is the list item that marks a list of an overlay as replacing the list it's merged over, rather than
+// being added to it, e.g. `- $patch: replace`
+const listReplaceMarker = "replace"
+
+const listPatchKey = "$patch"
+
+// Render will compose a manifest with the manifests in its spec.imports and the overlays in its spec.overlays, the paths
+// of them being relative to the manifest directory. The charts and chart sources of each import are included ahead of the
+// manifest's own, and then each overlay is merged over the manifest in order. A manifest without imports or overlays is
+// returned as is, and so is a manifest that doesn't parse, leaving it to its validation to report why
+func Render(manifestContent string, manifestDirectory string) (string, error) {
+	var manifest yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(manifestContent), &manifest); err != nil {
+		return manifestContent, nil
+	}
+	spec, _ := getKey(manifest, "spec").(yaml.MapSlice)
+	if getKey(spec, "imports") == nil && getKey(spec, "overlays") == nil {
+		return manifestContent, nil
+	}
+	manifestDirectory, err := filepath.Abs(manifestDirectory)
+	if err != nil {
+		return "", err
+	}
+	rendered, err := renderManifest(manifest, manifestDirectory, []string{})
+	if err != nil {
+		return "", err
+	}
+	renderedBytes, err := yaml.Marshal(rendered)
+	if err != nil {
+		return "", fmt.Errorf("Error rendering the manifest: %s", err)
+	}
+	return string(renderedBytes), nil
+}
+
+// renderManifest will compose a manifest with its imports and overlays, importing is the paths of the manifests being
+// imported that this one is imported by, to catch import cycles
+func renderManifest(manifest yaml.MapSlice, manifestDirectory string, importing []string) (yaml.MapSlice, error) {
+	spec, _ := getKey(manifest, "spec").(yaml.MapSlice)
+	imports, err := getPaths(spec, "imports", manifestDirectory)
+	if err != nil {
+		return nil, err
+	}
+	overlays, err := getPaths(spec, "overlays", manifestDirectory)
+	if err != nil {
+		return nil, err
+	}
+	spec = deleteKey(deleteKey(spec, "imports"), "overlays")
+
+	sources, _ := getKey(spec, "sources").(yaml.MapSlice)
+	chartSources := []interface{}{}
+	repos := []interface{}{}
+	charts := []interface{}{}
+	variables := yaml.MapSlice{}
+	for _, importPath := range imports {
+		if contains(importing, importPath) {
+			return nil, fmt.Errorf("spec.imports %s imports itself: %s", importPath, strings.Join(append(importing, importPath), " -> "))
+		}
+		imported, err := readManifest(importPath, "spec.imports")
+		if err != nil {
+			return nil, err
+		}
+		if imported, err = renderManifest(imported, filepath.Dir(importPath), append(importing, importPath)); err != nil {
+			return nil, err
+		}
+		rebasePaths(imported, filepath.Dir(importPath), manifestDirectory)
+		importedSpec, _ := getKey(imported, "spec").(yaml.MapSlice)
+		importedSources, _ := getKey(importedSpec, "sources").(yaml.MapSlice)
+		if chartSources, err = addNamed(chartSources, getList(importedSources, "charts"), "spec.sources.charts"); err != nil {
+			return nil, err
+		}
+		if repos, err = addNamed(repos, getList(importedSources, "repos"), "spec.sources.repos"); err != nil {
+			return nil, err
+		}
+		if charts, err = addCharts(charts, getList(importedSpec, "charts"), importPath); err != nil {
+			return nil, err
+		}
+		if importedVariables, ok := getKey(importedSpec, "variables").(yaml.MapSlice); ok {
+			variables = mergeMaps(variables, importedVariables)
+		}
+	}
+	if len(imports) > 0 {
+		if chartSources, err = addNamed(chartSources, getList(sources, "charts"), "spec.sources.charts"); err != nil {
+			return nil, err
+		}
+		if repos, err = addNamed(repos, getList(sources, "repos"), "spec.sources.repos"); err != nil {
+			return nil, err
+		}
+		if charts, err = addCharts(charts, getList(spec, "charts"), ""); err != nil {
+			return nil, err
+		}
+		if ownVariables, ok := getKey(spec, "variables").(yaml.MapSlice); ok {
+			variables = mergeMaps(variables, ownVariables)
+		}
+		if len(chartSources) > 0 {
+			sources = setKey(sources, "charts", chartSources)
+		}
+		if len(repos) > 0 {
+			sources = setKey(sources, "repos", repos)
+		}
+		if len(sources) > 0 {
+			spec = setKey(spec, "sources", sources)
+		}
+		if len(variables) > 0 {
+			spec = setKey(spec, "variables", variables)
+		}
+		spec = setKey(spec, "charts", charts)
+	}
+	manifest = setKey(manifest, "spec", spec)
+
+	for _, overlayPath := range overlays {
+		overlay, err := readManifest(overlayPath, "spec.overlays")
+		if err != nil {
+			return nil, err
+		}
+		overlaySpec, _ := getKey(overlay, "spec").(yaml.MapSlice)
+		if getKey(overlaySpec, "imports") != nil || getKey(overlaySpec, "overlays") != nil {
+			return nil, fmt.Errorf("spec.overlays %s can't have imports or overlays of its own", overlayPath)
+		}
+		rebasePaths(overlay, filepath.Dir(overlayPath), manifestDirectory)
+		if manifest, err = applyOverlay(manifest, overlay); err != nil {
+			return nil, fmt.Errorf("spec.overlays %s: %s", overlayPath, err)
+		}
+	}
+	return manifest, nil
+}
+
+// applyOverlay will merge an overlay over a manifest. Maps are merged key by key, lists are added to unless the overlay
+// list has the replace marker, and the charts and chart sources of the overlay are merged over those of the same name
+func applyOverlay(manifest yaml.MapSlice, overlay yaml.MapSlice) (yaml.MapSlice, error) {
+	spec, _ := getKey(manifest, "spec").(yaml.MapSlice)
+	overlaySpec, _ := getKey(overlay, "spec").(yaml.MapSlice)
+	sources, _ := getKey(spec, "sources").(yaml.MapSlice)
+	overlaySources, _ := getKey(overlaySpec, "sources").(yaml.MapSlice)
+	for _, key := range []string{"charts", "repos"} {
+		if overlayList := getKey(overlaySources, key); overlayList != nil {
+			merged, err := mergeNamed(getList(sources, key), overlayList, "spec.sources."+key)
+			if err != nil {
+				return nil, err
+			}
+			sources = setKey(sources, key, merged)
+			overlaySources = deleteKey(overlaySources, key)
+		}
+	}
+	if len(sources) > 0 {
+		spec = setKey(spec, "sources", sources)
+	}
+	if len(overlaySources) > 0 {
+		overlaySpec = setKey(overlaySpec, "sources", overlaySources)
+	} else {
+		overlaySpec = deleteKey(overlaySpec, "sources")
+	}
+	if overlayCharts := getKey(overlaySpec, "charts"); overlayCharts != nil {
+		merged, err := mergeNamed(getList(spec, "charts"), overlayCharts, "spec.charts")
+		if err != nil {
+			return nil, err
+		}
+		spec = setKey(spec, "charts", merged)
+		overlaySpec = deleteKey(overlaySpec, "charts")
+	}
+	manifest = setKey(manifest, "spec", mergeMaps(spec, overlaySpec))
+	if metadata, ok := getKey(overlay, "metadata").(yaml.MapSlice); ok {
+		existingMetadata, _ := getKey(manifest, "metadata").(yaml.MapSlice)
+		manifest = setKey(manifest, "metadata", mergeMaps(existingMetadata, metadata))
+	}
+	return manifest, nil
+}
+
+// mergeNamed will merge a list of an overlay over a list of items with names, merging each overlay item over the item
+// of the same name, or adding it when there isn't one
+func mergeNamed(list []interface{}, overlayValue interface{}, field string) ([]interface{}, error) {
+	overlayList, ok := overlayValue.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list", field)
+	}
+	if items, replace := getListReplace(overlayList); replace {
+		return items, nil
+	}
+	merged := append([]interface{}{}, list...)
+	for _, overlayItem := range overlayList {
+		overlayMap, ok := overlayItem.(yaml.MapSlice)
+		name, _ := getKey(overlayMap, "name").(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("each of %s must have a name to be merged by", field)
+		}
+		found := false
+		for i, item := range merged {
+			if itemMap, ok := item.(yaml.MapSlice); ok && getKey(itemMap, "name") == name {
+				merged[i] = mergeMaps(itemMap, overlayMap)
+				found = true
+			}
+		}
+		if !found {
+			merged = append(merged, removeListReplaceMarkers(overlayMap))
+		}
+	}
+	return merged, nil
+}
+
+// mergeMaps will deep-merge an overlay map over a map, returning the merged map
+func mergeMaps(base yaml.MapSlice, overlay yaml.MapSlice) yaml.MapSlice {
+	merged := append(yaml.MapSlice{}, base...)
+	for _, item := range overlay {
+		merged = setKey(merged, item.Key, mergeValues(getKey(merged, item.Key), item.Value))
+	}
+	return merged
+}
+
+// mergeValues will deep-merge an overlay value over a value, maps being merged, lists being added to unless the overlay
+// list has the replace marker, and anything else being replaced
+func mergeValues(base interface{}, overlay interface{}) interface{} {
+	switch overlayValue := overlay.(type) {
+	case yaml.MapSlice:
+		if baseMap, ok := base.(yaml.MapSlice); ok {
+			return mergeMaps(baseMap, overlayValue)
+		}
+		return removeListReplaceMarkers(overlayValue)
+	case []interface{}:
+		items, replace := getListReplace(overlayValue)
+		if baseList, ok := base.([]interface{}); ok && !replace {
+			return append(append([]interface{}{}, baseList...), items...)
+		}
+		return items
+	}
+	return overlay
+}
+
+// getListReplace will return the items of a list without the replace marker, and whether the list had it
+func getListReplace(list []interface{}) ([]interface{}, bool) {
+	items := []interface{}{}
+	replace := false
+	for _, item := range list {
+		if itemMap, ok := item.(yaml.MapSlice); ok && len(itemMap) == 1 && getKey(itemMap, listPatchKey) == listReplaceMarker {
+			replace = true
+			continue
+		}
+		items = append(items, removeListReplaceMarkers(item))
+	}
+	return items, replace
+}
+
+// removeListReplaceMarkers will remove the replace markers from the lists in a value that has nothing to merge over
+func removeListReplaceMarkers(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case yaml.MapSlice:
+		cleaned := yaml.MapSlice{}
+		for _, item := range typedValue {
+			cleaned = append(cleaned, yaml.MapItem{Key: item.Key, Value: removeListReplaceMarkers(item.Value)})
+		}
+		return cleaned
+	case []interface{}:
+		items, _ := getListReplace(typedValue)
+		return items
+	}
+	return value
+}
+
+// addCharts will add the charts of an import, or of the importing manifest itself when importPath is empty, to the
+// charts included so far. Releases can't be included twice, an overlay is what changes an imported chart
+func addCharts(charts []interface{}, added []interface{}, importPath string) ([]interface{}, error) {
+	for _, chart := range added {
+		releaseName := getReleaseName(chart)
+		for _, existing := range charts {
+			if getReleaseName(existing) == releaseName {
+				if importPath == "" {
+					return nil, fmt.Errorf("spec.charts release %s is already in the manifest's spec.imports, use spec.overlays to "+
+						"change an imported chart", releaseName)
+				}
+				return nil, fmt.Errorf("spec.imports %s has release %s, which is already in another of the manifest's imports",
+					importPath, releaseName)
+			}
+		}
+		charts = append(charts, chart)
+	}
+	return charts, nil
+}
+
+// addNamed will add the chart sources or repos of an import, or of the importing manifest itself, to those included so
+// far. The same source can be in more than one import, but not two different sources of the same name
+func addNamed(list []interface{}, added []interface{}, field string) ([]interface{}, error) {
+	for _, item := range added {
+		itemMap, _ := item.(yaml.MapSlice)
+		name := getKey(itemMap, "name")
+		duplicate := false
+		for _, existing := range list {
+			existingMap, _ := existing.(yaml.MapSlice)
+			if getKey(existingMap, "name") != name {
+				continue
+			}
+			if !reflect.DeepEqual(existingMap, itemMap) {
+				return nil, fmt.Errorf("%s name = %v is defined differently by more than one of the manifest and its spec.imports",
+					field, name)
+			}
+			duplicate = true
+		}
+		if !duplicate {
+			list = append(list, item)
+		}
+	}
+	return list, nil
+}
+
+func getReleaseName(chart interface{}) interface{} {
+	chartMap, _ := chart.(yaml.MapSlice)
+	if releaseName := getKey(chartMap, "releaseName"); releaseName != nil {
+		return releaseName
+	}
+	return getKey(chartMap, "name")
+}
+
+// rebasePaths will change the relative paths of a manifest's directory chart sources, keyrings, values files, and
+// post-renderers from being relative to the directory of the manifest to being relative to another directory. Paths
+// starting with a ${NAME} variable are left alone, since they may not be relative once substituted
+func rebasePaths(manifest yaml.MapSlice, fromDirectory string, toDirectory string) {
+	rebase := func(value interface{}) interface{} {
+		path, ok := value.(string)
+		if !ok || path == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "${") {
+			return value
+		}
+		rebased, err := filepath.Rel(toDirectory, filepath.Join(fromDirectory, path))
+		if err != nil {
+			return filepath.Join(fromDirectory, path)
+		}
+		return rebased
+	}
+	spec, _ := getKey(manifest, "spec").(yaml.MapSlice)
+	sources, _ := getKey(spec, "sources").(yaml.MapSlice)
+	for _, chartSource := range getList(sources, "charts") {
+		chartSourceMap, _ := chartSource.(yaml.MapSlice)
+		for i, item := range chartSourceMap {
+			if (item.Key == "location" && getKey(chartSourceMap, "type") == "directory") || item.Key == "keyring" {
+				chartSourceMap[i].Value = rebase(item.Value)
+			}
+		}
+	}
+	// a post-renderer without a path separator is found on the PATH rather than relative to the manifest
+	rebasePostRenderer := func(helmOptions yaml.MapSlice) {
+		for i, item := range helmOptions {
+			if path, ok := item.Value.(string); ok && item.Key == "postRenderer" && strings.Contains(path, "/") {
+				helmOptions[i].Value = rebase(path)
+			}
+		}
+	}
+	for _, chart := range getList(spec, "charts") {
+		chartMap, _ := chart.(yaml.MapSlice)
+		valuesFiles := getList(chartMap, "valuesFiles")
+		for i, valuesFile := range valuesFiles {
+			valuesFiles[i] = rebase(valuesFile)
+		}
+		helmOptions, _ := getKey(chartMap, "helm").(yaml.MapSlice)
+		rebasePostRenderer(helmOptions)
+	}
+	all, _ := getKey(spec, "all").(yaml.MapSlice)
+	helmOptions, _ := getKey(all, "helm").(yaml.MapSlice)
+	rebasePostRenderer(helmOptions)
+}
+
+// getPaths will return the absolute paths of a list of paths relative to the manifest directory
+func getPaths(spec yaml.MapSlice, key string, manifestDirectory string) ([]string, error) {
+	paths := []string{}
+	value := getKey(spec, key)
+	if value == nil {
+		return paths, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("spec.%s must be a list of paths", key)
+	}
+	for _, item := range list {
+		path, ok := item.(string)
+		if !ok || path == "" {
+			return nil, fmt.Errorf("spec.%s must be a list of paths", key)
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(manifestDirectory, path)
+		}
+		paths = append(paths, filepath.Clean(path))
+	}
+	return paths, nil
+}
+
+func readManifest(path string, field string) (yaml.MapSlice, error) {
+	var manifest yaml.MapSlice
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s %s: %s", field, path, err)
+	}
+	if err = yaml.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("could not parse %s %s as yaml: %s", field, path, err)
+	}
+	return manifest, nil
+}
+
+func getKey(m yaml.MapSlice, key interface{}) interface{} {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+func getList(m yaml.MapSlice, key string) []interface{} {
+	list, _ := getKey(m, key).([]interface{})
+	return list
+}
+
+// setKey will set the value of a key in a map, keeping the key where it is if it's already set
+func setKey(m yaml.MapSlice, key interface{}, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if item.Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
+
+func deleteKey(m yaml.MapSlice, key string) yaml.MapSlice {
+	deleted := yaml.MapSlice{}
+	for _, item := range m {
+		if item.Key != key {
+			deleted = append(deleted, item)
+		}
+	}
+	return deleted
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
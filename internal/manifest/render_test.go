@@ -0,0 +1,202 @@
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/loftsman/schemas/manifests/v1beta1"
+)
+
+const renderBaseManifest = `apiVersion: manifests/v1beta1
+metadata:
+  name: base
+spec:
+  variables:
+    DOMAIN: example.com
+  sources:
+    charts:
+    - type: directory
+      name: local
+      location: ./charts
+  charts:
+  - name: chart1
+    source: local
+    namespace: default
+    version: 1.0.0
+    valuesFiles:
+    - values/chart1.yaml
+    values:
+      replicas: 1
+      ingress:
+        host: chart1.${DOMAIN}
+      tolerations:
+      - key: a
+      args:
+      - --one
+  - name: chart2
+    source: local
+    namespace: default
+    version: 2.0.0
+`
+
+const renderLabOverlay = `metadata:
+  name: lab
+spec:
+  charts:
+  - name: chart1
+    version: 1.1.0
+    values:
+      replicas: 3
+      tolerations:
+      - key: b
+      args:
+      - $patch: replace
+      - --two
+`
+
+const renderLabManifest = `apiVersion: manifests/v1beta1
+metadata:
+  name: lab-manifest
+spec:
+  imports:
+  - base/manifest.yaml
+  overlays:
+  - overlays/lab.yaml
+  variables:
+    DOMAIN: lab.example.com
+  charts:
+  - name: chart3
+    source: local
+    namespace: lab
+    version: 3.0.0
+`
+
+func writeRenderTestFiles(t *testing.T, files map[string]string) string {
+	directory := t.TempDir()
+	for path, content := range files {
+		path = filepath.Join(directory, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return directory
+}
+
+func TestRender(t *testing.T) {
+	directory := writeRenderTestFiles(t, map[string]string{
+		"base/manifest.yaml":      renderBaseManifest,
+		"base/values/chart1.yaml": "replicas: 2\n",
+		"overlays/lab.yaml":       renderLabOverlay,
+	})
+	rendered, err := Render(renderLabManifest, directory)
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.TestRender(): %s", err)
+		return
+	}
+	if strings.Contains(rendered, "imports") || strings.Contains(rendered, "overlays") || strings.Contains(rendered, "$patch") {
+		t.Errorf("Didn't get expected imports, overlays, and markers removed from manifest.TestRender(), got:\n%s", rendered)
+	}
+	manifest, err := Validate(rendered, directory, nil)
+	if err != nil {
+		t.Errorf("Got unexpected error validating the manifest from manifest.TestRender(): %s\n%s", err, rendered)
+		return
+	}
+	m := manifest.(*v1beta1.Manifest)
+	if m.GetName() != "lab" || m.Spec.Sources.Charts[0].Location != "base/charts" {
+		t.Errorf("Didn't get expected name and chart source from manifest.TestRender(), got: %s %s", m.GetName(),
+			m.Spec.Sources.Charts[0].Location)
+	}
+	chartNames := []string{}
+	for _, chart := range m.Spec.Charts {
+		chartNames = append(chartNames, chart.Name)
+	}
+	if !reflect.DeepEqual(chartNames, []string{"chart1", "chart2", "chart3"}) {
+		t.Errorf("Didn't get expected charts from manifest.TestRender(), got: %v", chartNames)
+	}
+	chart1 := m.Spec.Charts[0]
+	if chart1.Version != "1.1.0" || !reflect.DeepEqual(chart1.ValuesFiles, []string{"base/values/chart1.yaml"}) {
+		t.Errorf("Didn't get expected chart1 version and values files from manifest.TestRender(), got: %s %v", chart1.Version,
+			chart1.ValuesFiles)
+	}
+	expectedValues := "map[args:[--two] ingress:map[host:chart1.lab.example.com] replicas:3 tolerations:[map[key:a] map[key:b]]]"
+	if values := fmt.Sprint(chart1.Values); values != expectedValues {
+		t.Errorf("Didn't get expected chart1 values from manifest.TestRender(), got: %s", values)
+	}
+}
+
+func TestRenderWithoutImports(t *testing.T) {
+	rendered, err := Render(renderBaseManifest, ".")
+	if err != nil || rendered != renderBaseManifest {
+		t.Errorf("Didn't get expected unchanged manifest from manifest.TestRenderWithoutImports(), got: %v\n%s", err, rendered)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	imports := "spec:\n  imports:\n  - base/manifest.yaml\n  - other.yaml\n"
+	tests := map[string]struct {
+		manifestContent string
+		files           map[string]string
+		expectedError   string
+	}{
+		"import cycle": {
+			manifestContent: "spec:\n  imports:\n  - a.yaml\n",
+			files: map[string]string{
+				"a.yaml": "spec:\n  imports:\n  - b.yaml\n",
+				"b.yaml": "spec:\n  imports:\n  - a.yaml\n",
+			},
+			expectedError: "imports itself",
+		},
+		"release in more than one import": {
+			manifestContent: imports,
+			files: map[string]string{
+				"base/manifest.yaml": renderBaseManifest,
+				"other.yaml":         "spec:\n  charts:\n  - name: chart2\n",
+			},
+			expectedError: "release chart2, which is already in another of the manifest's imports",
+		},
+		"imported release in the manifest": {
+			manifestContent: "spec:\n  imports:\n  - base/manifest.yaml\n  charts:\n  - name: chart1\n",
+			files: map[string]string{
+				"base/manifest.yaml": renderBaseManifest,
+			},
+			expectedError: "spec.charts release chart1 is already in the manifest's spec.imports",
+		},
+		"different sources of the same name": {
+			manifestContent: imports,
+			files: map[string]string{
+				"base/manifest.yaml": renderBaseManifest,
+				"other.yaml":         "spec:\n  sources:\n    charts:\n    - type: repo\n      name: local\n      location: https://charts.my.org\n",
+			},
+			expectedError: "spec.sources.charts name = local is defined differently",
+		},
+		"missing import": {
+			manifestContent: imports,
+			files: map[string]string{
+				"base/manifest.yaml": renderBaseManifest,
+			},
+			expectedError: "Error reading spec.imports",
+		},
+		"overlay with imports": {
+			manifestContent: "spec:\n  overlays:\n  - overlay.yaml\n",
+			files: map[string]string{
+				"overlay.yaml": "spec:\n  imports:\n  - base/manifest.yaml\n",
+			},
+			expectedError: "can't have imports or overlays of its own",
+		},
+	}
+	for name, test := range tests {
+		directory := writeRenderTestFiles(t, test.files)
+		_, err := Render(test.manifestContent, directory)
+		if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+			t.Errorf("Didn't get expected error for %s from manifest.TestRenderErrors(), instead got: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	"github.com/Cray-HPE/loftsman/schemas/manifests/v1"
+)
+
+func TestValidateV1ValidWithHelmOptions(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1
+metadata:
+  name: test-manifest
+  labels:
+    site: lab
+spec:
+  all:
+    timeout: 10m
+    onFailure: rollback
+    helm:
+      wait: true
+      maxHistory: 10
+  charts:
+    - name: chart1
+      namespace: default
+      version: 0.0.1
+      helm:
+        wait: false
+        atomic: true
+        skipCRDs: true
+        createNamespace: false
+        postRenderer: ./kustomize.sh
+        description: shipped by the platform team
+        extraArgs:
+          - --disable-openapi-validation
+`
+	validated, err := Validate(manifest, ".", nil)
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.TestValidateV1ValidWithHelmOptions(): %s", err)
+		return
+	}
+	m := validated.(*v1.Manifest)
+	if m.Metadata.Labels["site"] != "lab" || *m.Spec.All.Helm.MaxHistory != 10 || *m.Spec.Charts[0].Helm.Wait ||
+		*m.Spec.Charts[0].Helm.CreateNamespace || m.Spec.Charts[0].Helm.ExtraArgs[0] != "--disable-openapi-validation" {
+		t.Errorf("Didn't get expected labels and helm options from manifest.TestValidateV1ValidWithHelmOptions(), got: %v %v",
+			m.Metadata.Labels, m.Spec.Charts[0].Helm)
+	}
+}
+
+func TestValidateV1InvalidHelmOptions(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1
+metadata:
+  name: test-manifest
+spec:
+  charts:
+    - name: chart1
+      namespace: default
+      version: 0.0.1
+      helm:
+        maxHistory: -1
+`
+	_, err := Validate(manifest, ".", nil)
+	if err == nil || !strings.Contains(err.Error(), "maxHistory") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1InvalidHelmOptions(), instead got: %s", err)
+	}
+}
+
+func TestValidateV1HelmOptionsTypos(t *testing.T) {
+	tests := []struct {
+		helmOptions string
+		expected    string
+	}{
+		{"atomik: true", "Additional property atomik is not allowed"},
+		{"skipCrds: true", "Additional property skipCrds is not allowed"},
+		{"extraArgs: [--kube-token=secret]", "extraArgs.0 must be one of the following"},
+		{"description: \"it's shipped\"", "description: Does not match pattern"},
+	}
+	for _, test := range tests {
+		manifest := `---
+apiVersion: manifests/v1
+metadata:
+  name: test-manifest
+spec:
+  charts:
+    - name: chart1
+      namespace: default
+      version: 0.0.1
+      helm:
+        ` + test.helmOptions + `
+`
+		_, err := Validate(manifest, ".", nil)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("Didn't get expected error for %s from manifest.TestValidateV1HelmOptionsTypos(), instead got: %s",
+				test.helmOptions, err)
+		}
+	}
+}
+
+func TestValidateV1Beta1HelmOptions(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: test-manifest
+spec:
+  charts:
+    - name: chart1
+      namespace: default
+      version: 0.0.1
+      helm:
+        wait: true
+`
+	_, err := Validate(manifest, ".", nil)
+	if err == nil || !strings.Contains(err.Error(), "Additional property helm is not allowed") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1HelmOptions(), instead got: %s", err)
+	}
+}
+
+func TestValidateV1EnabledCharts(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1
+metadata:
+  name: test-manifest
+spec:
+  variables:
+    SITE: lab
+  charts:
+    - name: chart1
+      namespace: default
+      version: 0.0.1
+      labels:
+        tier: core
+    - name: chart2
+      namespace: default
+      version: 0.0.1
+      enabled: ${SITE} != lab
+`
+	validated, err := Validate(manifest, ".", nil)
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.TestValidateV1EnabledCharts(): %s", err)
+		return
+	}
+	if selected, err := validated.SelectCharts(&interfaces.ManifestChartSelection{}); err != nil || len(selected) != 1 || selected[0] != "chart1" {
+		t.Errorf("Didn't get expected enabled charts from manifest.TestValidateV1EnabledCharts(), got %v, error: %v", selected, err)
+	}
+	if validated, err = Validate(manifest, ".", map[string]string{"SITE": "prod"}); err != nil {
+		t.Errorf("Got unexpected error from manifest.TestValidateV1EnabledCharts(): %s", err)
+		return
+	}
+	if selected, err := validated.SelectCharts(&interfaces.ManifestChartSelection{}); err != nil || len(selected) != 2 {
+		t.Errorf("Didn't get expected chart enabled by a variable from manifest.TestValidateV1EnabledCharts(), got %v, error: %v", selected, err)
+	}
+	_, err = Validate(strings.Replace(manifest, "${SITE} != lab", "${SITE}", 1), ".", nil)
+	if err == nil || !strings.Contains(err.Error(), `invalid spec.charts[] name = chart2: invalid enabled expression "lab"`) {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1EnabledCharts(), instead got: %s", err)
+	}
+}
+
+func TestValidateV1Verify(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1
+metadata:
+  name: test-manifest
+spec:
+  charts:
+    - name: chart1
+      namespace: default
+      version: 0.0.1
+      verify:
+        timeout: 5m
+        rollout: {}
+        jobs:
+          - name: db-migrate
+            timeout: 15m
+        conditions:
+          - apiVersion: cert-manager.io/v1
+            kind: Certificate
+            name: chart1-tls
+            type: Ready
+        helmTest:
+          timeout: 2m
+`
+	if _, err := Validate(manifest, ".", nil); err != nil {
+		t.Errorf("Got unexpected error from manifest.TestValidateV1Verify(): %s", err)
+	}
+	tests := map[string]string{
+		"rollout: {}":      "rollout: {wait: true}",
+		"type: Ready":      "status: Ready",
+		"timeout: 15m":     "timeout: fifteen minutes",
+		"helmTest:":        "helmTests:",
+		"name: db-migrate": "namespace: jobs",
+	}
+	expected := map[string]string{
+		"rollout: {}":      "Additional property wait is not allowed",
+		"type: Ready":      "type is required",
+		"timeout: 15m":     "invalid verify timeout fifteen minutes",
+		"helmTest:":        "Additional property helmTests is not allowed",
+		"name: db-migrate": "name is required",
+	}
+	for old, new := range tests {
+		_, err := Validate(strings.Replace(manifest, old, new, 1), ".", nil)
+		if err == nil || !strings.Contains(err.Error(), expected[old]) {
+			t.Errorf("Didn't get expected error for %s from manifest.TestValidateV1Verify(), instead got: %s", new, err)
+		}
+	}
+}
@@ -2,6 +2,8 @@
 package manifest
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -17,7 +19,7 @@ spec:
       namespace: default
       version: 0.0.1
 `
-	_, err := Validate(manifest)
+	_, err := Validate(manifest, ".", nil)
 	if err != nil {
 		t.Errorf("Got unexpected error from manifest.TestValidateV1Beta1ValidWithMinimalChart(): %s", err)
 	}
@@ -33,7 +35,7 @@ spec:
     - name: chart1
       namespace: default
 `
-	_, err := Validate(manifest)
+	_, err := Validate(manifest, ".", nil)
 	if err == nil || !strings.Contains(err.Error(), "version is required") {
 		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1MissingChartVersion(), instead got: %s", err)
 	}
@@ -43,7 +45,7 @@ func TestValidateV1Beta1InvalidYAML(t *testing.T) {
 	manifest := `---
 invalidyaml
 	`
-	_, err := Validate(manifest)
+	_, err := Validate(manifest, ".", nil)
 	if err == nil || err.Error() != "could not parse the manifest as yaml to retrieve the apiVersion" {
 		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1InvalidYAML(), instead got: %s", err)
 	}
@@ -55,7 +57,7 @@ apiVersion: manifests/v1beta1
 spec:
   charts: []
 `
-	_, err := Validate(manifest)
+	_, err := Validate(manifest, ".", nil)
 	if err == nil || !strings.Contains(err.Error(), "manifest validation errors") {
 		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1MissingMetadata(), instead got: %s", err)
 	}
@@ -67,7 +69,7 @@ apiVersion: manifests/v1beta1
 metadata:
   name: test-manifest
 `
-	_, err := Validate(manifest)
+	_, err := Validate(manifest, ".", nil)
 	if err == nil || !strings.Contains(err.Error(), "manifest validation errors") {
 		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1MissingSpec(), instead got: %s", err)
 	}
@@ -80,7 +82,7 @@ metadata:
   name: test-manifest
 spec: {}
 `
-	_, err := Validate(manifest)
+	_, err := Validate(manifest, ".", nil)
 	if err == nil || !strings.Contains(err.Error(), "manifest validation errors") {
 		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1MissingCharts(), instead got: %s", err)
 	}
@@ -102,7 +104,7 @@ spec:
     namespace: default
     version: 1.0.0
 `
-	_, err := Validate(manifest)
+	_, err := Validate(manifest, ".", nil)
 	if err == nil || !strings.Contains(err.Error(), "manifest validation errors") {
 		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1MissingSourceOnChartWhileUsingChartsSource(), instead got: %s", err)
 	}
@@ -124,7 +126,7 @@ spec:
     namespace: default
     version: 1.0.0
 `
-	_, err := Validate(manifest)
+	_, err := Validate(manifest, ".", nil)
 	if err == nil || !strings.Contains(err.Error(), "manifest validation errors") {
 		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1IncompleteChartSource(), instead got: %s", err)
 	}
@@ -147,7 +149,7 @@ spec:
     namespace: default
     version: 1.0.0
 `
-	_, err := Validate(manifest)
+	_, err := Validate(manifest, ".", nil)
 	if err == nil || !strings.Contains(err.Error(), "manifest validation errors") {
 		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1InvalidChartSourceType(), instead got: %s", err)
 	}
@@ -175,8 +177,369 @@ spec:
     namespace: default
     version: 1.0.0
 `
-	_, err := Validate(manifest)
+	_, err := Validate(manifest, ".", nil)
 	if err != nil {
 		t.Errorf("Got unexpected error from manifest.TestValidateV1Beta1ValidRepoChartSource(): %s", err)
 	}
 }
+
+func TestValidateV1Beta1DependsOnCycle(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: test-manifest
+spec:
+  charts:
+  - name: chart1
+    namespace: default
+    version: 1.0.0
+    dependsOn: [chart2]
+  - name: chart2
+    namespace: default
+    version: 1.0.0
+    dependsOn: [chart1]
+`
+	_, err := Validate(manifest, ".", nil)
+	if err == nil || !strings.Contains(err.Error(), "dependsOn cycle detected") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1DependsOnCycle(), instead got: %s", err)
+	}
+}
+
+func TestValidateV1Beta1InvalidOnFailure(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: test-manifest
+spec:
+  all:
+    onFailure: rollback
+  charts:
+  - name: chart1
+    namespace: default
+    version: 1.0.0
+    onFailure: retry
+`
+	_, err := Validate(manifest, ".", nil)
+	if err == nil || !strings.Contains(err.Error(), "onFailure") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1InvalidOnFailure(), instead got: %s", err)
+	}
+}
+
+func TestValidateV1Beta1ValidOCIChartSource(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: test-manifest
+spec:
+  sources:
+    charts:
+    - type: oci
+      name: registry
+      location: oci://registry.io/charts
+      credentialsSecret:
+        name: secret
+        namespace: default
+        usernameKey: username
+        passwordKey: password
+  charts:
+  - name: chart1
+    source: registry
+    namespace: default
+    version: 1.0.0
+    digest: sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae
+`
+	_, err := Validate(manifest, ".", nil)
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.TestValidateV1Beta1ValidOCIChartSource(): %s", err)
+	}
+}
+
+func TestValidateV1Beta1InvalidOCIChartSourceLocation(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: test-manifest
+spec:
+  sources:
+    charts:
+    - type: oci
+      name: registry
+      location: https://registry.io/charts
+  charts:
+  - name: chart1
+    source: registry
+    namespace: default
+    version: 1.0.0
+`
+	_, err := Validate(manifest, ".", nil)
+	if err == nil || !strings.Contains(err.Error(), "must be oci://<registry>/<path>") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1InvalidOCIChartSourceLocation(), instead got: %s", err)
+	}
+}
+
+func TestValidateV1Beta1InvalidDigest(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: test-manifest
+spec:
+  sources:
+    charts:
+    - type: oci
+      name: registry
+      location: oci://registry.io/charts
+  charts:
+  - name: chart1
+    source: registry
+    namespace: default
+    version: 1.0.0
+    digest: 2c26b46b68ffc68f
+`
+	_, err := Validate(manifest, ".", nil)
+	if err == nil || !strings.Contains(err.Error(), "manifest validation errors") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1InvalidDigest(), instead got: %s", err)
+	}
+}
+
+func TestValidateV1Beta1ValidGitChartSource(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: test-manifest
+spec:
+  sources:
+    charts:
+    - type: git
+      name: charts-repo
+      location: file:///srv/git/charts.git
+      ref: v1.0.0
+      path: charts/chart1
+  charts:
+  - name: chart1
+    source: charts-repo
+    namespace: default
+    version: 1.0.0
+`
+	_, err := Validate(manifest, ".", nil)
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.TestValidateV1Beta1ValidGitChartSource(): %s", err)
+	}
+}
+
+func TestValidateV1Beta1GitChartSourceNoRef(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: test-manifest
+spec:
+  sources:
+    charts:
+    - type: git
+      name: charts-repo
+      location: file:///srv/git/charts.git
+      path: charts/chart1
+  charts:
+  - name: chart1
+    source: charts-repo
+    namespace: default
+    version: 1.0.0
+`
+	_, err := Validate(manifest, ".", nil)
+	if err == nil || !strings.Contains(err.Error(), "must have a ref to check out") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1GitChartSourceNoRef(), instead got: %s", err)
+	}
+}
+
+func TestValidateV1Beta1ValidVerifiedChartSource(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: test-manifest
+spec:
+  sources:
+    charts:
+    - type: repo
+      name: charts-repo
+      location: https://charts.io
+      verify: true
+      keyringSecret:
+        name: charts-keyring
+        namespace: loftsman
+        key: pubring.gpg
+    - type: directory
+      name: local
+      location: ./charts
+      verify: true
+      keyring: /etc/loftsman/pubring.gpg
+  charts:
+  - name: chart1
+    source: charts-repo
+    namespace: default
+    version: 1.0.0
+    digest: sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae
+`
+	_, err := Validate(manifest, ".", nil)
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.TestValidateV1Beta1ValidVerifiedChartSource(): %s", err)
+	}
+}
+
+func TestValidateV1Beta1VerifiedChartSourceNoKeyring(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: test-manifest
+spec:
+  sources:
+    charts:
+    - type: repo
+      name: charts-repo
+      location: https://charts.io
+      verify: true
+  charts:
+  - name: chart1
+    source: charts-repo
+    namespace: default
+    version: 1.0.0
+`
+	_, err := Validate(manifest, ".", nil)
+	if err == nil || !strings.Contains(err.Error(), "must have either a keyring or a keyringSecret") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1VerifiedChartSourceNoKeyring(), instead got: %s", err)
+	}
+}
+
+func TestValidateV1Beta1VerifiedOCIChartSource(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: test-manifest
+spec:
+  sources:
+    charts:
+    - type: oci
+      name: registry
+      location: oci://registry.io/charts
+      verify: true
+      keyring: /etc/loftsman/pubring.gpg
+  charts:
+  - name: chart1
+    source: registry
+    namespace: default
+    version: 1.0.0
+`
+	_, err := Validate(manifest, ".", nil)
+	if err == nil || !strings.Contains(err.Error(), "verify is only supported for sources of type directory or repo") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1VerifiedOCIChartSource(), instead got: %s", err)
+	}
+}
+
+func TestValidateV1Beta1DigestGitChartSource(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: test-manifest
+spec:
+  sources:
+    charts:
+    - type: git
+      name: charts-repo
+      location: file:///srv/git/charts.git
+      ref: v1.0.0
+  charts:
+  - name: chart1
+    source: charts-repo
+    namespace: default
+    version: 1.0.0
+    digest: sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae
+`
+	_, err := Validate(manifest, ".", nil)
+	if err == nil || !strings.Contains(err.Error(), "digests aren't supported for charts from a source of type git") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1DigestGitChartSource(), instead got: %s", err)
+	}
+}
+
+const valuesManifest = `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: test-manifest
+spec:
+  charts:
+  - name: chart1
+    namespace: default
+    version: 1.0.0
+    valuesFiles:
+    - values/chart1.yaml
+    valuesFrom:
+    - configMapKeyRef:
+        name: chart1-values
+        key: values.yaml
+    - secretKeyRef:
+        name: chart1-secret-values
+        namespace: services
+        key: values.yaml
+    values:
+      replicas: 2
+`
+
+func TestValidateV1Beta1ValidValues(t *testing.T) {
+	manifestDirectory := t.TempDir()
+	ioutil.WriteFile(filepath.Join(manifestDirectory, "chart1.yaml"), []byte("image:\n  tag: 1.0.0\n"), 0644)
+	_, err := Validate(strings.Replace(valuesManifest, "values/chart1.yaml", "chart1.yaml", 1), manifestDirectory, nil)
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.TestValidateV1Beta1ValidValues(): %s", err)
+	}
+}
+
+func TestValidateV1Beta1ValuesFileDoesntExist(t *testing.T) {
+	_, err := Validate(valuesManifest, t.TempDir(), nil)
+	if err == nil || !strings.Contains(err.Error(), "Error reading values file values/chart1.yaml") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1ValuesFileDoesntExist(), instead got: %s", err)
+	}
+}
+
+func TestValidateV1Beta1InvalidValuesFile(t *testing.T) {
+	manifestDirectory := t.TempDir()
+	ioutil.WriteFile(filepath.Join(manifestDirectory, "chart1.yaml"), []byte("- not\n- a map\n"), 0644)
+	_, err := Validate(strings.Replace(valuesManifest, "values/chart1.yaml", "chart1.yaml", 1), manifestDirectory, nil)
+	if err == nil || !strings.Contains(err.Error(), "Error parsing values file chart1.yaml as yaml") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1InvalidValuesFile(), instead got: %s", err)
+	}
+}
+
+func TestValidateV1Beta1InvalidValuesFrom(t *testing.T) {
+	manifestDirectory := t.TempDir()
+	ioutil.WriteFile(filepath.Join(manifestDirectory, "chart1.yaml"), []byte("image:\n  tag: 1.0.0\n"), 0644)
+	manifest := strings.Replace(valuesManifest, "values/chart1.yaml", "chart1.yaml", 1)
+	manifest = strings.Replace(manifest, `    - secretKeyRef:`, `      secretKeyRef:`, 1)
+	_, err := Validate(manifest, manifestDirectory, nil)
+	if err == nil || !strings.Contains(err.Error(), "each valuesFrom must have either a configMapKeyRef or a secretKeyRef") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1InvalidValuesFrom(), instead got: %s", err)
+	}
+}
+
+func TestValidateV1Beta1EncryptedValues(t *testing.T) {
+	manifest := `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: test-manifest
+spec:
+  charts:
+  - name: chart1
+    namespace: default
+    version: 1.0.0
+    encryptedValues: |
+      password: ENC[AES256_GCM,data:dGVzdA==,iv:dGVzdA==,tag:dGVzdA==,type:str]
+      sops:
+        age:
+        - recipient: age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+        mac: ENC[AES256_GCM,data:dGVzdA==,iv:dGVzdA==,tag:dGVzdA==,type:str]
+        version: 3.7.1
+`
+	if _, err := Validate(manifest, ".", nil); err != nil {
+		t.Errorf("Got unexpected error from manifest.TestValidateV1Beta1EncryptedValues(): %s", err)
+	}
+	_, err := Validate(strings.Replace(manifest, "mac:", "notmac:", 1), ".", nil)
+	if err == nil || !strings.Contains(err.Error(), "encryptedValues must be a yaml document encrypted with sops") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateV1Beta1EncryptedValues(), instead got: %s", err)
+	}
+}
@@ -0,0 +1,77 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	// variableReference matches a ${NAME} reference to a manifest variable, or an escaped $${NAME} that's left as ${NAME}
+	variableReference = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+	variableName      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// OnlyVariables is just a struct with the spec.variables field, so that the default values of a manifest's variables
+// can be read before they're substituted, and before the manifest is parsed as its apiVersion
+type OnlyVariables struct {
+	Spec struct {
+		Variables map[string]string `yaml:"variables"`
+	} `yaml:"spec"`
+}
+
+// getDefaultVariables returns the defaults in the manifest's spec.variables. A manifest that doesn't parse has none,
+// leaving it to the parsing of the manifest after its variables are substituted to report why
+func getDefaultVariables(manifestContent string) map[string]string {
+	var manifest *OnlyVariables
+	if err := yaml.Unmarshal([]byte(manifestContent), &manifest); err != nil || manifest == nil {
+		return map[string]string{}
+	}
+	return manifest.Spec.Variables
+}
+
+// substituteVariables will replace each ${NAME} in the manifest content with the value of the variable, from the
+// variables given, or else the default in the manifest's spec.variables. A variable without a value is an error
+func substituteVariables(manifestContent string, variables map[string]string) (string, error) {
+	defaultVariables := getDefaultVariables(manifestContent)
+	unresolved := map[string]bool{}
+	invalid := map[string]bool{}
+	substituted := variableReference.ReplaceAllStringFunc(manifestContent, func(reference string) string {
+		if strings.HasPrefix(reference, "$$") {
+			return reference[1:]
+		}
+		name := variableReference.FindStringSubmatch(reference)[1]
+		if !variableName.MatchString(name) {
+			invalid[name] = true
+			return reference
+		}
+		if value, ok := variables[name]; ok {
+			return value
+		}
+		if value, ok := defaultVariables[name]; ok {
+			return value
+		}
+		unresolved[name] = true
+		return reference
+	})
+	if len(invalid) > 0 {
+		return "", fmt.Errorf("invalid manifest variable names, which must be letters, digits and underscores: %s", joinNames(invalid))
+	}
+	if len(unresolved) > 0 {
+		return "", fmt.Errorf("unresolved manifest variables, set them in spec.variables, with --set-var or --vars-file, or "+
+			"with LOFTSMAN_VAR_<name> env vars: %s", joinNames(unresolved))
+	}
+	return substituted, nil
+}
+
+func joinNames(names map[string]bool) string {
+	sortedNames := []string{}
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+	return strings.Join(sortedNames, ", ")
+}
@@ -0,0 +1,68 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/loftsman/schemas/manifests/v1beta1"
+)
+
+const variablesManifest = `---
+apiVersion: manifests/v1beta1
+metadata:
+  name: ${SYSTEM}-manifest
+spec:
+  variables:
+    SYSTEM: default-system
+    REPLICAS: 3
+  charts:
+  - name: chart1
+    namespace: default
+    version: ${CHART1_VERSION}
+    values:
+      ingress:
+        host: chart1.${SYSTEM}.example.com
+      replicas: ${REPLICAS}
+      script: echo $${HOME}
+`
+
+func TestSubstituteVariables(t *testing.T) {
+	substituted, err := substituteVariables(variablesManifest, map[string]string{"CHART1_VERSION": "1.0.0", "SYSTEM": "x1000"})
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.TestSubstituteVariables(): %s", err)
+		return
+	}
+	for _, expected := range []string{"name: x1000-manifest", "version: 1.0.0", "host: chart1.x1000.example.com", "replicas: 3",
+		"script: echo ${HOME}"} {
+		if !strings.Contains(substituted, expected) {
+			t.Errorf("Didn't get expected %s in the manifest from manifest.TestSubstituteVariables(), got:\n%s", expected, substituted)
+		}
+	}
+}
+
+func TestSubstituteVariablesUnresolved(t *testing.T) {
+	_, err := substituteVariables(variablesManifest+"    other: ${OTHER}\n", map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), "unresolved manifest variables") || !strings.HasSuffix(err.Error(), ": CHART1_VERSION, OTHER") {
+		t.Errorf("Didn't get expected error from manifest.TestSubstituteVariablesUnresolved(), instead got: %v", err)
+	}
+	_, err = substituteVariables(variablesManifest+"    other: ${not a name}\n", map[string]string{"CHART1_VERSION": "1.0.0"})
+	if err == nil || !strings.Contains(err.Error(), "invalid manifest variable names") {
+		t.Errorf("Didn't get expected error for an invalid name from manifest.TestSubstituteVariablesUnresolved(), instead got: %v", err)
+	}
+}
+
+func TestValidateWithVariables(t *testing.T) {
+	manifest, err := Validate(variablesManifest, ".", map[string]string{"CHART1_VERSION": "1.0.0"})
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.TestValidateWithVariables(): %s", err)
+		return
+	}
+	charts := manifest.(*v1beta1.Manifest).Spec.Charts
+	if manifest.GetName() != "default-system-manifest" || charts[0].Version != "1.0.0" {
+		t.Errorf("Didn't get expected substituted manifest from manifest.TestValidateWithVariables(), got: %s %s",
+			manifest.GetName(), charts[0].Version)
+	}
+	if _, err = Validate(variablesManifest, ".", nil); err == nil || !strings.Contains(err.Error(), "CHART1_VERSION") {
+		t.Errorf("Didn't get expected error from manifest.TestValidateWithVariables(), instead got: %v", err)
+	}
+}
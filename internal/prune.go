@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+)
+
+// isPruneEnabled will return whether releases no longer in the manifest should be uninstalled, from --prune or
+// spec.prune in the manifest
+func (loftsman *Loftsman) isPruneEnabled() bool {
+	return loftsman.Settings.Ship.Prune || loftsman.manifest.GetPrune()
+}
+
+// getOwnedReleases will get the releases owned by the manifest as recorded in the ship configmap by its previous ships
+func (loftsman *Loftsman) getOwnedReleases(configMapName string) ([]*interfaces.ManifestRelease, error) {
+	var ownedReleases []*interfaces.ManifestRelease
+	configMap, err := loftsman.kubernetes.GetConfigMap(configMapName, loftsman.Settings.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if configMap == nil || configMap.Data[ownedReleasesKey] == "" {
+		return ownedReleases, nil
+	}
+	if err = json.Unmarshal([]byte(configMap.Data[ownedReleasesKey]), &ownedReleases); err != nil {
+		return nil, fmt.Errorf("Error parsing %s in configmap %s: %s", ownedReleasesKey, configMapName, err)
+	}
+	return ownedReleases, nil
+}
+
+// recordOwnedReleases will record the releases owned by the manifest to the ship configmap, so that a later ship knows
+// which of them are no longer in the manifest
+func (loftsman *Loftsman) recordOwnedReleases(configMapName string, ownedReleases []*interfaces.ManifestRelease) {
+	ownedReleasesEncoded, err := json.Marshal(ownedReleases)
+	if err == nil && !loftsman.holdsShipRecords(configMapName, fmt.Sprintf("Releases owned by the manifest: %s", ownedReleasesEncoded)) {
+		return
+	}
+	if err == nil {
+		_, err = loftsman.kubernetes.PatchConfigMap(configMapName, loftsman.Settings.Namespace, map[string]string{
+			ownedReleasesKey: string(ownedReleasesEncoded),
+		})
+	}
+	if err != nil {
+		loftsman.logger.Error().Err(fmt.Errorf("Error patching configmap %s with owned releases to the %s namespace: %s",
+			configMapName, loftsman.Settings.Namespace, err)).Msg("")
+		fmt.Println("")
+	}
+}
+
+// getPruneCandidates will return the owned releases that are no longer in the manifest
+func (loftsman *Loftsman) getPruneCandidates(ownedReleases []*interfaces.ManifestRelease) []*interfaces.ManifestRelease {
+	pruneCandidates := []*interfaces.ManifestRelease{}
+	for _, ownedRelease := range ownedReleases {
+		if !containsRelease(loftsman.manifest.GetReleases(), ownedRelease) {
+			pruneCandidates = append(pruneCandidates, ownedRelease)
+		}
+	}
+	return pruneCandidates
+}
+
+// mergeReleases will return the releases of all of the lists given, without duplicates, in the order they're first found
+func mergeReleases(releaseLists ...[]*interfaces.ManifestRelease) []*interfaces.ManifestRelease {
+	merged := []*interfaces.ManifestRelease{}
+	for _, releases := range releaseLists {
+		for _, release := range releases {
+			if !containsRelease(merged, release) {
+				merged = append(merged, release)
+			}
+		}
+	}
+	return merged
+}
+
+func containsRelease(releases []*interfaces.ManifestRelease, release *interfaces.ManifestRelease) bool {
+	for _, r := range releases {
+		if r.ReleaseName == release.ReleaseName && r.Namespace == release.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmPrune will ask the user to confirm uninstalling the releases to be pruned, unless they already confirmed it
+// with --confirm-prune
+func (loftsman *Loftsman) confirmPrune(pruneCandidates []*interfaces.ManifestRelease) (bool, error) {
+	var response string
+	if loftsman.Settings.Ship.ConfirmPrune {
+		return true, nil
+	}
+	fmt.Println("The following releases were shipped with a previous ship of this manifest but are no longer in it, and will be uninstalled:")
+	for _, pruneCandidate := range pruneCandidates {
+		fmt.Println(fmt.Sprintf("  * %s in namespace %s", pruneCandidate.ReleaseName, pruneCandidate.Namespace))
+	}
+	fmt.Print("Do you want to uninstall them? (only a response of 'yes' will prune them): ")
+	if _, err := fmt.Fscanln(loftsman.reader, &response); err != nil {
+		return false, err
+	}
+	return response == "yes", nil
+}
+
+// pruneReleases will uninstall releases no longer in the manifest, returning those that couldn't be uninstalled along
+// with the errors from trying. A release that's already gone is considered pruned
+func (loftsman *Loftsman) pruneReleases(pruneCandidates []*interfaces.ManifestRelease) ([]*interfaces.ManifestRelease, []error) {
+	notPruned := []*interfaces.ManifestRelease{}
+	pruneErrors := []error{}
+	for _, pruneCandidate := range pruneCandidates {
+		loftsman.logger.Info().
+			Str("release", pruneCandidate.ReleaseName).
+			Str("namespace", pruneCandidate.Namespace).
+			Msgf("Pruning release %s, it's no longer in the manifest", pruneCandidate.ReleaseName)
+		err := loftsman.helm.Uninstall(pruneCandidate.ReleaseName, pruneCandidate.Namespace, false)
+		if err != nil && !errors.Is(err, interfaces.ErrReleaseNotFound) {
+			notPruned = append(notPruned, pruneCandidate)
+			pruneErrors = append(pruneErrors, fmt.Errorf("Error pruning release %s in namespace %s: %s", pruneCandidate.ReleaseName,
+				pruneCandidate.Namespace, err))
+		}
+	}
+	return notPruned, pruneErrors
+}
@@ -3,10 +3,15 @@ package settings
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/Cray-HPE/go-lib/shell"
+	"github.com/Cray-HPE/loftsman/internal/helm"
 	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	yaml "gopkg.in/yaml.v2"
 )
 
 // Settings are all dynamic settings and data to be used in Loftsman operations
@@ -16,9 +21,16 @@ type Settings struct {
 	JSONLog        *JSONLog
 	Namespace      string // the namespace where loftsman will keep internal-use resources
 	Manifest       *Manifest
+	Ship           *Ship
+	History        *History
+	Logs           *Logs
+	Avast          *Avast
 	ChartsSource   *interfaces.HelmChartsSource
 	Kubernetes     *Kubernetes
 	HelmExecConfig *interfaces.HelmExecConfig
+	HelmBackend    string // how Helm operations are run, one of HelmBackends
+	GitExecConfig  *interfaces.GitExecConfig
+	SopsExecConfig *interfaces.SopsExecConfig
 }
 
 // JSONLog are settings related to the written JSON log file
@@ -29,12 +41,58 @@ type JSONLog struct {
 
 // Manifest are those specific to operations using, producing, validating manifests
 type Manifest struct {
-	Name       string // name of the manifest, used in avast operations
-	Path       string // local path to the loftsman manifest to use for a ship
-	Content    []byte // the bytes of the manifest file
-	ChartNames string // comma-delimited list of charts provided when creating a new manifest file
+	Name          string   // name of the manifest, used in avast operations
+	Path          string   // local path to the loftsman manifest to use for a ship
+	Content       []byte   // the bytes of the manifest file
+	ChartNames    string   // comma-delimited list of charts provided when creating a new manifest file
+	LockPath      string   // local path to the manifest lock, defaults to manifest.lock next to the manifest
+	Variables     []string // NAME=value manifest variables, taking precedence over those of the variables file
+	VariablesFile string   // local path to a yaml file of manifest variables, taking precedence over those of env vars
+	InPlace       bool     // write a migrated manifest over the manifest file rather than to stdout
 }
 
+// ManifestVariableEnvPrefix is the prefix of env vars setting manifest variables, LOFTSMAN_VAR_NAME sets ${NAME}
+const ManifestVariableEnvPrefix = "LOFTSMAN_VAR_"
+
+// Ship are those specific to shipping manifests
+type Ship struct {
+	DryRun         bool     // plan the ship and report what would happen for each chart, without making any changes
+	MaxConcurrency int      // the max number of charts to release at the same time
+	Resume         bool     // skip charts that already succeeded with the same version and values in the last ship of the manifest
+	Prune          bool     // uninstall releases that were in the previous ship of the manifest but no longer are
+	ConfirmPrune   bool     // don't ask for confirmation before pruning releases
+	Update         bool     // resolve chart versions against the charts sources, ignoring the manifest lock
+	Only           []string // only ship the charts with these names
+	Skip           []string // don't ship the charts with these names
+	Selector       string   // only ship the charts with these labels, as key=value[,key=value]
+	HistoryLimit   int      // the number of the latest ships kept in the ship history of the manifest
+}
+
+// History are those specific to listing the history of past ships
+type History struct {
+	Output string // the output format of the history, one of HistoryOutputs
+}
+
+// HistoryOutputs are the supported output formats when listing the history of past ships
+var HistoryOutputs = []string{"table", "json"}
+
+// Logs are those specific to printing the stored log of a ship
+type Logs struct {
+	ShipID string // print the log of this ship, from the ship history, rather than of the latest ship
+	Follow bool   // keep printing new log lines as they're stored until the ship finishes
+	Chart  string // only print log lines for this chart
+	Level  string // only print log lines of this level or above
+}
+
+// Avast are those specific to halting a ship in progress
+type Avast struct {
+	Yes    bool   // don't ask for confirmation before halting the ship
+	Reason string // why the ship is being halted, stored on the ship record
+}
+
+// HelmBackends are the supported ways of running Helm operations
+var HelmBackends = []string{interfaces.HelmBackendExec, interfaces.HelmBackendSDK}
+
 // Kubernetes are settings and data related to Kubernetes API communication
 type Kubernetes struct {
 	KubeconfigPath string // absolute path to the k8s config path to use
@@ -55,6 +113,73 @@ func (s *Settings) ValidateChartsSource() error {
 	return nil
 }
 
+// GetManifestLockPath returns the path of the manifest lock, the lock path setting if set, otherwise a .lock file named
+// after the manifest in the same directory, manifest.lock for manifest.yaml
+func (s *Settings) GetManifestLockPath() string {
+	if s.Manifest.LockPath != "" {
+		return s.Manifest.LockPath
+	}
+	return fmt.Sprintf("%s.lock", strings.TrimSuffix(s.Manifest.Path, filepath.Ext(s.Manifest.Path)))
+}
+
+// GetManifestVariables returns the variables to substitute in the manifest, from LOFTSMAN_VAR_ env vars, then the
+// variables file, then the variables set individually, each taking precedence over the ones before it. The defaults in
+// the manifest's spec.variables take the least precedence of all, and are applied when the manifest is validated
+func (s *Settings) GetManifestVariables() (map[string]string, error) {
+	variables := make(map[string]string)
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, ManifestVariableEnvPrefix) {
+			nameValue := strings.SplitN(strings.TrimPrefix(env, ManifestVariableEnvPrefix), "=", 2)
+			variables[nameValue[0]] = nameValue[1]
+		}
+	}
+	if s.Manifest.VariablesFile != "" {
+		content, err := ioutil.ReadFile(s.Manifest.VariablesFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading vars-file %s: %s", s.Manifest.VariablesFile, err)
+		}
+		fileVariables := make(map[string]string)
+		if err = yaml.Unmarshal(content, &fileVariables); err != nil {
+			return nil, fmt.Errorf("Error parsing vars-file %s as a yaml map of variable names to values: %s", s.Manifest.VariablesFile, err)
+		}
+		for name, value := range fileVariables {
+			variables[name] = value
+		}
+	}
+	for _, variable := range s.Manifest.Variables {
+		nameValue := strings.SplitN(variable, "=", 2)
+		if len(nameValue) != 2 || nameValue[0] == "" {
+			return nil, fmt.Errorf("set-var %s must be of the form NAME=value", variable)
+		}
+		variables[nameValue[0]] = nameValue[1]
+	}
+	return variables, nil
+}
+
+// GetChartSelection returns the selection of the charts to ship from the only, skip and selector settings, or nil when
+// all of the charts are to be shipped
+func (s *Settings) GetChartSelection() (*interfaces.ManifestChartSelection, error) {
+	if len(s.Ship.Only) == 0 && len(s.Ship.Skip) == 0 && s.Ship.Selector == "" {
+		return nil, nil
+	}
+	selection := &interfaces.ManifestChartSelection{
+		Only:     s.Ship.Only,
+		Skip:     s.Ship.Skip,
+		Selector: make(map[string]string),
+	}
+	if s.Ship.Selector == "" {
+		return selection, nil
+	}
+	for _, label := range strings.Split(s.Ship.Selector, ",") {
+		keyValue := strings.SplitN(label, "=", 2)
+		if len(keyValue) != 2 || keyValue[0] == "" {
+			return nil, fmt.Errorf("selector %s must be of the form key=value[,key=value]", s.Ship.Selector)
+		}
+		selection.Selector[keyValue[0]] = keyValue[1]
+	}
+	return selection, nil
+}
+
 // ValidateManifestPath will ensure our manifest path setting is valid
 func (s *Settings) ValidateManifestPath() error {
 	var err error
@@ -64,6 +189,34 @@ func (s *Settings) ValidateManifestPath() error {
 	return nil
 }
 
+// ValidateHistoryOutput will ensure our history output setting is a supported output format
+func (s *Settings) ValidateHistoryOutput() error {
+	for _, historyOutput := range HistoryOutputs {
+		if s.History.Output == historyOutput {
+			return nil
+		}
+	}
+	return fmt.Errorf("history output %s is not supported, must be one of: %s", s.History.Output, strings.Join(HistoryOutputs, ", "))
+}
+
+// ValidateShipHistoryLimit will ensure the ship history keeps at least the ship in progress
+func (s *Settings) ValidateShipHistoryLimit() error {
+	if s.Ship.HistoryLimit < 1 {
+		return fmt.Errorf("history limit %d is not supported, at least 1 ship must be kept in the ship history", s.Ship.HistoryLimit)
+	}
+	return nil
+}
+
+// ValidateHelmBackend will ensure our helm backend setting is a supported backend
+func (s *Settings) ValidateHelmBackend() error {
+	for _, helmBackend := range HelmBackends {
+		if s.HelmBackend == helmBackend {
+			return nil
+		}
+	}
+	return fmt.Errorf("helm backend %s is not supported, must be one of: %s", s.HelmBackend, strings.Join(HelmBackends, ", "))
+}
+
 // New gets a settings object with defaults
 func New() *Settings {
 	return &Settings{
@@ -73,10 +226,27 @@ func New() *Settings {
 		Namespace:    "loftsman",
 		ChartsSource: &interfaces.HelmChartsSource{},
 		Manifest:     &Manifest{},
-		Kubernetes:   &Kubernetes{},
+		Ship: &Ship{
+			MaxConcurrency: 1,
+			HistoryLimit:   100,
+		},
+		History: &History{
+			Output: "table",
+		},
+		Logs:       &Logs{},
+		Avast:      &Avast{},
+		Kubernetes: &Kubernetes{},
 		HelmExecConfig: &interfaces.HelmExecConfig{
 			Binary: "helm",
+			Shell:  &helm.Shell{},
+		},
+		HelmBackend: interfaces.HelmBackendExec,
+		GitExecConfig: &interfaces.GitExecConfig{
+			Binary: "git",
 			Shell:  &shell.Shell{},
 		},
+		SopsExecConfig: &interfaces.SopsExecConfig{
+			Binary: "sops",
+		},
 	}
 }
@@ -1,6 +1,9 @@
 package settings
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -77,3 +80,116 @@ func TestValidateManifestPathSuccess(t *testing.T) {
 		t.Errorf("Got unexpected error from settings.ValidateManifestPath() when settings.Manifest.Path exists, is valid: %s", err)
 	}
 }
+
+func TestValidateHistoryOutputInvalid(t *testing.T) {
+	s := New()
+	s.History.Output = "yaml"
+	err := s.ValidateHistoryOutput()
+	if err == nil || !strings.Contains(err.Error(), "history output yaml is not supported") {
+		t.Errorf("Didn't get expected error from settings.ValidateHistoryOutput() when settings.History.Output is unsupported, got: %s", err)
+	}
+}
+
+func TestValidateHistoryOutputSuccess(t *testing.T) {
+	s := New()
+	s.History.Output = "json"
+	err := s.ValidateHistoryOutput()
+	if err != nil {
+		t.Errorf("Got unexpected error from settings.ValidateHistoryOutput() when settings.History.Output is supported: %s", err)
+	}
+}
+
+func TestValidateShipHistoryLimitInvalid(t *testing.T) {
+	s := New()
+	s.Ship.HistoryLimit = 0
+	err := s.ValidateShipHistoryLimit()
+	if err == nil || !strings.Contains(err.Error(), "history limit 0 is not supported") {
+		t.Errorf("Didn't get expected error from settings.ValidateShipHistoryLimit() when settings.Ship.HistoryLimit is 0, got: %s", err)
+	}
+}
+
+func TestValidateShipHistoryLimitSuccess(t *testing.T) {
+	s := New()
+	err := s.ValidateShipHistoryLimit()
+	if err != nil {
+		t.Errorf("Got unexpected error from settings.ValidateShipHistoryLimit() with the default settings.Ship.HistoryLimit: %s", err)
+	}
+}
+
+func TestValidateHelmBackendInvalid(t *testing.T) {
+	s := New()
+	s.HelmBackend = "tiller"
+	err := s.ValidateHelmBackend()
+	if err == nil || !strings.Contains(err.Error(), "helm backend tiller is not supported") {
+		t.Errorf("Didn't get expected error from settings.ValidateHelmBackend() when settings.HelmBackend is unsupported, got: %s", err)
+	}
+}
+
+func TestValidateHelmBackendSuccess(t *testing.T) {
+	s := New()
+	s.HelmBackend = "sdk"
+	err := s.ValidateHelmBackend()
+	if err != nil {
+		t.Errorf("Got unexpected error from settings.ValidateHelmBackend() when settings.HelmBackend is supported: %s", err)
+	}
+}
+
+func TestGetManifestLockPath(t *testing.T) {
+	s := New()
+	s.Manifest.Path = "./manifests/platform.yaml"
+	if lockPath := s.GetManifestLockPath(); lockPath != "./manifests/platform.lock" {
+		t.Errorf("Didn't get expected default lock path from settings.GetManifestLockPath(), got: %s", lockPath)
+	}
+	s.Manifest.LockPath = "/tmp/platform.lock"
+	if lockPath := s.GetManifestLockPath(); lockPath != "/tmp/platform.lock" {
+		t.Errorf("Didn't get expected lock path setting from settings.GetManifestLockPath(), got: %s", lockPath)
+	}
+}
+
+func TestGetManifestVariables(t *testing.T) {
+	s := New()
+	varsFile := filepath.Join(t.TempDir(), "vars.yaml")
+	ioutil.WriteFile(varsFile, []byte("FROM_FILE: file\nOVERRIDDEN: file\nREPLICAS: 3\n"), 0644)
+	os.Setenv("LOFTSMAN_VAR_FROM_ENV", "env")
+	os.Setenv("LOFTSMAN_VAR_OVERRIDDEN", "env")
+	defer os.Unsetenv("LOFTSMAN_VAR_FROM_ENV")
+	defer os.Unsetenv("LOFTSMAN_VAR_OVERRIDDEN")
+	s.Manifest.VariablesFile = varsFile
+	s.Manifest.Variables = []string{"OVERRIDDEN=flag=value", "EMPTY="}
+	variables, err := s.GetManifestVariables()
+	if err != nil {
+		t.Errorf("Got unexpected error from settings.TestGetManifestVariables(): %s", err)
+		return
+	}
+	expected := map[string]string{"FROM_ENV": "env", "FROM_FILE": "file", "OVERRIDDEN": "flag=value", "EMPTY": "", "REPLICAS": "3"}
+	for name, value := range expected {
+		if variables[name] != value {
+			t.Errorf("Didn't get expected value of %s from settings.TestGetManifestVariables(), expected %s, got: %s", name, value, variables[name])
+		}
+	}
+	s.Manifest.Variables = []string{"NOEQUALS"}
+	if _, err = s.GetManifestVariables(); err == nil {
+		t.Errorf("Didn't get expected error for an invalid set-var from settings.TestGetManifestVariables()")
+	}
+}
+
+func TestGetChartSelection(t *testing.T) {
+	s := New()
+	if selection, err := s.GetChartSelection(); err != nil || selection != nil {
+		t.Errorf("Didn't get expected nil selection from settings.GetChartSelection() without only, skip or selector, got: %v %v", selection, err)
+	}
+	s.Ship.Skip = []string{"chart1"}
+	s.Ship.Selector = "tier=core,site=lab"
+	selection, err := s.GetChartSelection()
+	if err != nil {
+		t.Errorf("Got unexpected error from settings.GetChartSelection(): %s", err)
+		return
+	}
+	if selection.Skip[0] != "chart1" || len(selection.Selector) != 2 || selection.Selector["site"] != "lab" {
+		t.Errorf("Didn't get expected selection from settings.GetChartSelection(), got: %v", selection)
+	}
+	s.Ship.Selector = "tier"
+	if _, err = s.GetChartSelection(); err == nil || !strings.Contains(err.Error(), "must be of the form key=value") {
+		t.Errorf("Didn't get expected error from settings.GetChartSelection() for an invalid selector, got: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+// Package sops is for our sops command object, used to decrypt chart values encrypted with sops
+package sops
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+)
+
+// Sops is our object for running sops commands, implements internal/interfaces/sops.go
+type Sops struct {
+	ExecConfig *interfaces.SopsExecConfig
+}
+
+// Initialize will set our instance up with necessary config/settings. The sops binary isn't checked for here since
+// it's only needed by manifests with encrypted values
+func (s *Sops) Initialize(execConfig *interfaces.SopsExecConfig) error {
+	s.ExecConfig = execConfig
+	return nil
+}
+
+// Decrypt will decrypt a yaml document encrypted with sops. The document is passed to sops on stdin and decrypted to
+// stdout, so that the decrypted values are only ever in memory, and sops' stderr is kept apart from them for errors
+func (s *Sops) Decrypt(content []byte) ([]byte, error) {
+	cmd := exec.Command(s.ExecConfig.Binary, "--decrypt", "--input-type", "yaml", "--output-type", "yaml", "/dev/stdin")
+	cmd.Env = os.Environ()
+	if s.ExecConfig.AgeKeyFile != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("SOPS_AGE_KEY_FILE=%s", s.ExecConfig.AgeKeyFile))
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("Error decrypting with sops: %s", message)
+		}
+		return nil, fmt.Errorf("Error decrypting with sops: %s", err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,60 @@
+package sops
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+)
+
+// getTestSops will return a sops object running a fake sops binary, which "decrypts" its stdin by replacing ENC[test]
+// with the name of the age key file it was given
+func getTestSops(t *testing.T, ageKeyFile string) *Sops {
+	binary := filepath.Join(t.TempDir(), "sops")
+	script := `#!/bin/sh
+if [ "$1 $6" != "--decrypt /dev/stdin" ] || [ -z "$SOPS_AGE_KEY_FILE" ]; then
+  echo "Failed to get the data key required to decrypt the SOPS file." >&2
+  exit 128
+fi
+sed "s|ENC\[test\]|$(basename $SOPS_AGE_KEY_FILE)|" "$6"
+`
+	if err := ioutil.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := &Sops{}
+	s.Initialize(&interfaces.SopsExecConfig{Binary: binary, AgeKeyFile: ageKeyFile})
+	return s
+}
+
+func TestDecrypt(t *testing.T) {
+	decrypted, err := getTestSops(t, "/keys/age.txt").Decrypt([]byte("password: ENC[test]\n"))
+	if err != nil {
+		t.Errorf("Got unexpected error from sops.TestDecrypt(): %s", err)
+		return
+	}
+	if string(decrypted) != "password: age.txt\n" {
+		t.Errorf("Didn't get expected decrypted content from sops.TestDecrypt(), got: %s", decrypted)
+	}
+}
+
+func TestDecryptNoKey(t *testing.T) {
+	// without --age-key-file, the key would otherwise come from the environment
+	os.Unsetenv("SOPS_AGE_KEY_FILE")
+	_, err := getTestSops(t, "").Decrypt([]byte("password: ENC[test]\n"))
+	expected := "Failed to get the data key"
+	if err == nil || !strings.Contains(err.Error(), expected) || strings.Contains(err.Error(), "password") {
+		t.Errorf("Didn't get expected error from sops.TestDecryptNoKey(), instead got: %v", err)
+	}
+}
+
+func TestDecryptNoBinary(t *testing.T) {
+	s := &Sops{}
+	s.Initialize(&interfaces.SopsExecConfig{Binary: fmt.Sprintf("%s/sops", t.TempDir())})
+	if _, err := s.Decrypt([]byte("password: ENC[test]\n")); err == nil {
+		t.Errorf("Didn't get expected error from sops.TestDecryptNoBinary()")
+	}
+}
@@ -2,6 +2,10 @@ package mocks
 
 import (
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 
 	helminterface "github.com/Cray-HPE/loftsman/internal/interfaces"
@@ -9,19 +13,133 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
+// TestRenderedManifestTemplate is the manifest rendered for any chart by the mock Template, and the live release
+// manifest for any release that hasn't drifted
+const TestRenderedManifestTemplate = `---
+# Source: %s/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+data:
+  key: value
+`
+
+// TestReleaseWorkloadsTemplate is added to the live release manifest of releases with healthy in their name, so that
+// they have workloads to roll out
+const TestReleaseWorkloadsTemplate = `---
+# Source: %s/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: %s
+---
+# Source: %s/templates/statefulset.yaml
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: %s-db
+  namespace: databases
+`
+
+// TestOCIChartDigest is the only digest the mock PullChart will pull a chart with
+const TestOCIChartDigest = "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+// TestDownloadedChartContent is the content of every chart the mock DownloadChart downloads
+const TestDownloadedChartContent = "test chart"
+
+// TestDownloadedChartDigest is the sha256 digest of TestDownloadedChartContent
+const TestDownloadedChartDigest = "sha256:11b555cea79b2c1b3134e1aafd24ffa35013bf3ccf4409d8c217da9c4c92c273"
+
+// TestUntrustedKeyring is a keyring the mock VerifyChart fails to verify any chart with
+const TestUntrustedKeyring = "untrusted.gpg"
+
 // GetHelmMock will return a common mock for the Helm interface/object
 func GetHelmMock(availableChartVersions []*helminterface.HelmAvailableChartVersion) *helmmocks.Helm {
 	h := &helmmocks.Helm{}
 	h.On("Initialize", mock.AnythingOfType("*interfaces.HelmExecConfig"), mock.AnythingOfType("*interfaces.HelmChartsSource")).Return(nil)
-	h.On("Exec", mock.MatchedBy(func(command string) bool {
-		if strings.Contains(command, "upgrade --install failed") {
-			return true
-		}
-		return false
-	})).Return("", errors.New("failed install/upgrade"))
-	h.On("Exec", "uninstall failed-remove --namespace default").Return("", errors.New("failed removing failed release"))
-	h.On("Exec", mock.AnythingOfType("string")).Return("", nil)
+	h.On("ForChartsSource", mock.AnythingOfType("*interfaces.HelmChartsSource")).Return(h, nil)
+	h.On("AddRepo", mock.AnythingOfType("*interfaces.HelmChartsSource")).Return(nil)
+	h.On("RemoveRepo", mock.AnythingOfType("string")).Return(nil)
+	h.On("Upgrade", mock.AnythingOfType("*interfaces.HelmReleaseOptions")).Return(func(options *helminterface.HelmReleaseOptions) *helminterface.HelmReleaseResult {
+		if strings.HasPrefix(options.ReleaseName, "failed") {
+			return nil
+		}
+		return &helminterface.HelmReleaseResult{
+			ReleaseName: options.ReleaseName,
+			Namespace:   options.Namespace,
+			Revision:    1,
+			Status:      "deployed",
+		}
+	}, func(options *helminterface.HelmReleaseOptions) error {
+		if strings.HasPrefix(options.ReleaseName, "failed") {
+			return errors.New("failed install/upgrade")
+		}
+		return nil
+	})
+	h.On("Uninstall", "failed-remove", "default", true).Return(errors.New("failed removing failed release"))
+	h.On("Uninstall", "failed-prune", "default", false).Return(errors.New("failed pruning release"))
+	h.On("Uninstall", "already-removed", "default", false).Return(fmt.Errorf("%w: already-removed in namespace default", helminterface.ErrReleaseNotFound))
+	h.On("Uninstall", "namespace-removed", "removed", false).Return(errors.New("uninstall: Release not loaded: namespace-removed: namespaces \"removed\" not found"))
+	h.On("Uninstall", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("bool")).Return(nil)
+	h.On("Rollback", mock.MatchedBy(func(releaseName string) bool {
+		return strings.HasPrefix(releaseName, "failed-rollback")
+	}), mock.AnythingOfType("string"), mock.AnythingOfType("int")).Return(errors.New("failed rollback"))
+	h.On("Rollback", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("int")).Return(nil)
+	h.On("Template", mock.AnythingOfType("*interfaces.HelmReleaseOptions")).Return(func(options *helminterface.HelmReleaseOptions) string {
+		return fmt.Sprintf(TestRenderedManifestTemplate, options.ReleaseName, options.ReleaseName)
+	}, nil)
+	h.On("GetManifest", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(func(releaseName string, namespace string) string {
+		if strings.Contains(releaseName, "not-installed") {
+			return ""
+		}
+		manifest := fmt.Sprintf(TestRenderedManifestTemplate, releaseName, releaseName)
+		if strings.Contains(releaseName, "drifted") {
+			manifest = strings.ReplaceAll(manifest, "key: value", "key: drifted-value")
+		}
+		if strings.Contains(releaseName, "healthy") {
+			manifest += fmt.Sprintf(TestReleaseWorkloadsTemplate, releaseName, releaseName, releaseName, releaseName)
+		}
+		return manifest
+	}, nil)
+	h.On("Test", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(func(releaseName string, namespace string, timeout string) string {
+		return fmt.Sprintf("NAME: %s\nNAMESPACE: %s", releaseName, namespace)
+	}, func(releaseName string, namespace string, timeout string) error {
+		if strings.HasPrefix(releaseName, "unhealthy") {
+			return fmt.Errorf("pod %s-test failed", releaseName)
+		}
+		return nil
+	})
+	h.On("PullChart", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(func(chartPath string, digest string, destination string) string {
+		return filepath.Join(destination, fmt.Sprintf("%s.tgz", strings.ReplaceAll(filepath.Base(chartPath), ":", "-")))
+	}, TestOCIChartDigest, func(chartPath string, digest string, destination string) error {
+		if digest != "" && digest != TestOCIChartDigest {
+			return fmt.Errorf("Chart %s has digest %s, not the pinned digest %s", chartPath, TestOCIChartDigest, digest)
+		}
+		return nil
+	})
+	h.On("PackageChart", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(func(chartDirectory string, destination string) string {
+		return filepath.Join(destination, fmt.Sprintf("%s.tgz", filepath.Base(chartDirectory)))
+	}, nil)
+	h.On("DownloadChart", mock.AnythingOfType("string"), mock.AnythingOfType("bool"), mock.AnythingOfType("string")).Return(func(chartPath string, provenance bool, destination string) string {
+		return filepath.Join(destination, filepath.Base(chartPath))
+	}, func(chartPath string, provenance bool, destination string) error {
+		if strings.Contains(chartPath, "not-found") {
+			return fmt.Errorf("GET %s returned 404 Not Found", chartPath)
+		}
+		if err := os.MkdirAll(destination, 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(destination, filepath.Base(chartPath)), []byte(TestDownloadedChartContent), 0644)
+	})
+	h.On("VerifyChart", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(func(chartPath string, keyring string) error {
+		if filepath.Base(keyring) == TestUntrustedKeyring {
+			return errors.New("openpgp: signature made by unknown entity")
+		}
+		return nil
+	})
 	h.On("GetAvailableChartVersions", mock.AnythingOfType("string")).Return(availableChartVersions, nil)
+	h.On("ValidateChartsDirectory", mock.AnythingOfType("string")).Return([]string{}, nil)
 	h.On("GetReleaseStatus", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(func(chartName string, chartNamespace string) *helminterface.HelmReleaseStatus {
 		rs := &helminterface.HelmReleaseStatus{
 			Revision: 0,
@@ -30,11 +148,40 @@ func GetHelmMock(availableChartVersions []*helminterface.HelmAvailableChartVersi
 			},
 		}
 		rs.Revision = 1
+		if strings.Contains(chartName, "not-installed") {
+			return &helminterface.HelmReleaseStatus{}
+		}
 		if strings.Contains(chartName, "failed") {
 			rs.Info.Status = "failed"
 		}
+		if strings.Contains(chartName, "deployed") {
+			rs.Revision = 2
+			rs.Info.Status = "deployed"
+			rs.Chart = &helminterface.HelmReleaseStatusChart{
+				Metadata: &helminterface.HelmReleaseStatusChartMetadata{
+					Name:    chartName,
+					Version: "0.0.1",
+				},
+			}
+			rs.Config = map[string]interface{}{
+				"global": map[interface{}]interface{}{
+					"chart": map[interface{}]interface{}{
+						"name":    chartName,
+						"version": "0.0.1",
+					},
+				},
+			}
+		}
 		return rs
-	}, nil)
+	}, func(chartName string, chartNamespace string) error {
+		if strings.Contains(chartName, "not-installed") {
+			return fmt.Errorf("%w: %s in namespace %s", helminterface.ErrReleaseNotFound, chartName, chartNamespace)
+		}
+		if strings.Contains(chartName, "status-error") {
+			return errors.New("Kubernetes cluster unreachable: dial tcp: lookup kubernetes: not found")
+		}
+		return nil
+	})
 	h.On("GetExecConfig").Return(&helminterface.HelmExecConfig{})
 	return h
 }
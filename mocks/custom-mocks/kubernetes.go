@@ -1,8 +1,13 @@
 package mocks
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	kubernetesmocks "github.com/Cray-HPE/loftsman/mocks/interfaces"
 	"github.com/stretchr/testify/mock"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -10,6 +15,37 @@ import (
 const (
 	// TestSecretKeyValue is just a mock value always returns when using GetSecretKeyValue
 	TestSecretKeyValue = "secret"
+	// TestChartResults is just a mock value of recorded chart results always in the configmap returned by GetConfigMap
+	TestChartResults = `[{"chart":"tests","version":"0.0.1","namespace":"default","releaseName":"tests","valuesHash":"","status":"success"}]`
+	// TestOwnedReleases is just a mock value of the releases owned by a manifest always in the configmap returned by
+	// GetConfigMap, the removed release isn't in the test manifests
+	TestOwnedReleases = `[{"releaseName":"tests","namespace":"default"},{"releaseName":"removed","namespace":"default"}]`
+	// TestReleasedOptions is just a mock value of the options hash each release was last released with always in the
+	// configmap returned by GetConfigMap
+	TestReleasedOptions = `[{"releaseName":"tests","namespace":"default","optionsHash":"sha256:abc123"}]`
+	// TestShipLog is just a mock ship log always in the log configmaps returned by GetConfigMap
+	TestShipLog = `{"level":"info","command":"ship","time":"2021-12-09T14:08:31-06:00","message":"Running a release"}
+{"command":"ship","sub-header":"Releasing tests v0.0.1","time":"2021-12-09T14:08:31-06:00"}
+{"level":"info","command":"ship","chart":"tests","version":"0.0.1","namespace":"default","time":"2021-12-09T14:08:31-06:00","message":"Running helm install/upgrade"}
+{"level":"error","command":"ship","chart":"tests","version":"0.0.1","namespace":"default","time":"2021-12-09T14:08:37-06:00","message":"Error releasing chart tests v0.0.1"}
+`
+	// TestShipID is just a mock ship ID, of the ship log in the log configmaps returned by GetConfigMap
+	TestShipID = "20211209T200714Z-0a1b2c3d"
+	// TestPastShipID is just a mock ship ID of an earlier ship, whose log is also in the log configmaps returned by
+	// GetConfigMap
+	TestPastShipID = "20211208T100000Z-00000000"
+	// TestAvastReason is just a mock reason of the avasted ship configmaps returned by GetConfigMap, for names ending in
+	// -avasted
+	TestAvastReason = "bad values"
+	// TestValues is just a mock of chart values in the values.yaml key of the configmaps returned by GetConfigMap, for
+	// names ending in -values
+	TestValues = "replicas: 2\nimage:\n  tag: configmap\n"
+	// TestLeaseHolderIdentity is just a mock holder of the lease returned by AcquireLease when it's held by another ship
+	TestLeaseHolderIdentity = "tester@test-host/1234"
+	// TestShipHistoryEntry is just a mock ship history entry always in the history configmaps returned by GetConfigMap
+	// and ListConfigMaps
+	TestShipHistoryEntry = `{"id":"20211209T200714Z-0a1b2c3d","manifest":"test-manifest","startTime":"2021-12-09T20:07:14Z",` +
+		`"endTime":"2021-12-09T20:08:39Z","status":"success","user":"tester","host":"test-host","manifestDigest":"sha256:abc123"}`
 )
 
 // GetKubernetesMock will return a common mock for the Kubernetes interface/object
@@ -31,9 +67,111 @@ func GetKubernetesMock(triggerFoundConfigMap bool) *kubernetesmocks.Kubernetes {
 		}
 		return nil
 	}, nil)
+	k.On("GetConfigMap", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(func(name string, namespace string) *v1.ConfigMap {
+		if strings.HasSuffix(name, "-ship-history") {
+			return getHistoryConfigMap(name, namespace)
+		}
+		if strings.HasSuffix(name, "-ship-log") {
+			data := make(map[string]string)
+			data["loftsman.log"] = TestShipLog
+			data["ship-id"] = TestShipID
+			data[TestPastShipID+".log"] = TestShipLog
+			return &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: namespace,
+					Name:      name,
+				},
+				Data: data,
+			}
+		}
+		if strings.HasSuffix(name, "-values") {
+			data := make(map[string]string)
+			data["values.yaml"] = TestValues
+			return &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: namespace,
+					Name:      name,
+				},
+				Data: data,
+			}
+		}
+		data := make(map[string]string)
+		data["status"] = "failed"
+		if strings.HasSuffix(name, "-avasted") {
+			data["status"] = "avasted"
+			data["avast-reason"] = TestAvastReason
+		}
+		data["charts.json"] = TestChartResults
+		data["releases.json"] = TestOwnedReleases
+		data["options.json"] = TestReleasedOptions
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      name,
+			},
+			Data: data,
+		}
+	}, nil)
+	k.On("ListConfigMaps", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(func(namespace string, labelSelector string) []v1.ConfigMap {
+		return []v1.ConfigMap{*getHistoryConfigMap("loftsman-test-manifest-ship-history", namespace)}
+	}, nil)
+	k.On("InitializeHistoryConfigMap", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(&v1.ConfigMap{}, nil)
 	k.On("InitializeShipConfigMap", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("map[string]string")).Return(&v1.ConfigMap{}, nil)
 	k.On("InitializeLogConfigMap", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("map[string]string")).Return(&v1.ConfigMap{}, nil)
 	k.On("PatchConfigMap", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("map[string]string")).Return(&v1.ConfigMap{}, nil)
+	k.On("RemoveConfigMapKeys", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("[]string")).Return(&v1.ConfigMap{}, nil)
+	k.On("AcquireLease", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("time.Duration")).Return(func(name string, namespace string, holderIdentity string, leaseDuration time.Duration) *coordinationv1.Lease {
+		if triggerFoundConfigMap {
+			holderIdentity = TestLeaseHolderIdentity
+		}
+		leaseDurationSeconds := int32(leaseDuration.Seconds())
+		acquireTime := metav1.NewMicroTime(time.Now().Add(-5 * time.Minute))
+		renewTime := metav1.NewMicroTime(time.Now().Add(-10 * time.Second))
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      name,
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holderIdentity,
+				LeaseDurationSeconds: &leaseDurationSeconds,
+				AcquireTime:          &acquireTime,
+				RenewTime:            &renewTime,
+			},
+		}
+	}, !triggerFoundConfigMap, nil)
+	k.On("RenewLease", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(&coordinationv1.Lease{}, nil)
+	k.On("ReleaseLease", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
 	k.On("GetSecretKeyValue", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(TestSecretKeyValue, nil)
+	k.On("WaitForRollout", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("time.Duration")).Return(func(kind string, name string, namespace string, timeout time.Duration) error {
+		if strings.HasPrefix(name, "unhealthy") {
+			return fmt.Errorf("timed out after %s waiting for %s %s/%s to roll out: 0 of 1 updated replicas are available", timeout, kind, namespace, name)
+		}
+		return nil
+	})
+	k.On("WaitForJob", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("time.Duration")).Return(func(name string, namespace string, timeout time.Duration) error {
+		if strings.HasPrefix(name, "unhealthy") {
+			return fmt.Errorf("error waiting for Job %s/%s to complete: job %s failed", namespace, name, name)
+		}
+		return nil
+	})
+	k.On("WaitForCondition", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("time.Duration")).Return(func(apiVersion string, kind string, name string, namespace string, conditionType string, status string, timeout time.Duration) error {
+		if strings.HasPrefix(name, "unhealthy") {
+			return fmt.Errorf("timed out after %s waiting for %s %s/%s to have condition %s=%s", timeout, kind, namespace, name, conditionType, status)
+		}
+		return nil
+	})
 	return k
 }
+
+func getHistoryConfigMap(name string, namespace string) *v1.ConfigMap {
+	data := make(map[string]string)
+	data[TestShipID] = TestShipHistoryEntry
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Data: data,
+	}
+}
@@ -12,20 +12,57 @@ type Helm struct {
 	mock.Mock
 }
 
-// Exec provides a mock function with given fields: subCommand
-func (_m *Helm) Exec(subCommand string) (string, error) {
-	ret := _m.Called(subCommand)
+// AddRepo provides a mock function with given fields: chartsSource
+func (_m *Helm) AddRepo(chartsSource *interfaces.HelmChartsSource) error {
+	ret := _m.Called(chartsSource)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*interfaces.HelmChartsSource) error); ok {
+		r0 = rf(chartsSource)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DownloadChart provides a mock function with given fields: chartPath, provenance, destination
+func (_m *Helm) DownloadChart(chartPath string, provenance bool, destination string) (string, error) {
+	ret := _m.Called(chartPath, provenance, destination)
 
 	var r0 string
-	if rf, ok := ret.Get(0).(func(string) string); ok {
-		r0 = rf(subCommand)
+	if rf, ok := ret.Get(0).(func(string, bool, string) string); ok {
+		r0 = rf(chartPath, provenance, destination)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(subCommand)
+	if rf, ok := ret.Get(1).(func(string, bool, string) error); ok {
+		r1 = rf(chartPath, provenance, destination)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ForChartsSource provides a mock function with given fields: chartsSource
+func (_m *Helm) ForChartsSource(chartsSource *interfaces.HelmChartsSource) (interfaces.Helm, error) {
+	ret := _m.Called(chartsSource)
+
+	var r0 interfaces.Helm
+	if rf, ok := ret.Get(0).(func(*interfaces.HelmChartsSource) interfaces.Helm); ok {
+		r0 = rf(chartsSource)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(interfaces.Helm)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*interfaces.HelmChartsSource) error); ok {
+		r1 = rf(chartsSource)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -72,6 +109,27 @@ func (_m *Helm) GetExecConfig() *interfaces.HelmExecConfig {
 	return r0
 }
 
+// GetManifest provides a mock function with given fields: releaseName, namespace
+func (_m *Helm) GetManifest(releaseName string, namespace string) (string, error) {
+	ret := _m.Called(releaseName, namespace)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string, string) string); ok {
+		r0 = rf(releaseName, namespace)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(releaseName, namespace)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetReleaseStatus provides a mock function with given fields: chartName, chartNamespace
 func (_m *Helm) GetReleaseStatus(chartName string, chartNamespace string) (*interfaces.HelmReleaseStatus, error) {
 	ret := _m.Called(chartName, chartNamespace)
@@ -108,3 +166,196 @@ func (_m *Helm) Initialize(execConfig *interfaces.HelmExecConfig, chartsSource *
 
 	return r0
 }
+
+// PackageChart provides a mock function with given fields: chartDirectory, destination
+func (_m *Helm) PackageChart(chartDirectory string, destination string) (string, error) {
+	ret := _m.Called(chartDirectory, destination)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string, string) string); ok {
+		r0 = rf(chartDirectory, destination)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(chartDirectory, destination)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PullChart provides a mock function with given fields: chartPath, digest, destination
+func (_m *Helm) PullChart(chartPath string, digest string, destination string) (string, string, error) {
+	ret := _m.Called(chartPath, digest, destination)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string, string, string) string); ok {
+		r0 = rf(chartPath, digest, destination)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(string, string, string) string); ok {
+		r1 = rf(chartPath, digest, destination)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(string, string, string) error); ok {
+		r2 = rf(chartPath, digest, destination)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// RemoveRepo provides a mock function with given fields: repoName
+func (_m *Helm) RemoveRepo(repoName string) error {
+	ret := _m.Called(repoName)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(repoName)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Rollback provides a mock function with given fields: releaseName, namespace, revision
+func (_m *Helm) Rollback(releaseName string, namespace string, revision int) error {
+	ret := _m.Called(releaseName, namespace, revision)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, int) error); ok {
+		r0 = rf(releaseName, namespace, revision)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Template provides a mock function with given fields: options
+func (_m *Helm) Template(options *interfaces.HelmReleaseOptions) (string, error) {
+	ret := _m.Called(options)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(*interfaces.HelmReleaseOptions) string); ok {
+		r0 = rf(options)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*interfaces.HelmReleaseOptions) error); ok {
+		r1 = rf(options)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Test provides a mock function with given fields: releaseName, namespace, timeout
+func (_m *Helm) Test(releaseName string, namespace string, timeout string) (string, error) {
+	ret := _m.Called(releaseName, namespace, timeout)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string, string, string) string); ok {
+		r0 = rf(releaseName, namespace, timeout)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, string) error); ok {
+		r1 = rf(releaseName, namespace, timeout)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Uninstall provides a mock function with given fields: releaseName, namespace, noHooks
+func (_m *Helm) Uninstall(releaseName string, namespace string, noHooks bool) error {
+	ret := _m.Called(releaseName, namespace, noHooks)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, bool) error); ok {
+		r0 = rf(releaseName, namespace, noHooks)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Upgrade provides a mock function with given fields: options
+func (_m *Helm) Upgrade(options *interfaces.HelmReleaseOptions) (*interfaces.HelmReleaseResult, error) {
+	ret := _m.Called(options)
+
+	var r0 *interfaces.HelmReleaseResult
+	if rf, ok := ret.Get(0).(func(*interfaces.HelmReleaseOptions) *interfaces.HelmReleaseResult); ok {
+		r0 = rf(options)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*interfaces.HelmReleaseResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*interfaces.HelmReleaseOptions) error); ok {
+		r1 = rf(options)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ValidateChartsDirectory provides a mock function with given fields: directory
+func (_m *Helm) ValidateChartsDirectory(directory string) ([]string, error) {
+	ret := _m.Called(directory)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(string) []string); ok {
+		r0 = rf(directory)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(directory)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// VerifyChart provides a mock function with given fields: chartPath, keyring
+func (_m *Helm) VerifyChart(chartPath string, keyring string) error {
+	ret := _m.Called(chartPath, keyring)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(chartPath, keyring)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -4,6 +4,10 @@ package mocks
 
 import (
 	mock "github.com/stretchr/testify/mock"
+	coordinationv1 "k8s.io/api/coordination/v1"
+
+	time "time"
+
 	v1 "k8s.io/api/core/v1"
 )
 
@@ -12,6 +16,36 @@ type Kubernetes struct {
 	mock.Mock
 }
 
+// AcquireLease provides a mock function with given fields: name, namespace, holderIdentity, leaseDuration
+func (_m *Kubernetes) AcquireLease(name string, namespace string, holderIdentity string, leaseDuration time.Duration) (*coordinationv1.Lease, bool, error) {
+	ret := _m.Called(name, namespace, holderIdentity, leaseDuration)
+
+	var r0 *coordinationv1.Lease
+	if rf, ok := ret.Get(0).(func(string, string, string, time.Duration) *coordinationv1.Lease); ok {
+		r0 = rf(name, namespace, holderIdentity, leaseDuration)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*coordinationv1.Lease)
+		}
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string, string, string, time.Duration) bool); ok {
+		r1 = rf(name, namespace, holderIdentity, leaseDuration)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(string, string, string, time.Duration) error); ok {
+		r2 = rf(name, namespace, holderIdentity, leaseDuration)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // EnsureNamespace provides a mock function with given fields: name
 func (_m *Kubernetes) EnsureNamespace(name string) error {
 	ret := _m.Called(name)
@@ -49,6 +83,29 @@ func (_m *Kubernetes) FindConfigMap(name string, namespace string, withKey strin
 	return r0, r1
 }
 
+// GetConfigMap provides a mock function with given fields: name, namespace
+func (_m *Kubernetes) GetConfigMap(name string, namespace string) (*v1.ConfigMap, error) {
+	ret := _m.Called(name, namespace)
+
+	var r0 *v1.ConfigMap
+	if rf, ok := ret.Get(0).(func(string, string) *v1.ConfigMap); ok {
+		r0 = rf(name, namespace)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v1.ConfigMap)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(name, namespace)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetSecretKeyValue provides a mock function with given fields: secretName, namespace, dataKey
 func (_m *Kubernetes) GetSecretKeyValue(secretName string, namespace string, dataKey string) (string, error) {
 	ret := _m.Called(secretName, namespace, dataKey)
@@ -84,6 +141,29 @@ func (_m *Kubernetes) Initialize(kubeconfigPath string, kubeContext string) erro
 	return r0
 }
 
+// InitializeHistoryConfigMap provides a mock function with given fields: name, namespace
+func (_m *Kubernetes) InitializeHistoryConfigMap(name string, namespace string) (*v1.ConfigMap, error) {
+	ret := _m.Called(name, namespace)
+
+	var r0 *v1.ConfigMap
+	if rf, ok := ret.Get(0).(func(string, string) *v1.ConfigMap); ok {
+		r0 = rf(name, namespace)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v1.ConfigMap)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(name, namespace)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // InitializeLogConfigMap provides a mock function with given fields: name, namespace, data
 func (_m *Kubernetes) InitializeLogConfigMap(name string, namespace string, data map[string]string) (*v1.ConfigMap, error) {
 	ret := _m.Called(name, namespace, data)
@@ -144,6 +224,29 @@ func (_m *Kubernetes) IsRetryError(err error) bool {
 	return r0
 }
 
+// ListConfigMaps provides a mock function with given fields: namespace, labelSelector
+func (_m *Kubernetes) ListConfigMaps(namespace string, labelSelector string) ([]v1.ConfigMap, error) {
+	ret := _m.Called(namespace, labelSelector)
+
+	var r0 []v1.ConfigMap
+	if rf, ok := ret.Get(0).(func(string, string) []v1.ConfigMap); ok {
+		r0 = rf(namespace, labelSelector)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]v1.ConfigMap)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(namespace, labelSelector)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // PatchConfigMap provides a mock function with given fields: name, namespace, data
 func (_m *Kubernetes) PatchConfigMap(name string, namespace string, data map[string]string) (*v1.ConfigMap, error) {
 	ret := _m.Called(name, namespace, data)
@@ -166,3 +269,105 @@ func (_m *Kubernetes) PatchConfigMap(name string, namespace string, data map[str
 
 	return r0, r1
 }
+
+// ReleaseLease provides a mock function with given fields: name, namespace, holderIdentity
+func (_m *Kubernetes) ReleaseLease(name string, namespace string, holderIdentity string) error {
+	ret := _m.Called(name, namespace, holderIdentity)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string) error); ok {
+		r0 = rf(name, namespace, holderIdentity)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveConfigMapKeys provides a mock function with given fields: name, namespace, keys
+func (_m *Kubernetes) RemoveConfigMapKeys(name string, namespace string, keys []string) (*v1.ConfigMap, error) {
+	ret := _m.Called(name, namespace, keys)
+
+	var r0 *v1.ConfigMap
+	if rf, ok := ret.Get(0).(func(string, string, []string) *v1.ConfigMap); ok {
+		r0 = rf(name, namespace, keys)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v1.ConfigMap)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, []string) error); ok {
+		r1 = rf(name, namespace, keys)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RenewLease provides a mock function with given fields: name, namespace, holderIdentity
+func (_m *Kubernetes) RenewLease(name string, namespace string, holderIdentity string) (*coordinationv1.Lease, error) {
+	ret := _m.Called(name, namespace, holderIdentity)
+
+	var r0 *coordinationv1.Lease
+	if rf, ok := ret.Get(0).(func(string, string, string) *coordinationv1.Lease); ok {
+		r0 = rf(name, namespace, holderIdentity)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*coordinationv1.Lease)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, string) error); ok {
+		r1 = rf(name, namespace, holderIdentity)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// WaitForCondition provides a mock function with given fields: apiVersion, kind, name, namespace, conditionType, status, timeout
+func (_m *Kubernetes) WaitForCondition(apiVersion string, kind string, name string, namespace string, conditionType string, status string, timeout time.Duration) error {
+	ret := _m.Called(apiVersion, kind, name, namespace, conditionType, status, timeout)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string, string, string, string, time.Duration) error); ok {
+		r0 = rf(apiVersion, kind, name, namespace, conditionType, status, timeout)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// WaitForJob provides a mock function with given fields: name, namespace, timeout
+func (_m *Kubernetes) WaitForJob(name string, namespace string, timeout time.Duration) error {
+	ret := _m.Called(name, namespace, timeout)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, time.Duration) error); ok {
+		r0 = rf(name, namespace, timeout)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// WaitForRollout provides a mock function with given fields: kind, name, namespace, timeout
+func (_m *Kubernetes) WaitForRollout(kind string, name string, namespace string, timeout time.Duration) error {
+	ret := _m.Called(kind, name, namespace, timeout)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string, time.Duration) error); ok {
+		r0 = rf(kind, name, namespace, timeout)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
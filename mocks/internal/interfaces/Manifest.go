@@ -14,6 +14,20 @@ type Manifest struct {
 	mock.Mock
 }
 
+// ApplyLock provides a mock function with given fields: lock
+func (_m *Manifest) ApplyLock(lock *interfaces.ManifestLock) error {
+	ret := _m.Called(lock)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*interfaces.ManifestLock) error); ok {
+		r0 = rf(lock)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Create provides a mock function with given fields: initializeCharts
 func (_m *Manifest) Create(initializeCharts []string) (string, error) {
 	ret := _m.Called(initializeCharts)
@@ -35,6 +49,31 @@ func (_m *Manifest) Create(initializeCharts []string) (string, error) {
 	return r0, r1
 }
 
+// Diff provides a mock function with given fields: kubernetes, helm
+func (_m *Manifest) Diff(kubernetes interfaces.Kubernetes, helm interfaces.Helm) ([]*interfaces.ManifestDiffEntry, []*interfaces.ManifestReleaseError) {
+	ret := _m.Called(kubernetes, helm)
+
+	var r0 []*interfaces.ManifestDiffEntry
+	if rf, ok := ret.Get(0).(func(interfaces.Kubernetes, interfaces.Helm) []*interfaces.ManifestDiffEntry); ok {
+		r0 = rf(kubernetes, helm)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*interfaces.ManifestDiffEntry)
+		}
+	}
+
+	var r1 []*interfaces.ManifestReleaseError
+	if rf, ok := ret.Get(1).(func(interfaces.Kubernetes, interfaces.Helm) []*interfaces.ManifestReleaseError); ok {
+		r1 = rf(kubernetes, helm)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]*interfaces.ManifestReleaseError)
+		}
+	}
+
+	return r0, r1
+}
+
 // GetName provides a mock function with given fields:
 func (_m *Manifest) GetName() string {
 	ret := _m.Called()
@@ -49,6 +88,36 @@ func (_m *Manifest) GetName() string {
 	return r0
 }
 
+// GetPrune provides a mock function with given fields:
+func (_m *Manifest) GetPrune() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// GetReleases provides a mock function with given fields:
+func (_m *Manifest) GetReleases() []*interfaces.ManifestRelease {
+	ret := _m.Called()
+
+	var r0 []*interfaces.ManifestRelease
+	if rf, ok := ret.Get(0).(func() []*interfaces.ManifestRelease); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*interfaces.ManifestRelease)
+		}
+	}
+
+	return r0
+}
+
 // Load provides a mock function with given fields: manifestContent
 func (_m *Manifest) Load(manifestContent string) error {
 	ret := _m.Called(manifestContent)
@@ -63,6 +132,56 @@ func (_m *Manifest) Load(manifestContent string) error {
 	return r0
 }
 
+// Lock provides a mock function with given fields: kubernetes, helm
+func (_m *Manifest) Lock(kubernetes interfaces.Kubernetes, helm interfaces.Helm) (*interfaces.ManifestLock, []*interfaces.ManifestReleaseError) {
+	ret := _m.Called(kubernetes, helm)
+
+	var r0 *interfaces.ManifestLock
+	if rf, ok := ret.Get(0).(func(interfaces.Kubernetes, interfaces.Helm) *interfaces.ManifestLock); ok {
+		r0 = rf(kubernetes, helm)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*interfaces.ManifestLock)
+		}
+	}
+
+	var r1 []*interfaces.ManifestReleaseError
+	if rf, ok := ret.Get(1).(func(interfaces.Kubernetes, interfaces.Helm) []*interfaces.ManifestReleaseError); ok {
+		r1 = rf(kubernetes, helm)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]*interfaces.ManifestReleaseError)
+		}
+	}
+
+	return r0, r1
+}
+
+// Plan provides a mock function with given fields: kubernetes, helm
+func (_m *Manifest) Plan(kubernetes interfaces.Kubernetes, helm interfaces.Helm) ([]*interfaces.ManifestPlanEntry, []*interfaces.ManifestReleaseError) {
+	ret := _m.Called(kubernetes, helm)
+
+	var r0 []*interfaces.ManifestPlanEntry
+	if rf, ok := ret.Get(0).(func(interfaces.Kubernetes, interfaces.Helm) []*interfaces.ManifestPlanEntry); ok {
+		r0 = rf(kubernetes, helm)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*interfaces.ManifestPlanEntry)
+		}
+	}
+
+	var r1 []*interfaces.ManifestReleaseError
+	if rf, ok := ret.Get(1).(func(interfaces.Kubernetes, interfaces.Helm) []*interfaces.ManifestReleaseError); ok {
+		r1 = rf(kubernetes, helm)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]*interfaces.ManifestReleaseError)
+		}
+	}
+
+	return r0, r1
+}
+
 // Release provides a mock function with given fields: kubernetes, helm
 func (_m *Manifest) Release(kubernetes interfaces.Kubernetes, helm interfaces.Helm) []*interfaces.ManifestReleaseError {
 	ret := _m.Called(kubernetes, helm)
@@ -79,12 +198,69 @@ func (_m *Manifest) Release(kubernetes interfaces.Kubernetes, helm interfaces.He
 	return r0
 }
 
+// SelectCharts provides a mock function with given fields: selection
+func (_m *Manifest) SelectCharts(selection *interfaces.ManifestChartSelection) ([]string, error) {
+	ret := _m.Called(selection)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(*interfaces.ManifestChartSelection) []string); ok {
+		r0 = rf(selection)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*interfaces.ManifestChartSelection) error); ok {
+		r1 = rf(selection)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetDirectory provides a mock function with given fields: directory
+func (_m *Manifest) SetDirectory(directory string) {
+	_m.Called(directory)
+}
+
+// SetGit provides a mock function with given fields: git
+func (_m *Manifest) SetGit(git interfaces.Git) {
+	_m.Called(git)
+}
+
 // SetLogger provides a mock function with given fields: log
 func (_m *Manifest) SetLogger(log *logger.Logger) {
 	_m.Called(log)
 }
 
+// SetReleaseOptions provides a mock function with given fields: releaseOptions
+func (_m *Manifest) SetReleaseOptions(releaseOptions *interfaces.ManifestReleaseOptions) {
+	_m.Called(releaseOptions)
+}
+
+// SetSops provides a mock function with given fields: sops
+func (_m *Manifest) SetSops(sops interfaces.Sops) {
+	_m.Called(sops)
+}
+
 // SetTempDirectory provides a mock function with given fields: tempDirectory
 func (_m *Manifest) SetTempDirectory(tempDirectory string) {
 	_m.Called(tempDirectory)
 }
+
+// ValidateSpec provides a mock function with given fields:
+func (_m *Manifest) ValidateSpec() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -0,0 +1,37 @@
+package v1
+
+import (
+	"github.com/Cray-HPE/loftsman/schemas/manifests/v1beta1"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Create will make a baseline manifest for this version
+func (m *Manifest) Create(initializeCharts []string) (string, error) {
+	var charts []*v1beta1.Chart
+	for _, initializeChart := range initializeCharts {
+		charts = append(charts, &v1beta1.Chart{
+			Name:      initializeChart,
+			Namespace: "",
+			Version:   "",
+		})
+	}
+	manifest := &Manifest{
+		Manifest: v1beta1.Manifest{
+			APIVersion: APIVersion,
+			Metadata: &v1beta1.Metadata{
+				Name: "",
+			},
+			Spec: &v1beta1.Spec{
+				Sources: &v1beta1.Sources{
+					Charts: []*v1beta1.ChartSource{},
+				},
+				Charts: charts,
+			},
+		},
+	}
+	manifestContent, err := yaml.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+	return string(manifestContent), nil
+}
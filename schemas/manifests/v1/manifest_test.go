@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestLoad(t *testing.T) {
+	manifest := &Manifest{}
+	manifestContent := `---
+apiVersion: manifests/v1
+metadata:
+  name: test-manifest
+  labels:
+    site: lab
+spec:
+  all:
+    helm:
+      wait: true
+  charts: []`
+	err := manifest.Load(manifestContent)
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.v1.TestLoad(): %s", err)
+		return
+	}
+	if manifest.GetName() != "test-manifest" || manifest.Metadata.Labels["site"] != "lab" || !*manifest.Spec.All.Helm.Wait {
+		t.Errorf("Didn't get expected manifest from manifest.v1.TestLoad(), got: %+v", manifest.Manifest)
+	}
+}
+
+func TestCreateSomeCharts(t *testing.T) {
+	manifest := &Manifest{}
+	created, err := manifest.Create([]string{"one", "two"})
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.v1.TestCreateSomeCharts: %s", err)
+		return
+	}
+	if !strings.HasPrefix(created, "apiVersion: manifests/v1\n") || !strings.Contains(created, "name: one") ||
+		!strings.Contains(created, "name: two") {
+		t.Errorf("Didn't get expected manifest from manifest.v1.TestCreateSomeCharts, got output: %s", created)
+	}
+	loaded := &Manifest{}
+	if err = yaml.Unmarshal([]byte(created), loaded); err != nil || len(loaded.Spec.Charts) != 2 {
+		t.Errorf("Couldn't load the created manifest from manifest.v1.TestCreateSomeCharts: %v", err)
+	}
+}
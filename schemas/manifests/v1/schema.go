@@ -0,0 +1,18 @@
+// Package v1 is manifest resources for the v1 schema
+package v1
+
+import (
+	"github.com/Cray-HPE/loftsman/schemas/manifests/v1beta1"
+)
+
+const (
+	// APIVersion is the string representation of the api version of this schema
+	APIVersion = "manifests/v1"
+)
+
+// Manifest is the v1 manifest object, implements internal/interfaces/manifest.go. v1 is v1beta1 with metadata.labels,
+// the Helm options of each chart, and spec.all defaults for them, so it's released by the v1beta1 implementation and
+// what differs is its schema
+type Manifest struct {
+	v1beta1.Manifest `yaml:",inline"`
+}
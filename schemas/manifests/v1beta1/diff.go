@@ -0,0 +1,235 @@
+package v1beta1
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	"github.com/pmezard/go-difflib/difflib"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var manifestDocumentSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// secretDataFields are the fields of a Secret resource whose values are masked before it's diffed
+var secretDataFields = []string{"data", "stringData"}
+
+const (
+	secretValueMask         = "[REDACTED]"
+	secretLiveValueMask     = "[REDACTED live value]"
+	secretManifestValueMask = "[REDACTED manifest value]"
+)
+
+// manifestResource is the minimal identifying info of a single Kubernetes resource in a rendered manifest
+type manifestResource struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+}
+
+// Diff will render each enabled chart in the manifest and compare it with the manifest of its live release in the cluster,
+// returning a unified diff for every resource that differs between the two
+func (m *Manifest) Diff(kubernetes interfaces.Kubernetes, helm interfaces.Helm) ([]*interfaces.ManifestDiffEntry, []*interfaces.ManifestReleaseError) {
+	var diffEntries []*interfaces.ManifestDiffEntry
+	var releaseErrors []*interfaces.ManifestReleaseError
+	addedRepos := []string{}
+
+	m.validateChartsDirectories(helm)
+
+CHARTS:
+	for _, chart := range m.Spec.Charts {
+		if chart.disabled {
+			continue
+		}
+		recordReleaseError := func(releaseErr error) {
+			releaseErrors = append(releaseErrors, &interfaces.ManifestReleaseError{
+				Chart:     chart.Name,
+				Version:   chart.Version,
+				Namespace: chart.Namespace,
+				Error:     releaseErr,
+			})
+		}
+
+		target, err := m.resolveChart(chart, kubernetes, helm)
+		if err != nil {
+			recordReleaseError(err)
+			continue CHARTS
+		}
+		if target.needsAddedRepo() {
+			if addedRepos, err = addChartsRepo(helm, target.chartsSource, addedRepos); err != nil {
+				recordReleaseError(err)
+				continue CHARTS
+			}
+		}
+		helmReleaseOptions, removeValuesFile, err := m.getHelmReleaseOptions(chart, target)
+		if err != nil {
+			recordReleaseError(err)
+			continue CHARTS
+		}
+		rendered, err := helm.Template(helmReleaseOptions)
+		removeValuesFile()
+		if err != nil {
+			recordReleaseError(fmt.Errorf("Error rendering chart %s v%s: %s", chart.Name, chart.Version, err))
+			continue CHARTS
+		}
+		live, err := helm.GetManifest(target.releaseName, chart.Namespace)
+		if err != nil {
+			recordReleaseError(fmt.Errorf("Error getting the live manifest of release %s: %s", target.releaseName, err))
+			continue CHARTS
+		}
+
+		renderedResources, err := splitManifestResources(rendered, chart.Namespace)
+		if err != nil {
+			recordReleaseError(fmt.Errorf("Error parsing the rendered manifest of chart %s v%s: %s", chart.Name, chart.Version, err))
+			continue CHARTS
+		}
+		liveResources, err := splitManifestResources(live, chart.Namespace)
+		if err != nil {
+			recordReleaseError(fmt.Errorf("Error parsing the live manifest of release %s: %s", target.releaseName, err))
+			continue CHARTS
+		}
+		resourceKeys := []string{}
+		for resourceKey := range renderedResources {
+			resourceKeys = append(resourceKeys, resourceKey)
+		}
+		for resourceKey := range liveResources {
+			if _, ok := renderedResources[resourceKey]; !ok {
+				resourceKeys = append(resourceKeys, resourceKey)
+			}
+		}
+		sort.Strings(resourceKeys)
+		for _, resourceKey := range resourceKeys {
+			if renderedResources[resourceKey] == liveResources[resourceKey] {
+				continue
+			}
+			liveResource, renderedResource := liveResources[resourceKey], renderedResources[resourceKey]
+			if strings.HasPrefix(resourceKey, "Secret/") {
+				if liveResource, renderedResource, err = maskSecretData(liveResource, renderedResource); err != nil {
+					recordReleaseError(fmt.Errorf("Error masking the data of resource %s: %s", resourceKey, err))
+					continue CHARTS
+				}
+			}
+			diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        difflib.SplitLines(liveResource),
+				B:        difflib.SplitLines(renderedResource),
+				FromFile: fmt.Sprintf("live/%s", resourceKey),
+				ToFile:   fmt.Sprintf("manifest/%s", resourceKey),
+				Context:  3,
+			})
+			if err != nil {
+				recordReleaseError(fmt.Errorf("Error generating the diff of resource %s: %s", resourceKey, err))
+				continue CHARTS
+			}
+			if diff == "" {
+				// only the formatting of the Secret differed, which masking it evened out
+				continue
+			}
+			diffEntries = append(diffEntries, &interfaces.ManifestDiffEntry{
+				Chart:       chart.Name,
+				Version:     chart.Version,
+				Namespace:   chart.Namespace,
+				ReleaseName: target.releaseName,
+				Resource:    resourceKey,
+				Diff:        diff,
+			})
+		}
+	}
+	for _, addedRepo := range addedRepos {
+		_ = helm.RemoveRepo(addedRepo)
+	}
+	return diffEntries, releaseErrors
+}
+
+// splitManifestResources will split a multi-document rendered Helm manifest into its individual resources, keyed by
+// kind/namespace/name
+func splitManifestResources(manifest string, defaultNamespace string) (map[string]string, error) {
+	resources := make(map[string]string)
+	for _, document := range manifestDocumentSeparator.Split(manifest, -1) {
+		resource := &manifestResource{}
+		if err := yaml.Unmarshal([]byte(document), resource); err != nil {
+			return resources, err
+		}
+		if resource.Kind == "" {
+			continue
+		}
+		namespace := resource.Metadata.Namespace
+		if namespace == "" {
+			namespace = defaultNamespace
+		}
+		resources[fmt.Sprintf("%s/%s/%s", resource.Kind, namespace, resource.Metadata.Name)] = fmt.Sprintf("%s\n", strings.TrimSpace(document))
+	}
+	return resources, nil
+}
+
+// maskSecretData will mask the values of the data and stringData of a Secret resource in both its live and rendered
+// manifests, so that secret values are never printed in a diff. A value that differs between the two is masked
+// differently on each side, so the diff still shows which keys of the Secret changed
+func maskSecretData(live string, rendered string) (string, string, error) {
+	liveSecret, renderedSecret := yaml.MapSlice{}, yaml.MapSlice{}
+	if err := yaml.Unmarshal([]byte(live), &liveSecret); err != nil {
+		return "", "", err
+	}
+	if err := yaml.Unmarshal([]byte(rendered), &renderedSecret); err != nil {
+		return "", "", err
+	}
+	maskedLive := maskSecretFields(liveSecret, renderedSecret, secretLiveValueMask)
+	maskedRendered := maskSecretFields(renderedSecret, liveSecret, secretManifestValueMask)
+	liveBytes, err := marshalSecret(maskedLive)
+	if err != nil {
+		return "", "", err
+	}
+	renderedBytes, err := marshalSecret(maskedRendered)
+	if err != nil {
+		return "", "", err
+	}
+	return liveBytes, renderedBytes, nil
+}
+
+// maskSecretFields will return a copy of a Secret with the values of its data fields masked, using changedMask for the
+// values that are different in the other side of the diff, and leaving the original Secret as it is
+func maskSecretFields(secret yaml.MapSlice, otherSecret yaml.MapSlice, changedMask string) yaml.MapSlice {
+	masked := yaml.MapSlice{}
+	for _, item := range secret {
+		data, isMap := item.Value.(yaml.MapSlice)
+		if !isMap || !contains(secretDataFields, fmt.Sprint(item.Key)) {
+			masked = append(masked, item)
+			continue
+		}
+		otherData, _ := getMapSliceValue(otherSecret, item.Key).(yaml.MapSlice)
+		maskedData := yaml.MapSlice{}
+		for _, dataItem := range data {
+			mask := secretValueMask
+			if otherValue := getMapSliceValue(otherData, dataItem.Key); otherValue != nil && !reflect.DeepEqual(otherValue, dataItem.Value) {
+				mask = changedMask
+			}
+			maskedData = append(maskedData, yaml.MapItem{Key: dataItem.Key, Value: mask})
+		}
+		masked = append(masked, yaml.MapItem{Key: item.Key, Value: maskedData})
+	}
+	return masked
+}
+
+// getMapSliceValue will return the value of a key in a yaml.MapSlice, or nil if it isn't there
+func getMapSliceValue(mapSlice yaml.MapSlice, key interface{}) interface{} {
+	for _, item := range mapSlice {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// marshalSecret will marshal a masked Secret back to a manifest document, an empty Secret being an empty document as
+// it is when the resource is only on one side of the diff
+func marshalSecret(secret yaml.MapSlice) (string, error) {
+	if len(secret) == 0 {
+		return "", nil
+	}
+	secretBytes, err := yaml.Marshal(secret)
+	return string(secretBytes), err
+}
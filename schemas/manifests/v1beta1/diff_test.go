@@ -0,0 +1,145 @@
+package v1beta1
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	custommocks "github.com/Cray-HPE/loftsman/mocks/custom-mocks"
+	mocks "github.com/Cray-HPE/loftsman/mocks/interfaces"
+)
+
+func TestDiff(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/chart-0.0.1.tgz",
+		},
+	}
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{
+			Name:      "unchanged",
+			Namespace: "default",
+			Version:   "0.0.1",
+		},
+		&Chart{
+			Name:      "drifted",
+			Namespace: "default",
+			Version:   "0.0.1",
+			Values: map[string]interface{}{
+				"one": "1",
+			},
+		},
+		&Chart{
+			Name:      "not-installed",
+			Namespace: "default",
+			Version:   "0.0.1",
+		},
+	}
+	diffEntries, errs := manifest.Diff(custommocks.GetKubernetesMock(false), custommocks.GetHelmMock(availableChartVersions))
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestDiff(): %s", errsToString(errs))
+		return
+	}
+	if len(diffEntries) != 2 {
+		t.Errorf("Expected 2 diff entries from manifest.v1beta1.TestDiff(), got %d", len(diffEntries))
+		return
+	}
+	if diffEntries[0].Chart != "drifted" || diffEntries[0].Resource != "ConfigMap/default/drifted" ||
+		!strings.Contains(diffEntries[0].Diff, "-  key: drifted-value") || !strings.Contains(diffEntries[0].Diff, "+  key: value") {
+		t.Errorf("Didn't get expected diff for the drifted chart from manifest.v1beta1.TestDiff(), got: %s", diffEntries[0].Diff)
+	}
+	if diffEntries[1].Chart != "not-installed" || !strings.Contains(diffEntries[1].Diff, "+kind: ConfigMap") {
+		t.Errorf("Didn't get expected diff for the not-installed chart from manifest.v1beta1.TestDiff(), got: %s", diffEntries[1].Diff)
+	}
+}
+
+// secretHelm is a helm mock that renders a Secret with new values, where the live release has the old ones
+type secretHelm struct {
+	*mocks.Helm
+}
+
+const testSecretManifest = `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+type: Opaque
+data:
+  username: YWRtaW4=
+  password: %s
+stringData:
+  token: %s
+`
+
+func (h *secretHelm) Template(options *interfaces.HelmReleaseOptions) (string, error) {
+	return fmt.Sprintf(testSecretManifest, "bmV3LXBhc3N3b3Jk", "new-token"), nil
+}
+
+func (h *secretHelm) GetManifest(releaseName string, namespace string) (string, error) {
+	return fmt.Sprintf(testSecretManifest, "b2xkLXBhc3N3b3Jk", "old-token"), nil
+}
+
+func TestDiffMasksSecretData(t *testing.T) {
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "secrets", Namespace: "default", Version: "0.0.1"},
+	}
+	helm := &secretHelm{custommocks.GetHelmMock(getTestAvailableChartVersions("0.0.1"))}
+	diffEntries, errs := manifest.Diff(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestDiffMasksSecretData(): %s", errsToString(errs))
+		return
+	}
+	if len(diffEntries) != 1 || diffEntries[0].Resource != "Secret/default/credentials" {
+		t.Errorf("Expected a diff of the Secret from manifest.v1beta1.TestDiffMasksSecretData(), got %d diff entries", len(diffEntries))
+		return
+	}
+	diff := diffEntries[0].Diff
+	for _, secretValue := range []string{"YWRtaW4=", "bmV3LXBhc3N3b3Jk", "b2xkLXBhc3N3b3Jk", "new-token", "old-token"} {
+		if strings.Contains(diff, secretValue) {
+			t.Errorf("Didn't expect secret value %s in the diff from manifest.v1beta1.TestDiffMasksSecretData(), got: %s", secretValue, diff)
+		}
+	}
+	// the changed keys are still in the diff, the unchanged ones are masked the same on both sides
+	for _, line := range []string{"-  password: '[REDACTED live value]'", "+  password: '[REDACTED manifest value]'",
+		"-  token: '[REDACTED live value]'", "+  token: '[REDACTED manifest value]'", "   username: '[REDACTED]'"} {
+		if !strings.Contains(diff, line) {
+			t.Errorf("Didn't get expected line %q in the diff from manifest.v1beta1.TestDiffMasksSecretData(), got: %s", line, diff)
+		}
+	}
+}
+
+func TestSplitManifestResources(t *testing.T) {
+	manifest := `---
+# Source: chart/templates/namespaced.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: one
+  namespace: other
+---
+# Source: chart/templates/empty.yaml
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: two
+`
+	resources, err := splitManifestResources(manifest, "default")
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.v1beta1.TestSplitManifestResources(): %s", err)
+		return
+	}
+	if len(resources) != 2 {
+		t.Errorf("Expected 2 resources from manifest.v1beta1.TestSplitManifestResources(), got %d", len(resources))
+	}
+	if _, ok := resources["ConfigMap/other/one"]; !ok {
+		t.Error("Didn't find expected resource ConfigMap/other/one from manifest.v1beta1.TestSplitManifestResources()")
+	}
+	if _, ok := resources["Service/default/two"]; !ok {
+		t.Error("Didn't find expected resource Service/default/two from manifest.v1beta1.TestSplitManifestResources()")
+	}
+}
@@ -0,0 +1,178 @@
+package v1beta1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// isEnabled will evaluate a chart's enabled, which is true when it isn't set. It's either a bool, or an expression of
+// the manifest's ${NAME} variables once they've been substituted, like `${SITE} == lab && ${MONITORING}`. Expressions
+// compare values with == and !=, combine them with &&, || and !, and group them with parentheses. Values are single
+// words, or quoted when they have spaces or operators or may be empty, and a value on its own must be true or false
+func (c *Chart) isEnabled() (bool, error) {
+	switch enabled := c.Enabled.(type) {
+	case nil:
+		return true, nil
+	case bool:
+		return enabled, nil
+	case string:
+		return evaluateEnabled(enabled)
+	default:
+		return false, fmt.Errorf("enabled must be true, false, or an expression, not %v", enabled)
+	}
+}
+
+// enabledToken is a single operator or value of an enabled expression
+type enabledToken struct {
+	value    string
+	operator bool
+}
+
+// enabledParser is a recursive descent parser of an enabled expression, evaluating it as it's parsed
+type enabledParser struct {
+	tokens   []enabledToken
+	position int
+}
+
+// evaluateEnabled will evaluate an enabled expression
+func evaluateEnabled(expression string) (bool, error) {
+	tokens, err := tokenizeEnabled(expression)
+	if err != nil {
+		return false, fmt.Errorf("invalid enabled expression %q: %s", expression, err)
+	}
+	parser := &enabledParser{tokens: tokens}
+	result, err := parser.parseOr()
+	if err == nil && parser.position < len(parser.tokens) {
+		err = fmt.Errorf("unexpected %s", parser.tokens[parser.position].value)
+	}
+	if err != nil {
+		return false, fmt.Errorf("invalid enabled expression %q: %s", expression, err)
+	}
+	return result, nil
+}
+
+// tokenizeEnabled will split an enabled expression into its operators and values
+func tokenizeEnabled(expression string) ([]enabledToken, error) {
+	tokens := []enabledToken{}
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		switch {
+		case unicode.IsSpace(runes[i]):
+			i++
+		case i+1 < len(runes) && (string(runes[i:i+2]) == "==" || string(runes[i:i+2]) == "!=" ||
+			string(runes[i:i+2]) == "&&" || string(runes[i:i+2]) == "||"):
+			tokens = append(tokens, enabledToken{value: string(runes[i : i+2]), operator: true})
+			i += 2
+		case runes[i] == '!' || runes[i] == '(' || runes[i] == ')':
+			tokens = append(tokens, enabledToken{value: string(runes[i]), operator: true})
+			i++
+		case runes[i] == '"' || runes[i] == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != runes[i] {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated quote %c", runes[i])
+			}
+			tokens = append(tokens, enabledToken{value: string(runes[i+1 : end])})
+			i = end + 1
+		case runes[i] == '=' || runes[i] == '&' || runes[i] == '|':
+			return nil, fmt.Errorf("unexpected %c, the operators are ==, !=, &&, || and !", runes[i])
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(`"'()!=&|`, runes[i]) {
+				i++
+			}
+			tokens = append(tokens, enabledToken{value: string(runes[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+// peek returns whether the next token is the given operator, without consuming it
+func (p *enabledParser) peek(operator string) bool {
+	return p.position < len(p.tokens) && p.tokens[p.position].operator && p.tokens[p.position].value == operator
+}
+
+// parseOr parses values or'd together with ||
+func (p *enabledParser) parseOr() (bool, error) {
+	result, err := p.parseAnd()
+	for err == nil && p.peek("||") {
+		p.position++
+		var next bool
+		next, err = p.parseAnd()
+		result = result || next
+	}
+	return result, err
+}
+
+// parseAnd parses values and'd together with &&
+func (p *enabledParser) parseAnd() (bool, error) {
+	result, err := p.parseNot()
+	for err == nil && p.peek("&&") {
+		p.position++
+		var next bool
+		next, err = p.parseNot()
+		result = result && next
+	}
+	return result, err
+}
+
+// parseNot parses a value negated with !, or a parenthesized expression, or a comparison
+func (p *enabledParser) parseNot() (bool, error) {
+	if p.peek("!") {
+		p.position++
+		result, err := p.parseNot()
+		return !result, err
+	}
+	if p.peek("(") {
+		p.position++
+		result, err := p.parseOr()
+		if err != nil {
+			return false, err
+		}
+		if !p.peek(")") {
+			return false, fmt.Errorf("missing )")
+		}
+		p.position++
+		return result, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses a value compared to another with == or !=, or a value on its own, which must be a bool
+func (p *enabledParser) parseComparison() (bool, error) {
+	left, err := p.parseValue()
+	if err != nil {
+		return false, err
+	}
+	if p.peek("==") || p.peek("!=") {
+		operator := p.tokens[p.position].value
+		p.position++
+		right, err := p.parseValue()
+		if err != nil {
+			return false, err
+		}
+		return (left == right) == (operator == "=="), nil
+	}
+	result, err := strconv.ParseBool(left)
+	if err != nil {
+		return false, fmt.Errorf("%q on its own must be true or false", left)
+	}
+	return result, nil
+}
+
+// parseValue parses a single value
+func (p *enabledParser) parseValue() (string, error) {
+	if p.position >= len(p.tokens) {
+		return "", fmt.Errorf("missing a value at the end")
+	}
+	token := p.tokens[p.position]
+	if token.operator {
+		return "", fmt.Errorf("expected a value, not %s", token.value)
+	}
+	p.position++
+	return token.value, nil
+}
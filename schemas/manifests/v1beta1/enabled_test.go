@@ -0,0 +1,59 @@
+package v1beta1
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsEnabled(t *testing.T) {
+	for enabled, expected := range map[interface{}]bool{
+		nil:                                  true,
+		true:                                 true,
+		false:                                false,
+		"true":                               true,
+		"lab == lab":                         true,
+		"lab != lab":                         false,
+		`"" == ''`:                           true,
+		`"edge site" == 'edge site'`:         true,
+		"lab == prod || true && false":       false,
+		"(lab == prod || true) && !false":    true,
+		"!(lab == lab)":                      false,
+		"true && lab != edge && ! false":     true,
+		"lab==lab&&prod!=lab":                true,
+		"(lab == prod) || (core == core)":    true,
+		"false || false || (true && !false)": true,
+	} {
+		chart := &Chart{Name: "test-chart", Enabled: enabled}
+		result, err := chart.isEnabled()
+		if err != nil {
+			t.Errorf("Got unexpected error from manifest.v1beta1.TestIsEnabled() for %v: %s", enabled, err)
+			continue
+		}
+		if result != expected {
+			t.Errorf("Didn't get expected result from manifest.v1beta1.TestIsEnabled() for %v, expected %t, got: %t",
+				enabled, expected, result)
+		}
+	}
+}
+
+func TestIsEnabledInvalid(t *testing.T) {
+	for enabled, expected := range map[interface{}]string{
+		"lab":             `"lab" on its own must be true or false`,
+		"lab == ":         "missing a value at the end",
+		"lab = lab":       "unexpected =",
+		"(true":           "missing )",
+		"true)":           "unexpected )",
+		"true false":      "unexpected false",
+		`"lab == lab`:     "unterminated quote",
+		"== lab":          "expected a value, not ==",
+		"":                "missing a value at the end",
+		3:                 "enabled must be true, false, or an expression",
+		"true && || true": "expected a value, not ||",
+	} {
+		chart := &Chart{Name: "test-chart", Enabled: enabled}
+		_, err := chart.isEnabled()
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Didn't get expected error from manifest.v1beta1.TestIsEnabledInvalid() for %v, got: %v", enabled, err)
+		}
+	}
+}
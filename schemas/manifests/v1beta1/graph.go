@@ -0,0 +1,163 @@
+package v1beta1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// chartGraph is the dependency graph of the charts in a manifest, as defined by spec.charts[].dependsOn
+type chartGraph struct {
+	charts     []*Chart
+	dependsOn  [][]int
+	dependents [][]int
+}
+
+// newChartGraph will build the dependency graph for a list of charts, returning an error if a chart depends on a chart
+// that isn't in the list, or if there's a dependency cycle
+func newChartGraph(charts []*Chart) (*chartGraph, error) {
+	g := &chartGraph{
+		charts:     charts,
+		dependsOn:  make([][]int, len(charts)),
+		dependents: make([][]int, len(charts)),
+	}
+	chartIndices := make(map[string][]int)
+	for i, chart := range charts {
+		chartIndices[chart.Name] = append(chartIndices[chart.Name], i)
+	}
+	for i, chart := range charts {
+		added := make(map[int]bool)
+		for _, dependency := range chart.DependsOn {
+			dependencyIndices, ok := chartIndices[dependency]
+			if !ok {
+				return nil, fmt.Errorf("chart %s depends on %s, which isn't in spec.charts", chart.Name, dependency)
+			}
+			for _, j := range dependencyIndices {
+				if added[j] {
+					continue
+				}
+				added[j] = true
+				g.dependsOn[i] = append(g.dependsOn[i], j)
+				g.dependents[j] = append(g.dependents[j], i)
+			}
+		}
+	}
+
+	// Kahn's algorithm, any charts we can't get to are part of, or depend on, a cycle
+	remaining := make([]int, len(charts))
+	ready := []int{}
+	for i := range charts {
+		remaining[i] = len(g.dependsOn[i])
+		if remaining[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	visited := 0
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		visited++
+		for _, j := range g.dependents[i] {
+			remaining[j]--
+			if remaining[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+	}
+	if visited < len(charts) {
+		cycleCharts := []string{}
+		for i, chart := range charts {
+			if remaining[i] > 0 {
+				cycleCharts = append(cycleCharts, chart.Name)
+			}
+		}
+		return nil, fmt.Errorf("dependsOn cycle detected between charts: %s", strings.Join(cycleCharts, ", "))
+	}
+	return g, nil
+}
+
+// releaseOutcome is the outcome of releasing a single chart while walking the chart graph
+type releaseOutcome int
+
+const (
+	releaseSucceeded releaseOutcome = iota
+	releaseFailed
+	releaseFailedStop // the chart failed and no further charts should be released
+)
+
+// walk will call releaseFn for every chart in the graph once all of the charts it depends on have been released
+// successfully, running up to maxConcurrency charts at a time. Ready charts are always started in their manifest order,
+// so a maxConcurrency of 1 without any dependsOn releases charts exactly in the order they're listed. Charts that depend,
+// directly or not, on a chart that failed aren't released, skipFn is called for them instead. Once a chart fails with
+// releaseFailedStop, no further charts are started and skipFn is called for all of those remaining with stopped set
+func (g *chartGraph) walk(maxConcurrency int, releaseFn func(chart *Chart) releaseOutcome,
+	skipFn func(chart *Chart, failedChart *Chart, stopped bool)) {
+	type releaseResult struct {
+		index   int
+		outcome releaseOutcome
+	}
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+	remaining := make([]int, len(g.charts))
+	skipped := make([]bool, len(g.charts))
+	ready := []int{}
+	for i := range g.charts {
+		remaining[i] = len(g.dependsOn[i])
+		if remaining[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	results := make(chan releaseResult)
+	running := 0
+	done := 0
+	stoppedBy := -1
+	skip := func(toSkip []int, failed int, stopped bool) {
+		for len(toSkip) > 0 {
+			j := toSkip[0]
+			toSkip = toSkip[1:]
+			if skipped[j] {
+				continue
+			}
+			skipped[j] = true
+			done++
+			skipFn(g.charts[j], g.charts[failed], stopped)
+			toSkip = append(toSkip, g.dependents[j]...)
+		}
+	}
+	for done < len(g.charts) {
+		if stoppedBy >= 0 {
+			skip(ready, stoppedBy, true)
+			ready = []int{}
+		}
+		for running < maxConcurrency && len(ready) > 0 {
+			i := ready[0]
+			ready = ready[1:]
+			running++
+			go func(i int) {
+				results <- releaseResult{index: i, outcome: releaseFn(g.charts[i])}
+			}(i)
+		}
+		if running == 0 {
+			// nothing left that can be released, only possible with a cycle which newChartGraph prevents
+			return
+		}
+		result := <-results
+		running--
+		done++
+		if result.outcome == releaseSucceeded {
+			for _, j := range g.dependents[result.index] {
+				remaining[j]--
+				if remaining[j] == 0 && !skipped[j] {
+					ready = append(ready, j)
+				}
+			}
+			sort.Ints(ready)
+			continue
+		}
+		skip(g.dependents[result.index], result.index, false)
+		if result.outcome == releaseFailedStop && stoppedBy < 0 {
+			stoppedBy = result.index
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package v1beta1
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	custommocks "github.com/Cray-HPE/loftsman/mocks/custom-mocks"
+)
+
+func TestNewChartGraphUnknownDependency(t *testing.T) {
+	_, err := newChartGraph([]*Chart{
+		&Chart{Name: "one", DependsOn: []string{"two"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "isn't in spec.charts") {
+		t.Errorf("Didn't get expected error from manifest.v1beta1.TestNewChartGraphUnknownDependency(), instead got: %s", err)
+	}
+}
+
+func TestNewChartGraphCycle(t *testing.T) {
+	_, err := newChartGraph([]*Chart{
+		&Chart{Name: "one", DependsOn: []string{"three"}},
+		&Chart{Name: "two"},
+		&Chart{Name: "three", DependsOn: []string{"one"}},
+	})
+	if err == nil || err.Error() != "dependsOn cycle detected between charts: one, three" {
+		t.Errorf("Didn't get expected error from manifest.v1beta1.TestNewChartGraphCycle(), instead got: %s", err)
+	}
+}
+
+func TestChartGraphWalkInOrder(t *testing.T) {
+	g, err := newChartGraph([]*Chart{
+		&Chart{Name: "one", DependsOn: []string{"three"}},
+		&Chart{Name: "two"},
+		&Chart{Name: "three"},
+	})
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.v1beta1.TestChartGraphWalkInOrder(): %s", err)
+		return
+	}
+	released := []string{}
+	g.walk(1, func(chart *Chart) releaseOutcome {
+		released = append(released, chart.Name)
+		return releaseSucceeded
+	}, func(chart *Chart, failedChart *Chart, stopped bool) {
+		t.Errorf("Got unexpected skip of chart %s from manifest.v1beta1.TestChartGraphWalkInOrder()", chart.Name)
+	})
+	if strings.Join(released, ",") != "two,three,one" {
+		t.Errorf("Didn't get expected release order from manifest.v1beta1.TestChartGraphWalkInOrder(), got: %s", released)
+	}
+}
+
+func TestChartGraphWalkConcurrency(t *testing.T) {
+	g, _ := newChartGraph([]*Chart{
+		&Chart{Name: "one"},
+		&Chart{Name: "two"},
+		&Chart{Name: "three"},
+		&Chart{Name: "four", DependsOn: []string{"one", "two", "three"}},
+	})
+	var mutex sync.Mutex
+	running := 0
+	maxRunning := 0
+	released := []string{}
+	g.walk(2, func(chart *Chart) releaseOutcome {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mutex.Lock()
+		running--
+		released = append(released, chart.Name)
+		mutex.Unlock()
+		return releaseSucceeded
+	}, func(chart *Chart, failedChart *Chart, stopped bool) {})
+	if maxRunning != 2 {
+		t.Errorf("Expected 2 charts releasing at the same time from manifest.v1beta1.TestChartGraphWalkConcurrency(), got %d", maxRunning)
+	}
+	if len(released) != 4 || released[3] != "four" {
+		t.Errorf("Didn't get expected release order from manifest.v1beta1.TestChartGraphWalkConcurrency(), got: %s", released)
+	}
+}
+
+func TestChartGraphWalkSkipsDependentsOfFailures(t *testing.T) {
+	g, _ := newChartGraph([]*Chart{
+		&Chart{Name: "one"},
+		&Chart{Name: "two", DependsOn: []string{"one"}},
+		&Chart{Name: "three", DependsOn: []string{"two"}},
+		&Chart{Name: "four"},
+	})
+	released := []string{}
+	skipped := []string{}
+	g.walk(1, func(chart *Chart) releaseOutcome {
+		released = append(released, chart.Name)
+		if chart.Name == "one" {
+			return releaseFailed
+		}
+		return releaseSucceeded
+	}, func(chart *Chart, failedChart *Chart, stopped bool) {
+		skipped = append(skipped, chart.Name)
+	})
+	if strings.Join(released, ",") != "one,four" || strings.Join(skipped, ",") != "two,three" {
+		t.Errorf("Didn't get expected results from manifest.v1beta1.TestChartGraphWalkSkipsDependentsOfFailures(), released: %s, skipped: %s",
+			released, skipped)
+	}
+}
+
+func TestChartGraphWalkStop(t *testing.T) {
+	g, _ := newChartGraph([]*Chart{
+		&Chart{Name: "one"},
+		&Chart{Name: "two", DependsOn: []string{"one"}},
+		&Chart{Name: "three"},
+		&Chart{Name: "four"},
+	})
+	released := []string{}
+	stopped := []string{}
+	g.walk(1, func(chart *Chart) releaseOutcome {
+		released = append(released, chart.Name)
+		if chart.Name == "three" {
+			return releaseFailedStop
+		}
+		return releaseSucceeded
+	}, func(chart *Chart, failedChart *Chart, wasStopped bool) {
+		if wasStopped && failedChart.Name == "three" {
+			stopped = append(stopped, chart.Name)
+		}
+	})
+	if strings.Join(released, ",") != "one,two,three" || strings.Join(stopped, ",") != "four" {
+		t.Errorf("Didn't get expected results from manifest.v1beta1.TestChartGraphWalkStop(), released: %s, stopped: %s",
+			released, stopped)
+	}
+}
+
+func TestReleaseWithDependencies(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/chart-0.0.1.tgz",
+		},
+	}
+	manifest := getTestManifest()
+	manifest.SetReleaseOptions(&interfaces.ManifestReleaseOptions{MaxConcurrency: 3})
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "failed", Namespace: "default", Version: "0.0.1"},
+		&Chart{Name: "depends-on-failed", Namespace: "default", Version: "0.0.1", DependsOn: []string{"failed"}},
+		&Chart{Name: "independent", Namespace: "default", Version: "0.0.1"},
+		&Chart{Name: "depends-on-independent", Namespace: "default", Version: "0.0.1", DependsOn: []string{"independent"}},
+	}
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), custommocks.GetHelmMock(availableChartVersions))
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors from manifest.v1beta1.TestReleaseWithDependencies(), got: %s", errsToString(errs))
+		return
+	}
+	for _, err := range errs {
+		if err.Chart == "depends-on-failed" && !strings.Contains(err.Error.Error(), "depends on chart failed") {
+			t.Errorf("Didn't get expected error for depends-on-failed from manifest.v1beta1.TestReleaseWithDependencies(), got: %s", err.Error)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package v1beta1
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+)
+
+var chartURLPath = regexp.MustCompile("^http(s)?://")
+
+// checkChartIntegrity will check the packaged chart of a release target against the digest the chart is pinned to, and
+// verify its .prov file when its source is verified. A chart from a chart repo is downloaded to the temp directory first,
+// and released from there, so that the chart checked is the one released
+func (m *Manifest) checkChartIntegrity(chart *Chart, target *releaseTarget, verifySource *ChartSource,
+	kubernetes interfaces.Kubernetes, helm interfaces.Helm) error {
+	var err error
+	if chartURLPath.MatchString(target.chartPath) {
+		target.chartPath, err = helm.DownloadChart(target.chartPath, verifySource != nil, filepath.Join(m.tempDirectory, "charts"))
+		if err != nil {
+			return fmt.Errorf("Error downloading chart %s v%s from its chart repo: %s", chart.Name, chart.Version, err)
+		}
+		target.downloaded = true
+	}
+	if chart.Digest != "" {
+		chartBytes, err := ioutil.ReadFile(target.chartPath)
+		if err != nil {
+			return fmt.Errorf("Error reading chart %s v%s to check its digest: %s", chart.Name, chart.Version, err)
+		}
+		if digest := fmt.Sprintf("sha256:%x", sha256.Sum256(chartBytes)); digest != chart.Digest {
+			return fmt.Errorf("Chart %s v%s has digest %s, not the pinned digest %s", chart.Name, chart.Version, digest, chart.Digest)
+		}
+	}
+	if verifySource != nil {
+		keyring, err := m.getKeyring(verifySource, kubernetes)
+		if err != nil {
+			return err
+		}
+		if err = helm.VerifyChart(target.chartPath, keyring); err != nil {
+			return fmt.Errorf("Error verifying the provenance of chart %s v%s with the keyring of spec.sources.charts[] name = %s: %s",
+				chart.Name, chart.Version, verifySource.Name, err)
+		}
+	}
+	return nil
+}
+
+// getKeyring returns the local path of the keyring to verify the charts of a source with, a relative keyring being
+// relative to the manifest, writing the keyring from the source's keyring secret to the temp directory if it has one
+func (m *Manifest) getKeyring(chartSource *ChartSource, kubernetes interfaces.Kubernetes) (string, error) {
+	if chartSource.KeyringSecret == nil {
+		return m.getManifestFilePath(chartSource.Keyring), nil
+	}
+	keyring, err := kubernetes.GetSecretKeyValue(chartSource.KeyringSecret.Name, chartSource.KeyringSecret.Namespace,
+		chartSource.KeyringSecret.Key)
+	if err != nil {
+		return "", fmt.Errorf("Error getting chart source keyring from secret %s for spec.sources.charts[] name = %s: %s",
+			chartSource.KeyringSecret.Name, chartSource.Name, err)
+	}
+	keyringDirectory := filepath.Join(m.tempDirectory, "keyrings")
+	if err = os.MkdirAll(keyringDirectory, 0755); err != nil {
+		return "", err
+	}
+	keyringPath := filepath.Join(keyringDirectory, fmt.Sprintf("%s.gpg", chartSource.Name))
+	if err = ioutil.WriteFile(keyringPath, []byte(keyring), 0600); err != nil {
+		return "", err
+	}
+	return keyringPath, nil
+}
@@ -0,0 +1,175 @@
+package v1beta1
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	custommocks "github.com/Cray-HPE/loftsman/mocks/custom-mocks"
+	"github.com/stretchr/testify/mock"
+)
+
+func getIntegrityTestManifest(t *testing.T, chartSource *ChartSource, digest string) (*Manifest, []*interfaces.HelmAvailableChartVersion) {
+	chartPath := filepath.Join(t.TempDir(), "full-chart-0.0.1.tgz")
+	if chartSource.Type == ChartSourceTypeRepo {
+		chartPath = "https://charts.io/charts/full-chart-0.0.1.tgz"
+	} else if err := ioutil.WriteFile(chartPath, []byte(custommocks.TestDownloadedChartContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := getTestManifest()
+	manifest.Spec.Sources = &Sources{[]*ChartSource{chartSource}}
+	manifest.Spec.Charts = []*Chart{
+		&Chart{
+			Name:      "full-chart",
+			Source:    chartSource.Name,
+			Namespace: "default",
+			Version:   "0.0.1",
+			Digest:    digest,
+		},
+	}
+	return manifest, []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    chartPath,
+		},
+	}
+}
+
+func TestChartDigestDirectory(t *testing.T) {
+	manifest, availableChartVersions := getIntegrityTestManifest(t,
+		&ChartSource{Type: ChartSourceTypeDirectory, Name: "local", Location: "/tmp"}, custommocks.TestDownloadedChartDigest)
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestChartDigestDirectory(): %s", errsToString(errs))
+	}
+	helm.AssertNotCalled(t, "DownloadChart", mock.Anything, mock.Anything, mock.Anything)
+	helm.AssertCalled(t, "Upgrade", mock.MatchedBy(func(options *interfaces.HelmReleaseOptions) bool {
+		return options.ChartPath == availableChartVersions[0].Path
+	}))
+}
+
+func TestChartDigestMismatch(t *testing.T) {
+	manifest, availableChartVersions := getIntegrityTestManifest(t,
+		&ChartSource{Type: ChartSourceTypeDirectory, Name: "local", Location: "/tmp"}, custommocks.TestOCIChartDigest)
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error.Error(), "not the pinned digest "+custommocks.TestOCIChartDigest) {
+		t.Errorf("Didn't get expected digest error from manifest.v1beta1.TestChartDigestMismatch(), got: %s", errsToString(errs))
+	}
+	helm.AssertNotCalled(t, "Upgrade", mock.Anything)
+}
+
+func TestChartDigestRepo(t *testing.T) {
+	manifest, availableChartVersions := getIntegrityTestManifest(t, &ChartSource{
+		Type:     ChartSourceTypeRepo,
+		Name:     "remote",
+		Location: "https://charts.io/charts",
+		CredentialsSecret: &ChartSourceCredentialsSecret{
+			Name:        "charts-creds",
+			Namespace:   "default",
+			UsernameKey: "username",
+			PasswordKey: "password",
+		},
+	}, custommocks.TestDownloadedChartDigest)
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestChartDigestRepo(): %s", errsToString(errs))
+	}
+	// the downloaded chart is the one released, so the repo doesn't need to be added
+	helm.AssertCalled(t, "DownloadChart", availableChartVersions[0].Path, false, filepath.Join(manifest.tempDirectory, "charts"))
+	helm.AssertNotCalled(t, "AddRepo", mock.Anything)
+	helm.AssertCalled(t, "Upgrade", mock.MatchedBy(func(options *interfaces.HelmReleaseOptions) bool {
+		return options.ChartPath == filepath.Join(manifest.tempDirectory, "charts", "full-chart-0.0.1.tgz") && options.Version == ""
+	}))
+}
+
+func TestChartSourceVerify(t *testing.T) {
+	manifest, availableChartVersions := getIntegrityTestManifest(t, &ChartSource{
+		Type:     ChartSourceTypeRepo,
+		Name:     "remote",
+		Location: "https://charts.io/charts",
+		Verify:   true,
+		KeyringSecret: &ChartSourceKeyringSecret{
+			Name:      "charts-keyring",
+			Namespace: "loftsman",
+			Key:       "pubring.gpg",
+		},
+	}, "")
+	kubernetes := custommocks.GetKubernetesMock(false)
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(kubernetes, helm)
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestChartSourceVerify(): %s", errsToString(errs))
+	}
+	keyringPath := filepath.Join(manifest.tempDirectory, "keyrings", "remote.gpg")
+	if keyring, err := ioutil.ReadFile(keyringPath); err != nil || string(keyring) != custommocks.TestSecretKeyValue {
+		t.Errorf("Didn't get expected keyring from the secret in manifest.v1beta1.TestChartSourceVerify(), got: %s, %v", keyring, err)
+	}
+	kubernetes.AssertCalled(t, "GetSecretKeyValue", "charts-keyring", "loftsman", "pubring.gpg")
+	helm.AssertCalled(t, "DownloadChart", availableChartVersions[0].Path, true, filepath.Join(manifest.tempDirectory, "charts"))
+	helm.AssertCalled(t, "VerifyChart", filepath.Join(manifest.tempDirectory, "charts", "full-chart-0.0.1.tgz"), keyringPath)
+}
+
+func TestChartSourceVerifyKeyringRelative(t *testing.T) {
+	manifest, availableChartVersions := getIntegrityTestManifest(t, &ChartSource{
+		Type:     ChartSourceTypeRepo,
+		Name:     "remote",
+		Location: "https://charts.io/charts",
+		Verify:   true,
+		Keyring:  "keys/pubring.gpg",
+	}, "")
+	manifest.directory = t.TempDir()
+	// the keyring is relative to the manifest, not wherever loftsman is run from
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(workingDirectory)
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	if errs := manifest.Release(custommocks.GetKubernetesMock(false), helm); len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestChartSourceVerifyKeyringRelative(): %s", errsToString(errs))
+	}
+	helm.AssertCalled(t, "VerifyChart", filepath.Join(manifest.tempDirectory, "charts", "full-chart-0.0.1.tgz"),
+		filepath.Join(manifest.directory, "keys", "pubring.gpg"))
+}
+
+func TestChartSourceVerifyFailed(t *testing.T) {
+	manifest, availableChartVersions := getIntegrityTestManifest(t, &ChartSource{
+		Type:     ChartSourceTypeDirectory,
+		Name:     "local",
+		Location: "/tmp",
+		Verify:   true,
+		Keyring:  filepath.Join(os.TempDir(), custommocks.TestUntrustedKeyring),
+	}, "")
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error.Error(), "Error verifying the provenance of chart full-chart v0.0.1") {
+		t.Errorf("Didn't get expected verify error from manifest.v1beta1.TestChartSourceVerifyFailed(), got: %s", errsToString(errs))
+	}
+	helm.AssertNotCalled(t, "Upgrade", mock.Anything)
+}
+
+func TestChartSourceVerifyDownloadFailed(t *testing.T) {
+	manifest, availableChartVersions := getIntegrityTestManifest(t, &ChartSource{
+		Type:     ChartSourceTypeRepo,
+		Name:     "remote",
+		Location: "https://charts.io/charts",
+		Verify:   true,
+		Keyring:  "/etc/loftsman/pubring.gpg",
+	}, "")
+	availableChartVersions[0].Path = "https://charts.io/charts/not-found-0.0.1.tgz"
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error.Error(), "Error downloading chart full-chart v0.0.1 from its chart repo: GET https://charts.io/charts/not-found-0.0.1.tgz returned 404 Not Found") {
+		t.Errorf("Didn't get expected download error from manifest.v1beta1.TestChartSourceVerifyDownloadFailed(), got: %s", errsToString(errs))
+	}
+	helm.AssertNotCalled(t, "Upgrade", mock.Anything)
+}
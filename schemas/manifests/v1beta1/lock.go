@@ -0,0 +1,70 @@
+package v1beta1
+
+import (
+	"fmt"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+)
+
+// Lock will resolve each enabled chart in the manifest the same way Release does, returning the exact version and
+// digest each chart's version resolved to. Charts that aren't enabled are never shipped, so they're left out of the lock
+func (m *Manifest) Lock(kubernetes interfaces.Kubernetes, helm interfaces.Helm) (*interfaces.ManifestLock, []*interfaces.ManifestReleaseError) {
+	lock := &interfaces.ManifestLock{Manifest: m.GetName(), Charts: []*interfaces.ManifestLockedChart{}}
+	var releaseErrors []*interfaces.ManifestReleaseError
+
+	m.validateChartsDirectories(helm)
+
+	for _, chart := range m.Spec.Charts {
+		if chart.disabled {
+			continue
+		}
+		target, err := m.resolveChart(chart, kubernetes, helm)
+		if err != nil {
+			releaseErrors = append(releaseErrors, &interfaces.ManifestReleaseError{
+				Chart:     chart.Name,
+				Version:   chart.Version,
+				Namespace: chart.Namespace,
+				Error:     err,
+			})
+			continue
+		}
+		lock.Charts = append(lock.Charts, &interfaces.ManifestLockedChart{
+			Chart:       chart.Name,
+			ReleaseName: target.releaseName,
+			Namespace:   chart.Namespace,
+			Constraint:  chart.getVersionConstraint(),
+			Version:     chart.Version,
+			Digest:      target.digest,
+		})
+	}
+	return lock, releaseErrors
+}
+
+// ApplyLock will set each enabled chart in the manifest to the exact version in a manifest lock, and pin it to the
+// digest there. The lock must have been made from the manifest as it is now, with the same chart and version for each
+// release, and the same charts enabled. Charts that aren't enabled are left out of the lock, so they're skipped here too
+func (m *Manifest) ApplyLock(lock *interfaces.ManifestLock) error {
+	for _, chart := range m.Spec.Charts {
+		if chart.disabled {
+			continue
+		}
+		var lockedChart *interfaces.ManifestLockedChart
+		for _, candidate := range lock.Charts {
+			if candidate.ReleaseName == chart.getReleaseName() && candidate.Namespace == chart.Namespace {
+				lockedChart = candidate
+				break
+			}
+		}
+		if lockedChart == nil {
+			return fmt.Errorf("release %s of chart %s in namespace %s isn't in the manifest lock", chart.getReleaseName(), chart.Name, chart.Namespace)
+		}
+		if lockedChart.Chart != chart.Name || lockedChart.Constraint != chart.getVersionConstraint() {
+			return fmt.Errorf("release %s in namespace %s is chart %s %s in the manifest lock, but chart %s %s in the manifest",
+				chart.getReleaseName(), chart.Namespace, lockedChart.Chart, lockedChart.Constraint, chart.Name, chart.getVersionConstraint())
+		}
+		chart.versionConstraint = chart.getVersionConstraint()
+		chart.Version = lockedChart.Version
+		chart.lockedDigest = lockedChart.Digest
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package v1beta1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	custommocks "github.com/Cray-HPE/loftsman/mocks/custom-mocks"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testLockDigest      = "sha256:fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9"
+	testLockOtherDigest = "sha256:baa5a0964d3320fbc0c6a922140453c8513ea24ab8fd0577034804a967248096"
+)
+
+func getLockTestManifest() *Manifest {
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{
+			Name:      "test-chart",
+			Namespace: "default",
+			Version:   "~1.4",
+		},
+		&Chart{
+			Name:        "test-chart",
+			ReleaseName: "test-chart-pinned",
+			Namespace:   "services",
+			Version:     "1.4.0",
+		},
+	}
+	return manifest
+}
+
+func getLockTestAvailableChartVersions(digest string) []*interfaces.HelmAvailableChartVersion {
+	available := getTestAvailableChartVersions("1.4.0", "1.4.7", "1.5.0")
+	for _, availableVersion := range available {
+		availableVersion.Digest = digest
+	}
+	return available
+}
+
+func TestLock(t *testing.T) {
+	manifest := getLockTestManifest()
+	lock, errs := manifest.Lock(custommocks.GetKubernetesMock(false),
+		custommocks.GetHelmMock(getLockTestAvailableChartVersions(testLockDigest)))
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestLock(): %s", errsToString(errs))
+		return
+	}
+	if lock.Manifest != "test-manifest" || len(lock.Charts) != 2 {
+		t.Errorf("Didn't get expected lock from manifest.v1beta1.TestLock(), got: %v", lock)
+		return
+	}
+	expected := []*interfaces.ManifestLockedChart{
+		&interfaces.ManifestLockedChart{Chart: "test-chart", ReleaseName: "test-chart", Namespace: "default",
+			Constraint: "~1.4", Version: "1.4.7", Digest: testLockDigest},
+		&interfaces.ManifestLockedChart{Chart: "test-chart", ReleaseName: "test-chart-pinned", Namespace: "services",
+			Constraint: "1.4.0", Version: "1.4.0", Digest: testLockDigest},
+	}
+	for i, lockedChart := range lock.Charts {
+		if *lockedChart != *expected[i] {
+			t.Errorf("Didn't get expected locked chart from manifest.v1beta1.TestLock(), expected %v, got: %v", expected[i], lockedChart)
+		}
+	}
+}
+
+func TestLockChartDoesntExist(t *testing.T) {
+	manifest := getLockTestManifest()
+	manifest.Spec.Charts[0].Version = "~2.0"
+	lock, errs := manifest.Lock(custommocks.GetKubernetesMock(false),
+		custommocks.GetHelmMock(getLockTestAvailableChartVersions(testLockDigest)))
+	if len(errs) != 1 || len(lock.Charts) != 1 {
+		t.Errorf("Didn't get expected error from manifest.v1beta1.TestLockChartDoesntExist(), got: %s", errsToString(errs))
+	}
+}
+
+func TestLockDisabledCharts(t *testing.T) {
+	manifest := getLockTestManifest()
+	manifest.Spec.Charts = append(manifest.Spec.Charts, &Chart{Name: "lab-chart", Namespace: "default", Version: "~9.0", Enabled: "false"})
+	if err := manifest.ValidateSpec(); err != nil {
+		t.Errorf("Got unexpected error from manifest.v1beta1.TestLockDisabledCharts(): %s", err)
+		return
+	}
+	// the disabled chart isn't resolved, so its version not existing isn't an error
+	lock, errs := manifest.Lock(custommocks.GetKubernetesMock(false),
+		custommocks.GetHelmMock(getLockTestAvailableChartVersions(testLockDigest)))
+	if len(errs) != 0 || len(lock.Charts) != 2 {
+		t.Errorf("Didn't get expected lock without the disabled chart from manifest.v1beta1.TestLockDisabledCharts(), got %d charts, errors: %s",
+			len(lock.Charts), errsToString(errs))
+		return
+	}
+	if err := manifest.ApplyLock(lock); err != nil {
+		t.Errorf("Got unexpected error applying the lock from manifest.v1beta1.TestLockDisabledCharts(): %s", err)
+	}
+
+	enabled := getLockTestManifest()
+	enabled.Spec.Charts = append(enabled.Spec.Charts, &Chart{Name: "lab-chart", Namespace: "default", Version: "~9.0", Enabled: "true"})
+	if err := enabled.ValidateSpec(); err != nil {
+		t.Errorf("Got unexpected error from manifest.v1beta1.TestLockDisabledCharts(): %s", err)
+		return
+	}
+	if err := enabled.ApplyLock(lock); err == nil || !strings.Contains(err.Error(), "release lab-chart of chart lab-chart in namespace default isn't in the manifest lock") {
+		t.Errorf("Didn't get expected error applying the lock to an enabled chart from manifest.v1beta1.TestLockDisabledCharts(), got: %v", err)
+	}
+}
+
+func TestApplyLock(t *testing.T) {
+	lock, errs := getLockTestManifest().Lock(custommocks.GetKubernetesMock(false),
+		custommocks.GetHelmMock(getLockTestAvailableChartVersions(testLockDigest)))
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestApplyLock(): %s", errsToString(errs))
+		return
+	}
+	manifest := getLockTestManifest()
+	if err := manifest.ApplyLock(lock); err != nil {
+		t.Errorf("Got unexpected error from manifest.v1beta1.TestApplyLock(): %s", err)
+		return
+	}
+	// 1.5.0 was published after the lock, but ~1.4 stays at the locked version
+	helm := custommocks.GetHelmMock(getLockTestAvailableChartVersions(testLockDigest))
+	if errs = manifest.Release(custommocks.GetKubernetesMock(false), helm); len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestApplyLock(): %s", errsToString(errs))
+	}
+	helm.AssertCalled(t, "Upgrade", mock.MatchedBy(func(options *interfaces.HelmReleaseOptions) bool {
+		return options.ReleaseName == "test-chart" && options.ChartPath == "/tmp/test-chart-1.4.7.tgz"
+	}))
+}
+
+func TestApplyLockDigestMismatch(t *testing.T) {
+	lock, _ := getLockTestManifest().Lock(custommocks.GetKubernetesMock(false),
+		custommocks.GetHelmMock(getLockTestAvailableChartVersions(testLockDigest)))
+	manifest := getLockTestManifest()
+	if err := manifest.ApplyLock(lock); err != nil {
+		t.Errorf("Got unexpected error from manifest.v1beta1.TestApplyLockDigestMismatch(): %s", err)
+		return
+	}
+	errs := manifest.Release(custommocks.GetKubernetesMock(false),
+		custommocks.GetHelmMock(getLockTestAvailableChartVersions(testLockOtherDigest)))
+	if len(errs) != 2 || !strings.Contains(errs[0].Error.Error(), "not the digest") {
+		t.Errorf("Didn't get expected digest errors from manifest.v1beta1.TestApplyLockDigestMismatch(), got: %s", errsToString(errs))
+	}
+}
+
+func TestApplyLockOutOfDate(t *testing.T) {
+	lock, _ := getLockTestManifest().Lock(custommocks.GetKubernetesMock(false),
+		custommocks.GetHelmMock(getLockTestAvailableChartVersions(testLockDigest)))
+
+	changedVersion := getLockTestManifest()
+	changedVersion.Spec.Charts[0].Version = "~1.5"
+	if err := changedVersion.ApplyLock(lock); err == nil || !strings.Contains(err.Error(), "in the manifest lock, but chart") {
+		t.Errorf("Didn't get expected error for a changed version from manifest.v1beta1.TestApplyLockOutOfDate(), got: %v", err)
+	}
+
+	addedChart := getLockTestManifest()
+	addedChart.Spec.Charts = append(addedChart.Spec.Charts, &Chart{Name: "other-chart", Namespace: "default", Version: "0.0.1"})
+	if err := addedChart.ApplyLock(lock); err == nil || !strings.Contains(err.Error(), "isn't in the manifest lock") {
+		t.Errorf("Didn't get expected error for an added chart from manifest.v1beta1.TestApplyLockOutOfDate(), got: %v", err)
+	}
+}
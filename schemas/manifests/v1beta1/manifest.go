@@ -2,10 +2,15 @@ package v1beta1
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/Cray-HPE/loftsman/internal/interfaces"
 	"github.com/Cray-HPE/loftsman/internal/logger"
@@ -30,11 +35,121 @@ func (m *Manifest) SetTempDirectory(tempDirectory string) {
 	m.tempDirectory = tempDirectory
 }
 
+// SetDirectory will set the directory of the manifest file, that the paths of values files are relative to
+func (m *Manifest) SetDirectory(directory string) {
+	m.directory = directory
+}
+
+// SetGit will set the git object used to check out charts from git chart sources
+func (m *Manifest) SetGit(git interfaces.Git) {
+	m.git = git
+}
+
+// SetSops will set the sops object used to decrypt chart values encrypted with sops
+func (m *Manifest) SetSops(sops interfaces.Sops) {
+	m.sops = sops
+}
+
+// SetReleaseOptions sets the options used when releasing the manifest
+func (m *Manifest) SetReleaseOptions(releaseOptions *interfaces.ManifestReleaseOptions) {
+	m.releaseOptions = releaseOptions
+}
+
+// getReleaseOptions returns the options to use when releasing the manifest, or the defaults if none have been set
+func (m *Manifest) getReleaseOptions() *interfaces.ManifestReleaseOptions {
+	if m.releaseOptions == nil {
+		return &interfaces.ManifestReleaseOptions{
+			MaxConcurrency: 1,
+		}
+	}
+	return m.releaseOptions
+}
+
 // GetName will return the unique name for this manifest
 func (m *Manifest) GetName() string {
 	return m.Metadata.Name
 }
 
+// GetReleases will return the Helm releases of all of the charts in the manifest. Charts that aren't enabled are
+// included, their releases are left as they are in the cluster and are still owned by the manifest, so never pruned
+func (m *Manifest) GetReleases() []*interfaces.ManifestRelease {
+	releases := []*interfaces.ManifestRelease{}
+	for _, chart := range m.Spec.Charts {
+		releases = append(releases, &interfaces.ManifestRelease{
+			ReleaseName: chart.getReleaseName(),
+			Namespace:   chart.Namespace,
+		})
+	}
+	return releases
+}
+
+// SelectCharts will select some of the enabled charts in the manifest to release and plan, rather than all of them,
+// returning the names of the charts selected. The names to include or skip must be of charts in the manifest
+func (m *Manifest) SelectCharts(selection *interfaces.ManifestChartSelection) ([]string, error) {
+	for _, name := range append(append([]string{}, selection.Only...), selection.Skip...) {
+		if !m.hasChart(name) {
+			return nil, fmt.Errorf("chart %s isn't in spec.charts", name)
+		}
+	}
+	selected := []string{}
+	for _, chart := range m.Spec.Charts {
+		chart.unselected = chart.disabled || !chart.isSelected(selection)
+		if !chart.unselected && !contains(selected, chart.Name) {
+			selected = append(selected, chart.Name)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("none of the enabled charts in spec.charts are selected")
+	}
+	return selected, nil
+}
+
+// contains returns whether a list of strings has a string
+func contains(list []string, item string) bool {
+	for _, listItem := range list {
+		if listItem == item {
+			return true
+		}
+	}
+	return false
+}
+
+// hasChart returns whether the manifest has a chart with a name
+func (m *Manifest) hasChart(name string) bool {
+	for _, chart := range m.Spec.Charts {
+		if chart.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isSelected returns whether a chart is in a selection of charts, by its name and labels
+func (c *Chart) isSelected(selection *interfaces.ManifestChartSelection) bool {
+	if len(selection.Only) > 0 && !contains(selection.Only, c.Name) {
+		return false
+	}
+	if contains(selection.Skip, c.Name) {
+		return false
+	}
+	for key, value := range selection.Selector {
+		if labelValue, ok := c.Labels[key]; !ok || labelValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// isShipped returns whether a chart is released, or planned, it's enabled and in the selected charts if some are
+func (c *Chart) isShipped() bool {
+	return !c.disabled && !c.unselected
+}
+
+// GetPrune will return whether the manifest asks for releases no longer in it to be uninstalled
+func (m *Manifest) GetPrune() bool {
+	return m.Spec.Prune
+}
+
 // Load will load manifest string/file/byte content into a v1beta1.Manifest object
 func (m *Manifest) Load(manifestContent string) error {
 	if err := yaml.Unmarshal([]byte(manifestContent), &m); err != nil {
@@ -43,6 +158,69 @@ func (m *Manifest) Load(manifestContent string) error {
 	return nil
 }
 
+// ValidateSpec will validate the parts of the manifest spec that can't be validated by the schema
+func (m *Manifest) ValidateSpec() error {
+	if m.Spec.Sources != nil {
+		for _, chartSource := range m.Spec.Sources.Charts {
+			if chartSource.Type == ChartSourceTypeOCI && !strings.HasPrefix(chartSource.Location, "oci://") {
+				return fmt.Errorf("invalid spec.sources.charts[] name = %s: the location of a source of type %s must be oci://<registry>/<path>",
+					chartSource.Name, ChartSourceTypeOCI)
+			}
+			if chartSource.Type == ChartSourceTypeGit && chartSource.Ref == "" {
+				return fmt.Errorf("invalid spec.sources.charts[] name = %s: a source of type %s must have a ref to check out",
+					chartSource.Name, ChartSourceTypeGit)
+			}
+			if chartSource.Verify && chartSource.Type != ChartSourceTypeDirectory && chartSource.Type != ChartSourceTypeRepo {
+				return fmt.Errorf("invalid spec.sources.charts[] name = %s: verify is only supported for sources of type %s or %s",
+					chartSource.Name, ChartSourceTypeDirectory, ChartSourceTypeRepo)
+			}
+			if chartSource.Verify && (chartSource.Keyring == "") == (chartSource.KeyringSecret == nil) {
+				return fmt.Errorf("invalid spec.sources.charts[] name = %s: a source with verify must have either a keyring or a keyringSecret",
+					chartSource.Name)
+			}
+		}
+	}
+	for _, chart := range m.Spec.Charts {
+		enabled, err := chart.isEnabled()
+		if err != nil {
+			return fmt.Errorf("invalid spec.charts[] name = %s: %s", chart.Name, err)
+		}
+		chart.disabled = !enabled
+		if err = chart.validateVerify(); err != nil {
+			return fmt.Errorf("invalid spec.charts[] name = %s: %s", chart.Name, err)
+		}
+		for _, valuesFile := range chart.ValuesFiles {
+			if _, err := m.readValuesFile(valuesFile); err != nil {
+				return fmt.Errorf("invalid spec.charts[] name = %s: %s", chart.Name, err)
+			}
+		}
+		if chart.EncryptedValues != "" {
+			if values, err := parseValues([]byte(chart.EncryptedValues)); err != nil || !isEncrypted(values) {
+				return fmt.Errorf("invalid spec.charts[] name = %s: encryptedValues must be a yaml document encrypted with sops", chart.Name)
+			}
+		}
+		for _, valuesFrom := range chart.ValuesFrom {
+			if (valuesFrom.ConfigMapKeyRef == nil) == (valuesFrom.SecretKeyRef == nil) {
+				return fmt.Errorf("invalid spec.charts[] name = %s: each valuesFrom must have either a configMapKeyRef or a secretKeyRef",
+					chart.Name)
+			}
+		}
+		if chart.Digest == "" || m.Spec.Sources == nil {
+			continue
+		}
+		for _, chartSource := range m.Spec.Sources.Charts {
+			if chartSource.Name == chart.Source && chartSource.Type == ChartSourceTypeGit {
+				return fmt.Errorf("invalid spec.charts[] name = %s: digests aren't supported for charts from a source of type %s, "+
+					"which are packaged again by each ship", chart.Name, ChartSourceTypeGit)
+			}
+		}
+	}
+	if _, err := newChartGraph(m.Spec.Charts); err != nil {
+		return fmt.Errorf("invalid spec.charts[].dependsOn: %s", err)
+	}
+	return nil
+}
+
 // Create will make a baseline manifest for this version
 func (m *Manifest) Create(initializeCharts []string) (string, error) {
 	var charts []*Chart
@@ -72,179 +250,717 @@ func (m *Manifest) Create(initializeCharts []string) (string, error) {
 	return string(manifestContent), nil
 }
 
-// Release will run a full release/install/upgrade of all charts in the manifest
-func (m *Manifest) Release(kubernetes interfaces.Kubernetes, helm interfaces.Helm) []*interfaces.ManifestReleaseError {
+// releaseTarget is a chart with its source, packaged chart location and values resolved for a Helm install/upgrade
+type releaseTarget struct {
+	releaseName  string
+	chartPath    string
+	version      string // only set when the chart path is from an added repo, which needs the version to find the chart
+	digest       string // the sha256:<hex> digest of the chart, if its source has one
+	downloaded   bool   // the chart was downloaded from its chart repo to the temp directory, to be checked before it's released
+	timeout      string
+	values       []byte
+	chartsSource *interfaces.HelmChartsSource
+}
+
+// needsAddedRepo returns whether the chart is from a credentialed chart repo, which needs to be added to Helm to be
+// able to release its charts
+func (t *releaseTarget) needsAddedRepo() bool {
+	return t.chartsSource.Repo != "" && t.chartsSource.RepoUsername != "" && !t.downloaded
+}
+
+// getReleaseName returns the Helm release name for a chart, by default the chart name itself
+func (c *Chart) getReleaseName() string {
+	if c.ReleaseName != "" {
+		return c.ReleaseName
+	}
+	return c.Name
+}
+
+// getValues returns the chart's values as yaml, the merged values from its valuesFiles and valuesFrom once they've
+// been resolved, otherwise just its inline values
+func (c *Chart) getValues() ([]byte, error) {
+	if c.valuesResolved {
+		return c.resolvedValues, nil
+	}
+	if c.Values == nil {
+		return nil, nil
+	}
+	return yaml.Marshal(c.Values)
+}
+
+// getValuesHash returns a hash of the chart's manifest values, used to tell if a chart's values have changed since a
+// previous release of it
+func (c *Chart) getValuesHash() string {
+	values, err := c.getValues()
+	if err != nil || values == nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(values))
+}
+
+// isFailedFirstRelease determines if a release status is of a failed first install of a chart
+func isFailedFirstRelease(releaseStatus *interfaces.HelmReleaseStatus) bool {
+	return releaseStatus != nil && releaseStatus.Info != nil && releaseStatus.Info.Status == "failed" && releaseStatus.Revision == 1
+}
+
+// resolveChart will determine the chart source, packaged chart location, and values for a chart in the manifest,
+// this is shared by both Release and Plan so that a plan resolves everything exactly as a release would
+func (m *Manifest) resolveChart(chart *Chart, kubernetes interfaces.Kubernetes, helm interfaces.Helm) (*releaseTarget, error) {
 	var err error
+	fromGit := false
+	var verifySource *ChartSource
+	target := &releaseTarget{
+		releaseName:  chart.getReleaseName(),
+		chartsSource: &interfaces.HelmChartsSource{},
+	}
+
+	// TODO: when we're able to deprecate --charts-* CLI args, we can move to some slightly cleaner patterns here. In order to continue to
+	//       support both manifest-defined chart sources and the CLI ones, and doing as little as possible around it for now, this is deemed the
+	//       best path
+	if m.Spec.All != nil && m.Spec.All.Timeout != "" && chart.Timeout == "" {
+		chart.Timeout = m.Spec.All.Timeout
+	}
+	if m.Spec.Sources != nil && len(m.Spec.Sources.Charts) > 0 {
+		foundSource := false
+		for _, chartSource := range m.Spec.Sources.Charts {
+			if chartSource.Name == chart.Source {
+				foundSource = true
+				switch chartSource.Type {
+				case ChartSourceTypeRepo:
+					target.chartsSource.RepoName = chartSource.Name
+					target.chartsSource.Repo = chartSource.Location
+				case ChartSourceTypeOCI:
+					target.chartsSource.OCI = chartSource.Location
+				case ChartSourceTypeDirectory:
+					target.chartsSource.Path = chartSource.Location
+				case ChartSourceTypeGit:
+					if target.chartsSource.Path, err = m.packageGitChartSource(chartSource, helm); err != nil {
+						return nil, err
+					}
+					fromGit = true
+				}
+				if chartSource.CredentialsSecret != nil && (chartSource.Type == ChartSourceTypeRepo || chartSource.Type == ChartSourceTypeOCI) {
+					target.chartsSource.RepoUsername, err = kubernetes.GetSecretKeyValue(chartSource.CredentialsSecret.Name, chartSource.CredentialsSecret.Namespace,
+						chartSource.CredentialsSecret.UsernameKey)
+					if err != nil {
+						return nil, fmt.Errorf("Error getting chart source username from secret %s for spec.sources.charts[] name = %s: %s",
+							chartSource.CredentialsSecret.Name, chartSource.Name, err)
+					}
+					target.chartsSource.RepoPassword, err = kubernetes.GetSecretKeyValue(chartSource.CredentialsSecret.Name, chartSource.CredentialsSecret.Namespace,
+						chartSource.CredentialsSecret.PasswordKey)
+					if err != nil {
+						return nil, fmt.Errorf("Error getting chart source password from secret %s for spec.sources.charts[] name = %s: %s",
+							chartSource.CredentialsSecret.Name, chartSource.Name, err)
+					}
+				}
+				if chartSource.Verify {
+					verifySource = chartSource
+				}
+				if helm, err = helm.ForChartsSource(target.chartsSource); err != nil {
+					return nil, fmt.Errorf("Error initializing Helm for specific source %s for chart %s: %s", chart.Source, chart.Name, err)
+				}
+				break
+			}
+		}
+		if !foundSource {
+			return nil, fmt.Errorf("Source name not found in spec.sources.charts[]: %s", chart.Source)
+		}
+	}
+
+	availableVersions, err := helm.GetAvailableChartVersions(chart.Name)
+	if err != nil {
+		return nil, fmt.Errorf("Error determining available versions for the chart %s: %s", chart.Name, err)
+	}
+	availableVersion, err := resolveChartVersion(chart, availableVersions)
+	if err != nil {
+		return nil, err
+	}
+	if availableVersion.Version != chart.Version {
+		m.logForChart(chart, zerolog.InfoLevel, fmt.Sprintf("Resolved version %s of chart %s to v%s", chart.Version, chart.Name, availableVersion.Version))
+		chart.versionConstraint = chart.Version
+		chart.Version = availableVersion.Version
+	}
+	target.chartPath = availableVersion.Path
+	if !fromGit {
+		// charts packaged from git are packaged again by each run, so there's no digest they'll always have
+		target.digest = availableVersion.Digest
+	}
+	if chart.lockedDigest != "" && target.digest != "" && target.digest != chart.lockedDigest {
+		return nil, fmt.Errorf("Chart %s v%s has digest %s, not the digest %s in the manifest lock", chart.Name, chart.Version, target.digest,
+			chart.lockedDigest)
+	}
+
+	if target.chartsSource.OCI == "" && (chart.Digest != "" || verifySource != nil) {
+		if err = m.checkChartIntegrity(chart, target, verifySource, kubernetes, helm); err != nil {
+			return nil, err
+		}
+	}
+	if target.chartsSource.OCI != "" {
+		// charts from an OCI registry are pulled to the temp directory to install/upgrade from there
+		pinnedDigest := chart.Digest
+		if pinnedDigest == "" {
+			pinnedDigest = chart.lockedDigest
+		}
+		target.chartPath, target.digest, err = helm.PullChart(target.chartPath, pinnedDigest, filepath.Join(m.tempDirectory, "charts"))
+		if err != nil {
+			return nil, fmt.Errorf("Error pulling chart %s v%s from OCI registry %s: %s", chart.Name, chart.Version, target.chartsSource.OCI, err)
+		}
+	}
+
+	target.timeout = chart.Timeout
+	if target.needsAddedRepo() {
+		if target.chartsSource.RepoName == "" {
+			target.chartsSource.RepoName = fmt.Sprintf("%x", md5.Sum([]byte(target.chartsSource.Repo)))
+		}
+		target.chartPath = fmt.Sprintf("%s/%s", target.chartsSource.RepoName, chart.Name)
+		target.version = chart.Version
+	}
+
+	if target.values, err = m.resolveValues(chart, kubernetes); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// packageGitChartSource will check out the ref of a git chart source to the temp directory, and package the chart at
+// its path there with its dependencies, returning the directory with the packaged chart. This is only done once per
+// source, so that every chart from the source is released from the same checkout
+func (m *Manifest) packageGitChartSource(chartSource *ChartSource, helm interfaces.Helm) (string, error) {
+	packagedDirectory := filepath.Join(m.tempDirectory, "git-charts", chartSource.Name)
+	if _, err := os.Stat(packagedDirectory); err == nil {
+		return packagedDirectory, nil
+	}
+	if m.git == nil {
+		return "", fmt.Errorf("Unable to check out spec.sources.charts[] name = %s, git isn't set up", chartSource.Name)
+	}
+	checkoutDirectory := filepath.Join(m.tempDirectory, "git", chartSource.Name)
+	if err := os.RemoveAll(checkoutDirectory); err != nil {
+		return "", err
+	}
+	if err := m.git.Checkout(chartSource.Location, chartSource.Ref, checkoutDirectory); err != nil {
+		return "", fmt.Errorf("Error checking out spec.sources.charts[] name = %s: %s", chartSource.Name, err)
+	}
+	chartDirectory := filepath.Join(checkoutDirectory, chartSource.Path)
+	if err := os.MkdirAll(packagedDirectory, 0755); err != nil {
+		return "", err
+	}
+	if _, err := helm.PackageChart(chartDirectory, packagedDirectory); err != nil {
+		// don't leave an empty packaged directory behind to be mistaken for a successful packaging by the next chart
+		_ = os.RemoveAll(packagedDirectory)
+		return "", fmt.Errorf("Error packaging the chart at path %s of ref %s of spec.sources.charts[] name = %s: %s", chartSource.Path,
+			chartSource.Ref, chartSource.Name, err)
+	}
+	return packagedDirectory, nil
+}
+
+// validateChartsDirectories will log warnings about the packaged charts in the manifest's directory sources, like
+// packages that duplicate or conflict with another package of the same chart version
+func (m *Manifest) validateChartsDirectories(helm interfaces.Helm) {
+	if m.Spec.Sources == nil {
+		return
+	}
+	for _, chartSource := range m.Spec.Sources.Charts {
+		if chartSource.Type != ChartSourceTypeDirectory {
+			continue
+		}
+		warnings, err := helm.ValidateChartsDirectory(chartSource.Location)
+		if err != nil {
+			// the charts from the source will fail to resolve with this error, no need to report it twice
+			continue
+		}
+		for _, warning := range warnings {
+			m.logger.Warn().Msgf("spec.sources.charts[] name = %s: %s", chartSource.Name, warning)
+		}
+	}
+}
+
+// addChartsRepo will add a credentialed chart repo to Helm if it hasn't already been added, returning the updated list
+// of added repos that should be removed once we're done with them
+func addChartsRepo(helm interfaces.Helm, chartsSource *interfaces.HelmChartsSource, addedRepos []string) ([]string, error) {
+	for _, addedRepo := range addedRepos {
+		if chartsSource.RepoName == addedRepo {
+			return addedRepos, nil
+		}
+	}
+	if err := helm.AddRepo(chartsSource); err != nil {
+		return addedRepos, fmt.Errorf("Error adding secure chart repo %s: %s", chartsSource.Repo, err)
+	}
+	return append(addedRepos, chartsSource.RepoName), nil
+}
+
+// getHelmReleaseOptions will build the options to release, or render, a resolved chart with, writing its values to a
+// file to pass to Helm if there are any. The values can be decrypted secrets, so the returned func must be called to
+// remove the file as soon as Helm is done with it
+func (m *Manifest) getHelmReleaseOptions(chart *Chart, target *releaseTarget) (*interfaces.HelmReleaseOptions, func(), error) {
+	helmReleaseOptions := &interfaces.HelmReleaseOptions{
+		ReleaseName: target.releaseName,
+		Namespace:   chart.Namespace,
+		ChartPath:   target.chartPath,
+		Version:     target.version,
+		SetValues: []string{
+			fmt.Sprintf("global.chart.name=%s", chart.Name),
+			fmt.Sprintf("global.chart.version=%s", chart.Version),
+		},
+		Timeout: target.timeout,
+	}
+	m.setHelmOptions(chart, helmReleaseOptions)
+	if target.values == nil {
+		return helmReleaseOptions, func() {}, nil
+	}
+	valuesFilePath, err := m.writeValuesFile(chart, target)
+	if err != nil {
+		return nil, nil, err
+	}
+	helmReleaseOptions.ValuesFiles = append(helmReleaseOptions.ValuesFiles, valuesFilePath)
+	return helmReleaseOptions, func() { os.Remove(valuesFilePath) }, nil
+}
+
+// setHelmOptions will set the Helm options of a chart on the options to release it with, from the chart itself or else
+// spec.all
+func (m *Manifest) setHelmOptions(chart *Chart, helmReleaseOptions *interfaces.HelmReleaseOptions) {
+	helmOptions := []*ChartHelm{chart.Helm}
+	if m.Spec.All != nil {
+		helmOptions = append(helmOptions, m.Spec.All.Helm)
+	}
+	// first returns the first of the chart's then spec.all's Helm options to have an option set, if any
+	first := func(isSet func(h *ChartHelm) bool) *ChartHelm {
+		for _, h := range helmOptions {
+			if h != nil && isSet(h) {
+				return h
+			}
+		}
+		return nil
+	}
+	getBool := func(option func(h *ChartHelm) *bool, defaultValue bool) bool {
+		if h := first(func(h *ChartHelm) bool { return option(h) != nil }); h != nil {
+			return *option(h)
+		}
+		return defaultValue
+	}
+	helmReleaseOptions.Wait = getBool(func(h *ChartHelm) *bool { return h.Wait }, false)
+	helmReleaseOptions.WaitForJobs = getBool(func(h *ChartHelm) *bool { return h.WaitForJobs }, false)
+	helmReleaseOptions.Atomic = getBool(func(h *ChartHelm) *bool { return h.Atomic }, false)
+	helmReleaseOptions.Force = getBool(func(h *ChartHelm) *bool { return h.Force }, false)
+	helmReleaseOptions.DisableHooks = getBool(func(h *ChartHelm) *bool { return h.DisableHooks }, false)
+	helmReleaseOptions.SkipCRDs = getBool(func(h *ChartHelm) *bool { return h.SkipCRDs }, false)
+	helmReleaseOptions.CleanupOnFail = getBool(func(h *ChartHelm) *bool { return h.CleanupOnFail }, false)
+	helmReleaseOptions.CreateNamespace = getBool(func(h *ChartHelm) *bool { return h.CreateNamespace }, true)
+	if h := first(func(h *ChartHelm) bool { return h.MaxHistory != nil }); h != nil {
+		helmReleaseOptions.MaxHistory = *h.MaxHistory
+	}
+	if h := first(func(h *ChartHelm) bool { return h.PostRenderer != "" }); h != nil {
+		helmReleaseOptions.PostRenderer = m.getPostRendererPath(h.PostRenderer)
+	}
+	if h := first(func(h *ChartHelm) bool { return h.Description != "" }); h != nil {
+		helmReleaseOptions.Description = h.Description
+	}
+	if h := first(func(h *ChartHelm) bool { return h.ExtraArgs != nil }); h != nil {
+		helmReleaseOptions.ExtraArgs = h.ExtraArgs
+	}
+}
+
+// getReleaseOptionsHash will return a hash of what a chart is released with besides its chart and values: its Helm
+// options, from the chart or spec.all, and its timeout. Release records it with the result of the chart, so that Plan
+// can tell when the options of a release have changed
+func (m *Manifest) getReleaseOptionsHash(chart *Chart, target *releaseTarget) string {
+	helmReleaseOptions := &interfaces.HelmReleaseOptions{Timeout: target.timeout}
+	m.setHelmOptions(chart, helmReleaseOptions)
+	// the post renderer path is relative to the manifest, wherever it's shipped from
+	if helmReleaseOptions.PostRenderer != "" {
+		if postRenderer, err := filepath.Rel(m.directory, helmReleaseOptions.PostRenderer); err == nil && !strings.HasPrefix(postRenderer, "..") {
+			helmReleaseOptions.PostRenderer = postRenderer
+		}
+	}
+	optionsBytes, _ := json.Marshal(helmReleaseOptions)
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(optionsBytes))
+}
+
+// getPostRendererPath returns the path of a post-renderer, a path with a / being relative to the manifest, otherwise
+// it's left for Helm to find on the PATH
+func (m *Manifest) getPostRendererPath(postRenderer string) string {
+	if filepath.IsAbs(postRenderer) || !strings.Contains(postRenderer, "/") {
+		return postRenderer
+	}
+	return filepath.Join(m.directory, postRenderer)
+}
+
+// writeValuesFile will write the resolved values for a chart to a file in the temp directory to pass to Helm
+func (m *Manifest) writeValuesFile(chart *Chart, target *releaseTarget) (string, error) {
+	valuesFileName := fmt.Sprintf("%s-values.yaml", chart.Name)
+	if target.releaseName != chart.Name {
+		// the same chart can be released more than once under different release names, possibly at the same time
+		valuesFileName = fmt.Sprintf("%s-%s-values.yaml", chart.Name, target.releaseName)
+	}
+	valuesFilePath := filepath.Join(m.tempDirectory, valuesFileName)
+	if err := ioutil.WriteFile(valuesFilePath, target.values, 0600); err != nil {
+		return "", fmt.Errorf("Error writing Helm values for for chart %s: %s", chart.Name, err)
+	}
+	return valuesFilePath, nil
+}
+
+// logForChart will log a message with the chart fields, so that logs stay attributable to a chart even when charts
+// are released in parallel
+func (m *Manifest) logForChart(chart *Chart, level zerolog.Level, msg string) {
+	if strings.TrimSpace(msg) == "" {
+		return
+	}
+	m.logger.WithLevel(level).
+		Str("chart", chart.Name).
+		Str("version", chart.Version).
+		Str("namespace", chart.Namespace).
+		Msg(msg)
+}
+
+// getResult returns the outcome of releasing the chart with a status
+func (c *Chart) getResult(status string) *interfaces.ManifestChartResult {
+	return &interfaces.ManifestChartResult{
+		Chart:       c.Name,
+		Version:     c.Version,
+		Namespace:   c.Namespace,
+		ReleaseName: c.getReleaseName(),
+		ValuesHash:  c.getValuesHash(),
+		Status:      status,
+	}
+}
+
+// recordChartResult will pass the outcome of releasing a chart to the release options chart result callback, if any
+func (m *Manifest) recordChartResult(chartResult *interfaces.ManifestChartResult) {
+	releaseOptions := m.getReleaseOptions()
+	if releaseOptions.OnChartResult == nil {
+		return
+	}
+	releaseOptions.OnChartResult(chartResult)
+}
+
+// isCancelled returns whether the release has been cancelled, in which case no further charts should be released
+func (m *Manifest) isCancelled() bool {
+	releaseOptions := m.getReleaseOptions()
+	return releaseOptions.Cancelled != nil && releaseOptions.Cancelled()
+}
+
+// getOnFailure returns the policy for what to do when a chart fails to release, from the chart itself, spec.all, or
+// otherwise the default of continuing on with the other charts
+func (m *Manifest) getOnFailure(chart *Chart) string {
+	if chart.OnFailure != "" {
+		return chart.OnFailure
+	}
+	if m.Spec.All != nil && m.Spec.All.OnFailure != "" {
+		return m.Spec.All.OnFailure
+	}
+	return ChartOnFailureContinue
+}
+
+// isResumable determines if a chart already released successfully with the same version and values in the previous
+// release being resumed, and so doesn't need to be released again
+func (m *Manifest) isResumable(chart *Chart) bool {
+	for _, previousResult := range m.getReleaseOptions().ResumeFrom {
+		if previousResult.ReleaseName != chart.getReleaseName() || previousResult.Namespace != chart.Namespace {
+			continue
+		}
+		if previousResult.Status != interfaces.ManifestChartStatusSuccess && previousResult.Status != interfaces.ManifestChartStatusSkipped {
+			return false
+		}
+		return previousResult.Chart == chart.Name && previousResult.Version == chart.Version && previousResult.ValuesHash == chart.getValuesHash()
+	}
+	return false
+}
+
+// Release will run a full release/install/upgrade of all enabled charts in the manifest, or of the selected ones if
+// some are. Charts are released in parallel, up to the release options max concurrency, once all of the charts they
+// depend on via spec.charts[].dependsOn have released, or aren't being released.
+// When resuming a previous release, charts that already succeeded there with the same version and values are skipped.
+// Once a chart is released, its spec.charts[].verify checks are run, and a chart failing them fails the same as one that
+// failed to release. When a chart fails, its spec.charts[].onFailure policy determines whether to continue, roll it
+// back, or stop the release
+func (m *Manifest) Release(kubernetes interfaces.Kubernetes, helm interfaces.Helm) []*interfaces.ManifestReleaseError {
 	var releaseErrors []*interfaces.ManifestReleaseError
+	var releaseErrorsMutex sync.Mutex
+	// resolving a chart's values and source (decrypting values, pulling or packaging charts, and adding repos) happens one
+	// chart at a time, this also guards addedRepos. Each chart's source gets its own helm object from ForChartsSource, so
+	// the shared one is never changed while the helm install/upgrade of other charts runs in parallel
+	var resolveMutex sync.Mutex
 	addedRepos := []string{}
 
-CHARTS:
-	for _, chart := range m.Spec.Charts {
-		logForChart := func(level zerolog.Level, msg string) {
-			if strings.TrimSpace(msg) == "" {
-				return
+	addReleaseError := func(chart *Chart, releaseErr error) {
+		releaseErrorsMutex.Lock()
+		releaseErrors = append(releaseErrors, &interfaces.ManifestReleaseError{
+			Chart:     chart.Name,
+			Version:   chart.Version,
+			Namespace: chart.Namespace,
+			Error:     releaseErr,
+		})
+		releaseErrorsMutex.Unlock()
+		m.logForChart(chart, zerolog.ErrorLevel, strings.TrimSpace(releaseErr.Error()))
+	}
+	recordReleaseError := func(chart *Chart, releaseErr error) {
+		addReleaseError(chart, releaseErr)
+		m.recordChartResult(chart.getResult(interfaces.ManifestChartStatusFailed))
+	}
+
+	// charts that aren't shipped are walked as if they released, so that the charts depending on them still release
+	graph, err := newChartGraph(m.Spec.Charts)
+	if err != nil {
+		for _, chart := range m.Spec.Charts {
+			if chart.isShipped() {
+				recordReleaseError(chart, err)
 			}
-			m.logger.WithLevel(level).
-				Str("chart", chart.Name).
-				Str("version", chart.Version).
-				Str("namespace", chart.Namespace).
-				Msg(msg)
 		}
-		recordReleaseError := func(releaseErr error) {
-			releaseErrors = append(releaseErrors, &interfaces.ManifestReleaseError{
-				Chart:     chart.Name,
-				Version:   chart.Version,
-				Namespace: chart.Namespace,
-				Error:     releaseErr,
-			})
-			logForChart(zerolog.ErrorLevel, strings.TrimSpace(releaseErr.Error()))
+		return releaseErrors
+	}
+	m.validateChartsDirectories(helm)
+
+	graph.walk(m.getReleaseOptions().MaxConcurrency, func(chart *Chart) releaseOutcome {
+		if !chart.isShipped() {
+			return releaseSucceeded
+		}
+		failedOutcome := releaseFailed
+		if m.getOnFailure(chart) == ChartOnFailureStop {
+			failedOutcome = releaseFailedStop
+		}
+		if m.isCancelled() {
+			recordReleaseError(chart, fmt.Errorf("Not releasing chart %s v%s, the release was cancelled", chart.Name, chart.Version))
+			return releaseFailedStop
+		}
+		// the chart is resolved ahead of checking for a resumable chart, so that a version constraint is compared by the
+		// version it resolves to, and changes to values from files or configmaps and secrets are caught along with
+		// changes to inline values
+		resolveMutex.Lock()
+		target, err := m.resolveChart(chart, kubernetes, helm)
+		resolveMutex.Unlock()
+		if err != nil {
+			recordReleaseError(chart, err)
+			return failedOutcome
+		}
+		if m.isResumable(chart) {
+			m.logForChart(chart, zerolog.InfoLevel, "Skipping chart, it was already released successfully with the same version and values in the ship being resumed")
+			m.recordChartResult(chart.getResult(interfaces.ManifestChartStatusSkipped))
+			return releaseSucceeded
+		}
+		if target.needsAddedRepo() {
+			resolveMutex.Lock()
+			addedRepos, err = addChartsRepo(helm, target.chartsSource, addedRepos)
+			resolveMutex.Unlock()
+			if err != nil {
+				recordReleaseError(chart, err)
+				return failedOutcome
+			}
 		}
 
-		releaseStatus, _ := helm.GetReleaseStatus(chart.Name, chart.Namespace)
+		releaseName := chart.getReleaseName()
+		releaseStatus, _ := helm.GetReleaseStatus(releaseName, chart.Namespace)
 		removedFailedRelease := false
-		if releaseStatus.Info != nil && releaseStatus.Info.Status == "failed" && releaseStatus.Revision == 1 {
+		if isFailedFirstRelease(releaseStatus) {
 			// in the case of a failed release from the first install, we want to remove it before attempting an "upgrade": https://github.com/helm/helm/issues/3353
-			logForChart(zerolog.InfoLevel, fmt.Sprintf("Attempting to remove previously-failed first release for %s", chart.Name))
-			_, err := helm.Exec(fmt.Sprintf("uninstall %s --namespace %s --no-hooks", chart.Name, chart.Namespace))
-			if err != nil {
-				recordReleaseError(fmt.Errorf("Error attempting to remove previously-failed first release for %s: %s", chart.Name, err))
-				continue CHARTS
+			m.logForChart(chart, zerolog.InfoLevel, fmt.Sprintf("Attempting to remove previously-failed first release for %s", releaseName))
+			if err := helm.Uninstall(releaseName, chart.Namespace, true); err != nil {
+				recordReleaseError(chart, fmt.Errorf("Error attempting to remove previously-failed first release for %s: %s", releaseName, err))
+				return failedOutcome
 			}
 			removedFailedRelease = true
 		}
 
 		m.logger.SubHeader(fmt.Sprintf("Releasing %s v%s", chart.Name, chart.Version))
 		if removedFailedRelease {
-			logForChart(zerolog.InfoLevel, "Removed previously-failed first release successfully")
-		}
-
-		// TODO: when we're able to deprecate --charts-* CLI args, we can move to some slightly cleaner patterns here. In order to continue to
-		//       support both manifest-defined chart sources and the CLI ones, and doing as little as possible around it for now, this is deemed the
-		//       best path
-		if m.Spec.All != nil && m.Spec.All.Timeout != "" && chart.Timeout == "" {
-			chart.Timeout = m.Spec.All.Timeout
-		}
-		extraCmdArgs := ""
-		helmChartsSource := &interfaces.HelmChartsSource{}
-		if m.Spec.Sources != nil && len(m.Spec.Sources.Charts) > 0 {
-			foundSource := false
-			for _, chartSource := range m.Spec.Sources.Charts {
-				if chartSource.Name == chart.Source {
-					foundSource = true
-					if chartSource.Type == ChartSourceTypeRepo {
-						helmChartsSource.RepoName = chartSource.Name
-						helmChartsSource.Repo = chartSource.Location
-						if chartSource.CredentialsSecret != nil {
-							helmChartsSource.RepoUsername, err = kubernetes.GetSecretKeyValue(chartSource.CredentialsSecret.Name, chartSource.CredentialsSecret.Namespace,
-								chartSource.CredentialsSecret.UsernameKey)
-							if err != nil {
-								recordReleaseError(fmt.Errorf("Error getting chart source username from secret %s for spec.sources.charts[] name = %s: %s",
-									chartSource.CredentialsSecret.Name, chartSource.Name, err))
-								continue CHARTS
-							}
-							helmChartsSource.RepoPassword, err = kubernetes.GetSecretKeyValue(chartSource.CredentialsSecret.Name, chartSource.CredentialsSecret.Namespace,
-								chartSource.CredentialsSecret.PasswordKey)
-							if err != nil {
-								recordReleaseError(fmt.Errorf("Error getting chart source password from secret %s for spec.sources.charts[] name = %s: %s",
-									chartSource.CredentialsSecret.Name, chartSource.Name, err))
-								continue CHARTS
-							}
-						}
-					} else if chartSource.Type == ChartSourceTypeDirectory {
-						helmChartsSource.Path = chartSource.Location
-					}
-					if err = helm.Initialize(helm.GetExecConfig(), helmChartsSource); err != nil {
-						recordReleaseError(fmt.Errorf("Error re-initializing Helm for specific source %s for chart %s: %s", chart.Source, chart.Name, err))
-						continue CHARTS
-					}
-					break
-				}
-			}
-			if !foundSource {
-				recordReleaseError(fmt.Errorf("Source name not found in spec.sources.charts[]: %s", chart.Source))
-				continue CHARTS
-			}
+			m.logForChart(chart, zerolog.InfoLevel, "Removed previously-failed first release successfully")
 		}
 
-		availableVersions, err := helm.GetAvailableChartVersions(chart.Name)
+		helmReleaseOptions, removeValuesFile, err := m.getHelmReleaseOptions(chart, target)
 		if err != nil {
-			recordReleaseError(fmt.Errorf("Error determining available versions for the chart %s: %s", chart.Name, err))
-			continue CHARTS
+			recordReleaseError(chart, err)
+			return failedOutcome
+		}
+		if target.values != nil && chart.valuesSecret {
+			m.logForChart(chart, zerolog.InfoLevel, "Found value overrides for chart, applying them without logging them since some are secret")
+		} else if target.values != nil {
+			m.logForChart(chart, zerolog.InfoLevel, fmt.Sprintf("Found value overrides for chart, applying: \n%s", target.values))
 		}
-		chartPath := ""
-		for _, availableVersion := range availableVersions {
-			if chart.Version == availableVersion.Version {
-				chartPath = availableVersion.Path
+		m.logForChart(chart, zerolog.InfoLevel, fmt.Sprintf("Running helm install/upgrade of release %s with chart %s", target.releaseName, target.chartPath))
+		var releaseErr error
+		result, err := helm.Upgrade(helmReleaseOptions)
+		removeValuesFile()
+		if err != nil {
+			releaseErr = fmt.Errorf("Error releasing chart %s v%s: %s", chart.Name, chart.Version, err)
+		} else {
+			m.logForChart(chart, zerolog.InfoLevel, fmt.Sprintf("%s\n", result.Output))
+			// a release that was installed/upgraded but fails its verify checks fails the same as one that failed to release
+			if err = m.verifyRelease(chart, target.releaseName, kubernetes, helm); err != nil {
+				releaseErr = fmt.Errorf("Error verifying release %s of chart %s v%s: %s", target.releaseName, chart.Name, chart.Version, err)
 			}
 		}
-		if chartPath == "" {
-			recordReleaseError(fmt.Errorf("Unable to find chart %s v%s in the configured charts location", chart.Name, chart.Version))
-			continue CHARTS
+		if releaseErr == nil {
+			chartResult := chart.getResult(interfaces.ManifestChartStatusSuccess)
+			chartResult.OptionsHash = m.getReleaseOptionsHash(chart, target)
+			m.recordChartResult(chartResult)
+			return releaseSucceeded
+		}
+		if m.getOnFailure(chart) != ChartOnFailureRollback {
+			recordReleaseError(chart, releaseErr)
+			return failedOutcome
+		}
+		addReleaseError(chart, releaseErr)
+		previousRevision := 0
+		if releaseStatus != nil && !removedFailedRelease {
+			previousRevision = releaseStatus.Revision
+		}
+		if previousRevision == 0 {
+			recordReleaseError(chart, fmt.Errorf("Not rolling back release %s, there's no previous revision to roll back to", releaseName))
+			return failedOutcome
+		}
+		m.logForChart(chart, zerolog.InfoLevel, fmt.Sprintf("Rolling back release %s to revision %d", releaseName, previousRevision))
+		if err = helm.Rollback(releaseName, chart.Namespace, previousRevision); err != nil {
+			recordReleaseError(chart, fmt.Errorf("Error rolling back release %s to revision %d: %s", releaseName, previousRevision, err))
+			return failedOutcome
+		}
+		addReleaseError(chart, fmt.Errorf("Rolled back release %s to revision %d after chart %s v%s failed to release",
+			releaseName, previousRevision, chart.Name, chart.Version))
+		chartResult := chart.getResult(interfaces.ManifestChartStatusRolledBack)
+		chartResult.RolledBackToRevision = previousRevision
+		m.recordChartResult(chartResult)
+		return failedOutcome
+	}, func(chart *Chart, failedChart *Chart, stopped bool) {
+		if !chart.isShipped() {
+			return
+		}
+		if m.isCancelled() {
+			recordReleaseError(chart, fmt.Errorf("Not releasing chart %s v%s, the release was cancelled", chart.Name, chart.Version))
+			return
 		}
+		if stopped {
+			recordReleaseError(chart, fmt.Errorf("Not releasing chart %s v%s, the release was stopped after chart %s failed with onFailure: %s",
+				chart.Name, chart.Version, failedChart.Name, ChartOnFailureStop))
+			return
+		}
+		recordReleaseError(chart, fmt.Errorf("Not releasing chart %s v%s, it depends on chart %s which did not release successfully",
+			chart.Name, chart.Version, failedChart.Name))
+	})
+
+	for _, addedRepo := range addedRepos {
+		_ = helm.RemoveRepo(addedRepo)
+	}
+	return releaseErrors
+}
+
+// Plan will resolve each chart that Release would release the same way Release does, and determine what a release would do for each
+// chart based on its current release status, without making any changes to the cluster
+func (m *Manifest) Plan(kubernetes interfaces.Kubernetes, helm interfaces.Helm) ([]*interfaces.ManifestPlanEntry, []*interfaces.ManifestReleaseError) {
+	var planEntries []*interfaces.ManifestPlanEntry
+	var releaseErrors []*interfaces.ManifestReleaseError
+
+	m.validateChartsDirectories(helm)
 
-		releaseName := chart.Name
-		if chart.ReleaseName != "" {
-			releaseName = chart.ReleaseName
+	for _, chart := range m.Spec.Charts {
+		if !chart.isShipped() {
+			continue
 		}
-		if chart.Timeout != "" {
-			extraCmdArgs = fmt.Sprintf("%s --timeout %s", extraCmdArgs, chart.Timeout)
+		recordReleaseError := func(releaseErr error) {
+			releaseErrors = append(releaseErrors, &interfaces.ManifestReleaseError{
+				Chart:     chart.Name,
+				Version:   chart.Version,
+				Namespace: chart.Namespace,
+				Error:     releaseErr,
+			})
 		}
-		if helmChartsSource.RepoUsername != "" {
-			if helmChartsSource.RepoName == "" {
-				helmChartsSource.RepoName = fmt.Sprintf("%x", md5.Sum([]byte(helmChartsSource.Repo)))
-			}
-			isAlreadyAdded := false
-			for _, addedRepo := range addedRepos {
-				if helmChartsSource.RepoName == addedRepo {
-					isAlreadyAdded = true
-					break
-				}
-			}
-			if !isAlreadyAdded {
-				_, err := helm.Exec(fmt.Sprintf("repo add %s %s --username %s --password %s", helmChartsSource.RepoName,
-					helmChartsSource.Repo, helmChartsSource.RepoUsername, helmChartsSource.RepoPassword))
-				if err != nil {
-					recordReleaseError(fmt.Errorf("Error adding secure chart repo %s: %s", helmChartsSource.Repo, err))
-					continue CHARTS
-				}
-				addedRepos = append(addedRepos, helmChartsSource.RepoName)
-			}
-			chartPath = fmt.Sprintf("%s/%s", helmChartsSource.RepoName, chart.Name)
-			extraCmdArgs = fmt.Sprintf("%s --version %s", extraCmdArgs, chart.Version)
-		}
-		installUpgradeCmd := strings.TrimSpace(fmt.Sprintf(
-			"upgrade --install %s %s --namespace %s --create-namespace --set global.chart.name=%s --set global.chart.version=%s %s",
-			releaseName,
-			chartPath,
-			chart.Namespace,
-			chart.Name,
-			chart.Version,
-			strings.TrimSpace(extraCmdArgs),
-		))
-		if chart.Values != nil {
-			valuesBytes, err := yaml.Marshal(chart.Values)
-			if err != nil {
-				recordReleaseError(fmt.Errorf("Error parsing override values for chart %s: %s", chart.Name, err))
-				continue CHARTS
-			}
-			valuesFilePath := filepath.Join(m.tempDirectory, fmt.Sprintf("%s-values.yaml", chart.Name))
-			if err = ioutil.WriteFile(valuesFilePath, valuesBytes, 0644); err != nil {
-				recordReleaseError(fmt.Errorf("Error writing Helm values for for chart %s: %s", chart.Name, err))
-				continue CHARTS
+
+		target, err := m.resolveChart(chart, kubernetes, helm)
+		if err != nil {
+			recordReleaseError(err)
+			continue
+		}
+		planEntry := &interfaces.ManifestPlanEntry{
+			Chart:       chart.Name,
+			Version:     chart.Version,
+			Namespace:   chart.Namespace,
+			ReleaseName: target.releaseName,
+			ChartPath:   target.chartPath,
+		}
+		releaseStatus, err := helm.GetReleaseStatus(target.releaseName, chart.Namespace)
+		if errors.Is(err, interfaces.ErrReleaseNotFound) {
+			releaseStatus = nil
+		} else if err != nil {
+			recordReleaseError(fmt.Errorf("Error getting the current release status of %s: %s", target.releaseName, err))
+			continue
+		}
+		if releaseStatus != nil && releaseStatus.Chart != nil && releaseStatus.Chart.Metadata != nil {
+			planEntry.CurrentVersion = releaseStatus.Chart.Metadata.Version
+		}
+		switch {
+		case releaseStatus == nil || releaseStatus.Info == nil:
+			planEntry.Action = interfaces.ManifestPlanActionInstall
+		case isFailedFirstRelease(releaseStatus):
+			planEntry.Action = interfaces.ManifestPlanActionReinstall
+			planEntry.CurrentRevision = releaseStatus.Revision
+		default:
+			planEntry.CurrentRevision = releaseStatus.Revision
+			planEntry.Action = interfaces.ManifestPlanActionUpgrade
+			if isReleaseUnchanged(chart, releaseStatus) && m.isReleaseOptionsUnchanged(chart, target) {
+				planEntry.Action = interfaces.ManifestPlanActionUnchanged
 			}
-			logForChart(zerolog.InfoLevel, fmt.Sprintf("Found value overrides for chart, applying: \n%s", valuesBytes))
-			installUpgradeCmd = fmt.Sprintf("%s -f %s", installUpgradeCmd, valuesFilePath)
 		}
-		logForChart(zerolog.InfoLevel, fmt.Sprintf("Running helm install/upgrade with arguments: %s", installUpgradeCmd))
-		output, err := helm.Exec(installUpgradeCmd)
-		if err != nil {
-			recordReleaseError(fmt.Errorf("Error releasing chart %s v%s: %s", chart.Name, chart.Version, err))
-			continue CHARTS
+		planEntries = append(planEntries, planEntry)
+	}
+	return planEntries, releaseErrors
+}
+
+// isReleaseUnchanged will determine if a deployed release already matches the chart name, version, and values that a
+// release of the chart would install it with
+func isReleaseUnchanged(chart *Chart, releaseStatus *interfaces.HelmReleaseStatus) bool {
+	if releaseStatus.Info.Status != "deployed" || releaseStatus.Chart == nil || releaseStatus.Chart.Metadata == nil {
+		return false
+	}
+	if releaseStatus.Chart.Metadata.Name != chart.Name || releaseStatus.Chart.Metadata.Version != chart.Version {
+		return false
+	}
+	releaseConfig, err := getReleaseConfig(chart)
+	if err != nil {
+		return false
+	}
+	desiredConfigBytes, err := yaml.Marshal(releaseConfig)
+	if err != nil {
+		return false
+	}
+	currentConfigBytes, err := yaml.Marshal(releaseStatus.Config)
+	if err != nil {
+		return false
+	}
+	return string(desiredConfigBytes) == string(currentConfigBytes)
+}
+
+// getReleaseConfig will return the user-supplied values Helm records for a release of the chart: the chart values
+// along with the global.chart.* values Release always sets
+func getReleaseConfig(chart *Chart) (map[string]interface{}, error) {
+	releaseConfig := make(map[string]interface{})
+	valuesBytes, err := chart.getValues()
+	if err != nil {
+		return nil, err
+	}
+	if valuesBytes != nil {
+		if err = yaml.Unmarshal(valuesBytes, &releaseConfig); err != nil {
+			return nil, err
 		}
-		logForChart(zerolog.InfoLevel, fmt.Sprintf("%s\n", output))
 	}
-	for _, addedRepo := range addedRepos {
-		_, _ = helm.Exec(fmt.Sprintf("repo rm %s", addedRepo))
+	global, ok := releaseConfig["global"].(map[interface{}]interface{})
+	if !ok {
+		global = make(map[interface{}]interface{})
 	}
-	return releaseErrors
+	globalChart, ok := global["chart"].(map[interface{}]interface{})
+	if !ok {
+		globalChart = make(map[interface{}]interface{})
+	}
+	globalChart["name"] = chart.Name
+	globalChart["version"] = chart.Version
+	global["chart"] = globalChart
+	releaseConfig["global"] = global
+	return releaseConfig, nil
+}
+
+// isReleaseOptionsUnchanged will determine if a release was last released with the same Helm options and timeout that a
+// release of the chart would release it with, as recorded by previous ships. A release without a recorded options hash
+// can't be told to be unchanged
+func (m *Manifest) isReleaseOptionsUnchanged(chart *Chart, target *releaseTarget) bool {
+	for _, releasedOptions := range m.getReleaseOptions().ReleasedOptions {
+		if releasedOptions.ReleaseName == target.releaseName && releasedOptions.Namespace == chart.Namespace {
+			return releasedOptions.OptionsHash != "" && releasedOptions.OptionsHash == m.getReleaseOptionsHash(chart, target)
+		}
+	}
+	return false
 }
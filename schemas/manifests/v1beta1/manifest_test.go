@@ -1,15 +1,23 @@
 package v1beta1
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/Cray-HPE/go-lib/shell"
+	"github.com/Cray-HPE/loftsman/internal/helm"
 	"github.com/Cray-HPE/loftsman/internal/interfaces"
 	"github.com/Cray-HPE/loftsman/internal/logger"
 	custommocks "github.com/Cray-HPE/loftsman/mocks/custom-mocks"
+	mocks "github.com/Cray-HPE/loftsman/mocks/interfaces"
+	"github.com/stretchr/testify/mock"
 )
 
 func getTestManifest() *Manifest {
@@ -52,6 +60,26 @@ spec:
 	}
 }
 
+func TestGetReleases(t *testing.T) {
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "chart", Namespace: "default", Version: "0.0.1"},
+		&Chart{Name: "chart", ReleaseName: "custom-release", Namespace: "other", Version: "0.0.1"},
+		&Chart{Name: "disabled-chart", Namespace: "default", Version: "0.0.1", Enabled: "false"},
+	}
+	if err := manifest.ValidateSpec(); err != nil {
+		t.Errorf("Got unexpected error from manifest.v1beta1.TestGetReleases(): %s", err)
+		return
+	}
+	// the release of a chart that isn't enabled is still owned by the manifest, so it's never pruned
+	releases := manifest.GetReleases()
+	if len(releases) != 3 || releases[0].ReleaseName != "chart" || releases[0].Namespace != "default" ||
+		releases[1].ReleaseName != "custom-release" || releases[1].Namespace != "other" ||
+		releases[2].ReleaseName != "disabled-chart" {
+		t.Errorf("Didn't get expected releases from manifest.v1beta1.TestGetReleases(), got: %v", releases)
+	}
+}
+
 func TestCreateNoCharts(t *testing.T) {
 	manifest := &Manifest{}
 	created, err := manifest.Create([]string{})
@@ -173,6 +201,187 @@ func TestReleaseChartWithValues(t *testing.T) {
 	}
 }
 
+func TestReleaseResume(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/chart-0.0.1.tgz",
+		},
+	}
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "succeeded", Namespace: "default", Version: "0.0.1"},
+		&Chart{Name: "changed-values", Namespace: "default", Version: "0.0.1", Values: map[string]interface{}{"one": "1"}},
+		&Chart{Name: "failed", Namespace: "default", Version: "0.0.1"},
+		&Chart{Name: "new", Namespace: "default", Version: "0.0.1"},
+	}
+	chartResults := make(map[string]string)
+	manifest.SetReleaseOptions(&interfaces.ManifestReleaseOptions{
+		MaxConcurrency: 1,
+		ResumeFrom: []*interfaces.ManifestChartResult{
+			&interfaces.ManifestChartResult{Chart: "succeeded", Version: "0.0.1", Namespace: "default", ReleaseName: "succeeded",
+				Status: interfaces.ManifestChartStatusSuccess},
+			&interfaces.ManifestChartResult{Chart: "changed-values", Version: "0.0.1", Namespace: "default", ReleaseName: "changed-values",
+				Status: interfaces.ManifestChartStatusSuccess},
+			&interfaces.ManifestChartResult{Chart: "failed", Version: "0.0.1", Namespace: "default", ReleaseName: "failed",
+				Status: interfaces.ManifestChartStatusFailed},
+		},
+		OnChartResult: func(chartResult *interfaces.ManifestChartResult) {
+			chartResults[chartResult.Chart] = chartResult.Status
+		},
+	})
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), custommocks.GetHelmMock(availableChartVersions))
+	if len(errs) != 1 || errs[0].Chart != "failed" {
+		t.Errorf("Expected only an error for the failed chart from manifest.v1beta1.TestReleaseResume(), got: %s", errsToString(errs))
+	}
+	expectedChartResults := map[string]string{
+		"succeeded":      interfaces.ManifestChartStatusSkipped,
+		"changed-values": interfaces.ManifestChartStatusSuccess,
+		"failed":         interfaces.ManifestChartStatusFailed,
+		"new":            interfaces.ManifestChartStatusSuccess,
+	}
+	if !reflect.DeepEqual(chartResults, expectedChartResults) {
+		t.Errorf("Didn't get expected chart results from manifest.v1beta1.TestReleaseResume(), got: %v", chartResults)
+	}
+}
+
+func TestReleaseResumeVersionConstraint(t *testing.T) {
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "same-version", Namespace: "default", Version: "~0.1"},
+		&Chart{Name: "newer-version", Namespace: "default", Version: ">=0.0.1"},
+	}
+	chartResults := make(map[string]*interfaces.ManifestChartResult)
+	manifest.SetReleaseOptions(&interfaces.ManifestReleaseOptions{
+		MaxConcurrency: 1,
+		ResumeFrom: []*interfaces.ManifestChartResult{
+			&interfaces.ManifestChartResult{Chart: "same-version", Version: "0.1.0", Namespace: "default", ReleaseName: "same-version",
+				Status: interfaces.ManifestChartStatusSuccess},
+			&interfaces.ManifestChartResult{Chart: "newer-version", Version: "0.0.1", Namespace: "default", ReleaseName: "newer-version",
+				Status: interfaces.ManifestChartStatusSuccess},
+		},
+		OnChartResult: func(chartResult *interfaces.ManifestChartResult) {
+			chartResults[chartResult.Chart] = chartResult
+		},
+	})
+	helm := custommocks.GetHelmMock(getTestAvailableChartVersions("0.0.1", "0.1.0"))
+	if errs := manifest.Release(custommocks.GetKubernetesMock(false), helm); len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestReleaseResumeVersionConstraint(): %s", errsToString(errs))
+		return
+	}
+	if chartResults["same-version"].Status != interfaces.ManifestChartStatusSkipped ||
+		chartResults["newer-version"].Status != interfaces.ManifestChartStatusSuccess || chartResults["newer-version"].Version != "0.1.0" {
+		t.Errorf("Didn't get expected chart results from manifest.v1beta1.TestReleaseResumeVersionConstraint(), got: %v", chartResults)
+	}
+	helm.AssertNotCalled(t, "Upgrade", mock.MatchedBy(func(options *interfaces.HelmReleaseOptions) bool {
+		return options.ReleaseName == "same-version"
+	}))
+}
+
+func TestReleaseOnFailureRollback(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/chart-0.0.1.tgz",
+		},
+	}
+	manifest := getTestManifest()
+	manifest.Spec.All = &Chart{OnFailure: ChartOnFailureRollback}
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "failed-upgrade-deployed", Namespace: "default", Version: "0.0.1"},
+		&Chart{Name: "failed-upgrade-continue-deployed", Namespace: "default", Version: "0.0.1", OnFailure: ChartOnFailureContinue},
+	}
+	chartResults := make(map[string]*interfaces.ManifestChartResult)
+	manifest.SetReleaseOptions(&interfaces.ManifestReleaseOptions{
+		MaxConcurrency: 1,
+		OnChartResult: func(chartResult *interfaces.ManifestChartResult) {
+			chartResults[chartResult.Chart] = chartResult
+		},
+	})
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 3 || !strings.Contains(errs[1].Error.Error(), "Rolled back release failed-upgrade-deployed to revision 2") {
+		t.Errorf("Didn't get expected errors from manifest.v1beta1.TestReleaseOnFailureRollback(), got: %s", errsToString(errs))
+	}
+	helm.AssertCalled(t, "Rollback", "failed-upgrade-deployed", "default", 2)
+	helm.AssertNotCalled(t, "Rollback", "failed-upgrade-continue-deployed", "default", 2)
+	if chartResults["failed-upgrade-deployed"].Status != interfaces.ManifestChartStatusRolledBack ||
+		chartResults["failed-upgrade-deployed"].RolledBackToRevision != 2 {
+		t.Errorf("Didn't get expected rolled back chart result from manifest.v1beta1.TestReleaseOnFailureRollback(), got: %v",
+			chartResults["failed-upgrade-deployed"])
+	}
+}
+
+func TestReleaseOnFailureRollbackFailed(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/chart-0.0.1.tgz",
+		},
+	}
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "failed-rollback-deployed", Namespace: "default", Version: "0.0.1", OnFailure: ChartOnFailureRollback},
+	}
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), custommocks.GetHelmMock(availableChartVersions))
+	if len(errs) != 2 || !strings.Contains(errs[1].Error.Error(), "Error rolling back release failed-rollback-deployed to revision 2") {
+		t.Errorf("Didn't get expected errors from manifest.v1beta1.TestReleaseOnFailureRollbackFailed(), got: %s", errsToString(errs))
+	}
+}
+
+func TestReleaseOnFailureStop(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/chart-0.0.1.tgz",
+		},
+	}
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "released", Namespace: "default", Version: "0.0.1"},
+		&Chart{Name: "failed", Namespace: "default", Version: "0.0.1", OnFailure: ChartOnFailureStop},
+		&Chart{Name: "stopped", Namespace: "default", Version: "0.0.1"},
+	}
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 2 || errs[1].Chart != "stopped" || !strings.Contains(errs[1].Error.Error(), "the release was stopped after chart failed") {
+		t.Errorf("Didn't get expected errors from manifest.v1beta1.TestReleaseOnFailureStop(), got: %s", errsToString(errs))
+	}
+	helm.AssertNotCalled(t, "GetReleaseStatus", "stopped", "default")
+}
+
+func TestReleaseCancelled(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/chart-0.0.1.tgz",
+		},
+	}
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "released", Namespace: "default", Version: "0.0.1"},
+		&Chart{Name: "cancelled", Namespace: "default", Version: "0.0.1"},
+		&Chart{Name: "also-cancelled", Namespace: "default", Version: "0.0.1", DependsOn: []string{"cancelled"}},
+	}
+	released := 0
+	manifest.SetReleaseOptions(&interfaces.ManifestReleaseOptions{
+		MaxConcurrency: 1,
+		OnChartResult: func(chartResult *interfaces.ManifestChartResult) {
+			released++
+		},
+		Cancelled: func() bool {
+			return released > 0
+		},
+	})
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 2 || errs[0].Chart != "cancelled" || errs[1].Chart != "also-cancelled" ||
+		!strings.Contains(errs[0].Error.Error(), "the release was cancelled") || !strings.Contains(errs[1].Error.Error(), "the release was cancelled") {
+		t.Errorf("Didn't get expected errors from manifest.v1beta1.TestReleaseCancelled(), got: %s", errsToString(errs))
+	}
+	helm.AssertNotCalled(t, "GetReleaseStatus", "cancelled", "default")
+}
+
 func TestReleaseChartWithFullChart(t *testing.T) {
 	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
 		&interfaces.HelmAvailableChartVersion{
@@ -295,6 +504,86 @@ func TestIndividualChartTimeout(t *testing.T) {
 	}
 }
 
+func TestChartHelmOptions(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/full-chart-0.0.1.tgz",
+		},
+	}
+	enabled, disabled, maxHistory := true, false, 5
+	manifest := getTestManifest()
+	manifest.Spec.All = &Chart{
+		Helm: &ChartHelm{Wait: &enabled, Atomic: &enabled, MaxHistory: &maxHistory, CreateNamespace: &disabled,
+			PostRenderer: "bin/post-render.sh", ExtraArgs: []string{"--reset-values"}},
+	}
+	manifest.SetDirectory("/manifests")
+	manifest.Spec.Charts = []*Chart{
+		&Chart{
+			Name:      "full-chart",
+			Namespace: "default",
+			Version:   "0.0.1",
+			Helm:      &ChartHelm{Atomic: &disabled, SkipCRDs: &enabled, Description: "full chart"},
+		},
+	}
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestChartHelmOptions(): %s", errsToString(errs))
+	}
+	helm.AssertCalled(t, "Upgrade", mock.MatchedBy(func(options *interfaces.HelmReleaseOptions) bool {
+		return options.Wait && !options.Atomic && options.SkipCRDs && !options.Force && options.MaxHistory == 5 &&
+			!options.CreateNamespace && options.PostRenderer == "/manifests/bin/post-render.sh" &&
+			options.Description == "full chart" && len(options.ExtraArgs) == 1 && options.ExtraArgs[0] == "--reset-values"
+	}))
+}
+
+func TestSelectCharts(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/full-chart-0.0.1.tgz",
+		},
+	}
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "core-chart", Namespace: "default", Version: "0.0.1", Labels: map[string]string{"tier": "core"}},
+		&Chart{Name: "edge-chart", Namespace: "default", Version: "0.0.1", Labels: map[string]string{"tier": "edge"},
+			DependsOn: []string{"core-chart"}},
+		&Chart{Name: "lab-chart", Namespace: "default", Version: "0.0.1", Labels: map[string]string{"tier": "edge"},
+			Enabled: "prod == lab"},
+	}
+	if err := manifest.ValidateSpec(); err != nil {
+		t.Errorf("Got unexpected error from manifest.v1beta1.TestSelectCharts(): %s", err)
+		return
+	}
+	if selected, err := manifest.SelectCharts(&interfaces.ManifestChartSelection{}); err != nil || !reflect.DeepEqual(selected, []string{"core-chart", "edge-chart"}) {
+		t.Errorf("Didn't get expected enabled charts from manifest.v1beta1.TestSelectCharts(), got %v, error: %v", selected, err)
+	}
+	for _, selection := range []*interfaces.ManifestChartSelection{
+		&interfaces.ManifestChartSelection{Only: []string{"missing-chart"}},
+		&interfaces.ManifestChartSelection{Only: []string{"lab-chart"}},
+	} {
+		if _, err := manifest.SelectCharts(selection); err == nil {
+			t.Errorf("Didn't get expected error from manifest.v1beta1.TestSelectCharts() for selection %v", selection)
+		}
+	}
+	selected, err := manifest.SelectCharts(&interfaces.ManifestChartSelection{Selector: map[string]string{"tier": "edge"}})
+	if err != nil || len(selected) != 1 || selected[0] != "edge-chart" {
+		t.Errorf("Didn't get expected selected charts from manifest.v1beta1.TestSelectCharts(), got: %v %v", selected, err)
+		return
+	}
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestSelectCharts(): %s", errsToString(errs))
+	}
+	helm.AssertNumberOfCalls(t, "Upgrade", 1)
+	helm.AssertCalled(t, "Upgrade", mock.MatchedBy(func(options *interfaces.HelmReleaseOptions) bool {
+		return options.ReleaseName == "edge-chart"
+	}))
+}
+
 func TestCustomReleaseName(t *testing.T) {
 	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
 		&interfaces.HelmAvailableChartVersion{
@@ -346,10 +635,12 @@ func TestChartSourceLocal(t *testing.T) {
 			Version:   "0.0.1",
 		},
 	}
-	errs := manifest.Release(custommocks.GetKubernetesMock(false), custommocks.GetHelmMock(availableChartVersions))
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
 	if len(errs) != 0 {
 		t.Errorf("Got unexpected errors from manifest.v1beta1.TestChartSourceLocal(): %s", errsToString(errs))
 	}
+	helm.AssertCalled(t, "ValidateChartsDirectory", "/tmp")
 }
 
 func TestChartSourceNotFound(t *testing.T) {
@@ -450,3 +741,404 @@ func TestChartSourceRepoCredentials(t *testing.T) {
 		t.Errorf("Got unexpected errors from manifest.v1beta1.TestChartSourceRepoCredentials(): %s", errsToString(errs))
 	}
 }
+
+func TestChartSourceOCI(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "oci://registry.io/charts/full-chart:0.0.1",
+		},
+	}
+	manifest := getTestManifest()
+	manifest.Spec.Sources = &Sources{
+		[]*ChartSource{
+			&ChartSource{
+				Type:     ChartSourceTypeOCI,
+				Name:     "registry",
+				Location: "oci://registry.io/charts",
+				CredentialsSecret: &ChartSourceCredentialsSecret{
+					Name:        "registry-creds",
+					Namespace:   "default",
+					UsernameKey: "username",
+					PasswordKey: "password",
+				},
+			},
+		},
+	}
+	manifest.Spec.Charts = []*Chart{
+		&Chart{
+			Name:      "full-chart",
+			Source:    "registry",
+			Namespace: "default",
+			Version:   "0.0.1",
+			Digest:    custommocks.TestOCIChartDigest,
+		},
+	}
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestChartSourceOCI(): %s", errsToString(errs))
+	}
+	helm.AssertNotCalled(t, "AddRepo", mock.Anything)
+	helm.AssertCalled(t, "Upgrade", mock.MatchedBy(func(options *interfaces.HelmReleaseOptions) bool {
+		return options.ChartPath == filepath.Join(manifest.tempDirectory, "charts", "full-chart-0.0.1.tgz") && options.Version == ""
+	}))
+}
+
+func TestChartSourceOCIDigestMismatch(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "oci://registry.io/charts/full-chart:0.0.1",
+		},
+	}
+	manifest := getTestManifest()
+	manifest.Spec.Sources = &Sources{
+		[]*ChartSource{
+			&ChartSource{
+				Type:     ChartSourceTypeOCI,
+				Name:     "registry",
+				Location: "oci://registry.io/charts",
+			},
+		},
+	}
+	manifest.Spec.Charts = []*Chart{
+		&Chart{
+			Name:      "full-chart",
+			Source:    "registry",
+			Namespace: "default",
+			Version:   "0.0.1",
+			Digest:    "sha256:fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9",
+		},
+	}
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), custommocks.GetHelmMock(availableChartVersions))
+	if len(errs) != 1 || !strings.Contains(errs[0].Error.Error(), "not the pinned digest") {
+		t.Errorf("Didn't get expected digest error from manifest.v1beta1.TestChartSourceOCIDigestMismatch(), got: %s", errsToString(errs))
+	}
+}
+
+func getGitChartSourceTestManifest() *Manifest {
+	manifest := getTestManifest()
+	os.RemoveAll(filepath.Join(manifest.tempDirectory, "git-charts"))
+	manifest.Spec.Sources = &Sources{
+		[]*ChartSource{
+			&ChartSource{
+				Type:     ChartSourceTypeGit,
+				Name:     "charts-repo",
+				Location: "file:///tmp/charts.git",
+				Ref:      "v1.0.0",
+				Path:     "charts/full-chart",
+			},
+		},
+	}
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "full-chart", Source: "charts-repo", Namespace: "default", Version: "0.0.1"},
+		&Chart{Name: "full-chart", ReleaseName: "other-full-chart", Source: "charts-repo", Namespace: "other", Version: "0.0.1"},
+	}
+	return manifest
+}
+
+func TestChartSourceGit(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/full-chart-0.0.1.tgz",
+		},
+	}
+	manifest := getGitChartSourceTestManifest()
+	git := &mocks.Git{}
+	git.On("Checkout", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
+	manifest.SetGit(git)
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestChartSourceGit(): %s", errsToString(errs))
+	}
+	checkoutDirectory := filepath.Join(manifest.tempDirectory, "git", "charts-repo")
+	git.AssertCalled(t, "Checkout", "file:///tmp/charts.git", "v1.0.0", checkoutDirectory)
+	// both charts are released from the one checkout and packaging
+	git.AssertNumberOfCalls(t, "Checkout", 1)
+	helm.AssertNumberOfCalls(t, "PackageChart", 1)
+	helm.AssertCalled(t, "PackageChart", filepath.Join(checkoutDirectory, "charts", "full-chart"),
+		filepath.Join(manifest.tempDirectory, "git-charts", "charts-repo"))
+	helm.AssertNotCalled(t, "AddRepo", mock.Anything)
+}
+
+func TestChartSourceGitCheckoutError(t *testing.T) {
+	manifest := getGitChartSourceTestManifest()
+	git := &mocks.Git{}
+	git.On("Checkout", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(
+		fmt.Errorf("Error checking out ref v1.0.0 of git repo file:///tmp/charts.git"))
+	manifest.SetGit(git)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), custommocks.GetHelmMock([]*interfaces.HelmAvailableChartVersion{}))
+	if len(errs) != 2 || !strings.Contains(errs[0].Error.Error(), "Error checking out spec.sources.charts[] name = charts-repo") {
+		t.Errorf("Didn't get expected checkout errors from manifest.v1beta1.TestChartSourceGitCheckoutError(), got: %s", errsToString(errs))
+	}
+}
+
+// setReleasedOptions will set the release options of the manifest as if the releases given were last released, in the
+// default namespace, with the default Helm options and timeout
+func setReleasedOptions(manifest *Manifest, releaseNames ...string) {
+	releasedOptions := []*interfaces.ManifestRelease{}
+	for _, releaseName := range releaseNames {
+		releasedOptions = append(releasedOptions, &interfaces.ManifestRelease{
+			ReleaseName: releaseName,
+			Namespace:   "default",
+			OptionsHash: manifest.getReleaseOptionsHash(&Chart{}, &releaseTarget{}),
+		})
+	}
+	manifest.SetReleaseOptions(&interfaces.ManifestReleaseOptions{ReleasedOptions: releasedOptions})
+}
+
+func TestReleaseRecordsOptionsHash(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/chart-0.0.1.tgz",
+		},
+	}
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "chart1", Namespace: "default", Version: "0.0.1"},
+	}
+	var chartResult *interfaces.ManifestChartResult
+	manifest.SetReleaseOptions(&interfaces.ManifestReleaseOptions{
+		MaxConcurrency: 1,
+		OnChartResult: func(result *interfaces.ManifestChartResult) {
+			chartResult = result
+		},
+	})
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	if errs := manifest.Release(custommocks.GetKubernetesMock(false), helm); len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestReleaseRecordsOptionsHash(): %s", errsToString(errs))
+		return
+	}
+	expectedOptionsHash := manifest.getReleaseOptionsHash(&Chart{}, &releaseTarget{})
+	if chartResult == nil || chartResult.OptionsHash != expectedOptionsHash || len(expectedOptionsHash) != len("sha256:")+64 {
+		t.Errorf("Didn't get expected options hash %s in the chart result from manifest.v1beta1.TestReleaseRecordsOptionsHash(), got: %v",
+			expectedOptionsHash, chartResult)
+	}
+	// the options hash isn't passed to the chart in its values
+	helm.AssertCalled(t, "Upgrade", mock.MatchedBy(func(options *interfaces.HelmReleaseOptions) bool {
+		return !strings.Contains(strings.Join(options.SetValues, ","), "optionsHash")
+	}))
+}
+
+func TestPlan(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/chart-0.0.1.tgz",
+		},
+	}
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{
+			Name:      "not-installed",
+			Namespace: "default",
+			Version:   "0.0.1",
+		},
+		&Chart{
+			Name:      "failed",
+			Namespace: "default",
+			Version:   "0.0.1",
+		},
+		&Chart{
+			Name:      "deployed",
+			Namespace: "default",
+			Version:   "0.0.1",
+		},
+		&Chart{
+			Name:      "deployed-with-values",
+			Namespace: "default",
+			Version:   "0.0.1",
+			Values: map[string]interface{}{
+				"one": "1",
+			},
+		},
+	}
+	expectedActions := []string{
+		interfaces.ManifestPlanActionInstall,
+		interfaces.ManifestPlanActionReinstall,
+		interfaces.ManifestPlanActionUnchanged,
+		interfaces.ManifestPlanActionUpgrade,
+	}
+	// a release without recorded options can't be told to be unchanged
+	manifest.Spec.Charts = append(manifest.Spec.Charts, &Chart{Name: "deployed-unrecorded", Namespace: "default", Version: "0.0.1"})
+	expectedActions = append(expectedActions, interfaces.ManifestPlanActionUpgrade)
+	setReleasedOptions(manifest, "deployed", "deployed-with-values")
+	planEntries, errs := manifest.Plan(custommocks.GetKubernetesMock(false), custommocks.GetHelmMock(availableChartVersions))
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestPlan(): %s", errsToString(errs))
+		return
+	}
+	if len(planEntries) != len(expectedActions) {
+		t.Errorf("Expected %d plan entries from manifest.v1beta1.TestPlan(), got %d", len(expectedActions), len(planEntries))
+		return
+	}
+	for i, planEntry := range planEntries {
+		if planEntry.Action != expectedActions[i] {
+			t.Errorf("Expected action %s for chart %s from manifest.v1beta1.TestPlan(), got %s", expectedActions[i], planEntry.Chart, planEntry.Action)
+		}
+	}
+}
+
+func TestPlanReleaseOptionsChanged(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/chart-0.0.1.tgz",
+		},
+	}
+	enabled := true
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "deployed", Namespace: "default", Version: "0.0.1"},
+		&Chart{Name: "deployed-waiting", Namespace: "default", Version: "0.0.1", Helm: &ChartHelm{Wait: &enabled}},
+		&Chart{Name: "deployed-timeout", Namespace: "default", Version: "0.0.1", Timeout: "10m"},
+	}
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	setReleasedOptions(manifest, "deployed", "deployed-waiting", "deployed-timeout")
+	expectedActions := []string{
+		interfaces.ManifestPlanActionUnchanged,
+		interfaces.ManifestPlanActionUpgrade,
+		interfaces.ManifestPlanActionUpgrade,
+	}
+	planEntries, errs := manifest.Plan(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 0 || len(planEntries) != len(expectedActions) {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestPlanReleaseOptionsChanged(): %s", errsToString(errs))
+		return
+	}
+	for i, planEntry := range planEntries {
+		if planEntry.Action != expectedActions[i] {
+			t.Errorf("Expected action %s for chart %s from manifest.v1beta1.TestPlanReleaseOptionsChanged(), got %s", expectedActions[i], planEntry.Chart, planEntry.Action)
+		}
+	}
+
+	// the Helm options of spec.all apply to every chart
+	manifest.Spec.Charts = manifest.Spec.Charts[:1]
+	manifest.Spec.All = &Chart{Helm: &ChartHelm{Atomic: &enabled}}
+	planEntries, errs = manifest.Plan(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 0 || len(planEntries) != 1 || planEntries[0].Action != interfaces.ManifestPlanActionUpgrade {
+		t.Errorf("Didn't get expected upgrade for a change to spec.all from manifest.v1beta1.TestPlanReleaseOptionsChanged(), got: %v, errors: %s",
+			planEntries, errsToString(errs))
+	}
+}
+
+func TestPlanReleaseStatusError(t *testing.T) {
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "status-error", Namespace: "default", Version: "0.0.1"},
+	}
+	planEntries, errs := manifest.Plan(custommocks.GetKubernetesMock(false), custommocks.GetHelmMock(getTestAvailableChartVersions("0.0.1")))
+	// an error getting the status that isn't of the release not being installed doesn't plan an install
+	if len(errs) != 1 || len(planEntries) != 0 || !strings.Contains(errs[0].Error.Error(), "Kubernetes cluster unreachable") {
+		t.Errorf("Didn't get expected error from manifest.v1beta1.TestPlanReleaseStatusError(), got %d plan entries, errors: %s",
+			len(planEntries), errsToString(errs))
+	}
+}
+
+func TestPlanChartDoesntExist(t *testing.T) {
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{
+			Name:      "chart",
+			Namespace: "default",
+			Version:   "0.0.1",
+		},
+	}
+	planEntries, errs := manifest.Plan(custommocks.GetKubernetesMock(false), custommocks.GetHelmMock([]*interfaces.HelmAvailableChartVersion{}))
+	if len(errs) == 0 || len(planEntries) != 0 {
+		t.Error("Didn't get expected error from manifest.v1beta1.TestPlanChartDoesntExist()")
+	}
+}
+
+// recordingShell is a shell for a real helm backend that records the helm commands it's given, as if they ran
+type recordingShell struct {
+	mutex    sync.Mutex
+	commands []string
+}
+
+func (s *recordingShell) Exec(command string, options shell.ExecOptions) (string, error) {
+	s.mutex.Lock()
+	s.commands = append(s.commands, command)
+	s.mutex.Unlock()
+	switch {
+	case strings.Contains(command, "version --client"):
+		return `version.BuildInfo{Version:"v3.6.3"}`, nil
+	case strings.Contains(command, " status "):
+		return "", fmt.Errorf("Error: release: not found")
+	}
+	return "STATUS: deployed\nREVISION: 1", nil
+}
+
+// writeTestChartPackage will write a packaged chart with just a Chart.yaml to a directory
+func writeTestChartPackage(t *testing.T, directory string, name string, version string) {
+	file, err := os.Create(filepath.Join(directory, fmt.Sprintf("%s-%s.tgz", name, version)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	chartYAML := fmt.Sprintf("apiVersion: v2\nname: %s\nversion: %s\n", name, version)
+	if err = tarWriter.WriteHeader(&tar.Header{Name: fmt.Sprintf("%s/Chart.yaml", name), Mode: 0644, Size: int64(len(chartYAML))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tarWriter.Write([]byte(chartYAML)); err != nil {
+		t.Fatal(err)
+	}
+	if err = tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestReleaseParallelSources releases charts from two sources in parallel with a real helm backend, rather than a
+// mock, so that each chart is checked to be released from its own source. Run with -race to catch shared state
+func TestReleaseParallelSources(t *testing.T) {
+	alphaDirectory, betaDirectory := t.TempDir(), t.TempDir()
+	writeTestChartPackage(t, alphaDirectory, "alpha", "1.0.0")
+	writeTestChartPackage(t, betaDirectory, "beta", "1.0.0")
+	recorder := &recordingShell{}
+	helmBackend := &helm.Helm{}
+	if err := helmBackend.Initialize(&interfaces.HelmExecConfig{Shell: recorder, Binary: "helm"}, &interfaces.HelmChartsSource{}); err != nil {
+		t.Fatal(err)
+	}
+	manifest := getTestManifest()
+	manifest.Spec.Sources = &Sources{[]*ChartSource{
+		&ChartSource{Type: ChartSourceTypeDirectory, Name: "alpha-source", Location: alphaDirectory},
+		&ChartSource{Type: ChartSourceTypeDirectory, Name: "beta-source", Location: betaDirectory},
+	}}
+	const charts = 40
+	for i := 0; i < charts; i++ {
+		name := "alpha"
+		if i%2 == 1 {
+			name = "beta"
+		}
+		manifest.Spec.Charts = append(manifest.Spec.Charts, &Chart{Name: name, Source: fmt.Sprintf("%s-source", name),
+			ReleaseName: fmt.Sprintf("%s-%d", name, i), Namespace: "default", Version: "1.0.0", Values: map[interface{}]interface{}{"index": i}})
+	}
+	manifest.SetReleaseOptions(&interfaces.ManifestReleaseOptions{MaxConcurrency: 8})
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helmBackend)
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestReleaseParallelSources(): %s", errsToString(errs))
+	}
+	upgrades := 0
+	for _, command := range recorder.commands {
+		if !strings.Contains(command, "upgrade --install") {
+			continue
+		}
+		upgrades++
+		for name, directory := range map[string]string{"alpha": alphaDirectory, "beta": betaDirectory} {
+			if strings.Contains(command, fmt.Sprintf(" %s-", name)) && !strings.Contains(command, filepath.Join(directory, name)) {
+				t.Errorf("Didn't get expected chart source from manifest.v1beta1.TestReleaseParallelSources(), got: %s", command)
+			}
+		}
+	}
+	if upgrades != charts {
+		t.Errorf("Didn't get expected upgrades from manifest.v1beta1.TestReleaseParallelSources(), got %d of %d", upgrades, charts)
+	}
+}
@@ -2,6 +2,10 @@
 package v1beta1
 
 import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
 	"github.com/Cray-HPE/loftsman/internal/logger"
 )
 
@@ -12,27 +16,45 @@ const (
 	ChartSourceTypeDirectory = "directory"
 	// ChartSourceTypeRepo is the identifier for spec.source.charts[].type where charts exist in a chart repository
 	ChartSourceTypeRepo = "repo"
+	// ChartSourceTypeOCI is the identifier for spec.source.charts[].type where charts exist in an OCI registry
+	ChartSourceTypeOCI = "oci"
+	// ChartSourceTypeGit is the identifier for spec.source.charts[].type where a chart exists unpackaged in a git repo
+	ChartSourceTypeGit = "git"
+	// ChartOnFailureContinue is the spec.charts[].onFailure policy to carry on releasing the other charts when a chart fails
+	ChartOnFailureContinue = "continue"
+	// ChartOnFailureStop is the spec.charts[].onFailure policy to stop releasing any further charts when a chart fails
+	ChartOnFailureStop = "stop"
+	// ChartOnFailureRollback is the spec.charts[].onFailure policy to roll a chart back to its previous release revision
+	// when it fails to upgrade, and carry on releasing the other charts
+	ChartOnFailureRollback = "rollback"
 )
 
 // Manifest is the v1beta1 manifest object, implements internal/interfaces/manifest.go
 type Manifest struct {
-	logger        *logger.Logger
-	tempDirectory string
-	APIVersion    string    `yaml:"apiVersion,omitempty" json:"apiVersion,omitempty"`
-	Metadata      *Metadata `yaml:"metadata,omitempty" json:"metadata,omitempty"`
-	Spec          *Spec     `yaml:"spec,omitempty" json:"spec,omitempty"`
+	logger         *logger.Logger
+	tempDirectory  string
+	directory      string
+	releaseOptions *interfaces.ManifestReleaseOptions
+	git            interfaces.Git
+	sops           interfaces.Sops
+	APIVersion     string    `yaml:"apiVersion,omitempty" json:"apiVersion,omitempty"`
+	Metadata       *Metadata `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	Spec           *Spec     `yaml:"spec,omitempty" json:"spec,omitempty"`
 }
 
 // Metadata stores the meta info about the manifest
 type Metadata struct {
-	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	Name   string            `yaml:"name,omitempty" json:"name,omitempty"`
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
 }
 
 // Spec is the root of definitions and instructions for the manifest
 type Spec struct {
-	Sources *Sources `yaml:"sources,omitempty" json:"sources,omitempty"`
-	All     *Chart   `yaml:"all,omitempty" json:"all,omitempty"` // All is really a subset of *Chart, but we can restrict accepted parts via our schema
-	Charts  []*Chart `yaml:"charts,omitempty" json:"charts,omitempty"`
+	Sources   *Sources          `yaml:"sources,omitempty" json:"sources,omitempty"`
+	All       *Chart            `yaml:"all,omitempty" json:"all,omitempty"` // All is really a subset of *Chart, but we can restrict accepted parts via our schema
+	Charts    []*Chart          `yaml:"charts,omitempty" json:"charts,omitempty"`
+	Prune     bool              `yaml:"prune,omitempty" json:"prune,omitempty"`         // uninstall releases that were in the previous ship of the manifest but no longer are
+	Variables map[string]string `yaml:"variables,omitempty" json:"variables,omitempty"` // the default values of the ${NAME} variables substituted in the manifest
 }
 
 // Sources contains info about artifact sources to use during loftsman shipping
@@ -48,6 +70,11 @@ type ChartSource struct {
 	// all properties below here are not relevant to every ChartSource.Type, but will either
 	// just be used when needed/ignored otherwise for chart source types where they're irrelevant
 	CredentialsSecret *ChartSourceCredentialsSecret `yaml:"credentialsSecret,omitempty" json:"credentialsSecret,omitempty"`
+	Ref               string                        `yaml:"ref,omitempty" json:"ref,omitempty"`         // the branch, tag or commit to check out of a git source
+	Path              string                        `yaml:"path,omitempty" json:"path,omitempty"`       // the path of the chart directory in a git source, the repo root by default
+	Verify            bool                          `yaml:"verify,omitempty" json:"verify,omitempty"`   // verify the .prov file of each chart from a directory or repo source
+	Keyring           string                        `yaml:"keyring,omitempty" json:"keyring,omitempty"` // the local path of the keyring to verify charts with
+	KeyringSecret     *ChartSourceKeyringSecret     `yaml:"keyringSecret,omitempty" json:"keyringSecret,omitempty"`
 }
 
 // ChartSourceCredentialsSecret is a reference to a Kubernetes secret storing credentials for accessing
@@ -59,13 +86,168 @@ type ChartSourceCredentialsSecret struct {
 	PasswordKey string `yaml:"passwordKey,omitempty" json:"passwordKey,omitempty"`
 }
 
+// ChartSourceKeyringSecret is a reference to a Kubernetes secret storing the keyring to verify the charts of a chart
+// source with
+type ChartSourceKeyringSecret struct {
+	Name      string `yaml:"name,omitempty" json:"name,omitempty"`
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Key       string `yaml:"key,omitempty" json:"key,omitempty"`
+}
+
+// ChartValuesFrom is a reference to chart values stored in a key of a Kubernetes configmap or secret, only one of which
+// should be set
+type ChartValuesFrom struct {
+	ConfigMapKeyRef *ChartValuesKeyRef `yaml:"configMapKeyRef,omitempty" json:"configMapKeyRef,omitempty"`
+	SecretKeyRef    *ChartValuesKeyRef `yaml:"secretKeyRef,omitempty" json:"secretKeyRef,omitempty"`
+}
+
+// ChartValuesKeyRef is a key of a Kubernetes configmap or secret storing chart values as YAML, in the chart's namespace
+// unless a namespace is given
+type ChartValuesKeyRef struct {
+	Name      string `yaml:"name,omitempty" json:"name,omitempty"`
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Key       string `yaml:"key,omitempty" json:"key,omitempty"`
+}
+
 // Chart is a single chart to install/upgrade
 type Chart struct {
-	Name        string      `yaml:"name,omitempty" json:"name,omitempty"`
-	Source      string      `yaml:"source,omitempty" json:"source,omitempty"`
-	ReleaseName string      `yaml:"releaseName,omitempty" json:"releaseName,omitempty"`
-	Namespace   string      `yaml:"namespace,omitempty" json:"namespace,omitempty"`
-	Version     string      `yaml:"version,omitempty" json:"version,omitempty"`
-	Values      interface{} `yaml:"values,omitempty" json:"-"` // json:"-" here is to ignore generic type validation, otherwise we'd get: json: unsupported type: map[interface {}]interface {}
-	Timeout     string      `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Name            string             `yaml:"name,omitempty" json:"name,omitempty"`
+	Source          string             `yaml:"source,omitempty" json:"source,omitempty"`
+	ReleaseName     string             `yaml:"releaseName,omitempty" json:"releaseName,omitempty"`
+	Namespace       string             `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Version         string             `yaml:"version,omitempty" json:"version,omitempty"`
+	Values          interface{}        `yaml:"values,omitempty" json:"-"`                                  // json:"-" here is to ignore generic type validation, otherwise we'd get: json: unsupported type: map[interface {}]interface {}
+	ValuesFiles     []string           `yaml:"valuesFiles,omitempty" json:"valuesFiles,omitempty"`         // paths relative to the manifest, merged in order ahead of valuesFrom and values
+	ValuesFrom      []*ChartValuesFrom `yaml:"valuesFrom,omitempty" json:"valuesFrom,omitempty"`           // merged in order after valuesFiles, ahead of values
+	EncryptedValues string             `yaml:"encryptedValues,omitempty" json:"encryptedValues,omitempty"` // a values document encrypted with sops, merged after valuesFrom, ahead of values
+	Sensitive       []string           `yaml:"sensitive,omitempty" json:"sensitive,omitempty"`             // dot-separated paths of values to redact from logs, e.g. auth.adminPassword
+	Timeout         string             `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	DependsOn       []string           `yaml:"dependsOn,omitempty" json:"dependsOn,omitempty"`
+	OnFailure       string             `yaml:"onFailure,omitempty" json:"onFailure,omitempty"`
+	Digest          string             `yaml:"digest,omitempty" json:"digest,omitempty"`   // pins a chart to the digest of its .tgz, or of its manifest for a chart from an OCI registry
+	Helm            *ChartHelm         `yaml:"helm,omitempty" json:"helm,omitempty"`       // the Helm options to install/upgrade the chart with, only in the manifests/v1 schema
+	Enabled         interface{}        `yaml:"enabled,omitempty" json:"enabled,omitempty"` // a bool, or an expression of manifest variables, only in the manifests/v1 schema
+	Labels          map[string]string  `yaml:"labels,omitempty" json:"labels,omitempty"`   // for selecting charts to ship with --selector, only in the manifests/v1 schema
+	Verify          *ChartVerify       `yaml:"verify,omitempty" json:"verify,omitempty"`   // checks of the release once it's installed/upgraded, only in the manifests/v1 schema
+
+	versionConstraint string // the version as written in the manifest, once Version has been resolved to an exact version
+	lockedDigest      string // the digest of the chart in the applied manifest lock, if any
+	resolvedValues    []byte // the chart's values merged from its valuesFiles, valuesFrom and values, once resolved
+	valuesResolved    bool
+	valuesSecret      bool // some of the chart's values were decrypted or are from a secret, so they mustn't be logged
+	disabled          bool // the chart's enabled evaluated to false, so it isn't shipped
+	unselected        bool // the chart isn't in the charts selected to ship, so it isn't shipped this time
+}
+
+// ChartVerify is the checks that a chart's release is healthy once it's been installed/upgraded, any of which failing
+// fails the chart. Each check has a timeout, a go duration, taken from the verify timeout when it isn't set, then the
+// chart's timeout, or otherwise defaulting to 5m
+type ChartVerify struct {
+	Timeout    string                  `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Rollout    *ChartVerifyCheck       `yaml:"rollout,omitempty" json:"rollout,omitempty"` // wait for the release's Deployments, StatefulSets and DaemonSets to roll out
+	Jobs       []*ChartVerifyJob       `yaml:"jobs,omitempty" json:"jobs,omitempty"`
+	Conditions []*ChartVerifyCondition `yaml:"conditions,omitempty" json:"conditions,omitempty"`
+	HelmTest   *ChartVerifyCheck       `yaml:"helmTest,omitempty" json:"helmTest,omitempty"` // run `helm test` on the release
+
+	// the keys that aren't verify options, kept so that schema validation reports them rather than them being ignored
+	Unknown map[string]interface{} `yaml:",inline" json:"-"`
+}
+
+// ChartVerifyCheck is a verify check that only needs a timeout
+type ChartVerifyCheck struct {
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	Unknown map[string]interface{} `yaml:",inline" json:"-"` // the same as ChartVerify.Unknown
+}
+
+// ChartVerifyJob is a Job to wait to complete, in the chart's namespace unless a namespace is given
+type ChartVerifyJob struct {
+	Name      string `yaml:"name,omitempty" json:"name,omitempty"`
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Timeout   string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	Unknown map[string]interface{} `yaml:",inline" json:"-"` // the same as ChartVerify.Unknown
+}
+
+// ChartVerifyCondition is a resource of any kind to wait to have a status condition, in the chart's namespace unless a
+// namespace is given
+type ChartVerifyCondition struct {
+	APIVersion string `yaml:"apiVersion,omitempty" json:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind,omitempty" json:"kind,omitempty"`
+	Name       string `yaml:"name,omitempty" json:"name,omitempty"`
+	Namespace  string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Type       string `yaml:"type,omitempty" json:"type,omitempty"`
+	Status     string `yaml:"status,omitempty" json:"status,omitempty"` // True by default
+	Timeout    string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	Unknown map[string]interface{} `yaml:",inline" json:"-"` // the same as ChartVerify.Unknown
+}
+
+// ChartHelm is the Helm options to install/upgrade a chart with. Options that aren't set are taken from spec.all.helm,
+// or are Helm's defaults
+type ChartHelm struct {
+	Wait          *bool `yaml:"wait,omitempty" json:"wait,omitempty"`
+	WaitForJobs   *bool `yaml:"waitForJobs,omitempty" json:"waitForJobs,omitempty"`
+	Atomic        *bool `yaml:"atomic,omitempty" json:"atomic,omitempty"`
+	Force         *bool `yaml:"force,omitempty" json:"force,omitempty"`
+	DisableHooks  *bool `yaml:"disableHooks,omitempty" json:"disableHooks,omitempty"`
+	SkipCRDs      *bool `yaml:"skipCRDs,omitempty" json:"skipCRDs,omitempty"`
+	CleanupOnFail *bool `yaml:"cleanupOnFail,omitempty" json:"cleanupOnFail,omitempty"`
+	MaxHistory    *int  `yaml:"maxHistory,omitempty" json:"maxHistory,omitempty"`
+	// an executable to pass the rendered resources through, a path with a / being relative to the manifest, otherwise
+	// found on the PATH
+	PostRenderer    string   `yaml:"postRenderer,omitempty" json:"postRenderer,omitempty"`
+	CreateNamespace *bool    `yaml:"createNamespace,omitempty" json:"createNamespace,omitempty"` // true when not set
+	Description     string   `yaml:"description,omitempty" json:"description,omitempty"`
+	ExtraArgs       []string `yaml:"extraArgs,omitempty" json:"extraArgs,omitempty"` // each one of interfaces.HelmExtraArgs
+
+	// the keys that aren't Helm options, kept so that schema validation reports them rather than them being ignored
+	Unknown map[string]interface{} `yaml:",inline" json:"-"`
+}
+
+// MarshalJSON will marshal the Helm options along with any unknown keys, so that schema validation sees them
+func (h ChartHelm) MarshalJSON() ([]byte, error) {
+	type chartHelm ChartHelm
+	return marshalWithUnknown(chartHelm(h), h.Unknown)
+}
+
+// MarshalJSON will marshal the verify checks along with any unknown keys, so that schema validation sees them
+func (v ChartVerify) MarshalJSON() ([]byte, error) {
+	type chartVerify ChartVerify
+	return marshalWithUnknown(chartVerify(v), v.Unknown)
+}
+
+// MarshalJSON will marshal the verify check along with any unknown keys, so that schema validation sees them
+func (c ChartVerifyCheck) MarshalJSON() ([]byte, error) {
+	type chartVerifyCheck ChartVerifyCheck
+	return marshalWithUnknown(chartVerifyCheck(c), c.Unknown)
+}
+
+// MarshalJSON will marshal the verify job along with any unknown keys, so that schema validation sees them
+func (j ChartVerifyJob) MarshalJSON() ([]byte, error) {
+	type chartVerifyJob ChartVerifyJob
+	return marshalWithUnknown(chartVerifyJob(j), j.Unknown)
+}
+
+// MarshalJSON will marshal the verify condition along with any unknown keys, so that schema validation sees them
+func (c ChartVerifyCondition) MarshalJSON() ([]byte, error) {
+	type chartVerifyCondition ChartVerifyCondition
+	return marshalWithUnknown(chartVerifyCondition(c), c.Unknown)
+}
+
+// marshalWithUnknown will marshal known, which mustn't have a MarshalJSON of its own, with the unknown keys merged in
+func marshalWithUnknown(known interface{}, unknown map[string]interface{}) ([]byte, error) {
+	knownJSON, err := json.Marshal(known)
+	if err != nil || len(unknown) == 0 {
+		return knownJSON, err
+	}
+	fields := make(map[string]interface{})
+	if err = json.Unmarshal(knownJSON, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range unknown {
+		// only the key matters to the schema, and yaml values may not marshal as json
+		fields[key] = fmt.Sprintf("%v", value)
+	}
+	return json.Marshal(fields)
 }
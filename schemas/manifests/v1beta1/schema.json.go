@@ -18,14 +18,51 @@ const Schema = `
         "namespace": { "type": "string" },
         "version": { "type": "string" },
         "values": { "type": [ "object", "null" ] },
-        "timeout": { "type": "string" }
+        "valuesFiles": {
+          "type": "array",
+          "items": { "type": "string" }
+        },
+        "encryptedValues": { "type": "string" },
+        "sensitive": {
+          "type": "array",
+          "items": { "type": "string" }
+        },
+        "valuesFrom": {
+          "type": "array",
+          "items": {
+            "type": "object",
+            "properties": {
+              "configMapKeyRef": { "$ref": "#/definitions/valuesKeyRef" },
+              "secretKeyRef": { "$ref": "#/definitions/valuesKeyRef" }
+            },
+            "additionalProperties": false
+          }
+        },
+        "timeout": { "type": "string" },
+        "dependsOn": {
+          "type": "array",
+          "items": { "type": "string" }
+        },
+        "onFailure": { "type": "string", "enum": ["continue", "stop", "rollback"] },
+        "digest": { "type": "string", "pattern": "^sha256:[a-f0-9]{64}$" }
+      },
+      "additionalProperties": false
+    },
+    "valuesKeyRef": {
+      "type": "object",
+      "required": ["name", "key"],
+      "properties": {
+        "name": { "type": "string" },
+        "namespace": { "type": "string" },
+        "key": { "type": "string" }
       },
       "additionalProperties": false
     },
     "all": {
       "type": "object",
       "properties": {
-        "timeout": { "type": "string" }
+        "timeout": { "type": "string" },
+        "onFailure": { "type": "string", "enum": ["continue", "stop", "rollback"] }
       },
       "additionalProperties": false
     }
@@ -55,9 +92,23 @@ const Schema = `
                 "type": "object",
                 "required": [ "type", "name", "location" ],
                 "properties": {
-                  "type": { "type": "string", "enum": ["directory", "repo"] },
+                  "type": { "type": "string", "enum": ["directory", "repo", "oci", "git"] },
                   "name": { "type": "string" },
                   "location": { "type": "string" },
+                  "ref": { "type": "string" },
+                  "path": { "type": "string" },
+                  "verify": { "type": "boolean" },
+                  "keyring": { "type": "string" },
+                  "keyringSecret": {
+                    "type": "object",
+                    "required": ["name", "namespace", "key"],
+                    "properties": {
+                      "name": { "type": "string" },
+                      "namespace": { "type": "string" },
+                      "key": { "type": "string" }
+                    },
+                    "additionalProperties": false
+                  },
                   "credentialsSecret": {
                     "type": "object",
                     "required": ["name", "namespace", "usernameKey", "passwordKey"],
@@ -89,6 +140,19 @@ const Schema = `
           "additionalProperties": false
         },
         "all": { "$ref": "#/definitions/all" },
+        "prune": { "type": "boolean" },
+        "variables": {
+          "type": "object",
+          "additionalProperties": { "type": "string" }
+        },
+        "imports": {
+          "type": "array",
+          "items": { "type": "string" }
+        },
+        "overlays": {
+          "type": "array",
+          "items": { "type": "string" }
+        },
         "charts": {
           "type": "array",
           "items": {
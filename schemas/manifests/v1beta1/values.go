@@ -0,0 +1,237 @@
+package v1beta1
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// getManifestFilePath returns the path of a file the manifest refers to, like a chart's values file or a source's
+// keyring, relative paths being relative to the manifest
+func (m *Manifest) getManifestFilePath(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(m.directory, file)
+}
+
+// readValuesFile will read a chart values file, checking that it parses as yaml
+func (m *Manifest) readValuesFile(valuesFile string) ([]byte, error) {
+	content, err := ioutil.ReadFile(m.getManifestFilePath(valuesFile))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading values file %s: %s", valuesFile, err)
+	}
+	if _, err = parseValues(content); err != nil {
+		return nil, fmt.Errorf("Error parsing values file %s as yaml: %s", valuesFile, err)
+	}
+	return content, nil
+}
+
+// parseValues will parse chart values yaml, which must be a map if it isn't empty
+func parseValues(content []byte) (map[interface{}]interface{}, error) {
+	values := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// isEncrypted returns whether parsed chart values are a document encrypted with sops, which has the sops metadata
+// alongside the encrypted values
+func isEncrypted(values map[interface{}]interface{}) bool {
+	metadata, ok := values["sops"].(map[interface{}]interface{})
+	if !ok {
+		return false
+	}
+	_, hasMAC := metadata["mac"]
+	return hasMAC
+}
+
+// decodeValues will parse chart values yaml, decrypting it in memory first if it's encrypted with sops
+func (m *Manifest) decodeValues(chart *Chart, content []byte, description string) (map[interface{}]interface{}, error) {
+	values, err := parseValues(content)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing values from %s as yaml: %s", description, err)
+	}
+	if !isEncrypted(values) {
+		return values, nil
+	}
+	if m.sops == nil {
+		return nil, fmt.Errorf("Unable to decrypt the values from %s, sops isn't set up", description)
+	}
+	decrypted, err := m.sops.Decrypt(content)
+	if err != nil {
+		return nil, fmt.Errorf("Error decrypting the values from %s: %s", description, err)
+	}
+	chart.valuesSecret = true
+	if values, err = parseValues(decrypted); err != nil {
+		// the yaml error could quote the decrypted values, so it's left out
+		return nil, fmt.Errorf("Error parsing the decrypted values from %s as yaml", description)
+	}
+	// every decrypted value is a secret, whatever its key, so they're all redacted from the logs before Helm gets them
+	if m.logger != nil {
+		m.logger.AddSensitiveValues(getSensitiveValues(values)...)
+	}
+	return values, nil
+}
+
+// readValuesFrom will read the chart values in the configmap or secret key of a valuesFrom reference
+func readValuesFrom(chart *Chart, valuesFrom *ChartValuesFrom, kubernetes interfaces.Kubernetes) ([]byte, string, error) {
+	var content string
+	var description string
+	if valuesFrom.ConfigMapKeyRef != nil {
+		ref := valuesFrom.ConfigMapKeyRef
+		namespace := ref.getNamespace(chart)
+		description = fmt.Sprintf("key %s of configmap %s in namespace %s", ref.Key, ref.Name, namespace)
+		configMap, err := kubernetes.GetConfigMap(ref.Name, namespace)
+		if err != nil {
+			return nil, description, fmt.Errorf("Error getting values from %s: %s", description, err)
+		}
+		if configMap == nil {
+			return nil, description, fmt.Errorf("Error getting values from %s: configmap not found", description)
+		}
+		var found bool
+		if content, found = configMap.Data[ref.Key]; !found {
+			return nil, description, fmt.Errorf("Error getting values from %s: key not found", description)
+		}
+	} else if valuesFrom.SecretKeyRef != nil {
+		ref := valuesFrom.SecretKeyRef
+		namespace := ref.getNamespace(chart)
+		description = fmt.Sprintf("key %s of secret %s in namespace %s", ref.Key, ref.Name, namespace)
+		var err error
+		if content, err = kubernetes.GetSecretKeyValue(ref.Name, namespace, ref.Key); err != nil {
+			return nil, description, fmt.Errorf("Error getting values from %s: %s", description, err)
+		}
+	}
+	return []byte(content), description, nil
+}
+
+// getNamespace returns the namespace of the configmap or secret of a values reference, by default the chart's namespace
+func (r *ChartValuesKeyRef) getNamespace(chart *Chart) string {
+	if r.Namespace != "" {
+		return r.Namespace
+	}
+	return chart.Namespace
+}
+
+// resolveValues will merge a chart's values, from each of its valuesFiles in order, then each of its valuesFrom in order,
+// then its encryptedValues, and then its inline values, each taking precedence over the ones before it the same way
+// multiple Helm values files do. Any of them encrypted with sops are decrypted in memory. This is only done once per
+// chart, and returns nil when the chart has no values
+func (m *Manifest) resolveValues(chart *Chart, kubernetes interfaces.Kubernetes) ([]byte, error) {
+	if chart.valuesResolved {
+		return chart.resolvedValues, nil
+	}
+	var merged interface{}
+	if len(chart.ValuesFiles) > 0 || len(chart.ValuesFrom) > 0 || chart.EncryptedValues != "" {
+		mergedValues := map[interface{}]interface{}{}
+		for _, valuesFile := range chart.ValuesFiles {
+			content, err := m.readValuesFile(valuesFile)
+			if err != nil {
+				return nil, err
+			}
+			values, err := m.decodeValues(chart, content, fmt.Sprintf("values file %s", valuesFile))
+			if err != nil {
+				return nil, err
+			}
+			mergeValues(mergedValues, values)
+		}
+		for _, valuesFrom := range chart.ValuesFrom {
+			content, description, err := readValuesFrom(chart, valuesFrom, kubernetes)
+			if err != nil {
+				return nil, err
+			}
+			if valuesFrom.SecretKeyRef != nil {
+				chart.valuesSecret = true
+			}
+			values, err := m.decodeValues(chart, content, description)
+			if err != nil {
+				return nil, err
+			}
+			mergeValues(mergedValues, values)
+		}
+		if chart.EncryptedValues != "" {
+			values, err := m.decodeValues(chart, []byte(chart.EncryptedValues), fmt.Sprintf("the encryptedValues of chart %s", chart.Name))
+			if err != nil {
+				return nil, err
+			}
+			mergeValues(mergedValues, values)
+		}
+		if chart.Values != nil {
+			values, ok := chart.Values.(map[interface{}]interface{})
+			if !ok {
+				return nil, fmt.Errorf("Error merging values for chart %s: values must be a map", chart.Name)
+			}
+			mergeValues(mergedValues, values)
+		}
+		merged = mergedValues
+	} else if chart.Values != nil {
+		// with only inline values, they're used as is so that their hash is the same as it's always been
+		merged = chart.Values
+	}
+	if merged != nil {
+		if m.logger != nil {
+			for _, path := range chart.Sensitive {
+				m.logger.AddSensitiveValues(getSensitiveValues(getValueAtPath(merged, path))...)
+			}
+		}
+		resolvedValues, err := yaml.Marshal(merged)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing override values for chart %s: %s", chart.Name, err)
+		}
+		chart.resolvedValues = resolvedValues
+	}
+	chart.valuesResolved = true
+	return chart.resolvedValues, nil
+}
+
+// mergeValues will deep merge chart values into a destination, maps are merged key by key and anything else in values
+// replaces what's in the destination
+func mergeValues(destination map[interface{}]interface{}, values map[interface{}]interface{}) {
+	for key, value := range values {
+		valueMap, isMap := value.(map[interface{}]interface{})
+		destinationMap, destinationIsMap := destination[key].(map[interface{}]interface{})
+		if isMap && destinationIsMap {
+			mergeValues(destinationMap, valueMap)
+			continue
+		}
+		destination[key] = value
+	}
+}
+
+// getValueAtPath returns the value at a dot-separated path of chart values, e.g. auth.adminPassword, or nil if there's
+// no value there
+func getValueAtPath(values interface{}, path string) interface{} {
+	value := values
+	for _, key := range strings.Split(path, ".") {
+		valuesMap, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return nil
+		}
+		value = valuesMap[key]
+	}
+	return value
+}
+
+// getSensitiveValues returns the values to redact for a sensitive value, a value itself, or every value under it if
+// it's a map or list. Only strings are redacted, since numbers and booleans are in too much log output to redact
+func getSensitiveValues(value interface{}) []string {
+	sensitiveValues := []string{}
+	switch typedValue := value.(type) {
+	case string:
+		sensitiveValues = append(sensitiveValues, typedValue)
+	case map[interface{}]interface{}:
+		for _, nestedValue := range typedValue {
+			sensitiveValues = append(sensitiveValues, getSensitiveValues(nestedValue)...)
+		}
+	case []interface{}:
+		for _, nestedValue := range typedValue {
+			sensitiveValues = append(sensitiveValues, getSensitiveValues(nestedValue)...)
+		}
+	}
+	return sensitiveValues
+}
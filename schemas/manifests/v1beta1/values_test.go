@@ -0,0 +1,340 @@
+package v1beta1
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	"github.com/Cray-HPE/loftsman/internal/logger"
+	custommocks "github.com/Cray-HPE/loftsman/mocks/custom-mocks"
+	mocks "github.com/Cray-HPE/loftsman/mocks/interfaces"
+	"github.com/stretchr/testify/mock"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func getValuesTestManifest(t *testing.T) *Manifest {
+	manifest := getTestManifest()
+	directory := t.TempDir()
+	ioutil.WriteFile(filepath.Join(directory, "base.yaml"), []byte("replicas: 1\nimage:\n  repository: test\n  tag: base\n"), 0644)
+	ioutil.WriteFile(filepath.Join(directory, "site.yaml"), []byte("image:\n  tag: site\nsite: test-site\n"), 0644)
+	manifest.SetDirectory(directory)
+	manifest.Spec.Charts = []*Chart{
+		&Chart{
+			Name:        "test-chart",
+			Namespace:   "default",
+			Version:     "0.0.1",
+			ValuesFiles: []string{"base.yaml", "site.yaml"},
+			ValuesFrom: []*ChartValuesFrom{
+				&ChartValuesFrom{ConfigMapKeyRef: &ChartValuesKeyRef{Name: "test-chart-values", Key: "values.yaml"}},
+			},
+			Values: map[interface{}]interface{}{"site": "inline-site"},
+		},
+	}
+	return manifest
+}
+
+func TestResolveValues(t *testing.T) {
+	manifest := getValuesTestManifest(t)
+	valuesBytes, err := manifest.resolveValues(manifest.Spec.Charts[0], custommocks.GetKubernetesMock(false))
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.v1beta1.TestResolveValues(): %s", err)
+		return
+	}
+	values := map[string]interface{}{}
+	yaml.Unmarshal(valuesBytes, &values)
+	image, _ := values["image"].(map[interface{}]interface{})
+	// valuesFiles are merged in order, then valuesFrom, then the inline values
+	if values["replicas"] != 2 || values["site"] != "inline-site" || image["repository"] != "test" || image["tag"] != "configmap" {
+		t.Errorf("Didn't get expected merged values from manifest.v1beta1.TestResolveValues(), got:\n%s", valuesBytes)
+	}
+}
+
+func TestResolveValuesSecret(t *testing.T) {
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{
+			Name:      "test-chart",
+			Namespace: "default",
+			Version:   "0.0.1",
+			ValuesFrom: []*ChartValuesFrom{
+				&ChartValuesFrom{SecretKeyRef: &ChartValuesKeyRef{Name: "test-chart-secret", Namespace: "services", Key: "values.yaml"}},
+			},
+		},
+	}
+	kubernetes := &mocks.Kubernetes{}
+	kubernetes.On("GetSecretKeyValue", "test-chart-secret", "services", "values.yaml").Return("password: secret\n", nil)
+	valuesBytes, err := manifest.resolveValues(manifest.Spec.Charts[0], kubernetes)
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.v1beta1.TestResolveValuesSecret(): %s", err)
+		return
+	}
+	if string(valuesBytes) != "password: secret\n" {
+		t.Errorf("Didn't get expected values from manifest.v1beta1.TestResolveValuesSecret(), got: %s", valuesBytes)
+	}
+}
+
+func TestResolveValuesErrors(t *testing.T) {
+	for description, valuesFrom := range map[string]*ChartValuesFrom{
+		"key not found": &ChartValuesFrom{ConfigMapKeyRef: &ChartValuesKeyRef{Name: "test-chart-values", Key: "missing.yaml"}},
+		// the mock secret value isn't a map of values
+		"as yaml": &ChartValuesFrom{SecretKeyRef: &ChartValuesKeyRef{Name: "test-chart-secret", Key: "values.yaml"}},
+	} {
+		manifest := getValuesTestManifest(t)
+		manifest.Spec.Charts[0].ValuesFrom = []*ChartValuesFrom{valuesFrom}
+		_, err := manifest.resolveValues(manifest.Spec.Charts[0], custommocks.GetKubernetesMock(false))
+		if err == nil || !strings.Contains(err.Error(), description) {
+			t.Errorf("Didn't get expected error from manifest.v1beta1.TestResolveValuesErrors() for %s, got: %v", description, err)
+		}
+	}
+}
+
+func TestResolveValuesInlineOnly(t *testing.T) {
+	chart := &Chart{
+		Name:      "test-chart",
+		Namespace: "default",
+		Version:   "0.0.1",
+		Values:    map[interface{}]interface{}{"replicas": 1},
+	}
+	unresolvedHash := chart.getValuesHash()
+	if _, err := getTestManifest().resolveValues(chart, custommocks.GetKubernetesMock(false)); err != nil {
+		t.Errorf("Got unexpected error from manifest.v1beta1.TestResolveValuesInlineOnly(): %s", err)
+		return
+	}
+	// charts with only inline values keep the same hash as before values files and refs, to resume previous releases
+	if chart.getValuesHash() != unresolvedHash {
+		t.Errorf("Didn't get expected values hash from manifest.v1beta1.TestResolveValuesInlineOnly(), expected %s, got: %s",
+			unresolvedHash, chart.getValuesHash())
+	}
+}
+
+// valuesFileHelm is a helm mock that reads the values files passed to Template and Upgrade while they still exist
+type valuesFileHelm struct {
+	*mocks.Helm
+	valuesFiles map[string]string
+}
+
+func (h *valuesFileHelm) readValuesFiles(options *interfaces.HelmReleaseOptions) {
+	for _, valuesFile := range options.ValuesFiles {
+		valuesBytes, _ := ioutil.ReadFile(valuesFile)
+		h.valuesFiles[valuesFile] = string(valuesBytes)
+	}
+}
+
+func (h *valuesFileHelm) Upgrade(options *interfaces.HelmReleaseOptions) (*interfaces.HelmReleaseResult, error) {
+	h.readValuesFiles(options)
+	return h.Helm.Upgrade(options)
+}
+
+func (h *valuesFileHelm) Template(options *interfaces.HelmReleaseOptions) (string, error) {
+	h.readValuesFiles(options)
+	return h.Helm.Template(options)
+}
+
+func TestReleaseValuesFiles(t *testing.T) {
+	manifest := getValuesTestManifest(t)
+	helm := &valuesFileHelm{custommocks.GetHelmMock(getTestAvailableChartVersions("0.0.1")), map[string]string{}}
+	if errs := manifest.Release(custommocks.GetKubernetesMock(false), helm); len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestReleaseValuesFiles(): %s", errsToString(errs))
+		return
+	}
+	if len(helm.valuesFiles) != 1 {
+		t.Errorf("Didn't get expected values files from manifest.v1beta1.TestReleaseValuesFiles(), got: %v", helm.valuesFiles)
+		return
+	}
+	for valuesFile, values := range helm.valuesFiles {
+		if !strings.Contains(values, "tag: configmap") || !strings.Contains(values, "site: inline-site") {
+			t.Errorf("Didn't get expected values from manifest.v1beta1.TestReleaseValuesFiles(), got: %s", values)
+		}
+		// the values can be decrypted secrets, so they're removed once Helm is done with them
+		if _, err := os.Stat(valuesFile); !os.IsNotExist(err) {
+			t.Errorf("Didn't get expected removed values file %s from manifest.v1beta1.TestReleaseValuesFiles()", valuesFile)
+		}
+	}
+}
+
+func TestDiffValuesFiles(t *testing.T) {
+	manifest := getValuesTestManifest(t)
+	helm := &valuesFileHelm{custommocks.GetHelmMock(getTestAvailableChartVersions("0.0.1")), map[string]string{}}
+	if _, errs := manifest.Diff(custommocks.GetKubernetesMock(false), helm); len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestDiffValuesFiles(): %s", errsToString(errs))
+		return
+	}
+	for valuesFile := range helm.valuesFiles {
+		if _, err := os.Stat(valuesFile); !os.IsNotExist(err) {
+			t.Errorf("Didn't get expected removed values file %s from manifest.v1beta1.TestDiffValuesFiles()", valuesFile)
+		}
+	}
+	if len(helm.valuesFiles) != 1 {
+		t.Errorf("Didn't get expected values files from manifest.v1beta1.TestDiffValuesFiles(), got: %v", helm.valuesFiles)
+	}
+}
+
+func TestReleaseValuesFileMissing(t *testing.T) {
+	manifest := getValuesTestManifest(t)
+	manifest.Spec.Charts[0].ValuesFiles = append(manifest.Spec.Charts[0].ValuesFiles, "missing.yaml")
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), custommocks.GetHelmMock(getTestAvailableChartVersions("0.0.1")))
+	if len(errs) != 1 || !strings.Contains(errs[0].Error.Error(), "Error reading values file missing.yaml") {
+		t.Errorf("Didn't get expected error from manifest.v1beta1.TestReleaseValuesFileMissing(), got: %s", errsToString(errs))
+	}
+}
+
+const (
+	testEncryptedValues = `password: ENC[AES256_GCM,data:dGVzdA==,iv:dGVzdA==,tag:dGVzdA==,type:str]
+sops:
+  age:
+  - recipient: age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+  lastmodified: "2021-12-09T20:07:14Z"
+  mac: ENC[AES256_GCM,data:dGVzdA==,iv:dGVzdA==,tag:dGVzdA==,type:str]
+  version: 3.7.1
+`
+	testDecryptedValues = "password: hunter2\n"
+)
+
+func getEncryptedValuesTestManifest(t *testing.T) (*Manifest, *mocks.Sops) {
+	manifest := getValuesTestManifest(t)
+	ioutil.WriteFile(filepath.Join(manifest.directory, "secrets.yaml"), []byte(testEncryptedValues), 0644)
+	manifest.Spec.Charts[0].ValuesFiles = []string{"base.yaml", "secrets.yaml"}
+	manifest.Spec.Charts[0].ValuesFrom = nil
+	sops := &mocks.Sops{}
+	sops.On("Decrypt", []byte(testEncryptedValues)).Return([]byte(testDecryptedValues), nil)
+	manifest.SetSops(sops)
+	return manifest, sops
+}
+
+func TestResolveValuesEncrypted(t *testing.T) {
+	manifest, sops := getEncryptedValuesTestManifest(t)
+	chart := manifest.Spec.Charts[0]
+	chart.ValuesFiles = []string{"base.yaml"}
+	chart.EncryptedValues = testEncryptedValues
+	valuesBytes, err := manifest.resolveValues(chart, custommocks.GetKubernetesMock(false))
+	if err != nil {
+		t.Errorf("Got unexpected error from manifest.v1beta1.TestResolveValuesEncrypted(): %s", err)
+		return
+	}
+	values := map[string]interface{}{}
+	yaml.Unmarshal(valuesBytes, &values)
+	if values["password"] != "hunter2" || values["replicas"] != 1 || values["sops"] != nil || !chart.valuesSecret {
+		t.Errorf("Didn't get expected decrypted values from manifest.v1beta1.TestResolveValuesEncrypted(), got:\n%s", valuesBytes)
+	}
+	sops.AssertNumberOfCalls(t, "Decrypt", 1)
+}
+
+func TestResolveValuesEncryptedErrors(t *testing.T) {
+	manifest, _ := getEncryptedValuesTestManifest(t)
+	manifest.SetSops(nil)
+	if _, err := manifest.resolveValues(manifest.Spec.Charts[0], custommocks.GetKubernetesMock(false)); err == nil ||
+		!strings.Contains(err.Error(), "sops isn't set up") {
+		t.Errorf("Didn't get expected error without sops from manifest.v1beta1.TestResolveValuesEncryptedErrors(), got: %v", err)
+	}
+
+	manifest, _ = getEncryptedValuesTestManifest(t)
+	sops := &mocks.Sops{}
+	sops.On("Decrypt", mock.Anything).Return([]byte("- hunter2\n"), nil)
+	manifest.SetSops(sops)
+	_, err := manifest.resolveValues(manifest.Spec.Charts[0], custommocks.GetKubernetesMock(false))
+	if err == nil || !strings.Contains(err.Error(), "Error parsing the decrypted values from values file secrets.yaml") ||
+		strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("Didn't get expected error for invalid decrypted values from manifest.v1beta1.TestResolveValuesEncryptedErrors(), got: %v", err)
+	}
+}
+
+func TestResolveValuesEncryptedRedacted(t *testing.T) {
+	manifest, _ := getEncryptedValuesTestManifest(t)
+	logFile, _ := os.Create(filepath.Join(t.TempDir(), "loftsman.log"))
+	manifest.SetLogger(logger.New(logFile, "loftsman-tests-manifest-v1beta1"))
+	sops := &mocks.Sops{}
+	sops.On("Decrypt", mock.Anything).Return([]byte("database:\n  url: postgres://admin:opensesame@db:5432\n  hosts:\n  - db-primary\n"), nil)
+	manifest.SetSops(sops)
+	if _, err := manifest.resolveValues(manifest.Spec.Charts[0], custommocks.GetKubernetesMock(false)); err != nil {
+		t.Errorf("Got unexpected error from manifest.v1beta1.TestResolveValuesEncryptedRedacted(): %s", err)
+		return
+	}
+	// decrypted values are redacted even when their keys don't give them away
+	for _, secret := range []string{"postgres://admin:opensesame@db:5432", "db-primary"} {
+		if redacted := manifest.logger.Redact("connecting to " + secret); strings.Contains(redacted, secret) {
+			t.Errorf("Didn't get expected %s redacted from manifest.v1beta1.TestResolveValuesEncryptedRedacted(), got: %s", secret, redacted)
+		}
+	}
+}
+
+func TestReleaseEncryptedValuesNotLogged(t *testing.T) {
+	manifest, _ := getEncryptedValuesTestManifest(t)
+	logPath := filepath.Join(t.TempDir(), "loftsman.log")
+	logFile, _ := os.Create(logPath)
+	manifest.SetLogger(logger.New(logFile, "loftsman-tests-manifest-v1beta1"))
+	helm := &valuesFileHelm{custommocks.GetHelmMock(getTestAvailableChartVersions("0.0.1")), map[string]string{}}
+	if errs := manifest.Release(custommocks.GetKubernetesMock(false), helm); len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestReleaseEncryptedValuesNotLogged(): %s", errsToString(errs))
+		return
+	}
+	if len(helm.valuesFiles) != 1 {
+		t.Errorf("Didn't get expected values files from manifest.v1beta1.TestReleaseEncryptedValuesNotLogged(), got: %v", helm.valuesFiles)
+	}
+	for valuesFile, values := range helm.valuesFiles {
+		if !strings.Contains(values, "password: hunter2") {
+			t.Errorf("Didn't get expected decrypted values from manifest.v1beta1.TestReleaseEncryptedValuesNotLogged(), got: %s", values)
+		}
+		if _, err := os.Stat(valuesFile); !os.IsNotExist(err) {
+			t.Errorf("Didn't get expected removed decrypted values file %s from manifest.v1beta1.TestReleaseEncryptedValuesNotLogged()", valuesFile)
+		}
+	}
+	logBytes, _ := ioutil.ReadFile(logPath)
+	if strings.Contains(string(logBytes), "hunter2") || !strings.Contains(string(logBytes), "without logging them") {
+		t.Errorf("Didn't get expected log without the decrypted values from manifest.v1beta1.TestReleaseEncryptedValuesNotLogged(), got:\n%s", logBytes)
+	}
+}
+
+func TestReleaseSensitiveValues(t *testing.T) {
+	manifest := getTestManifest()
+	logPath := filepath.Join(t.TempDir(), "loftsman.log")
+	logFile, _ := os.Create(logPath)
+	manifest.SetLogger(logger.New(logFile, "loftsman-tests-manifest-v1beta1"))
+	manifest.Spec.Charts = []*Chart{
+		&Chart{
+			Name:      "test-chart",
+			Namespace: "default",
+			Version:   "0.0.1",
+			Values: map[interface{}]interface{}{
+				"replicas": 2,
+				"auth": map[interface{}]interface{}{
+					"adminPassword": "plain-password",
+					"users":         []interface{}{"first-user", "second-user"},
+				},
+			},
+			Sensitive: []string{"auth.users", "not.a.path"},
+		},
+	}
+	if errs := manifest.Release(custommocks.GetKubernetesMock(false),
+		custommocks.GetHelmMock(getTestAvailableChartVersions("0.0.1"))); len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestReleaseSensitiveValues(): %s", errsToString(errs))
+		return
+	}
+	logBytes, _ := ioutil.ReadFile(logPath)
+	for _, secret := range []string{"plain-password", "first-user", "second-user"} {
+		if strings.Contains(string(logBytes), secret) {
+			t.Errorf("Didn't get expected log with %s redacted from manifest.v1beta1.TestReleaseSensitiveValues(), got:\n%s", secret, logBytes)
+		}
+	}
+	if !strings.Contains(string(logBytes), "replicas: 2") {
+		t.Errorf("Didn't get expected log of the other values from manifest.v1beta1.TestReleaseSensitiveValues(), got:\n%s", logBytes)
+	}
+}
+
+func TestGetSensitiveValues(t *testing.T) {
+	value := map[interface{}]interface{}{
+		"password": "hunter2",
+		"port":     5432,
+		"enabled":  true,
+		"users":    []interface{}{"first-user", 1},
+	}
+	sensitiveValues := getSensitiveValues(value)
+	sort.Strings(sensitiveValues)
+	if !reflect.DeepEqual(sensitiveValues, []string{"first-user", "hunter2"}) {
+		t.Errorf("Didn't get expected sensitive values from manifest.v1beta1.TestGetSensitiveValues(), got: %v", sensitiveValues)
+	}
+}
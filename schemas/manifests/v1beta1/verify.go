@@ -0,0 +1,148 @@
+package v1beta1
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	"github.com/rs/zerolog"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// defaultVerifyTimeout is the timeout of a verify check when neither it, the chart's verify, nor the chart sets one
+const defaultVerifyTimeout = 5 * time.Minute
+
+// rolloutKinds are the kinds of the resources of a release that verify.rollout waits for
+var rolloutKinds = []string{"Deployment", "StatefulSet", "DaemonSet"}
+
+// getVerifyTimeout returns the timeout of a verify check, from the check itself, the chart's verify, the chart, or
+// otherwise the default
+func (c *Chart) getVerifyTimeout(checkTimeout string) (time.Duration, error) {
+	for _, timeout := range []string{checkTimeout, c.Verify.Timeout, c.Timeout} {
+		if timeout == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(timeout)
+		if err != nil {
+			return 0, fmt.Errorf("invalid verify timeout %s, it must be a go duration like 5m0s", timeout)
+		}
+		return parsed, nil
+	}
+	return defaultVerifyTimeout, nil
+}
+
+// validateVerify will validate the timeouts of each of the chart's verify checks
+func (c *Chart) validateVerify() error {
+	if c.Verify == nil {
+		return nil
+	}
+	checkTimeouts := []string{""}
+	for _, check := range []*ChartVerifyCheck{c.Verify.Rollout, c.Verify.HelmTest} {
+		if check != nil {
+			checkTimeouts = append(checkTimeouts, check.Timeout)
+		}
+	}
+	for _, job := range c.Verify.Jobs {
+		checkTimeouts = append(checkTimeouts, job.Timeout)
+	}
+	for _, condition := range c.Verify.Conditions {
+		checkTimeouts = append(checkTimeouts, condition.Timeout)
+	}
+	for _, checkTimeout := range checkTimeouts {
+		if _, err := c.getVerifyTimeout(checkTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getNamespace returns the namespace of a verify check, the chart's namespace unless the check sets one
+func (c *Chart) getNamespace(namespace string) string {
+	if namespace != "" {
+		return namespace
+	}
+	return c.Namespace
+}
+
+// verifyRelease will run the chart's verify checks of its release once it's been installed/upgraded, in the order of
+// waiting for its workloads to roll out, its jobs to complete, and its conditions to match, then running its tests
+func (m *Manifest) verifyRelease(chart *Chart, releaseName string, kubernetes interfaces.Kubernetes, helm interfaces.Helm) error {
+	if chart.Verify == nil {
+		return nil
+	}
+	if chart.Verify.Rollout != nil {
+		if err := m.verifyRollout(chart, releaseName, kubernetes, helm); err != nil {
+			return err
+		}
+	}
+	for _, job := range chart.Verify.Jobs {
+		timeout, err := chart.getVerifyTimeout(job.Timeout)
+		if err != nil {
+			return err
+		}
+		namespace := chart.getNamespace(job.Namespace)
+		m.logForChart(chart, zerolog.InfoLevel, fmt.Sprintf("Verifying release %s, waiting for Job %s/%s to complete", releaseName, namespace, job.Name))
+		if err = kubernetes.WaitForJob(job.Name, namespace, timeout); err != nil {
+			return err
+		}
+	}
+	for _, condition := range chart.Verify.Conditions {
+		timeout, err := chart.getVerifyTimeout(condition.Timeout)
+		if err != nil {
+			return err
+		}
+		namespace := chart.getNamespace(condition.Namespace)
+		status := condition.Status
+		if status == "" {
+			status = "True"
+		}
+		m.logForChart(chart, zerolog.InfoLevel, fmt.Sprintf("Verifying release %s, waiting for %s %s/%s to have condition %s=%s",
+			releaseName, condition.Kind, namespace, condition.Name, condition.Type, status))
+		if err = kubernetes.WaitForCondition(condition.APIVersion, condition.Kind, condition.Name, namespace, condition.Type, status, timeout); err != nil {
+			return err
+		}
+	}
+	if chart.Verify.HelmTest != nil {
+		timeout, err := chart.getVerifyTimeout(chart.Verify.HelmTest.Timeout)
+		if err != nil {
+			return err
+		}
+		m.logForChart(chart, zerolog.InfoLevel, fmt.Sprintf("Verifying release %s, running its tests", releaseName))
+		output, err := helm.Test(releaseName, chart.Namespace, timeout.String())
+		m.logForChart(chart, zerolog.InfoLevel, output)
+		if err != nil {
+			return fmt.Errorf("the tests of release %s failed: %s", releaseName, err)
+		}
+	}
+	return nil
+}
+
+// verifyRollout will wait for the Deployments, StatefulSets and DaemonSets in the live manifest of a release to roll
+// out, all within the rollout timeout since they roll out alongside each other
+func (m *Manifest) verifyRollout(chart *Chart, releaseName string, kubernetes interfaces.Kubernetes, helm interfaces.Helm) error {
+	timeout, err := chart.getVerifyTimeout(chart.Verify.Rollout.Timeout)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	live, err := helm.GetManifest(releaseName, chart.Namespace)
+	if err != nil {
+		return fmt.Errorf("Error getting the live manifest of release %s: %s", releaseName, err)
+	}
+	for _, document := range manifestDocumentSeparator.Split(live, -1) {
+		resource := &manifestResource{}
+		if err = yaml.Unmarshal([]byte(document), resource); err != nil {
+			return fmt.Errorf("Error parsing the live manifest of release %s: %s", releaseName, err)
+		}
+		if !contains(rolloutKinds, resource.Kind) {
+			continue
+		}
+		namespace := chart.getNamespace(resource.Metadata.Namespace)
+		m.logForChart(chart, zerolog.InfoLevel, fmt.Sprintf("Verifying release %s, waiting for %s %s/%s to roll out",
+			releaseName, resource.Kind, namespace, resource.Metadata.Name))
+		if err = kubernetes.WaitForRollout(resource.Kind, resource.Metadata.Name, namespace, time.Until(deadline)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
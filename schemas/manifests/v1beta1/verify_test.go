@@ -0,0 +1,120 @@
+package v1beta1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	custommocks "github.com/Cray-HPE/loftsman/mocks/custom-mocks"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReleaseVerify(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/chart-0.0.1.tgz",
+		},
+	}
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "healthy", Namespace: "default", Version: "0.0.1", Timeout: "10m", Verify: &ChartVerify{
+			Timeout: "2m",
+			Rollout: &ChartVerifyCheck{},
+			Jobs: []*ChartVerifyJob{
+				&ChartVerifyJob{Name: "db-migrate", Timeout: "30s"},
+			},
+			Conditions: []*ChartVerifyCondition{
+				&ChartVerifyCondition{APIVersion: "cert-manager.io/v1", Kind: "Certificate", Name: "tls", Namespace: "certs", Type: "Ready"},
+			},
+			HelmTest: &ChartVerifyCheck{Timeout: "1m"},
+		}},
+	}
+	kubernetes := custommocks.GetKubernetesMock(false)
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(kubernetes, helm)
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestReleaseVerify(): %s", errsToString(errs))
+	}
+	kubernetes.AssertCalled(t, "WaitForRollout", "Deployment", "healthy", "default", mock.AnythingOfType("time.Duration"))
+	kubernetes.AssertCalled(t, "WaitForRollout", "StatefulSet", "healthy-db", "databases", mock.AnythingOfType("time.Duration"))
+	kubernetes.AssertNotCalled(t, "WaitForRollout", "ConfigMap", mock.Anything, mock.Anything, mock.Anything)
+	kubernetes.AssertCalled(t, "WaitForJob", "db-migrate", "default", 30*time.Second)
+	kubernetes.AssertCalled(t, "WaitForCondition", "cert-manager.io/v1", "Certificate", "tls", "certs", "Ready", "True", 2*time.Minute)
+	helm.AssertCalled(t, "Test", "healthy", "default", "1m0s")
+}
+
+func TestReleaseVerifyFailed(t *testing.T) {
+	availableChartVersions := []*interfaces.HelmAvailableChartVersion{
+		&interfaces.HelmAvailableChartVersion{
+			Version: "0.0.1",
+			Path:    "/tmp/chart-0.0.1.tgz",
+		},
+	}
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "unhealthy-deployed", Namespace: "default", Version: "0.0.1", OnFailure: ChartOnFailureRollback,
+			Verify: &ChartVerify{Rollout: &ChartVerifyCheck{}}},
+		&Chart{Name: "dependent", Namespace: "default", Version: "0.0.1", DependsOn: []string{"unhealthy-deployed"}},
+		&Chart{Name: "tested", Namespace: "default", Version: "0.0.1", ReleaseName: "unhealthy-tested",
+			Verify: &ChartVerify{HelmTest: &ChartVerifyCheck{}}},
+	}
+	chartResults := make(map[string]*interfaces.ManifestChartResult)
+	manifest.SetReleaseOptions(&interfaces.ManifestReleaseOptions{
+		MaxConcurrency: 1,
+		OnChartResult: func(chartResult *interfaces.ManifestChartResult) {
+			chartResults[chartResult.Chart] = chartResult
+		},
+	})
+	helm := custommocks.GetHelmMock(availableChartVersions)
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 4 {
+		t.Errorf("Didn't get expected errors from manifest.v1beta1.TestReleaseVerifyFailed(), got: %s", errsToString(errs))
+	}
+	for _, expected := range []string{
+		"Error verifying release unhealthy-deployed of chart unhealthy-deployed v0.0.1: timed out after",
+		"Rolled back release unhealthy-deployed to revision 2",
+		"Not releasing chart dependent v0.0.1, it depends on chart unhealthy-deployed",
+		"Error verifying release unhealthy-tested of chart tested v0.0.1: the tests of release unhealthy-tested failed",
+	} {
+		if !strings.Contains(errsToString(errs), expected) {
+			t.Errorf("Didn't get expected error %q from manifest.v1beta1.TestReleaseVerifyFailed(), got: %s", expected, errsToString(errs))
+		}
+	}
+	helm.AssertCalled(t, "Rollback", "unhealthy-deployed", "default", 2)
+	if chartResults["unhealthy-deployed"].Status != interfaces.ManifestChartStatusRolledBack ||
+		chartResults["tested"].Status != interfaces.ManifestChartStatusFailed {
+		t.Errorf("Didn't get expected chart results from manifest.v1beta1.TestReleaseVerifyFailed(), got: %v", chartResults)
+	}
+}
+
+func TestGetVerifyTimeout(t *testing.T) {
+	for chart, expected := range map[*Chart]time.Duration{
+		&Chart{Verify: &ChartVerify{}}:                              defaultVerifyTimeout,
+		&Chart{Timeout: "10m", Verify: &ChartVerify{}}:              10 * time.Minute,
+		&Chart{Timeout: "10m", Verify: &ChartVerify{Timeout: "2m"}}: 2 * time.Minute,
+	} {
+		timeout, err := chart.getVerifyTimeout("")
+		if err != nil || timeout != expected {
+			t.Errorf("Didn't get expected timeout from manifest.v1beta1.TestGetVerifyTimeout(), expected %s, got: %s, %v", expected, timeout, err)
+		}
+	}
+	timeout, err := (&Chart{Timeout: "10m", Verify: &ChartVerify{Timeout: "2m"}}).getVerifyTimeout("30s")
+	if err != nil || timeout != 30*time.Second {
+		t.Errorf("Didn't get expected check timeout from manifest.v1beta1.TestGetVerifyTimeout(), got: %s, %v", timeout, err)
+	}
+}
+
+func TestValidateSpecVerifyTimeout(t *testing.T) {
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{Name: "chart", Namespace: "default", Version: "0.0.1", Verify: &ChartVerify{
+			Jobs: []*ChartVerifyJob{&ChartVerifyJob{Name: "db-migrate", Timeout: "ten minutes"}},
+		}},
+	}
+	err := manifest.ValidateSpec()
+	if err == nil || !strings.Contains(err.Error(), "invalid spec.charts[] name = chart: invalid verify timeout ten minutes") {
+		t.Errorf("Didn't get expected error from manifest.v1beta1.TestValidateSpecVerifyTimeout(), got: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package v1beta1
+
+import (
+	"fmt"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	"github.com/Masterminds/semver/v3"
+)
+
+// ChartVersionLatest is the chart version that resolves to the highest available version that isn't a pre-release
+const ChartVersionLatest = "latest"
+
+// resolveChartVersion will find the available version a chart's version resolves to. An available version that's an
+// exact match is always used, otherwise the chart's version is a semver constraint like ~1.4 or >=2.0.0 <3, or latest,
+// resolved to the highest available version satisfying it
+func resolveChartVersion(chart *Chart, availableVersions []*interfaces.HelmAvailableChartVersion) (*interfaces.HelmAvailableChartVersion, error) {
+	for _, availableVersion := range availableVersions {
+		if availableVersion.Version == chart.Version {
+			return availableVersion, nil
+		}
+	}
+	if _, err := semver.StrictNewVersion(chart.Version); err == nil {
+		return nil, fmt.Errorf("Unable to find chart %s v%s in the configured charts location", chart.Name, chart.Version)
+	}
+	var constraint *semver.Constraints
+	if chart.Version != ChartVersionLatest {
+		var err error
+		if constraint, err = semver.NewConstraint(chart.Version); err != nil {
+			return nil, fmt.Errorf("Unable to find chart %s v%s in the configured charts location, and it isn't a valid version constraint: %s",
+				chart.Name, chart.Version, err)
+		}
+	}
+	var resolved *interfaces.HelmAvailableChartVersion
+	var resolvedVersion *semver.Version
+	for _, availableVersion := range availableVersions {
+		version, err := semver.NewVersion(availableVersion.Version)
+		if err != nil {
+			// versions that aren't semver can only be used as an exact match
+			continue
+		}
+		if (constraint == nil && version.Prerelease() != "") || (constraint != nil && !constraint.Check(version)) {
+			continue
+		}
+		if resolvedVersion == nil || version.GreaterThan(resolvedVersion) {
+			resolved = availableVersion
+			resolvedVersion = version
+		}
+	}
+	if resolved == nil {
+		return nil, fmt.Errorf("Unable to find a version of chart %s satisfying %s in the configured charts location", chart.Name, chart.Version)
+	}
+	return resolved, nil
+}
+
+// getVersionConstraint returns the chart's version as written in the manifest, before it was resolved to an exact version
+func (c *Chart) getVersionConstraint() string {
+	if c.versionConstraint != "" {
+		return c.versionConstraint
+	}
+	return c.Version
+}
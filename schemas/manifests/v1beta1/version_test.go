@@ -0,0 +1,85 @@
+package v1beta1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Cray-HPE/loftsman/internal/interfaces"
+	custommocks "github.com/Cray-HPE/loftsman/mocks/custom-mocks"
+	"github.com/stretchr/testify/mock"
+)
+
+func getTestAvailableChartVersions(versions ...string) []*interfaces.HelmAvailableChartVersion {
+	available := []*interfaces.HelmAvailableChartVersion{}
+	for _, version := range versions {
+		available = append(available, &interfaces.HelmAvailableChartVersion{
+			Version: version,
+			Path:    "/tmp/test-chart-" + version + ".tgz",
+		})
+	}
+	return available
+}
+
+func TestResolveChartVersion(t *testing.T) {
+	available := getTestAvailableChartVersions("1.3.9", "1.4.0", "1.4.7", "1.5.0", "2.0.0", "2.3.1", "3.0.0-rc.1", "dev")
+	for constraint, expected := range map[string]string{
+		"1.4.0":            "1.4.0",
+		"dev":              "dev",
+		"~1.4":             "1.4.7",
+		"^1.4":             "1.5.0",
+		">=2.0.0 <3":       "2.3.1",
+		ChartVersionLatest: "2.3.1",
+		">=3.0.0-rc.0":     "3.0.0-rc.1",
+	} {
+		resolved, err := resolveChartVersion(&Chart{Name: "test-chart", Version: constraint}, available)
+		if err != nil {
+			t.Errorf("Got unexpected error from manifest.v1beta1.TestResolveChartVersion() for %s: %s", constraint, err)
+			continue
+		}
+		if resolved.Version != expected {
+			t.Errorf("Didn't get expected version from manifest.v1beta1.TestResolveChartVersion() for %s, expected %s, got: %s",
+				constraint, expected, resolved.Version)
+		}
+	}
+}
+
+func TestResolveChartVersionNotFound(t *testing.T) {
+	available := getTestAvailableChartVersions("1.4.0", "2.0.0-rc.1")
+	for constraint, expected := range map[string]string{
+		"1.4.1":            "Unable to find chart test-chart v1.4.1",
+		"~1.5":             "Unable to find a version of chart test-chart satisfying ~1.5",
+		"not-a-constraint": "isn't a valid version constraint",
+	} {
+		_, err := resolveChartVersion(&Chart{Name: "test-chart", Version: constraint}, available)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Didn't get expected error from manifest.v1beta1.TestResolveChartVersionNotFound() for %s, got: %v", constraint, err)
+		}
+	}
+	if _, err := resolveChartVersion(&Chart{Name: "test-chart", Version: ChartVersionLatest},
+		getTestAvailableChartVersions("2.0.0-rc.1")); err == nil {
+		t.Errorf("Didn't get expected error from manifest.v1beta1.TestResolveChartVersionNotFound() for latest with only pre-releases")
+	}
+}
+
+func TestReleaseChartVersionConstraint(t *testing.T) {
+	manifest := getTestManifest()
+	manifest.Spec.Charts = []*Chart{
+		&Chart{
+			Name:      "test-chart",
+			Namespace: "default",
+			Version:   "~1.4",
+		},
+	}
+	helm := custommocks.GetHelmMock(getTestAvailableChartVersions("1.4.0", "1.4.7", "1.5.0"))
+	errs := manifest.Release(custommocks.GetKubernetesMock(false), helm)
+	if len(errs) != 0 {
+		t.Errorf("Got unexpected errors from manifest.v1beta1.TestReleaseChartVersionConstraint(): %s", errsToString(errs))
+	}
+	if manifest.Spec.Charts[0].Version != "1.4.7" || manifest.Spec.Charts[0].getVersionConstraint() != "~1.4" {
+		t.Errorf("Didn't get expected resolved version from manifest.v1beta1.TestReleaseChartVersionConstraint(), got: %s (%s)",
+			manifest.Spec.Charts[0].Version, manifest.Spec.Charts[0].getVersionConstraint())
+	}
+	helm.AssertCalled(t, "Upgrade", mock.MatchedBy(func(options *interfaces.HelmReleaseOptions) bool {
+		return options.ChartPath == "/tmp/test-chart-1.4.7.tgz"
+	}))
+}